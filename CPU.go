@@ -2,7 +2,6 @@ package CPU_6502
 
 import (
 	"fmt"
-	"os"
 	"time"
 )
 
@@ -23,6 +22,7 @@ func Initialize() {
 
 	// Initialize CPU
 	CPU_Enabled = true
+	Halted = false
 
 	// Internal Opcode Cycle count
 	Opc_cycle_count = 1
@@ -57,6 +57,12 @@ func Reset() {
 
 	// Reset the SP
 	SP = 0xFF
+
+	Halted = false
+
+	// Drop an interrupt in progress (the IRQ lines belong to the devices)
+	interrupt_cycle = 0
+	nmi_pending = false
 }
 
 func ShowDebugHeader() {
@@ -67,9 +73,47 @@ func ShowDebugHeader() {
 // CPU Interpreter
 func CPU_Interpreter() {
 
+	if Halted {
+		return
+	}
+
+	// Interrupt sequence, between two instructions
+	if interrupt_cycle > 0 || (Opc_cycle_count == 1 && interruptPending()) {
+		if hooks_active && interrupt_cycle == 0 {
+			hooks_InterruptStart(PC)
+		}
+		cpu_Interrupt()
+		Cycle++
+		CPS++
+		if len(clocked) > 0 {
+			clockDevices()
+		}
+		return
+	}
+
 	// Read the Next Instruction to be executed
 	opcode = Memory[PC]
 
+	// Debugger and tracer hooks, before the first cycle of the instruction
+	if hooks_active && Opc_cycle_count == 1 {
+		if memory_mapped && MemoryViolations == VIOLATION_EVENT {
+			checkExecute()
+		}
+		if hooks_Instruction(PC, opcode) {
+			return
+		}
+	}
+
+	// Host traps, replacing the instruction
+	if traps_active && Opc_cycle_count == 1 && cpu_Trap() {
+		Cycle++
+		CPS++
+		if len(clocked) > 0 {
+			clockDevices()
+		}
+		return
+	}
+
 	// Show Debug Header
 	if Debug {
 		if Opc_cycle_count == 1 { // Just in the first opcode cycle
@@ -1129,12 +1173,20 @@ func CPU_Interpreter() {
 	// ------------------------------------------- OPCODE NOT IMPLEMENTED ------------------------------------------ //
 
 	default:
-		fmt.Printf("\n\tOpcode %02X not implemented! Exiting!\n\n", opcode)
-		os.Exit(2)
+		Halted = true
+		if Debug {
+			fmt.Printf("\n\tOpcode %02X not implemented! CPU halted.\n\n", opcode)
+		}
+		return
 	}
 
 	// Increment Cycle
 	Cycle++
 	CPS++
 
+	// Devices run in lock-step with the CPU
+	if len(clocked) > 0 {
+		clockDevices()
+	}
+
 }
@@ -1,9 +1,6 @@
 package CPU_6502
 
-import (
-	"fmt"
-	"strconv"
-)
+import "fmt"
 
 // ADC  Add Memory to Accumulator with Carry (zeropage)
 //
@@ -56,21 +53,13 @@ func opc_ADC(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 
 		} else {
 
-			var bcd_Mem int64
+			// Store the decimal result of A, from the decimal values of the original A and Memory (see CPU_Decimal.go)
+			tmp_A := bcd_to_bin[A] + bcd_to_bin[memData] + P[0]
 
-			// Store the decimal value of the original A (hex)
-			bcd_A, _ := strconv.ParseInt(fmt.Sprintf("%X", A), 0, 32)
+			// Convert the Decimal Result in to BCD to be returned to Accumulator
+			bcd_Result := int64(bin_to_bcd[tmp_A])
 
-			// Store the decimal value of the original Memory Address (hex)
-			bcd_Mem, _ = strconv.ParseInt(fmt.Sprintf("%X", memData), 0, 32)
-
-			// Store the decimal result of A (must be trasformed in hex to be stored)
-			tmp_A := byte(bcd_A) + byte(bcd_Mem) + P[0]
-
-			// Convert the Decimal Result in to Hex to be returned to Accumulator
-			bcd_Result, _ := strconv.ParseInt(fmt.Sprintf("%d", tmp_A), 16, 32)
-
-			// Tranform the uint64 into a byte (if > 255 will be rotated)
+			// Tranform the result into a byte (the hundreds are the carry)
 			A = byte(bcd_Result)
 
 			flags_V(original_A, memData, original_P0)
@@ -95,10 +84,10 @@ func opc_ADC_DebugMsg(bytes uint16, mode string, original_A byte, memAddr uint16
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
 		if P[3] == 0 { // Decimal flag OFF (Binary or Hex Mode)
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tADC  Add Memory to Accumulator with Carry [Binary/Hex Mode]\tA = A(%d) + Memory[0x%02X](%d) + Carry (%d)) = %d\n", opc_string, mode, original_A, memAddr, memData, original_P0, A)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tADC  Add Memory to Accumulator with Carry [Binary/Hex Mode]\tA = A(%d) + Memory[%s](%d) + Carry (%d)) = %d\n", opc_string, mode, original_A, debug_addr(memAddr), memData, original_P0, A)
 
 		} else { // Decimal flag ON (Decimal Mode)
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tADC  Add Memory to Accumulator with Carry [Decimal Mode]\tA = A(0x%02x) + Memory[0x%02X](0x%02x) + Carry (0x%02x)) = 0x%02X\n", opc_string, mode, original_A, memAddr, memData, original_P0, A)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tADC  Add Memory to Accumulator with Carry [Decimal Mode]\tA = A(0x%02x) + Memory[%s](0x%02x) + Carry (0x%02x)) = 0x%02X\n", opc_string, mode, original_A, debug_addr(memAddr), memData, original_P0, A)
 		}
 		fmt.Println(dbg_show_message)
 	}
@@ -55,7 +55,7 @@ func opc_AND(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_AND_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tAND  AND Memory with Accumulator.\tA = A(%d) & Memory[0x%02X](%d)\t(%d)\n", opc_string, mode, A, memAddr, memData, A&memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tAND  AND Memory with Accumulator.\tA = A(%d) & Memory[%s](%d)\t(%d)\n", opc_string, mode, A, debug_addr(memAddr), memData, A&memData)
 		fmt.Println(dbg_show_message)
 	}
 }
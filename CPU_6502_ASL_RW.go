@@ -103,7 +103,7 @@ func opc_ASL(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_ASL_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tASL  Shift Left One Bit.\tMemory[0x%02X]: (%d) Shift Left 1 bit\t(%d).\tCarry (Original Memory address bit 7): %d\n", opc_string, mode, memAddr, memData>>1, memData, P[0])
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tASL  Shift Left One Bit.\tMemory[%s]: (%d) Shift Left 1 bit\t(%d).\tCarry (Original Memory address bit 7): %d\n", opc_string, mode, debug_addr(memAddr), memData>>1, memData, P[0])
 		fmt.Println(dbg_show_message)
 	}
 }
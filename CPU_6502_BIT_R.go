@@ -53,7 +53,7 @@ func opc_BIT(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_BIT_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tBIT  Test Bits in Memory with Accumulator.\tA (%08b) AND Memory[0x%04X] (%08b) = %08b \tM7 -> N, M6 -> V\n", opc_string, mode, A, memAddr, memData, A&memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tBIT  Test Bits in Memory with Accumulator.\tA (%08b) AND Memory[%s] (%08b) = %08b \tM7 -> N, M6 -> V\n", opc_string, mode, A, debug_addr(memAddr), memData, A&memData)
 		fmt.Println(dbg_show_message)
 	}
 }
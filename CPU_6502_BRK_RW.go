@@ -35,6 +35,9 @@ func opc_BRK(bytes uint16, opc_cycles byte) {
 
 		// ---------- Store PC ---------- //
 
+		// Address pushed to the stack (and used by RTI)
+		return_addr := PC + 2
+
 		// 6502 handle Stack at the end of first memory page
 		SP_Address := uint16(SP) + 256
 
@@ -86,6 +89,11 @@ func opc_BRK(bytes uint16, opc_cycles byte) {
 
 		// Print Opcode Debug Message
 		opc_BRK_DebugMsg(bytes, SP_Address)
+
+		// Inform debuggers about the interrupt entry
+		if hooks_active {
+			hooks_Interrupt(INT_BRK, PC, return_addr)
+		}
 	}
 }
 
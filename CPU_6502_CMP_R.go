@@ -58,9 +58,9 @@ func opc_CMP_DebugMsg(bytes uint16, tmp byte, mode string, memAddr uint16, memDa
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
 		if tmp == 0 {
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tCMP  Compare Memory with Accumulator.\tA(%d) - Memory[0x%02X](%d) = (%d) EQUAL\n", opc_string, mode, A, memAddr, memData, tmp)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tCMP  Compare Memory with Accumulator.\tA(%d) - Memory[%s](%d) = (%d) EQUAL\n", opc_string, mode, A, debug_addr(memAddr), memData, tmp)
 		} else {
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tCMP  Compare Memory with Accumulator.\tA(%d) - Memory[0x%02X](%d) = (%d) NOT EQUAL\n", opc_string, mode, A, memAddr, memData, tmp)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tCMP  Compare Memory with Accumulator.\tA(%d) - Memory[%s](%d) = (%d) NOT EQUAL\n", opc_string, mode, A, debug_addr(memAddr), memData, tmp)
 		}
 		fmt.Println(dbg_show_message)
 	}
@@ -53,7 +53,7 @@ func opc_DEC(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_DEC_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tDEC  Decrement Memory by One.\tMemory[0x%02X](%d) - 1:\t%d\n", opc_string, mode, memAddr, memData, memData-1)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tDEC  Decrement Memory by One.\tMemory[%s](%d) - 1:\t%d\n", opc_string, mode, debug_addr(memAddr), memData, memData-1)
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -54,7 +54,7 @@ func opc_JSR(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_JSR_DebugMsg(bytes uint16, mode string, memAddr uint16, SP_Address uint16) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tJSR  Jump to New Location Saving Return Address.\tPC = Memory[0x%02X]\t|\t Stack[0x%02X] = %02X\t Stack[0x%02X] = 0x%02X\n", opc_string, mode, memAddr, SP_Address+2, Memory[SP_Address+2], SP_Address+1, Memory[SP_Address+1])
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tJSR  Jump to New Location Saving Return Address.\tPC = Memory[%s]\t|\t Stack[0x%02X] = %02X\t Stack[0x%02X] = 0x%02X\n", opc_string, mode, debug_addr(memAddr), SP_Address+2, Memory[SP_Address+2], SP_Address+1, Memory[SP_Address+1])
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -55,7 +55,7 @@ func opc_LDA(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_LDA_DebugMsg(bytes uint16, mode string, memAddr uint16) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDA  Load Accumulator with Memory.\tA = Memory[0x%02X] (%d)\n", opc_string, mode, memAddr, A)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDA  Load Accumulator with Memory.\tA = Memory[%s] (%d)\n", opc_string, mode, debug_addr(memAddr), A)
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -52,7 +52,7 @@ func opc_LDX(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_LDX_DebugMsg(bytes uint16, mode string, memAddr uint16) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDX  Load Index X with Memory.\tX = Memory[0x%02X] (%d)\n", opc_string, mode, memAddr, X)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDX  Load Index X with Memory.\tX = Memory[%s] (%d)\n", opc_string, mode, debug_addr(memAddr), X)
 		fmt.Println(dbg_show_message)
 	}
 }
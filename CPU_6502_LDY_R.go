@@ -52,7 +52,7 @@ func opc_LDY(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_LDY_DebugMsg(bytes uint16, mode string, memAddr uint16) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDY  Index Y with Memory.\tY = Memory[0x%02X] (%d)\n", opc_string, mode, memAddr, Y)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLDY  Index Y with Memory.\tY = Memory[%s] (%d)\n", opc_string, mode, debug_addr(memAddr), Y)
 		fmt.Println(dbg_show_message)
 	}
 }
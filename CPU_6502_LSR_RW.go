@@ -45,8 +45,8 @@ func opc_LSR_A(bytes uint16, opc_cycles byte) {
 		// Increment PC
 		PC += bytes
 
-		// Reset Opcode Cycle counter
-		Opc_cycle_count = 1
+		// Reset Internal Opcode Cycle counters
+		resetIntOpcCycleCounters()
 	}
 
 }
@@ -101,7 +101,7 @@ func opc_LSR(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_LSR_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLSR  Shift One Bit Right.\tMemory[0x%02X]: (%d) Shift Right 1 bit\t(%d)\n", opc_string, mode, memAddr, memData, memData>>1)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tLSR  Shift One Bit Right.\tMemory[%s]: (%d) Shift Right 1 bit\t(%d)\n", opc_string, mode, debug_addr(memAddr), memData, memData>>1)
 		fmt.Println(dbg_show_message)
 	}
 }
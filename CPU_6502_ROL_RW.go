@@ -52,8 +52,8 @@ func opc_ROL_A(bytes uint16, opc_cycles byte) {
 		// Increment PC
 		PC += bytes
 
-		// Reset Opcode Cycle counter
-		Opc_cycle_count = 1
+		// Reset Internal Opcode Cycle counters
+		resetIntOpcCycleCounters()
 	}
 }
 
@@ -110,7 +110,7 @@ func opc_ROL(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_ROL_DebugMsg(bytes uint16, mode string, memAddr uint16, carry_orig byte, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tROL  Rotate One Bit Left.\tMemory[0x%02X](%d) Roll Left 1 bit + Carry(%d)\t(%d)\n", opc_string, mode, memAddr, memData, carry_orig, (memData<<1)+carry_orig)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tROL  Rotate One Bit Left.\tMemory[%s](%d) Roll Left 1 bit + Carry(%d)\t(%d)\n", opc_string, mode, debug_addr(memAddr), memData, carry_orig, (memData<<1)+carry_orig)
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -58,8 +58,8 @@ func opc_ROR_A(bytes uint16, opc_cycles byte) {
 		// Increment PC
 		PC += bytes
 
-		// Reset Opcode Cycle counter
-		Opc_cycle_count = 1
+		// Reset Internal Opcode Cycle counters
+		resetIntOpcCycleCounters()
 	}
 
 }
@@ -123,7 +123,7 @@ func opc_ROR(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_ROR_DebugMsg(bytes uint16, mode string, memAddr uint16, original_MemValue byte, original_carry byte, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tROR  Rotate One Bit Right.\tMemory[0x%02d](%d) Roll Right 1 bit\t(%d) + Current Carry(%d) as new bit 7.\tA = %d\n", opc_string, mode, memAddr, original_MemValue, original_MemValue>>1, original_carry, memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tROR  Rotate One Bit Right.\tMemory[%s](%d) Roll Right 1 bit\t(%d) + Current Carry(%d) as new bit 7.\tA = %d\n", opc_string, mode, debug_addr(memAddr), original_MemValue, original_MemValue>>1, original_carry, memData)
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -1,9 +1,6 @@
 package CPU_6502
 
-import (
-	"fmt"
-	"strconv"
-)
+import "fmt"
 
 // SBC  Subtract Memory from Accumulator with Borrow (zeropage)
 //
@@ -61,22 +58,12 @@ func opc_SBC(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 
 		} else {
 
-			var (
-				bcd_Mem        int64
-				tmp_A          int
-				tmp_A_unsigned int
-			)
-
-			// Store the decimal value of the original A (hex)
-			bcd_A, _ := strconv.ParseInt(fmt.Sprintf("%X", A), 0, 32)
-
-			// Store the decimal value of the original Memory Address (hex)
-			bcd_Mem, _ = strconv.ParseInt(fmt.Sprintf("%X", memData), 0, 32)
+			var tmp_A int
 
 			borrow := original_P0 ^ 1
 
-			// Store the decimal result of A (must be trasformed in hex to be stored)
-			tmp_A_unsigned = int(bcd_A) - int(bcd_Mem) - int(borrow)
+			// Store the decimal result of A, from the decimal values of the original A and Memory (see CPU_Decimal.go)
+			tmp_A_unsigned := int(bcd_to_bin[A]) - int(bcd_to_bin[memData]) - int(borrow)
 			// BCD wrap-around between 0 and 99
 			if tmp_A_unsigned < 0 {
 				tmp_A = tmp_A_unsigned + 100
@@ -84,11 +71,8 @@ func opc_SBC(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 				tmp_A = tmp_A_unsigned
 			}
 
-			// Convert the Decimal Result in to Hex to be returned to Accumulator
-			bcd_Result, _ := strconv.ParseInt(fmt.Sprintf("%d", tmp_A), 16, 32)
-
-			// Tranform the uint64 into a byte
-			A = byte(bcd_Result)
+			// Convert the Decimal Result in to BCD to be returned to Accumulator
+			A = byte(bin_to_bcd[tmp_A])
 
 			// ------------------------------ Flags ------------------------------ //
 
@@ -114,9 +98,9 @@ func opc_SBC_DebugMsg(bytes uint16, mode string, original_A byte, memAddr uint16
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
 		if P[3] == 0 { // Decimal flag OFF (Binary or Hex Mode)
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSBC  Subtract Memory from Accumulator with Borrow.\tA = A(%d) - Memory[0x%02X](%d) - Borrow(Inverted Carry)(%d) = %d\n", opc_string, mode, original_A, memAddr, memData, original_P0^1, A)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSBC  Subtract Memory from Accumulator with Borrow.\tA = A(%d) - Memory[%s](%d) - Borrow(Inverted Carry)(%d) = %d\n", opc_string, mode, original_A, debug_addr(memAddr), memData, original_P0^1, A)
 		} else { // Decimal flag ON (Decimal Mode)
-			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSBC  Subtract Memory from Accumulator with Borrow. [Decimal Mode]\tA = A(0x%02X) - Memory[0x%02X](0x%02X) - Borrow(Inverted Carry)(0x%X) = 0x%02X\n", opc_string, mode, original_A, memAddr, memData, original_P0^1, A)
+			dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSBC  Subtract Memory from Accumulator with Borrow. [Decimal Mode]\tA = A(0x%02X) - Memory[%s](0x%02X) - Borrow(Inverted Carry)(0x%X) = 0x%02X\n", opc_string, mode, original_A, debug_addr(memAddr), memData, original_P0^1, A)
 		}
 		fmt.Println(dbg_show_message)
 	}
@@ -49,7 +49,7 @@ func opc_STA(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_STA_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTA  Store Accumulator in Memory.\tMemory[0x%02X] = A (0x%02X)\n", opc_string, mode, memAddr, memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTA  Store Accumulator in Memory.\tMemory[%s] = A (0x%02X)\n", opc_string, mode, debug_addr(memAddr), memData)
 		fmt.Println(dbg_show_message)
 	}
 }
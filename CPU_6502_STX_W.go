@@ -45,7 +45,7 @@ func opc_STX(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_STX_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTX  Store Index X in Memory.\tMemory[0x%02X] = X (%d)\n", opc_string, mode, memAddr, memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTX  Store Index X in Memory.\tMemory[%s] = X (%d)\n", opc_string, mode, debug_addr(memAddr), memData)
 		fmt.Println(dbg_show_message)
 	}
 }
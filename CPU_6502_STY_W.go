@@ -45,7 +45,7 @@ func opc_STY(memAddr uint16, mode string, bytes uint16, opc_cycles byte) {
 func opc_STY_DebugMsg(bytes uint16, mode string, memAddr uint16, memData byte) {
 	if Debug {
 		opc_string := debug_decode_opc(bytes)
-		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTY  Store Index Y in Memory.\tMemory[0x%02X] = Y (%d)\n", opc_string, mode, memAddr, memData)
+		dbg_show_message = fmt.Sprintf("\n\tOpcode %s [Mode: %s]\tSTY  Store Index Y in Memory.\tMemory[%s] = Y (%d)\n", opc_string, mode, debug_addr(memAddr), memData)
 		fmt.Println(dbg_show_message)
 	}
 }
@@ -13,8 +13,11 @@ func addr_mode_Relative(offset uint16) uint16 {
 	memAddr := offset
 	mode := "Relative"
 
+	// Used by the branches to check the page of the destination
+	memValue = value
+
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\tCurrent Value: %d (Decimal SIGNED value)\n", mode, memAddr, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\tCurrent Value: %d (Decimal SIGNED value)\n", mode, debug_addr(memAddr), value)
 	}
 
 	return memAddr
@@ -28,7 +31,7 @@ func addr_mode_Zeropage(offset uint16) (uint16, string) {
 	mode := "Zeropage"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(uint16(memAddr)), value, value)
 	}
 
 	return uint16(memAddr), mode
@@ -42,7 +45,7 @@ func addr_mode_ZeropageX(offset uint16) (uint16, string) {
 	mode := "Zeropage,X"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(uint16(memAddr)), value, value)
 	}
 
 	return uint16(memAddr), mode
@@ -56,7 +59,7 @@ func addr_mode_ZeropageY(offset uint16) (uint16, string) {
 	mode := "Zeropage,Y"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(uint16(memAddr)), value, value)
 	}
 
 	return uint16(memAddr), mode
@@ -70,7 +73,7 @@ func addr_mode_Immediate(offset uint16) (uint16, string) {
 	mode := "Immediate"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -85,7 +88,7 @@ func addr_mode_Absolute(offset uint16) (uint16, string) {
 	mode := "Absolute"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%02X]\t\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\t\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -99,7 +102,7 @@ func addr_mode_AbsoluteY(offset uint16) (uint16, string) {
 	mode := "Absolute,Y"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\t\tADDRESS BUS: Memory[0x%02X]\t\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\t\tADDRESS BUS: Memory[%s]\t\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -113,7 +116,7 @@ func addr_mode_AbsoluteX(offset uint16) (uint16, string) {
 	mode := "Absolute,X"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\t\tADDRESS BUS: Memory[0x%02X]\t\tCurrent Value: 0x%02X (%d)\n", mode, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\t\tADDRESS BUS: Memory[%s]\t\tCurrent Value: 0x%02X (%d)\n", mode, debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -147,7 +150,7 @@ func addr_mode_Indirect(offset uint16) (uint16, string) {
 	mode := "Indirect"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[0x%04X]\t(Address inside 0x%04X points to 0x%04X)\n", mode, memAddr, (uint16(Memory[offset+1])<<8 | uint16(Memory[offset])), memAddr)
+		fmt.Printf("\t%s addressing mode.\tADDRESS BUS: Memory[%s]\t(Address inside 0x%04X points to 0x%04X)\n", mode, debug_addr(memAddr), (uint16(Memory[offset+1])<<8 | uint16(Memory[offset])), memAddr)
 	}
 
 	return memAddr, mode
@@ -181,7 +184,7 @@ func addr_mode_IndirectY(offset uint16) (uint16, string) {
 	mode := "(Indirect),Y"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode.\tIndirect Addr: 0x%02X\tLSB: (Memory[0x%02X]:0x%02X + Y:(0x%02X)) = 0x%04X & 00FF = 0x%02X and carry: %d\t\tMSB: (Memory[ (0x%02X+0x01=(0x%02X)) + carry(%d)]): 0x%02X\n\tADDRESS BUS: Memory[0x%04X]\t\tCurrent Value: 0x%02X (%d)\n", mode, indirect_addr, indirect_addr, Memory[indirect_addr], Y, LSB_tmp, LSB, carry, indirect_addr, Memory[indirect_addr+1], carry, MSB, memAddr, value, value)
+		fmt.Printf("\t%s addressing mode.\tIndirect Addr: 0x%02X\tLSB: (Memory[0x%02X]:0x%02X + Y:(0x%02X)) = 0x%04X & 00FF = 0x%02X and carry: %d\t\tMSB: (Memory[ (0x%02X+0x01=(0x%02X)) + carry(%d)]): 0x%02X\n\tADDRESS BUS: Memory[%s]\t\tCurrent Value: 0x%02X (%d)\n", mode, indirect_addr, indirect_addr, Memory[indirect_addr], Y, LSB_tmp, LSB, carry, indirect_addr, Memory[indirect_addr+1], carry, MSB, debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -207,7 +210,7 @@ func addr_mode_IndirectX(offset uint16) (uint16, string) {
 	mode := "(Indirect,X)"
 
 	if Debug {
-		fmt.Printf("\t%s addressing mode. Indirect Addr: 0x%02X\t\tLSB: indirect_addr:0x%02X + X:0x%02X = 0x%02X (Value: 0x%02X)\t\tMSB: Address of LSB(0x%02X) + 0x01: 0x%02X (Value: 0x%02X)\n\tADDRESS BUS: Memory[0x%04X]\t\tCurrent Value: 0x%02X (%02X)\n", mode, indirect_addr, indirect_addr, X, LSB, Memory[LSB], LSB, MSB, Memory[MSB], memAddr, value, value)
+		fmt.Printf("\t%s addressing mode. Indirect Addr: 0x%02X\t\tLSB: indirect_addr:0x%02X + X:0x%02X = 0x%02X (Value: 0x%02X)\t\tMSB: Address of LSB(0x%02X) + 0x01: 0x%02X (Value: 0x%02X)\n\tADDRESS BUS: Memory[%s]\t\tCurrent Value: 0x%02X (%02X)\n", mode, indirect_addr, indirect_addr, X, LSB, Memory[LSB], LSB, MSB, Memory[MSB], debug_addr(memAddr), value, value)
 	}
 
 	return memAddr, mode
@@ -0,0 +1,358 @@
+package CPU_6502
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cassianoperin/6502_GO_Core/assembler"
+)
+
+// ------------------------------- Workloads -------------------------------- //
+
+// Tight arithmetic loop
+const bench_arithmetic = `
+	.org $0200
+start:	CLC
+	LDA #$00
+	LDX #$00
+loop:	ADC #$03
+	EOR #$5A
+	ASL A
+	ROL $10
+	SBC $10
+	INX
+	BNE loop
+	JMP start
+
+	.org $FFFC
+	.word start
+`
+
+// Memory copy with the indexed modes
+const bench_copy = `
+	.org $0200
+start:	LDA #$00
+	STA $20
+	STA $22
+	LDA #$10
+	STA $21
+	LDA #$20
+	STA $23
+	LDY #$00
+copy:	LDA ($20),Y
+	STA ($22),Y
+	LDA $1100,Y
+	STA $2100,Y
+	INY
+	BNE copy
+	LDX #$00
+copyx:	LDA $1200,X
+	STA $2200,X
+	DEX
+	BNE copyx
+	JMP start
+
+	.org $FFFC
+	.word start
+`
+
+// Decimal mode arithmetic
+const bench_decimal = `
+	.org $0200
+start:	SED
+	CLC
+	LDA #$00
+	LDX #$00
+loop:	ADC #$19
+	STA $10
+	SEC
+	SBC #$07
+	ADC $10
+	INX
+	BNE loop
+	CLD
+	JMP start
+
+	.org $FFFC
+	.word start
+`
+
+// Taken and not taken branches
+const bench_branches = `
+	.org $0200
+start:	LDX #$00
+loop:	TXA
+	LSR A
+	BCC even
+	NOP
+even:	CPX #$80
+	BCS high
+	BPL next
+high:	BMI next
+next:	BVC skip
+	NOP
+skip:	DEX
+	BNE loop
+	BEQ start
+
+	.org $FFFC
+	.word start
+`
+
+// Counting loop interrupted by bench_timer every bench_irq_period cycles
+const bench_interrupts = `
+	.org $0200
+start:	LDX #$FF
+	TXS
+	CLI
+loop:	INX
+	BNE loop
+	INY
+	JMP loop
+
+irq:	PHA
+	LDA $6000
+	INC $10
+	PLA
+	RTI
+
+	.org $FFFA
+	.word irq, start, irq
+`
+
+const bench_irq_period = 100 // Cycles
+
+// Klaus Dormann functional test, loaded at $0000 and started at $0400 (skipped if the binary is missing)
+var bench_klaus = os.Getenv("KLAUS_FUNCTIONAL_TEST")
+
+const bench_klaus_success = 0x3469 // Success trap of the default build
+
+// Timer requesting an IRQ, acknowledged by reading it
+type bench_timer struct {
+	irq   *InterruptLine
+	count int
+}
+
+func (t *bench_timer) Read(offset uint16) byte {
+	t.irq.Set(false)
+	return 0
+}
+
+func (t *bench_timer) Write(offset uint16, value byte) {}
+
+func (t *bench_timer) Clock() {
+	if t.count++; t.count == bench_irq_period {
+		t.count = 0
+		t.irq.Set(true)
+	}
+}
+
+func (t *bench_timer) ClockCycles(cycles int) {
+	if t.count += cycles; t.count >= bench_irq_period {
+		t.count -= bench_irq_period
+		t.irq.Set(true)
+	}
+}
+
+// ------------------------------- Benchmarks ------------------------------- //
+
+// The programs of testdata/bench, used by the baseline benchmarks, are the workloads above
+func TestBenchImages(t *testing.T) {
+
+	for name, src := range map[string]string{
+		"arithmetic": bench_arithmetic,
+		"copy":       bench_copy,
+		"decimal":    bench_decimal,
+		"branches":   bench_branches,
+	} {
+		program, err := assembler.Assemble(src, assembler.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		image, err := os.ReadFile("testdata/bench/" + name + ".bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := program.Segments[0]; s.Address != 0x0200 || !bytes.Equal(s.Data, image) {
+			t.Errorf("testdata/bench/%s.bin doesn't match bench_%s", name, name)
+		}
+	}
+}
+
+func BenchmarkArithmetic(b *testing.B) {
+	benchWorkload(b, bench_arithmetic, nil)
+}
+
+func BenchmarkCopy(b *testing.B) {
+	benchWorkload(b, bench_copy, nil)
+}
+
+func BenchmarkDecimal(b *testing.B) {
+	benchWorkload(b, bench_decimal, nil)
+}
+
+func BenchmarkBranches(b *testing.B) {
+	benchWorkload(b, bench_branches, nil)
+}
+
+func BenchmarkInterrupts(b *testing.B) {
+	benchWorkload(b, bench_interrupts, func(b *testing.B) {
+		t := &bench_timer{irq: NewIRQLine()}
+		if err := AttachDevice(t, 0x6000, 1); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() {
+			DetachDevice(t)
+			t.irq.Set(false)
+		})
+	})
+}
+
+// One operation is a full run of the test
+func BenchmarkKlaus(b *testing.B) {
+
+	if bench_klaus == "" {
+		bench_klaus = "6502_functional_test.bin"
+	}
+	image, err := os.ReadFile(bench_klaus)
+	if err != nil {
+		b.Skipf("%v (set KLAUS_FUNCTIONAL_TEST to the binary)", err)
+	}
+
+	for _, r := range bench_runners {
+		r := r
+		b.Run(r.name, func(b *testing.B) {
+
+			var cycles, instructions uint64
+
+			m := benchMeasure(b, func() {
+				for i := 0; i < b.N; i++ {
+					benchSetup(func() { copy(Memory[:], image) })
+					PC = 0x0400
+					start_cycle, start_ips := Cycle, IPS
+
+					// Until a trap (an instruction jumping to itself)
+					for {
+						r.run(100000)
+						pc := PC
+						Step()
+						if PC == pc || Halted {
+							break
+						}
+					}
+					if PC != bench_klaus_success {
+						b.Fatalf("trap at $%04X", PC)
+					}
+
+					cycles += Cycle - start_cycle
+					instructions += IPS - start_ips
+				}
+			})
+			m.report(b, cycles, instructions)
+		})
+	}
+}
+
+// -------------------------------- Helpers --------------------------------- //
+
+// Cycle interpreter and fast path of this tree. The interpreter was changed in
+// place by the later work (memory map, devices, hooks, interrupts) and the package
+// keeps its state in globals, so the baseline can't be linked next to it: its
+// numbers come from testdata/CPU_Baseline_test.go in a worktree of the baseline
+var bench_runners = []struct {
+	name string
+	run  func(cycles uint64)
+}{
+	{"Interpreter", func(cycles uint64) {
+		for end := Cycle + cycles; Cycle < end && !Halted; {
+			CPU_Interpreter()
+		}
+	}},
+	{"Run", func(cycles uint64) {
+		Run(cycles)
+	}},
+}
+
+// Runs b.N instructions of the program with each runner
+func benchWorkload(b *testing.B, src string, setup func(b *testing.B)) {
+
+	program, err := assembler.Assemble(src, assembler.Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, r := range bench_runners {
+		r := r
+		b.Run(r.name, func(b *testing.B) {
+			benchSetup(func() {
+				for _, s := range program.Segments {
+					copy(Memory[s.Address:], s.Data)
+				}
+				if setup != nil {
+					setup(b)
+				}
+			})
+			benchInstructions(b, func() { r.run(1000) })
+		})
+	}
+}
+
+// Loads a program with load and resets the CPU
+func benchSetup(load func()) {
+	Debug = false
+	CPU_MODE = 1
+	Initialize()
+	load()
+	Reset()
+}
+
+// Runs b.N instructions, one operation is an instruction
+func benchInstructions(b *testing.B, step func()) {
+
+	cycles, instructions := Cycle, IPS
+
+	m := benchMeasure(b, func() {
+		for IPS-instructions < uint64(b.N) && !Halted {
+			step()
+		}
+	})
+	if Halted {
+		b.Fatalf("CPU halted at $%04X", PC)
+	}
+
+	m.report(b, Cycle-cycles, IPS-instructions)
+}
+
+type bench_measure struct {
+	elapsed float64
+	mallocs uint64
+}
+
+// Time and allocations of f
+func benchMeasure(b *testing.B, f func()) bench_measure {
+
+	var before, after runtime.MemStats
+
+	b.ReportAllocs()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+
+	start := time.Now()
+	f()
+	elapsed := time.Since(start).Seconds()
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+
+	return bench_measure{elapsed: elapsed, mallocs: after.Mallocs - before.Mallocs}
+}
+
+// Reports the cycles and instructions per second and the allocations per instruction
+func (m bench_measure) report(b *testing.B, cycles, instructions uint64) {
+	b.ReportMetric(float64(cycles)/m.elapsed, "cycles/s")
+	b.ReportMetric(float64(instructions)/m.elapsed, "instructions/s")
+	b.ReportMetric(float64(m.mallocs)/float64(instructions), "allocs/instruction")
+}
@@ -0,0 +1,110 @@
+package CPU_6502
+
+// ------------------------ Execution Control API ------------------------ //
+
+// Registers holds a copy of the CPU registers, with P packed in a byte
+type Registers struct {
+	PC uint16
+	A  byte
+	X  byte
+	Y  byte
+	SP byte
+	P  byte // N V - B D I Z C
+}
+
+// GetRegisters returns the current value of the registers
+func GetRegisters() Registers {
+	return Registers{PC: PC, A: A, X: X, Y: Y, SP: SP, P: PackP()}
+}
+
+// SetRegisters updates all the registers
+func SetRegisters(r Registers) {
+	PC, A, X, Y, SP = r.PC, r.A, r.X, r.Y, r.SP
+	UnpackP(r.P)
+}
+
+// PackP returns the Processor Status Register as a byte
+func PackP() byte {
+
+	var value byte
+
+	for i := 7; i >= 0; i-- {
+		value = value<<1 | P[i]&0x01
+	}
+
+	return value
+}
+
+// UnpackP loads the Processor Status Register from a byte
+func UnpackP(value byte) {
+	for i := 0; i < 8; i++ {
+		P[i] = value >> i & 0x01
+	}
+}
+
+// ReadMemory returns a memory value without going through the data BUS
+// (no hooks or side effects), for debuggers and front-ends
+func ReadMemory(addr uint16) byte {
+	return Memory[addr]
+}
+
+// WriteMemory changes a memory value without going through the data BUS
+func WriteMemory(addr uint16, value byte) {
+	Memory[addr] = value
+}
+
+// Step runs the CPU until the current instruction finishes
+// Returns the number of cycles executed (0 if an Instruction hook held the CPU or the CPU is Halted)
+func Step() uint64 {
+
+	start := Cycle
+
+	NewInstruction = false
+	hooks_held = false
+
+	for !NewInstruction && !Halted {
+		CPU_Interpreter()
+
+		if hooks_held {
+			break
+		}
+	}
+
+	return Cycle - start
+}
+
+// ------------------------------- Machine ------------------------------- //
+
+// Machine is the control API of the core as a value, for front-ends that
+// shouldn't depend on the package variables (monitors, tools and their tests)
+type Machine interface {
+	Registers() Registers
+	SetRegisters(r Registers)
+
+	// Memory without going through the data BUS
+	ReadMemory(addr uint16) byte
+	WriteMemory(addr uint16, value byte)
+
+	Cycles() uint64 // Cycle counter
+	Reset()         // PC from the reset vector
+	SetTrace(on bool)
+}
+
+// Core returns the Machine of the CPU of this package
+func Core() Machine {
+	return core{}
+}
+
+type core struct{}
+
+func (core) Registers() Registers                { return GetRegisters() }
+func (core) SetRegisters(r Registers)            { SetRegisters(r) }
+func (core) ReadMemory(addr uint16) byte         { return ReadMemory(addr) }
+func (core) WriteMemory(addr uint16, value byte) { WriteMemory(addr, value) }
+func (core) Cycles() uint64                      { return Cycle }
+func (core) SetTrace(on bool)                    { Debug = on }
+
+func (core) Reset() {
+	PC_as_argument = 0
+	Reset()
+}
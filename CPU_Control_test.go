@@ -0,0 +1,53 @@
+package CPU_6502
+
+import "testing"
+
+// One Step runs exactly one instruction, including the accumulator shifts and rotates
+func TestStepOneInstruction(t *testing.T) {
+
+	Debug = false
+
+	for _, opc := range []byte{0x0A, 0x4A, 0x2A, 0x6A, 0xEA} {
+
+		Initialize()
+		Memory[0x0200], Memory[0x0201] = opc, 0xE8 // opc, INX
+		PC = 0x0200
+
+		if cycles := Step(); cycles != 2 {
+			t.Errorf("opcode $%02X: Step ran %d cycles, expected 2", opc, cycles)
+		}
+		if PC != 0x0201 || X != 0 {
+			t.Errorf("opcode $%02X: PC=$%04X X=$%02X after Step, expected PC=$0201 X=$00", opc, PC, X)
+		}
+	}
+}
+
+// Branches take 2 cycles, 3 when taken and 4 when the destination is in another page
+func TestStepBranchCycles(t *testing.T) {
+
+	Debug = false
+
+	for _, c := range []struct {
+		pc     uint16
+		opc    byte
+		offset byte
+		cycles uint64
+		dest   uint16
+	}{
+		{0x0200, 0xF0, 0x10, 2, 0x0202}, // BEQ not taken
+		{0x0200, 0xD0, 0x10, 3, 0x0212}, // BNE forward, same page
+		{0x0280, 0xD0, 0xF0, 3, 0x0272}, // BNE backward, same page
+		{0x02F0, 0xD0, 0x20, 4, 0x0312}, // BNE forward to the next page
+		{0x0300, 0xD0, 0xF0, 4, 0x02F2}, // BNE backward to the previous page
+	} {
+
+		Initialize()
+		Memory[c.pc], Memory[c.pc+1] = c.opc, c.offset
+		PC = c.pc
+		P[1] = 0
+
+		if cycles := Step(); cycles != c.cycles || PC != c.dest {
+			t.Errorf("$%02X $%02X at $%04X: %d cycles to $%04X, expected %d cycles to $%04X", c.opc, c.offset, c.pc, cycles, PC, c.cycles, c.dest)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package CPU_6502
+
+// ------------------------------ Decimal Mode ------------------------------ //
+
+// Lookup tables of ADC and SBC in decimal mode
+var (
+	bcd_to_bin [256]byte   // Decimal value of a BCD byte (0 for the invalid ones, with a digit above 9)
+	bin_to_bcd [200]uint16 // BCD value of 0-199 (the hundreds in the high byte)
+)
+
+func init() {
+
+	for i := range bcd_to_bin {
+		if i>>4 <= 9 && i&0x0F <= 9 {
+			bcd_to_bin[i] = byte(i>>4*10 + i&0x0F)
+		}
+	}
+
+	for i := range bin_to_bcd {
+		bin_to_bcd[i] = uint16(i/100)<<8 | uint16(i/10%10)<<4 | uint16(i%10)
+	}
+}
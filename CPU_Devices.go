@@ -0,0 +1,196 @@
+package CPU_6502
+
+import "fmt"
+
+// ------------------------------ Devices ------------------------------- //
+//
+// Memory mapped chips (VIA, RIOT, ACIA, PIA...) answer the data BUS accesses
+// of the instructions in their address range instead of Memory. Devices that
+// implement Clocked run one cycle for each CPU cycle, in lock-step with Cycle.
+// Opcode and operand fetches, ReadMemory and WriteMemory still use Memory,
+// so the monitors don't trigger the side effects of the registers.
+
+// Device is a memory mapped chip, offset is the address minus the base
+type Device interface {
+	Read(offset uint16) byte
+	Write(offset uint16, value byte)
+}
+
+// Clocked devices are called once per CPU cycle
+type Clocked interface {
+	Clock()
+}
+
+// MultiClocked devices can also run several cycles in one call, with the same
+// result as calling Clock as many times. Run uses it to clock the devices
+// between the data accesses of an instruction.
+type MultiClocked interface {
+	Clocked
+	ClockCycles(cycles int)
+}
+
+type device_map struct {
+	device Device
+	base   uint16
+	size   int
+	mask   uint16 // Partial address decoding (0 for a range of addresses)
+}
+
+// Addresses answered by a mapping
+func (m *device_map) each(f func(addr int)) {
+
+	if m.mask == 0 {
+		for addr := int(m.base); addr < int(m.base)+m.size; addr++ {
+			f(addr)
+		}
+		return
+	}
+
+	for addr := 0; addr < 0x10000; addr++ {
+		if uint16(addr)&m.mask == m.base {
+			f(addr)
+		}
+	}
+}
+
+// Offset of an address in the device
+func (m *device_map) offset(addr uint16) uint16 {
+	if m.mask != 0 {
+		return addr &^ m.mask
+	}
+	return addr - m.base
+}
+
+var (
+	devices        []device_map
+	device_index   [65536]byte // Index of the device + 1 (0 for memory)
+	devices_mapped bool        // Single check in the data BUS hot path
+	clocked        []Clocked
+	clocked_multi  []MultiClocked // The same devices, nil unless all of them are MultiClocked
+)
+
+// AttachDevice maps a device at base, size bytes (masking the device can decode fewer address lines)
+func AttachDevice(d Device, base uint16, size int) error {
+
+	if size <= 0 || int(base)+size > 0x10000 {
+		return fmt.Errorf("device at $%04X with %d bytes outside the 64KB address space", base, size)
+	}
+
+	return attachDevice(device_map{device: d, base: base, size: size})
+}
+
+// AttachDecodedDevice maps a device at all the addresses with addr & mask == match,
+// like the chips selected by a few address lines. The offset is the address
+// with the decoded bits cleared.
+func AttachDecodedDevice(d Device, mask, match uint16) error {
+
+	if mask == 0 || match&^mask != 0 {
+		return fmt.Errorf("invalid address decoding mask $%04X match $%04X", mask, match)
+	}
+
+	return attachDevice(device_map{device: d, base: match, mask: mask})
+}
+
+func attachDevice(m device_map) error {
+
+	if len(devices) == 255 {
+		return fmt.Errorf("too many devices")
+	}
+
+	overlap := -1
+	m.each(func(addr int) {
+		if i := device_index[addr]; i != 0 && overlap < 0 {
+			overlap = addr
+		}
+	})
+	if overlap >= 0 {
+		return fmt.Errorf("device at $%04X overlaps the device at $%04X", overlap, devices[device_index[overlap]-1].base)
+	}
+
+	devices = append(devices, m)
+
+	index := byte(len(devices))
+	m.each(func(addr int) {
+		device_index[addr] = index
+	})
+
+	// Clocked once, also when mapped at several bases
+	if c, ok := m.device.(Clocked); ok && !isClocked(c) {
+		clocked = append(clocked, c)
+		updateClockedMulti()
+	}
+
+	devices_mapped = true
+
+	return nil
+}
+
+// DetachDevice removes all the mappings of a device
+func DetachDevice(d Device) {
+
+	kept := devices[:0]
+	for _, m := range devices {
+		if m.device != d {
+			kept = append(kept, m)
+		}
+	}
+	devices = kept
+
+	// Rebuild the index
+	device_index = [65536]byte{}
+	for i := range devices {
+		index := byte(i + 1)
+		devices[i].each(func(addr int) {
+			device_index[addr] = index
+		})
+	}
+
+	for i, c := range clocked {
+		if any(c) == any(d) {
+			clocked = append(clocked[:i], clocked[i+1:]...)
+			break
+		}
+	}
+	updateClockedMulti()
+
+	devices_mapped = len(devices) > 0
+}
+
+// DeviceAt returns the device mapped at an address and the offset of the address in it
+func DeviceAt(addr uint16) (Device, uint16, bool) {
+
+	i := device_index[addr]
+	if i == 0 {
+		return nil, 0, false
+	}
+
+	return devices[i-1].device, devices[i-1].offset(addr), true
+}
+
+func isClocked(c Clocked) bool {
+	for _, other := range clocked {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
+
+func updateClockedMulti() {
+	clocked_multi = nil
+	for _, c := range clocked {
+		m, ok := c.(MultiClocked)
+		if !ok {
+			clocked_multi = nil
+			return
+		}
+		clocked_multi = append(clocked_multi, m)
+	}
+}
+
+// Run one cycle of the clocked devices
+func clockDevices() {
+	for _, c := range clocked {
+		c.Clock()
+	}
+}
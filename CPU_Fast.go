@@ -0,0 +1,1014 @@
+package CPU_6502
+
+// ------------------------------ Fast Execution ----------------------------- //
+
+// Run executes whole instructions until the given number of cycles has passed,
+// returns the cycles executed (fewer if the CPU halts or an Instruction hook holds it).
+//
+// It gives the same results and cycle counts as calling CPU_Interpreter, without
+// its per cycle bookkeeping: each instruction is decoded once and executed in a
+// single step, with the devices clocked around its data access. Debug messages,
+// hooks and instructions already started run in the cycle interpreter.
+func Run(cycles uint64) uint64 {
+
+	start := Cycle
+	end := Cycle + cycles
+
+	for Cycle < end && !Halted {
+
+		// Single check for everything the fast path doesn't handle
+		if Debug || hooks_active || Opc_cycle_count != 1 || interrupt_cycle > 0 {
+			if !run_Cycle() {
+				break
+			}
+			continue
+		}
+
+		if interruptPending() {
+			fast_Interrupt()
+			continue
+		}
+
+		opc := Memory[PC]
+		opcode = opc
+
+		// Host traps, replacing the instruction
+		if traps_active && cpu_Trap() {
+			fast_Clock(1)
+			continue
+		}
+
+		if !fast_Instruction(opc) && !run_Cycle() {
+			break
+		}
+	}
+
+	return Cycle - start
+}
+
+// One cycle of the cycle interpreter, false if it didn't run (halted or held by a hook)
+func run_Cycle() bool {
+	before := Cycle
+	CPU_Interpreter()
+	return Cycle != before
+}
+
+// Addressing modes
+const (
+	mode_Implied byte = iota
+	mode_Immediate
+	mode_Zeropage
+	mode_ZeropageX
+	mode_ZeropageY
+	mode_Absolute
+	mode_AbsoluteX
+	mode_AbsoluteY
+	mode_Indirect
+	mode_IndirectX
+	mode_IndirectY
+	mode_Relative
+)
+
+// Instruction bytes of each addressing mode
+var fast_bytes = [...]uint16{
+	mode_Implied: 1, mode_Immediate: 2, mode_Zeropage: 2, mode_ZeropageX: 2, mode_ZeropageY: 2, mode_Absolute: 3,
+	mode_AbsoluteX: 3, mode_AbsoluteY: 3, mode_Indirect: 3, mode_IndirectX: 2, mode_IndirectY: 2, mode_Relative: 2,
+}
+
+type fast_opcode struct {
+	mode   byte
+	cycles byte
+}
+
+// Addressing mode and cycles of the opcodes of the CPU_Interpreter
+var fast_opcodes = [256]fast_opcode{
+	0x00: {mode_Implied, 7}, 0x01: {mode_IndirectX, 6}, 0x05: {mode_Zeropage, 3}, 0x06: {mode_Zeropage, 5},
+	0x08: {mode_Implied, 3}, 0x09: {mode_Immediate, 2}, 0x0A: {mode_Implied, 2}, 0x0D: {mode_Absolute, 4},
+	0x0E: {mode_Absolute, 6}, 0x10: {mode_Relative, 2}, 0x11: {mode_IndirectY, 5}, 0x15: {mode_ZeropageX, 4},
+	0x16: {mode_ZeropageX, 6}, 0x18: {mode_Implied, 2}, 0x19: {mode_AbsoluteY, 4}, 0x1D: {mode_AbsoluteX, 4},
+	0x1E: {mode_AbsoluteX, 7}, 0x20: {mode_Absolute, 6}, 0x21: {mode_IndirectX, 6}, 0x24: {mode_Zeropage, 3},
+	0x25: {mode_Zeropage, 3}, 0x26: {mode_Zeropage, 5}, 0x28: {mode_Implied, 4}, 0x29: {mode_Immediate, 2},
+	0x2A: {mode_Implied, 2}, 0x2C: {mode_Absolute, 4}, 0x2D: {mode_Absolute, 4}, 0x2E: {mode_Absolute, 6},
+	0x30: {mode_Relative, 2}, 0x31: {mode_IndirectY, 5}, 0x35: {mode_ZeropageX, 4}, 0x36: {mode_ZeropageX, 6},
+	0x38: {mode_Implied, 2}, 0x39: {mode_AbsoluteY, 4}, 0x3D: {mode_AbsoluteX, 4}, 0x3E: {mode_AbsoluteX, 7},
+	0x40: {mode_Implied, 6}, 0x41: {mode_IndirectX, 6}, 0x45: {mode_Zeropage, 3}, 0x46: {mode_Zeropage, 5},
+	0x48: {mode_Implied, 3}, 0x49: {mode_Immediate, 2}, 0x4A: {mode_Implied, 2}, 0x4C: {mode_Absolute, 3},
+	0x4D: {mode_Absolute, 4}, 0x4E: {mode_Absolute, 6}, 0x50: {mode_Relative, 2}, 0x51: {mode_IndirectY, 5},
+	0x55: {mode_ZeropageX, 4}, 0x56: {mode_ZeropageX, 6}, 0x58: {mode_Implied, 2}, 0x59: {mode_AbsoluteY, 4},
+	0x5D: {mode_AbsoluteX, 4}, 0x5E: {mode_AbsoluteX, 7}, 0x60: {mode_Implied, 6}, 0x61: {mode_IndirectX, 6},
+	0x65: {mode_Zeropage, 3}, 0x66: {mode_Zeropage, 5}, 0x68: {mode_Implied, 4}, 0x69: {mode_Immediate, 2},
+	0x6A: {mode_Implied, 2}, 0x6C: {mode_Indirect, 5}, 0x6D: {mode_Absolute, 4}, 0x6E: {mode_Absolute, 6},
+	0x70: {mode_Relative, 2}, 0x71: {mode_IndirectY, 5}, 0x75: {mode_ZeropageX, 4}, 0x76: {mode_ZeropageX, 6},
+	0x78: {mode_Implied, 2}, 0x79: {mode_AbsoluteY, 4}, 0x7D: {mode_AbsoluteX, 4}, 0x7E: {mode_AbsoluteX, 7},
+	0x81: {mode_IndirectX, 6}, 0x84: {mode_Zeropage, 3}, 0x85: {mode_Zeropage, 3}, 0x86: {mode_Zeropage, 3},
+	0x88: {mode_Implied, 2}, 0x8A: {mode_Implied, 2}, 0x8C: {mode_Absolute, 4}, 0x8D: {mode_Absolute, 4},
+	0x8E: {mode_Absolute, 4}, 0x90: {mode_Relative, 2}, 0x91: {mode_IndirectY, 6}, 0x94: {mode_ZeropageX, 4},
+	0x95: {mode_ZeropageX, 4}, 0x96: {mode_ZeropageY, 4}, 0x98: {mode_Implied, 2}, 0x99: {mode_AbsoluteY, 5},
+	0x9A: {mode_Implied, 2}, 0x9D: {mode_AbsoluteX, 5}, 0xA0: {mode_Immediate, 2}, 0xA1: {mode_IndirectX, 6},
+	0xA2: {mode_Immediate, 2}, 0xA4: {mode_Zeropage, 3}, 0xA5: {mode_Zeropage, 3}, 0xA6: {mode_Zeropage, 3},
+	0xA8: {mode_Implied, 2}, 0xA9: {mode_Immediate, 2}, 0xAA: {mode_Implied, 2}, 0xAC: {mode_Absolute, 4},
+	0xAD: {mode_Absolute, 4}, 0xAE: {mode_Absolute, 4}, 0xB0: {mode_Relative, 2}, 0xB1: {mode_IndirectY, 5},
+	0xB4: {mode_ZeropageX, 4}, 0xB5: {mode_ZeropageX, 4}, 0xB6: {mode_ZeropageY, 4}, 0xB8: {mode_Implied, 2},
+	0xB9: {mode_AbsoluteY, 4}, 0xBA: {mode_Implied, 2}, 0xBC: {mode_AbsoluteX, 4}, 0xBD: {mode_AbsoluteX, 4},
+	0xBE: {mode_AbsoluteY, 4}, 0xC0: {mode_Immediate, 2}, 0xC1: {mode_IndirectX, 6}, 0xC4: {mode_Zeropage, 3},
+	0xC5: {mode_Zeropage, 3}, 0xC6: {mode_Zeropage, 5}, 0xC8: {mode_Implied, 2}, 0xC9: {mode_Immediate, 2},
+	0xCA: {mode_Implied, 2}, 0xCC: {mode_Absolute, 4}, 0xCD: {mode_Absolute, 4}, 0xCE: {mode_Absolute, 6},
+	0xD0: {mode_Relative, 2}, 0xD1: {mode_IndirectY, 5}, 0xD5: {mode_ZeropageX, 4}, 0xD6: {mode_ZeropageX, 6},
+	0xD8: {mode_Implied, 2}, 0xD9: {mode_AbsoluteY, 4}, 0xDD: {mode_AbsoluteX, 4}, 0xDE: {mode_AbsoluteX, 7},
+	0xE0: {mode_Immediate, 2}, 0xE1: {mode_IndirectX, 6}, 0xE4: {mode_Zeropage, 3}, 0xE5: {mode_Zeropage, 3},
+	0xE6: {mode_Zeropage, 5}, 0xE8: {mode_Implied, 2}, 0xE9: {mode_Immediate, 2}, 0xEA: {mode_Implied, 2},
+	0xEC: {mode_Absolute, 4}, 0xED: {mode_Absolute, 4}, 0xEE: {mode_Absolute, 6}, 0xF0: {mode_Relative, 2},
+	0xF1: {mode_IndirectY, 5}, 0xF5: {mode_ZeropageX, 4}, 0xF6: {mode_ZeropageX, 6}, 0xF8: {mode_Implied, 2},
+	0xF9: {mode_AbsoluteY, 4}, 0xFD: {mode_AbsoluteX, 4}, 0xFE: {mode_AbsoluteX, 7},
+}
+
+// Cycles of the instruction in progress, and how many of them the devices already ran
+var fast_cycles, fast_clocked byte
+
+// fast_opcodes with the instruction bytes of the addressing mode, so they aren't computed per instruction
+type fast_instruction struct {
+	fast_opcode
+	bytes uint16
+}
+
+var fast_instructions [256]fast_instruction
+
+func init() {
+	for i, t := range fast_opcodes {
+		fast_instructions[i] = fast_instruction{
+			fast_opcode: t,
+			bytes:       fast_bytes[t.mode],
+		}
+	}
+}
+
+// Executes the instruction opc at PC, false if it must run in the cycle interpreter
+func fast_Instruction(opc byte) bool {
+
+	t := &fast_instructions[opc]
+
+	fast_cycles, fast_clocked = t.cycles, 0
+	bytes := t.bytes
+
+	// The operands are read from the memory like in the addressing modes, the data
+	// accesses go through fast_Read and fast_Write
+	switch opc {
+
+	// Loads, logic and arithmetic
+	case 0x61: // ADC (indirect,X)
+		addr := fast_IndirectX()
+		fast_ADC(fast_Read(addr))
+	case 0x65: // ADC zero page
+		addr := uint16(Memory[PC+1])
+		fast_ADC(fast_Read(addr))
+	case 0x69: // ADC immediate
+		addr := PC + 1
+		fast_ADC(fast_Read(addr))
+	case 0x6D: // ADC absolute
+		addr := fast_Absolute()
+		fast_ADC(fast_Read(addr))
+	case 0x71: // ADC (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		fast_ADC(fast_Read(addr))
+	case 0x75: // ADC zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		fast_ADC(fast_Read(addr))
+	case 0x79: // ADC absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		fast_ADC(fast_Read(addr))
+	case 0x7D: // ADC absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		fast_ADC(fast_Read(addr))
+	case 0xE1: // SBC (indirect,X)
+		addr := fast_IndirectX()
+		fast_SBC(fast_Read(addr))
+	case 0xE5: // SBC zero page
+		addr := uint16(Memory[PC+1])
+		fast_SBC(fast_Read(addr))
+	case 0xE9: // SBC immediate
+		addr := PC + 1
+		fast_SBC(fast_Read(addr))
+	case 0xED: // SBC absolute
+		addr := fast_Absolute()
+		fast_SBC(fast_Read(addr))
+	case 0xF1: // SBC (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		fast_SBC(fast_Read(addr))
+	case 0xF5: // SBC zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		fast_SBC(fast_Read(addr))
+	case 0xF9: // SBC absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		fast_SBC(fast_Read(addr))
+	case 0xFD: // SBC absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		fast_SBC(fast_Read(addr))
+	case 0x21: // AND (indirect,X)
+		addr := fast_IndirectX()
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x25: // AND zero page
+		addr := uint16(Memory[PC+1])
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x29: // AND immediate
+		addr := PC + 1
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x2D: // AND absolute
+		addr := fast_Absolute()
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x31: // AND (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x35: // AND zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x39: // AND absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x3D: // AND absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		A &= fast_Read(addr)
+		fast_ZN(A)
+	case 0x01: // ORA (indirect,X)
+		addr := fast_IndirectX()
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x05: // ORA zero page
+		addr := uint16(Memory[PC+1])
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x09: // ORA immediate
+		addr := PC + 1
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x0D: // ORA absolute
+		addr := fast_Absolute()
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x11: // ORA (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x15: // ORA zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x19: // ORA absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x1D: // ORA absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		A |= fast_Read(addr)
+		fast_ZN(A)
+	case 0x41: // EOR (indirect,X)
+		addr := fast_IndirectX()
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x45: // EOR zero page
+		addr := uint16(Memory[PC+1])
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x49: // EOR immediate
+		addr := PC + 1
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x4D: // EOR absolute
+		addr := fast_Absolute()
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x51: // EOR (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x55: // EOR zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x59: // EOR absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0x5D: // EOR absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		A ^= fast_Read(addr)
+		fast_ZN(A)
+	case 0xA1: // LDA (indirect,X)
+		addr := fast_IndirectX()
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xA5: // LDA zero page
+		addr := uint16(Memory[PC+1])
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xA9: // LDA immediate
+		addr := PC + 1
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xAD: // LDA absolute
+		addr := fast_Absolute()
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xB1: // LDA (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xB5: // LDA zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xB9: // LDA absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xBD: // LDA absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		A = fast_Read(addr)
+		fast_ZN(A)
+	case 0xA2: // LDX immediate
+		addr := PC + 1
+		X = fast_Read(addr)
+		fast_ZN(X)
+	case 0xA6: // LDX zero page
+		addr := uint16(Memory[PC+1])
+		X = fast_Read(addr)
+		fast_ZN(X)
+	case 0xAE: // LDX absolute
+		addr := fast_Absolute()
+		X = fast_Read(addr)
+		fast_ZN(X)
+	case 0xB6: // LDX zero page,Y
+		addr := uint16(Memory[PC+1] + Y)
+		X = fast_Read(addr)
+		fast_ZN(X)
+	case 0xBE: // LDX absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		X = fast_Read(addr)
+		fast_ZN(X)
+	case 0xA0: // LDY immediate
+		addr := PC + 1
+		Y = fast_Read(addr)
+		fast_ZN(Y)
+	case 0xA4: // LDY zero page
+		addr := uint16(Memory[PC+1])
+		Y = fast_Read(addr)
+		fast_ZN(Y)
+	case 0xAC: // LDY absolute
+		addr := fast_Absolute()
+		Y = fast_Read(addr)
+		fast_ZN(Y)
+	case 0xB4: // LDY zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		Y = fast_Read(addr)
+		fast_ZN(Y)
+	case 0xBC: // LDY absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		Y = fast_Read(addr)
+		fast_ZN(Y)
+	case 0xC1: // CMP (indirect,X)
+		addr := fast_IndirectX()
+		fast_Compare(A, fast_Read(addr))
+	case 0xC5: // CMP zero page
+		addr := uint16(Memory[PC+1])
+		fast_Compare(A, fast_Read(addr))
+	case 0xC9: // CMP immediate
+		addr := PC + 1
+		fast_Compare(A, fast_Read(addr))
+	case 0xCD: // CMP absolute
+		addr := fast_Absolute()
+		fast_Compare(A, fast_Read(addr))
+	case 0xD1: // CMP (indirect),Y
+		addr := fast_IndirectY()
+		fast_Page(addr)
+		fast_Compare(A, fast_Read(addr))
+	case 0xD5: // CMP zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		fast_Compare(A, fast_Read(addr))
+	case 0xD9: // CMP absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Page(addr)
+		fast_Compare(A, fast_Read(addr))
+	case 0xDD: // CMP absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Page(addr)
+		fast_Compare(A, fast_Read(addr))
+	case 0xE0: // CPX immediate
+		addr := PC + 1
+		fast_Compare(X, fast_Read(addr))
+	case 0xE4: // CPX zero page
+		addr := uint16(Memory[PC+1])
+		fast_Compare(X, fast_Read(addr))
+	case 0xEC: // CPX absolute
+		addr := fast_Absolute()
+		fast_Compare(X, fast_Read(addr))
+	case 0xC0: // CPY immediate
+		addr := PC + 1
+		fast_Compare(Y, fast_Read(addr))
+	case 0xC4: // CPY zero page
+		addr := uint16(Memory[PC+1])
+		fast_Compare(Y, fast_Read(addr))
+	case 0xCC: // CPY absolute
+		addr := fast_Absolute()
+		fast_Compare(Y, fast_Read(addr))
+	case 0x24: // BIT zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr)
+		P[7] = value >> 7
+		P[6] = value >> 6 & 0x01
+		fast_Z(A & value)
+	case 0x2C: // BIT absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr)
+		P[7] = value >> 7
+		P[6] = value >> 6 & 0x01
+		fast_Z(A & value)
+
+	// Stores
+	case 0x81: // STA (indirect,X)
+		addr := fast_IndirectX()
+		fast_Write(addr, A)
+	case 0x85: // STA zero page
+		addr := uint16(Memory[PC+1])
+		fast_Write(addr, A)
+	case 0x8D: // STA absolute
+		addr := fast_Absolute()
+		fast_Write(addr, A)
+	case 0x91: // STA (indirect),Y
+		addr := fast_IndirectY()
+		fast_Write(addr, A)
+	case 0x95: // STA zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		fast_Write(addr, A)
+	case 0x99: // STA absolute,Y
+		addr := fast_Absolute() + uint16(Y)
+		fast_Write(addr, A)
+	case 0x9D: // STA absolute,X
+		addr := fast_Absolute() + uint16(X)
+		fast_Write(addr, A)
+	case 0x86: // STX zero page
+		addr := uint16(Memory[PC+1])
+		fast_Write(addr, X)
+	case 0x8E: // STX absolute
+		addr := fast_Absolute()
+		fast_Write(addr, X)
+	case 0x96: // STX zero page,Y
+		addr := uint16(Memory[PC+1] + Y)
+		fast_Write(addr, X)
+	case 0x84: // STY zero page
+		addr := uint16(Memory[PC+1])
+		fast_Write(addr, Y)
+	case 0x8C: // STY absolute
+		addr := fast_Absolute()
+		fast_Write(addr, Y)
+	case 0x94: // STY zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		fast_Write(addr, Y)
+
+	// Read-modify-write
+	case 0x06: // ASL zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr)
+		P[0] = value >> 7
+		value <<= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x0E: // ASL absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr)
+		P[0] = value >> 7
+		value <<= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x16: // ASL zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		value := fast_Read(addr)
+		P[0] = value >> 7
+		value <<= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x1E: // ASL absolute,X
+		addr := fast_Absolute() + uint16(X)
+		value := fast_Read(addr)
+		P[0] = value >> 7
+		value <<= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x46: // LSR zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr)
+		P[0] = value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x4E: // LSR absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr)
+		P[0] = value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x56: // LSR zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		value := fast_Read(addr)
+		P[0] = value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x5E: // LSR absolute,X
+		addr := fast_Absolute() + uint16(X)
+		value := fast_Read(addr)
+		P[0] = value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x26: // ROL zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr)
+		carry := P[0]
+		P[0] = value >> 7
+		value = value<<1 + carry
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x2E: // ROL absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr)
+		carry := P[0]
+		P[0] = value >> 7
+		value = value<<1 + carry
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x36: // ROL zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		value := fast_Read(addr)
+		carry := P[0]
+		P[0] = value >> 7
+		value = value<<1 + carry
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x3E: // ROL absolute,X
+		addr := fast_Absolute() + uint16(X)
+		value := fast_Read(addr)
+		carry := P[0]
+		P[0] = value >> 7
+		value = value<<1 + carry
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0x66: // ROR zero page
+		addr := uint16(Memory[PC+1])
+		// Two writes, like opc_ROR
+		value := fast_Read(addr)
+		carry := value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		value += P[0] << 7
+		fast_Write(addr, value)
+		P[0] = carry
+		fast_ZN(value)
+	case 0x6E: // ROR absolute
+		addr := fast_Absolute()
+		// Two writes, like opc_ROR
+		value := fast_Read(addr)
+		carry := value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		value += P[0] << 7
+		fast_Write(addr, value)
+		P[0] = carry
+		fast_ZN(value)
+	case 0x76: // ROR zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		// Two writes, like opc_ROR
+		value := fast_Read(addr)
+		carry := value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		value += P[0] << 7
+		fast_Write(addr, value)
+		P[0] = carry
+		fast_ZN(value)
+	case 0x7E: // ROR absolute,X
+		addr := fast_Absolute() + uint16(X)
+		// Two writes, like opc_ROR
+		value := fast_Read(addr)
+		carry := value & 0x01
+		value >>= 1
+		fast_Write(addr, value)
+		value += P[0] << 7
+		fast_Write(addr, value)
+		P[0] = carry
+		fast_ZN(value)
+	case 0xE6: // INC zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr) + 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xEE: // INC absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr) + 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xF6: // INC zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		value := fast_Read(addr) + 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xFE: // INC absolute,X
+		addr := fast_Absolute() + uint16(X)
+		value := fast_Read(addr) + 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xC6: // DEC zero page
+		addr := uint16(Memory[PC+1])
+		value := fast_Read(addr) - 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xCE: // DEC absolute
+		addr := fast_Absolute()
+		value := fast_Read(addr) - 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xD6: // DEC zero page,X
+		addr := uint16(Memory[PC+1] + X)
+		value := fast_Read(addr) - 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+	case 0xDE: // DEC absolute,X
+		addr := fast_Absolute() + uint16(X)
+		value := fast_Read(addr) - 1
+		fast_Write(addr, value)
+		fast_ZN(value)
+
+	// Accumulator
+	case 0x0A: // ASL accumulator
+		P[0] = A >> 7
+		A <<= 1
+		fast_ZN(A)
+	case 0x4A: // LSR accumulator
+		P[0] = A & 0x01
+		A >>= 1
+		fast_ZN(A)
+	case 0x2A: // ROL accumulator
+		carry := P[0]
+		P[0] = A >> 7
+		A = A<<1 + carry
+		fast_ZN(A)
+	case 0x6A: // ROR accumulator
+		carry := A & 0x01
+		A = A>>1 + P[0]<<7
+		P[0] = carry
+		fast_ZN(A)
+
+	// Registers
+	case 0xE8: // INX
+		X++
+		fast_ZN(X)
+	case 0xC8: // INY
+		Y++
+		fast_ZN(Y)
+	case 0xCA: // DEX
+		X--
+		fast_ZN(X)
+	case 0x88: // DEY
+		Y--
+		fast_ZN(Y)
+	case 0xAA: // TAX
+		X = A
+		fast_ZN(X)
+	case 0xA8: // TAY
+		Y = A
+		fast_ZN(Y)
+	case 0xBA: // TSX
+		X = SP
+		fast_ZN(X)
+	case 0x8A: // TXA
+		A = X
+		fast_ZN(A)
+	case 0x9A: // TXS
+		SP = X
+	case 0x98: // TYA
+		A = Y
+		fast_ZN(A)
+
+	// Flags
+	case 0x18: // CLC
+		P[0] = 0
+	case 0xD8: // CLD
+		P[3] = 0
+	case 0x58: // CLI
+		P[2] = 0
+	case 0xB8: // CLV
+		P[6] = 0
+	case 0x38: // SEC
+		P[0] = 1
+	case 0xF8: // SED
+		P[3] = 1
+	case 0x78: // SEI
+		P[2] = 1
+	case 0xEA: // NOP
+
+	// Stack, with the page 1 addresses of the opcodes
+	case 0x48: // PHA
+		fast_Write(uint16(SP)+256, A)
+		SP--
+	case 0x08: // PHP
+		fast_Write(uint16(SP)+256, PackP()|0x30)
+		SP--
+	case 0x68: // PLA
+		A = fast_Read(uint16(SP+1) + 256)
+		fast_ZN(A)
+		SP++
+	case 0x28: // PLP
+		fast_PullP(fast_Read(uint16(SP+1) + 256))
+		SP++
+
+	// Jumps
+	case 0x4C: // JMP absolute
+		addr := fast_Absolute()
+		PC, bytes = addr, 0
+	case 0x6C: // JMP indirect
+		// The cycle interpreter handles the vectors at the end of a page
+		if Memory[PC+1] == 0xFF || Memory[PC+2] == 0xFF {
+			return false
+		}
+		vector := fast_Absolute()
+		addr := uint16(Memory[vector+1])<<8 | uint16(Memory[vector])
+		PC, bytes = addr, 0
+	case 0x20: // JSR absolute
+		addr := fast_Absolute()
+		SP_Address := uint16(SP) + 256
+		fast_Write(SP_Address, byte((PC+2)>>8))
+		fast_Write(SP_Address-1, byte((PC+2)&0xFF))
+		SP -= 2
+		PC, bytes = addr, 0
+	case 0x60: // RTS
+		SP_Address := uint16(SP) + 256
+		hi := fast_Read(SP_Address + 2)
+		lo := fast_Read(SP_Address + 1)
+		PC = uint16(hi)<<8 | uint16(lo)
+		SP += 2
+	case 0x40: // RTI
+		SP_Address := uint16(SP+1) + 256
+		fast_PullP(fast_Read(SP_Address))
+		SP++
+		hi := fast_Read(SP_Address + 2)
+		lo := fast_Read(SP_Address + 1)
+		PC, bytes = uint16(hi)<<8|uint16(lo), 0
+		SP += 2
+	case 0x00: // BRK
+		SP_Address := uint16(SP) + 256
+		fast_Write(SP_Address, byte((PC+2)>>8))
+		fast_Write(SP_Address-1, byte((PC+2)&0xFF))
+		fast_Write(SP_Address-2, PackP()|0x30)
+		SP -= 3
+		hi := fast_Read(0xFFFF)
+		lo := fast_Read(0xFFFE)
+		PC, bytes = uint16(hi)<<8|uint16(lo), 0
+		P[2] = 1
+		P[4] = 1
+	case 0x10: // BPL
+		fast_Branch(P[7] == 0)
+	case 0x30: // BMI
+		fast_Branch(P[7] == 1)
+	case 0x50: // BVC
+		fast_Branch(P[6] == 0)
+	case 0x70: // BVS
+		fast_Branch(P[6] == 1)
+	case 0x90: // BCC
+		fast_Branch(P[0] == 0)
+	case 0xB0: // BCS
+		fast_Branch(P[0] == 1)
+	case 0xD0: // BNE
+		fast_Branch(P[1] == 0)
+	case 0xF0: // BEQ
+		fast_Branch(P[1] == 1)
+
+	default:
+		return false
+	}
+
+	PC += bytes
+
+	resetIntOpcCycleCounters()
+
+	fast_Clock(fast_cycles - fast_clocked)
+
+	return true
+}
+
+// ----------------------------- Addressing modes ------------------------------ //
+
+func fast_Absolute() uint16 {
+	return uint16(Memory[PC+2])<<8 | uint16(Memory[PC+1])
+}
+
+func fast_IndirectX() uint16 {
+	zp := Memory[PC+1] + X
+	return uint16(Memory[zp+1])<<8 | uint16(Memory[zp])
+}
+
+func fast_IndirectY() uint16 {
+	zp := Memory[PC+1]
+	return (uint16(Memory[zp+1])<<8 | uint16(Memory[zp])) + uint16(Y)
+}
+
+// Extra cycle of the indexed reads, with the same rule as the cycle interpreter (MemPageBoundary)
+func fast_Page(addr uint16) {
+	if addr>>8 != PC>>8 {
+		fast_cycles++
+	}
+}
+
+// Branches take an extra cycle, and another one when the destination is in another page
+func fast_Branch(taken bool) {
+
+	if taken {
+		fast_cycles++
+		if PC>>8 != (PC+uint16(int8(Memory[PC+1]))+2)>>8 {
+			fast_cycles++
+		}
+	}
+
+	offset := fast_Read(PC + 1)
+	if taken {
+		PC += uint16(int8(offset))
+	}
+}
+
+// The 7 cycles of the interrupt sequence in one step, the pushes and the vector fetch
+// happen on the last one like in cpu_Interrupt
+func fast_Interrupt() {
+	fast_Clock(6)
+	interrupt_cycle = 6
+	cpu_Interrupt()
+	fast_Clock(1)
+}
+
+// Advance the cycle counters, the devices run in lock-step with the CPU
+func fast_Clock(cycles byte) {
+	Cycle += uint64(cycles)
+	CPS += uint64(cycles)
+	if len(clocked) > 0 {
+		fast_ClockDevices(cycles)
+	}
+}
+
+func fast_ClockDevices(cycles byte) {
+
+	// Cycle is already the value after the last cycle
+	if clocked_multi != nil {
+		for _, c := range clocked_multi {
+			c.ClockCycles(int(cycles))
+		}
+		return
+	}
+
+	Cycle -= uint64(cycles)
+	CPS -= uint64(cycles)
+	for ; cycles > 0; cycles-- {
+		Cycle++
+		CPS++
+		clockDevices()
+	}
+}
+
+// ---------------------------------- Data BUS ---------------------------------- //
+
+// Without a memory map, a device at the address or a mapper the data BUS is the memory
+func fast_Read(addr uint16) byte {
+	if !memory_mapped && bus_mapper == nil && device_index[addr] == 0 {
+		return Memory[addr]
+	}
+	return fast_ReadBus(addr)
+}
+
+func fast_Write(addr uint16, value byte) {
+	if !memory_mapped && bus_mapper == nil && device_index[addr] == 0 {
+		Memory[addr] = value
+		return
+	}
+	fast_WriteBus(addr, value)
+}
+
+func fast_ReadBus(addr uint16) byte {
+	fast_ClockBefore(addr)
+	return dataBUS_Read(addr)
+}
+
+func fast_WriteBus(addr uint16, value byte) {
+	fast_ClockBefore(addr)
+	dataBUS_Write(addr, value)
+}
+
+// The data accesses happen on the last cycle of the instruction: the devices
+// run the cycles before it when the access reaches one of them
+func fast_ClockBefore(addr uint16) {
+	if device_index[addr] != 0 && fast_clocked < fast_cycles-1 {
+		fast_Clock(fast_cycles - 1 - fast_clocked)
+		fast_clocked = fast_cycles - 1
+	}
+}
+
+// ----------------------------------- Flags ------------------------------------ //
+
+func fast_Z(value byte) {
+	if value == 0 {
+		P[1] = 1
+	} else {
+		P[1] = 0
+	}
+}
+
+func fast_ZN(value byte) {
+	fast_Z(value)
+	P[7] = value >> 7
+}
+
+// CMP, CPX and CPY
+func fast_Compare(register, value byte) {
+	fast_ZN(register - value)
+	if register >= value {
+		P[0] = 1
+	} else {
+		P[0] = 0
+	}
+}
+
+// PLP and RTI ignore the bits 5 and 4
+func fast_PullP(value byte) {
+	B, unused := P[4], P[5]
+	UnpackP(value)
+	P[4], P[5] = B, unused
+}
+
+// Overflow of A + value + carry (the carries of bits 6 and 7 differ)
+func fast_V(a, value, carry byte) {
+	result := a + value + carry
+	P[6] = (a ^ result) & (value ^ result) >> 7
+}
+
+func fast_ADC(value byte) {
+
+	a, carry := A, P[0]
+
+	fast_V(a, value, carry)
+
+	if P[3] == 0 {
+		sum := uint16(a) + uint16(value) + uint16(carry)
+		A = byte(sum)
+		P[0] = byte(sum >> 8)
+	} else {
+		result := bin_to_bcd[bcd_to_bin[a]+bcd_to_bin[value]+carry]
+		A = byte(result)
+		P[0] = byte(result >> 8 & 0x01)
+	}
+
+	fast_ZN(A)
+}
+
+func fast_SBC(value byte) {
+
+	if P[3] == 0 {
+		fast_ADC(^value)
+		return
+	}
+
+	// Overflow of the binary sum, like opc_SBC in decimal mode
+	fast_V(A, value, P[0])
+
+	result := int(bcd_to_bin[A]) - int(bcd_to_bin[value]) - int(P[0]^1)
+	if result < 0 {
+		A = byte(bin_to_bcd[result+100])
+		P[0], P[7] = 0, 1
+	} else {
+		A = byte(bin_to_bcd[result])
+		P[0], P[7] = 1, 0
+	}
+
+	fast_Z(A)
+}
@@ -0,0 +1,214 @@
+package CPU_6502
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// ------------------------ Run and Step comparison ------------------------- //
+
+// Device logging its accesses and clock cycles
+type test_device struct {
+	log []string
+}
+
+func (d *test_device) Read(offset uint16) byte {
+	d.log = append(d.log, fmt.Sprintf("read %02X at %d", offset, Cycle))
+	return byte(offset * 7)
+}
+
+func (d *test_device) Write(offset uint16, value byte) {
+	d.log = append(d.log, fmt.Sprintf("write %02X=%02X at %d", offset, value, Cycle))
+}
+
+func (d *test_device) Clock() {
+	d.log = append(d.log, fmt.Sprintf("clock at %d", Cycle))
+}
+
+// Device running several cycles per call, the clocks are logged without the cycle
+type test_multi_device struct {
+	test_device
+}
+
+func (d *test_multi_device) Clock() {
+	d.log = append(d.log, "clock")
+}
+
+func (d *test_multi_device) ClockCycles(cycles int) {
+	for ; cycles > 0; cycles-- {
+		d.Clock()
+	}
+}
+
+// CPU state compared after an instruction
+type test_state struct {
+	Memory          [65536]byte
+	PC              uint16
+	A, X, Y, SP     byte
+	P               [8]byte
+	Cycle, IPS      uint64
+	Halted          bool
+	Opc_cycle_count byte
+}
+
+func testSave() test_state {
+	return test_state{Memory, PC, A, X, Y, SP, P, Cycle, IPS, Halted, Opc_cycle_count}
+}
+
+func testLoad(s *test_state) {
+	Memory, PC, A, X, Y, SP, P, Cycle, IPS, Halted, Opc_cycle_count = s.Memory, s.PC, s.A, s.X, s.Y, s.SP, s.P, s.Cycle, s.IPS, s.Halted, s.Opc_cycle_count
+	Opc_cycle_extra = 0
+	NewInstruction = false
+	memValue = 0
+}
+
+// Every opcode from random states gives the same registers, flags, memory,
+// cycles and device accesses with Step (cycle interpreter) and Run(1)
+func TestRunMatchesStep(t *testing.T) {
+
+	Debug = false
+	CPU_MODE = 1
+	Initialize()
+	defer Initialize()
+
+	r := rand.New(rand.NewSource(1))
+
+	var base [65536]byte
+	r.Read(base[:])
+
+	single, multi := &test_device{}, &test_multi_device{}
+
+	for _, dev := range []interface {
+		Device
+		Clocked
+	}{nil, single, multi} {
+
+		attached := dev != nil
+		log := &single.log
+		if dev == multi {
+			log = &multi.log
+		}
+		if attached {
+			if err := AttachDevice(dev, 0x4000, 0x100); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for opc := 0; opc < 256; opc++ {
+			for i := 0; i < 200; i++ {
+
+				s := test_state{Memory: base, Opc_cycle_count: 1}
+				s.PC = uint16(r.Intn(0x10000))
+				if i%4 == 0 {
+					s.PC |= 0x00FE // Branches and operands at the end of a page
+				}
+				if attached && s.PC>>8 == 0x40 {
+					s.PC ^= 0x8000 // Code doesn't run from the device
+				}
+
+				// Instruction, zero page and stack
+				s.Memory[s.PC] = byte(opc)
+				r.Read(s.Memory[0x0000:0x0200])
+				s.Memory[s.PC+1], s.Memory[s.PC+2] = byte(r.Int()), byte(r.Int())
+				if attached && i%2 == 0 {
+					s.Memory[s.PC+2] = 0x40 // Absolute modes on the device
+					for zp := 1; zp < 256; zp += 2 {
+						s.Memory[zp] = 0x40 // Indirect modes on the device
+					}
+				}
+
+				// The cycle interpreter exits on a JMP indirect vector at the end of a page
+				if opc == 0x6C && (s.Memory[s.PC+1] == 0xFF || s.Memory[s.PC+2] == 0xFF) {
+					s.Memory[s.PC+1] = 0x00
+				}
+
+				s.A, s.X, s.Y, s.SP = byte(r.Int()), byte(r.Int()), byte(r.Int()), byte(r.Int())
+				for f := range s.P {
+					s.P[f] = byte(r.Intn(2))
+				}
+				s.P[5] = 1
+				if i%2 == 0 {
+					s.P[3] = 1 // Decimal mode
+				}
+				s.Cycle = uint64(r.Intn(1000))
+
+				testLoad(&s)
+				*log = nil
+				Step()
+				step, step_log := testSave(), fmt.Sprint(*log)
+
+				testLoad(&s)
+				*log = nil
+				Run(1)
+				run, run_log := testSave(), fmt.Sprint(*log)
+
+				if step != run || step_log != run_log {
+					t.Fatalf("opcode $%02X at $%04X (device %T): Step and Run differ\n"+
+						"Step: PC=$%04X A=$%02X X=$%02X Y=$%02X SP=$%02X P=%v Cycle=%d IPS=%d %s\n"+
+						"Run:  PC=$%04X A=$%02X X=$%02X Y=$%02X SP=$%02X P=%v Cycle=%d IPS=%d %s\n"+
+						"same memory: %v",
+						opc, s.PC, dev,
+						step.PC, step.A, step.X, step.Y, step.SP, step.P, step.Cycle, step.IPS, step_log,
+						run.PC, run.A, run.X, run.Y, run.SP, run.P, run.Cycle, run.IPS, run_log,
+						step.Memory == run.Memory)
+				}
+			}
+		}
+
+		if attached {
+			DetachDevice(dev)
+		}
+	}
+}
+
+// The interrupt sequences give the same state with Step and Run(1)
+func TestRunMatchesStepInterrupt(t *testing.T) {
+
+	Debug = false
+	CPU_MODE = 1
+	Initialize()
+	defer Initialize()
+
+	r := rand.New(rand.NewSource(2))
+
+	irq, nmi := NewIRQLine(), NewNMILine()
+	defer irq.Set(false)
+
+	for i := 0; i < 1000; i++ {
+
+		s := test_state{Opc_cycle_count: 1}
+		r.Read(s.Memory[:])
+		s.PC = uint16(r.Intn(0x10000))
+		s.A, s.X, s.Y, s.SP = byte(r.Int()), byte(r.Int()), byte(r.Int()), byte(r.Int())
+		for f := range s.P {
+			s.P[f] = byte(r.Intn(2))
+		}
+		s.P[5] = 1
+		s.P[2] = 0
+		s.Cycle = uint64(r.Intn(1000))
+
+		var states [2]test_state
+		for k, f := range []func(){func() { Step() }, func() { Run(1) }} {
+			testLoad(&s)
+			irq.Set(i%2 == 0)
+			nmi.Set(false)
+			nmi.Set(i%2 == 1)
+			f()
+			states[k] = testSave()
+		}
+		step, run := states[0], states[1]
+
+		if step != run {
+			t.Fatalf("interrupt %d at $%04X: Step and Run differ\n"+
+				"Step: PC=$%04X SP=$%02X P=%v Cycle=%d IPS=%d\n"+
+				"Run:  PC=$%04X SP=$%02X P=%v Cycle=%d IPS=%d\n"+
+				"same memory: %v",
+				i, s.PC, step.PC, step.SP, step.P, step.Cycle, step.IPS, run.PC, run.SP, run.P, run.Cycle, run.IPS,
+				step.Memory == run.Memory)
+		}
+		if run.Cycle != s.Cycle+7 {
+			t.Fatalf("interrupt %d: %d cycles, expected 7", i, run.Cycle-s.Cycle)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package CPU_6502
+
+// ----------------------------- Hooks ------------------------------ //
+
+// Interrupt sources reported to the Interrupt hook
+type Interrupt byte
+
+const (
+	INT_BRK Interrupt = iota // BRK instruction
+	INT_IRQ                  // Maskable interrupt request
+	INT_NMI                  // Non maskable interrupt
+)
+
+func (i Interrupt) String() string {
+	switch i {
+	case INT_BRK:
+		return "BRK"
+	case INT_IRQ:
+		return "IRQ"
+	case INT_NMI:
+		return "NMI"
+	}
+	return "Unknown"
+}
+
+// Hooks lets debuggers, tracers and profilers observe the CPU
+// Any of the functions can be nil
+type Hooks struct {
+	// Called on the first cycle of each instruction, before executing it
+	// Returning true holds the CPU: the cycle is not executed and Pause is set
+	Instruction func(pc uint16, opcode byte) bool
+
+	// Data BUS accesses made by the instructions
+	Read  func(addr uint16, value byte)
+	Write func(addr uint16, old, value byte)
+
+	// Start of an interrupt sequence, on its first cycle (before the pushes)
+	InterruptStart func(pc uint16)
+
+	// Interrupt entry, called after the vector was loaded into PC
+	Interrupt func(source Interrupt, vector uint16, return_addr uint16)
+
+	// Memory map violations, with the VIOLATION_EVENT policy
+	Violation func(v MemoryViolation)
+}
+
+var (
+	hooks        []*Hooks
+	hooks_active bool // Single check in the interpreter hot path
+	hooks_held   bool // Last instruction hook returned true
+)
+
+// AttachHooks registers a set of hooks
+func AttachHooks(h *Hooks) {
+	hooks = append(hooks, h)
+	hooks_active = true
+}
+
+// DetachHooks removes a set of hooks previously attached
+func DetachHooks(h *Hooks) {
+	for i := range hooks {
+		if hooks[i] == h {
+			hooks = append(hooks[:i], hooks[i+1:]...)
+			break
+		}
+	}
+	hooks_active = len(hooks) > 0
+}
+
+// Call the Instruction hooks, returns true if any of them asked to hold the CPU
+func hooks_Instruction(pc uint16, opc byte) bool {
+
+	hold := false
+
+	for _, h := range hooks {
+		if h.Instruction != nil && h.Instruction(pc, opc) {
+			hold = true
+		}
+	}
+
+	hooks_held = hold
+
+	if hold {
+		Pause = true
+	}
+
+	return hold
+}
+
+func hooks_Read(addr uint16, value byte) {
+	for _, h := range hooks {
+		if h.Read != nil {
+			h.Read(addr, value)
+		}
+	}
+}
+
+func hooks_Write(addr uint16, old, value byte) {
+	for _, h := range hooks {
+		if h.Write != nil {
+			h.Write(addr, old, value)
+		}
+	}
+}
+
+func hooks_InterruptStart(pc uint16) {
+	for _, h := range hooks {
+		if h.InterruptStart != nil {
+			h.InterruptStart(pc)
+		}
+	}
+}
+
+func hooks_Interrupt(source Interrupt, vector uint16, return_addr uint16) {
+	for _, h := range hooks {
+		if h.Interrupt != nil {
+			h.Interrupt(source, vector, return_addr)
+		}
+	}
+}
+
+func hooks_Violation(v MemoryViolation) {
+	for _, h := range hooks {
+		if h.Violation != nil {
+			h.Violation(v)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package CPU_6502
+
+import "fmt"
+
+// ----------------------------- Interrupts ----------------------------- //
+//
+// The IRQ input is level triggered and shared (open collector): it is active
+// while any of the lines created with NewIRQLine is active. NMI is edge
+// triggered: a NewNMILine line going active requests one interrupt.
+//
+// The requests are taken between two instructions: the interrupt sequence
+// takes 7 cycles, pushes PC and P (with B clear), sets I and loads PC from
+// $FFFE (IRQ) or $FFFA (NMI). An NMI arriving during an IRQ sequence takes
+// over its vector.
+
+// InterruptLine is an interrupt output of a device connected to the CPU
+type InterruptLine struct {
+	nmi    bool
+	active bool
+}
+
+var (
+	irq_active  int  // Number of IRQ lines active
+	nmi_pending bool // NMI edge not serviced yet
+
+	interrupt_cycle int // Cycle of the interrupt sequence in progress (0 = none)
+)
+
+// NewIRQLine creates a line connected to the IRQ input
+func NewIRQLine() *InterruptLine {
+	return &InterruptLine{}
+}
+
+// NewNMILine creates a line connected to the NMI input
+func NewNMILine() *InterruptLine {
+	return &InterruptLine{nmi: true}
+}
+
+// Set changes the state of the line
+func (l *InterruptLine) Set(active bool) {
+
+	if l.active == active {
+		return
+	}
+
+	l.active = active
+
+	if l.nmi {
+		if active {
+			nmi_pending = true
+		}
+		return
+	}
+
+	if active {
+		irq_active++
+	} else {
+		irq_active--
+	}
+}
+
+// Active returns the state of the line
+func (l *InterruptLine) Active() bool {
+	return l.active
+}
+
+// IRQ returns true while the IRQ input is active
+func IRQ() bool {
+	return irq_active > 0
+}
+
+// Interrupt taken at the next instruction boundary
+func interruptPending() bool {
+	return nmi_pending || (irq_active > 0 && P[2] == 0)
+}
+
+// One cycle of the interrupt sequence, the pushes and the vector fetch happen on the last one
+func cpu_Interrupt() {
+
+	interrupt_cycle++
+
+	if interrupt_cycle < 7 {
+		return
+	}
+
+	interrupt_cycle = 0
+
+	source, vector := INT_IRQ, uint16(0xFFFE)
+	if nmi_pending {
+		source, vector = INT_NMI, 0xFFFA
+		nmi_pending = false
+	}
+
+	return_addr := PC
+
+	SP_Address := uint16(SP) + 256
+	_ = dataBUS_Write(SP_Address, byte(PC>>8))
+	SP--
+	SP_Address--
+	_ = dataBUS_Write(SP_Address, byte(PC&0xFF))
+	SP--
+	SP_Address--
+
+	// P with B clear and bit 5 set
+	var tmp_P byte
+	for i := 7; i >= 0; i-- {
+		switch i {
+		case 5:
+			tmp_P = tmp_P<<1 + 1
+		case 4:
+			tmp_P = tmp_P << 1
+		default:
+			tmp_P = tmp_P<<1 + P[i]
+		}
+	}
+	_ = dataBUS_Write(SP_Address, tmp_P)
+	SP--
+
+	PC = uint16(dataBUS_Read(vector+1))<<8 | uint16(dataBUS_Read(vector))
+
+	flags_I(1)
+
+	if Debug {
+		dbg_show_message = fmt.Sprintf("\n\t%s interrupt.\tPush PC 0x%04X and P 0x%02X (%08b) to Stack\t\tNew PC = 0x%04X\n", source, return_addr, tmp_P, tmp_P, PC)
+		fmt.Println(dbg_show_message)
+	}
+
+	// The sequence counts as an instruction for Step()
+	resetIntOpcCycleCounters()
+
+	if hooks_active {
+		hooks_Interrupt(source, PC, return_addr)
+	}
+}
+
+// Interrupt state for the snapshots (the lines are saved by their devices)
+func init() {
+	RegisterSnapshotChunk("INT ", func() []byte {
+		pending := byte(0)
+		if nmi_pending {
+			pending = 1
+		}
+		return []byte{pending, byte(interrupt_cycle)}
+	}, func(data []byte) error {
+		if len(data) != 2 || data[1] > 6 {
+			return fmt.Errorf("invalid interrupt state")
+		}
+		nmi_pending = data[0] != 0
+		interrupt_cycle = int(data[1])
+		return nil
+	})
+}
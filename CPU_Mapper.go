@@ -0,0 +1,31 @@
+package CPU_6502
+
+// ------------------------------ Mappers ------------------------------- //
+//
+// A mapper (cartridge or board logic) sees the data BUS accesses of the
+// instructions to switch banks. The banks are copied into Memory, so opcode
+// and operand fetches, the disassembler and the debuggers see the mapped
+// bytes. The mappers package implements the common schemes.
+
+// Mapper intercepts the data BUS accesses
+type Mapper interface {
+	// Called after a read, with the value on the data BUS
+	Read(addr uint16, value byte)
+
+	// Called before a write, returns true when the mapper consumed it
+	// (ROM or mapper register): Memory is not changed and the memory map
+	// permissions are not checked
+	Write(addr uint16, value byte) bool
+}
+
+var bus_mapper Mapper
+
+// SetMapper installs the mapper of the machine (nil removes it)
+func SetMapper(m Mapper) {
+	bus_mapper = m
+}
+
+// CurrentMapper returns the installed mapper (nil if none)
+func CurrentMapper() Mapper {
+	return bus_mapper
+}
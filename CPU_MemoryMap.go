@@ -0,0 +1,244 @@
+package CPU_6502
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------------------------- Memory Map ----------------------------- //
+//
+// Hosts declare regions of the address space with their permissions, checked
+// on the data BUS accesses of the instructions and on the opcode fetch.
+// Addresses outside the declared regions are RAM. Violations follow the
+// hardware behaviour: writes without permission are ignored (the ROM keeps its
+// image), reads without permission return the open bus value and fetches from
+// regions without execute permission run normally. With VIOLATION_EVENT they
+// are also reported to the Violation hooks (the debugger stops on them).
+// ReadMemory, WriteMemory and the loaders (without LoadThroughBus) bypass the
+// memory map.
+
+// RegionKind is the type of a memory region
+type RegionKind byte
+
+const (
+	REGION_RAM      RegionKind = iota // Read, write and execute
+	REGION_ROM                        // Read and execute
+	REGION_IO                         // Device registers, read and write
+	REGION_UNMAPPED                   // Nothing answers, reads return the open bus value
+)
+
+func (k RegionKind) String() string {
+	switch k {
+	case REGION_RAM:
+		return "RAM"
+	case REGION_ROM:
+		return "ROM"
+	case REGION_IO:
+		return "IO"
+	case REGION_UNMAPPED:
+		return "unmapped"
+	}
+	return "Unknown"
+}
+
+// Permission flags of a region
+type Permission byte
+
+const (
+	PERM_READ Permission = 1 << iota
+	PERM_WRITE
+	PERM_EXEC
+)
+
+func (p Permission) String() string {
+	s := []byte("---")
+	if p&PERM_READ != 0 {
+		s[0] = 'r'
+	}
+	if p&PERM_WRITE != 0 {
+		s[1] = 'w'
+	}
+	if p&PERM_EXEC != 0 {
+		s[2] = 'x'
+	}
+	return string(s)
+}
+
+// Region is a range of addresses (inclusive) with its permissions
+type Region struct {
+	Name  string
+	Start uint16
+	End   uint16
+	Kind  RegionKind
+	Perm  Permission
+}
+
+func (r Region) String() string {
+	name := r.Name
+	if name == "" {
+		name = strings.ToLower(r.Kind.String())
+	}
+	return fmt.Sprintf("$%04X-$%04X %-8s %s %s", r.Start, r.End, r.Kind, r.Perm, name)
+}
+
+// NewRegion returns a region with the default permissions of its kind
+func NewRegion(name string, start, end uint16, kind RegionKind) Region {
+
+	perm := map[RegionKind]Permission{
+		REGION_RAM: PERM_READ | PERM_WRITE | PERM_EXEC,
+		REGION_ROM: PERM_READ | PERM_EXEC,
+		REGION_IO:  PERM_READ | PERM_WRITE,
+	}[kind]
+
+	return Region{Name: name, Start: start, End: end, Kind: kind, Perm: perm}
+}
+
+// Access types reported in the violations
+type Access byte
+
+const (
+	ACCESS_READ Access = iota
+	ACCESS_WRITE
+	ACCESS_EXEC
+)
+
+func (a Access) String() string {
+	switch a {
+	case ACCESS_READ:
+		return "read"
+	case ACCESS_WRITE:
+		return "write"
+	case ACCESS_EXEC:
+		return "execute"
+	}
+	return "Unknown"
+}
+
+// MemoryViolation describes an access without permission
+type MemoryViolation struct {
+	Access  Access
+	Address uint16
+	Value   byte   // Value written, or returned by the read (open bus)
+	PC      uint16 // Instruction that made the access
+	Region  Region
+}
+
+func (v MemoryViolation) String() string {
+
+	name := v.Region.Name
+	if name == "" {
+		name = strings.ToLower(v.Region.Kind.String())
+	}
+
+	switch v.Access {
+	case ACCESS_WRITE:
+		return fmt.Sprintf("write $%02X to $%04X (%s %s) by $%04X", v.Value, v.Address, name, v.Region.Perm, v.PC)
+	case ACCESS_READ:
+		return fmt.Sprintf("read $%04X (%s %s) by $%04X, open bus $%02X", v.Address, name, v.Region.Perm, v.PC, v.Value)
+	}
+
+	return fmt.Sprintf("execute $%04X (%s %s)", v.Address, name, v.Region.Perm)
+}
+
+// ViolationPolicy chooses what happens on a violation
+type ViolationPolicy byte
+
+const (
+	VIOLATION_HARDWARE ViolationPolicy = iota // Hardware behaviour only
+	VIOLATION_EVENT                           // Hardware behaviour and Violation hooks
+)
+
+var (
+	MemoryViolations ViolationPolicy // Policy for the violations
+
+	memory_regions []Region
+	memory_region  [65536]byte       // Index of the region + 1 (0 for RAM outside the regions)
+	memory_perm    [65536]Permission // Valid while memory_mapped
+	memory_mapped  bool              // Single check in the data BUS hot path
+	exec_reported  bool              // Execute violation of the current instruction already reported
+)
+
+// MapRegion declares a region, overriding the overlapping parts of the previous ones
+func MapRegion(r Region) error {
+
+	if r.End < r.Start {
+		return fmt.Errorf("region end $%04X lower than start $%04X", r.End, r.Start)
+	}
+	if len(memory_regions) == 255 {
+		return fmt.Errorf("too many memory regions")
+	}
+
+	// Addresses outside the regions are RAM
+	if !memory_mapped {
+		for i := range memory_perm {
+			memory_perm[i] = PERM_READ | PERM_WRITE | PERM_EXEC
+		}
+	}
+
+	memory_regions = append(memory_regions, r)
+
+	for addr := int(r.Start); addr <= int(r.End); addr++ {
+		memory_region[addr] = byte(len(memory_regions))
+		memory_perm[addr] = r.Perm
+	}
+
+	memory_mapped = true
+
+	return nil
+}
+
+// ClearMemoryMap removes all the regions, the whole memory is RAM again
+func ClearMemoryMap() {
+	memory_regions = nil
+	memory_region = [65536]byte{}
+	memory_mapped = false
+}
+
+// MemoryMap returns the regions in the order they were declared
+func MemoryMap() []Region {
+	return append([]Region(nil), memory_regions...)
+}
+
+// RegionAt returns the region of an address (false for RAM outside the regions)
+func RegionAt(addr uint16) (Region, bool) {
+
+	i := memory_region[addr]
+	if i == 0 {
+		return NewRegion("", addr, addr, REGION_RAM), false
+	}
+
+	return memory_regions[i-1], true
+}
+
+// Value read from addresses nothing drives: the last byte on the data BUS,
+// usually the high byte of the address fetched just before the access
+func openBus(addr uint16) byte {
+	return byte(addr >> 8)
+}
+
+// Report an access without permission, returns the value seen on the data BUS
+func memoryViolation(access Access, addr uint16, value byte) byte {
+
+	if access == ACCESS_READ {
+		value = openBus(addr)
+	}
+
+	if MemoryViolations == VIOLATION_EVENT && hooks_active {
+		region, _ := RegionAt(addr)
+		hooks_Violation(MemoryViolation{Access: access, Address: addr, Value: value, PC: PC, Region: region})
+	}
+
+	return value
+}
+
+// Execute permission of the opcode fetched, reported once per instruction
+// (the Instruction hooks may hold and repeat the first cycle)
+func checkExecute() {
+
+	if memory_perm[PC]&PERM_EXEC != 0 || exec_reported {
+		return
+	}
+
+	exec_reported = true
+	memoryViolation(ACCESS_EXEC, PC, Memory[PC])
+}
@@ -0,0 +1,318 @@
+package CPU_6502
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// ------------------------------ Snapshots ------------------------------ //
+//
+// Binary format (little endian):
+//
+//	Header: "6502SNAP" magic, uint16 version
+//	Chunks: 4 bytes tag, uint32 length, data
+//
+// The core writes the "CPU " (registers, flags, halt state and the counters
+// of the instruction in progress) and "MEM " (64KB) chunks. Mappers and
+// devices add their own state with RegisterSnapshotChunk.
+
+const (
+	snapshot_magic = "6502SNAP"
+
+	// SnapshotVersion is the version of the snapshot format written by Snapshot
+	// 2: Halted in the CPU chunk
+	SnapshotVersion uint16 = 2
+)
+
+// CPU state, including the partial-instruction counters
+type snapshot_cpu struct {
+	CPU_MODE        byte
+	PC              uint16
+	A               byte
+	X               byte
+	Y               byte
+	SP              byte
+	P               [8]byte
+	Opcode          byte
+	Opc_cycles      byte
+	Opc_bytes       uint16
+	Opc_cycle_count byte
+	Opc_cycle_extra byte
+	NewInstruction  bool
+	Cycle           uint64
+	AddressBUS      uint16
+	MemValue        int8
+	CPU_Enabled     bool
+	PC_as_argument  uint16
+	Halted          bool
+}
+
+type snapshot_chunk struct {
+	tag     string
+	save    func() []byte
+	restore func(data []byte) error
+}
+
+var snapshot_chunks []snapshot_chunk
+
+// RegisterSnapshotChunk adds the state of a mapper or device to the snapshots
+// tag must have 4 characters, registering a tag again replaces its functions
+func RegisterSnapshotChunk(tag string, save func() []byte, restore func(data []byte) error) {
+
+	if len(tag) != 4 {
+		panic(fmt.Sprintf("snapshot chunk tag %q must have 4 characters", tag))
+	}
+
+	for i := range snapshot_chunks {
+		if snapshot_chunks[i].tag == tag {
+			snapshot_chunks[i] = snapshot_chunk{tag, save, restore}
+			return
+		}
+	}
+
+	snapshot_chunks = append(snapshot_chunks, snapshot_chunk{tag, save, restore})
+}
+
+// UnregisterSnapshotChunk removes a chunk registered with RegisterSnapshotChunk
+func UnregisterSnapshotChunk(tag string) {
+	for i := range snapshot_chunks {
+		if snapshot_chunks[i].tag == tag {
+			snapshot_chunks = append(snapshot_chunks[:i], snapshot_chunks[i+1:]...)
+			return
+		}
+	}
+}
+
+// SnapshotChunk is the state of a registered chunk
+type SnapshotChunk struct {
+	Tag  string
+	Data []byte
+}
+
+// SaveChunks appends the state of the registered chunks (mappers, devices and
+// interrupts) to dst, without the CPU and the memory
+func SaveChunks(dst []SnapshotChunk) []SnapshotChunk {
+	for _, c := range snapshot_chunks {
+		dst = append(dst, SnapshotChunk{c.tag, c.save()})
+	}
+	return dst
+}
+
+// RestoreChunks restores chunks saved by SaveChunks, skipping the tags not registered anymore
+func RestoreChunks(chunks []SnapshotChunk) error {
+
+	for _, saved := range chunks {
+		for _, c := range snapshot_chunks {
+			if c.tag == saved.Tag {
+				if err := c.restore(saved.Data); err != nil {
+					return fmt.Errorf("snapshot chunk %q: %v", saved.Tag, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Snapshot serializes the complete machine state
+// It can be taken at any cycle, also in the middle of an instruction
+func Snapshot() []byte {
+
+	var buf bytes.Buffer
+
+	buf.WriteString(snapshot_magic)
+	_ = binary.Write(&buf, binary.LittleEndian, SnapshotVersion)
+
+	cpu := snapshot_cpu{
+		CPU_MODE:        CPU_MODE,
+		PC:              PC,
+		A:               A,
+		X:               X,
+		Y:               Y,
+		SP:              SP,
+		P:               P,
+		Opcode:          opcode,
+		Opc_cycles:      Opc_cycles,
+		Opc_bytes:       Opc_bytes,
+		Opc_cycle_count: Opc_cycle_count,
+		Opc_cycle_extra: Opc_cycle_extra,
+		NewInstruction:  NewInstruction,
+		Cycle:           Cycle,
+		AddressBUS:      AddressBUS,
+		MemValue:        memValue,
+		CPU_Enabled:     CPU_Enabled,
+		PC_as_argument:  PC_as_argument,
+		Halted:          Halted,
+	}
+
+	var cpu_data bytes.Buffer
+	_ = binary.Write(&cpu_data, binary.LittleEndian, &cpu)
+
+	writeSnapshotChunk(&buf, "CPU ", cpu_data.Bytes())
+	writeSnapshotChunk(&buf, "MEM ", Memory[:])
+
+	for _, c := range snapshot_chunks {
+		writeSnapshotChunk(&buf, c.tag, c.save())
+	}
+
+	return buf.Bytes()
+}
+
+func writeSnapshotChunk(buf *bytes.Buffer, tag string, data []byte) {
+	buf.WriteString(tag)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+// Restore loads a state saved with Snapshot
+// The snapshot is validated before any change, and if a chunk handler fails
+// the previous state is restored, so on error the state is untouched
+func Restore(data []byte) error {
+
+	snap, err := parseSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	backup, _ := parseSnapshot(Snapshot())
+
+	if err := snap.apply(); err != nil {
+		_ = backup.apply()
+		return err
+	}
+
+	return nil
+}
+
+// Validated snapshot, ready to be applied
+type snapshot_state struct {
+	cpu    snapshot_cpu
+	mem    []byte
+	chunks map[string][]byte
+	order  []string
+}
+
+func parseSnapshot(data []byte) (*snapshot_state, error) {
+
+	if len(data) < len(snapshot_magic)+2 || string(data[:len(snapshot_magic)]) != snapshot_magic {
+		return nil, fmt.Errorf("not a 6502 snapshot")
+	}
+
+	version := binary.LittleEndian.Uint16(data[len(snapshot_magic):])
+	if version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (expected %d)", version, SnapshotVersion)
+	}
+
+	// Split the chunks
+	chunks := map[string][]byte{}
+	var order []string
+
+	for rest := data[len(snapshot_magic)+2:]; len(rest) > 0; {
+
+		if len(rest) < 8 {
+			return nil, fmt.Errorf("truncated snapshot chunk header")
+		}
+
+		tag := string(rest[:4])
+		size := binary.LittleEndian.Uint32(rest[4:8])
+		rest = rest[8:]
+
+		if uint64(size) > uint64(len(rest)) {
+			return nil, fmt.Errorf("truncated snapshot chunk %q", tag)
+		}
+
+		if _, ok := chunks[tag]; ok {
+			return nil, fmt.Errorf("duplicated snapshot chunk %q", tag)
+		}
+
+		chunks[tag] = rest[:size]
+		order = append(order, tag)
+		rest = rest[size:]
+	}
+
+	// Validate the core chunks
+	var cpu snapshot_cpu
+
+	cpu_data, ok := chunks["CPU "]
+	if !ok {
+		return nil, fmt.Errorf("snapshot without CPU state")
+	}
+	if len(cpu_data) != binary.Size(&cpu) {
+		return nil, fmt.Errorf("invalid CPU state size %d", len(cpu_data))
+	}
+	_ = binary.Read(bytes.NewReader(cpu_data), binary.LittleEndian, &cpu)
+
+	mem, ok := chunks["MEM "]
+	if !ok {
+		return nil, fmt.Errorf("snapshot without memory")
+	}
+	if len(mem) != len(Memory) {
+		return nil, fmt.Errorf("invalid memory size %d", len(mem))
+	}
+
+	// Every other chunk needs a registered handler
+	handlers := map[string]snapshot_chunk{}
+	for _, c := range snapshot_chunks {
+		handlers[c.tag] = c
+	}
+	for _, tag := range order {
+		if _, ok := handlers[tag]; !ok && tag != "CPU " && tag != "MEM " {
+			return nil, fmt.Errorf("snapshot chunk %q has no registered handler", tag)
+		}
+	}
+
+	return &snapshot_state{cpu: cpu, mem: mem, chunks: chunks, order: order}, nil
+}
+
+// Applies the core state and calls the chunk handlers in the snapshot order
+func (snap *snapshot_state) apply() error {
+
+	cpu := snap.cpu
+
+	CPU_MODE = cpu.CPU_MODE
+	PC, A, X, Y, SP, P = cpu.PC, cpu.A, cpu.X, cpu.Y, cpu.SP, cpu.P
+	opcode = cpu.Opcode
+	Opc_cycles = cpu.Opc_cycles
+	Opc_bytes = cpu.Opc_bytes
+	Opc_cycle_count = cpu.Opc_cycle_count
+	Opc_cycle_extra = cpu.Opc_cycle_extra
+	NewInstruction = cpu.NewInstruction
+	Cycle = cpu.Cycle
+	AddressBUS = cpu.AddressBUS
+	memValue = cpu.MemValue
+	CPU_Enabled = cpu.CPU_Enabled
+	PC_as_argument = cpu.PC_as_argument
+	Halted = cpu.Halted
+
+	copy(Memory[:], snap.mem)
+
+	for _, tag := range snap.order {
+		for _, c := range snapshot_chunks {
+			if c.tag == tag {
+				if err := c.restore(snap.chunks[tag]); err != nil {
+					return fmt.Errorf("snapshot chunk %q: %v", tag, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// SaveSnapshot writes a snapshot to a file
+func SaveSnapshot(filename string) error {
+	return os.WriteFile(filename, Snapshot(), 0644)
+}
+
+// LoadSnapshot restores a snapshot from a file
+func LoadSnapshot(filename string) error {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	return Restore(data)
+}
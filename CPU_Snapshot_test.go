@@ -0,0 +1,152 @@
+package CPU_6502
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ------------------------------- Snapshots -------------------------------- //
+
+// Adds $10 to A and counts in $12, forever
+var test_snapshot_program = []byte{
+	0x18,       // $0200 CLC
+	0x65, 0x10, // $0201 ADC $10
+	0x85, 0x11, // $0203 STA $11
+	0xE6, 0x12, // $0205 INC $12
+	0x4C, 0x01, 0x02, // $0207 JMP $0201
+}
+
+func testSnapshotCPU(t *testing.T) {
+
+	Debug = false
+	CPU_MODE = 1
+	Initialize()
+	copy(Memory[0x0200:], test_snapshot_program)
+	Memory[0x10] = 0x07
+	PC = 0x0200
+
+	for i := 0; i < 100; i++ {
+		CPU_Interpreter()
+	}
+}
+
+// A snapshot taken in the middle of an instruction runs exactly like the original
+func TestSnapshotRoundTrip(t *testing.T) {
+
+	testSnapshotCPU(t)
+
+	// Stop in the middle of an instruction
+	CPU_Interpreter()
+	for Opc_cycle_count == 1 {
+		CPU_Interpreter()
+	}
+
+	saved := Snapshot()
+
+	for i := 0; i < 50; i++ {
+		CPU_Interpreter()
+	}
+	want := Snapshot()
+
+	Initialize()
+	if err := Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Snapshot(), saved) {
+		t.Fatalf("state after Restore differs from the snapshot")
+	}
+
+	for i := 0; i < 50; i++ {
+		CPU_Interpreter()
+	}
+	if !bytes.Equal(Snapshot(), want) {
+		t.Errorf("execution after Restore differs: PC $%04X A $%02X cycle %d", PC, A, Cycle)
+	}
+}
+
+// A failing chunk handler leaves the state untouched
+func TestSnapshotRollback(t *testing.T) {
+
+	testSnapshotCPU(t)
+
+	state := "bad"
+	RegisterSnapshotChunk("TEST",
+		func() []byte { return []byte(state) },
+		func(data []byte) error {
+			if string(data) == "bad" {
+				return errors.New("bad state")
+			}
+			state = string(data)
+			return nil
+		})
+	t.Cleanup(func() { UnregisterSnapshotChunk("TEST") })
+
+	bad := Snapshot()
+
+	state = "good"
+	PC, Memory[0x11] = 0x1234, 0x55
+
+	if err := Restore(bad); err == nil || !strings.Contains(err.Error(), "TEST") {
+		t.Fatalf("error %v, want the TEST chunk error", err)
+	}
+	if PC != 0x1234 || Memory[0x11] != 0x55 || state != "good" {
+		t.Errorf("state changed by a failed Restore: PC $%04X, $11=$%02X, chunk %q", PC, Memory[0x11], state)
+	}
+
+	// Saved chunks skip the tags not registered anymore
+	chunks := SaveChunks(nil)
+	UnregisterSnapshotChunk("TEST")
+	if err := RestoreChunks(chunks); err != nil {
+		t.Errorf("RestoreChunks: %v", err)
+	}
+}
+
+// Invalid snapshots are rejected before any change
+func TestSnapshotValidation(t *testing.T) {
+
+	testSnapshotCPU(t)
+
+	good := Snapshot()
+	pc := PC
+
+	chunk := func(tag string, data []byte) []byte {
+		b := append([]byte(tag), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+		return append(b, data...)
+	}
+	header := good[:len(snapshot_magic)+2]
+	snap, _ := parseSnapshot(good)
+	cpu := chunk("CPU ", snap.chunks["CPU "])
+
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"magic", join([]byte("6502SNAX"), good[8:]), "not a 6502 snapshot"},
+		{"version", join([]byte(snapshot_magic), []byte{0xFF, 0xFF}, good[10:]), "unsupported snapshot version"},
+		{"truncated header", join(good, []byte("XY")), "truncated snapshot chunk header"},
+		{"truncated chunk", join(header, cpu[:len(cpu)-1]), "truncated snapshot chunk \"CPU \""},
+		{"duplicated", join(good, chunk("MEM ", Memory[:])), "duplicated"},
+		{"unknown", join(good, chunk("XXXX", nil)), "no registered handler"},
+		{"no CPU", join(header, chunk("MEM ", Memory[:])), "without CPU state"},
+		{"CPU size", join(header, chunk("CPU ", nil), chunk("MEM ", Memory[:])), "invalid CPU state size"},
+		{"memory size", join(header, cpu, chunk("MEM ", Memory[:0x100])), "invalid memory size"},
+		{"no memory", join(header, cpu), "without memory"},
+	} {
+		PC = pc
+		if err := Restore(test.data); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+		if PC != pc {
+			t.Errorf("%s: PC changed to $%04X", test.name, PC)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package CPU_6502
+
+import "fmt"
+
+// -------------------------------- Traps -------------------------------- //
+//
+// Traps give the host control when the CPU reaches an address (usually
+// called with JSR) or fetches an opcode (usually an unused one), to provide
+// OS services like the paravirtualized I/O of lib6502, py65 and sim65. The
+// handler replaces the instruction: it reads and changes the registers and
+// the memory (ReadMemory / WriteMemory) and takes one cycle. The Instruction
+// hooks run before the traps, so the debuggers can stop on them.
+
+// TrapAction is what the CPU does after a trap handler
+type TrapAction byte
+
+const (
+	TRAP_RTS     TrapAction = iota // Return to the caller, pulling the address pushed by JSR
+	TRAP_JUMP                      // Continue at the PC set by the handler
+	TRAP_EXECUTE                   // Execute the instruction at PC, the handler only observed it
+)
+
+// TrapHandler is called with the registers, its changes are written back
+type TrapHandler func(r *Registers) TrapAction
+
+var (
+	traps_addr   = map[uint16]TrapHandler{}
+	traps_at     [65536]bool // Address traps, checked in the interpreter hot path
+	traps_opcode [256]TrapHandler
+	traps_active bool // Single check in the interpreter hot path
+)
+
+// SetTrap registers a handler for an address, nil removes it
+func SetTrap(addr uint16, h TrapHandler) {
+
+	if h == nil {
+		delete(traps_addr, addr)
+	} else {
+		traps_addr[addr] = h
+	}
+	traps_at[addr] = h != nil
+
+	updateTraps()
+}
+
+// SetOpcodeTrap registers a handler for an opcode, nil removes it
+// The handler finds the operands at PC+1, TRAP_JUMP with PC after them continues the program
+func SetOpcodeTrap(opc byte, h TrapHandler) {
+	traps_opcode[opc] = h
+	updateTraps()
+}
+
+// ClearTraps removes all the traps
+func ClearTraps() {
+	traps_addr = map[uint16]TrapHandler{}
+	traps_at = [65536]bool{}
+	traps_opcode = [256]TrapHandler{}
+	traps_active = false
+}
+
+func updateTraps() {
+
+	traps_active = len(traps_addr) > 0
+
+	for _, h := range traps_opcode {
+		if h != nil {
+			traps_active = true
+		}
+	}
+}
+
+// Run the trap of the instruction at PC, returns true if it replaced the instruction
+func cpu_Trap() bool {
+
+	var h TrapHandler
+
+	if traps_at[PC] {
+		h = traps_addr[PC]
+	} else if h = traps_opcode[opcode]; h == nil {
+		return false
+	}
+
+	trap_addr := PC
+
+	r := GetRegisters()
+	action := h(&r)
+	SetRegisters(r)
+
+	switch action {
+
+	case TRAP_EXECUTE:
+		opcode = Memory[PC]
+		return false
+
+	case TRAP_RTS:
+		SP++
+		lo := dataBUS_Read(uint16(SP) + 256)
+		SP++
+		hi := dataBUS_Read(uint16(SP) + 256)
+		PC = (uint16(hi)<<8 | uint16(lo)) + 1
+	}
+
+	if Debug {
+		dbg_show_message = fmt.Sprintf("\n\tTrap at 0x%04X.\tNew PC = 0x%04X\n", trap_addr, PC)
+		fmt.Println(dbg_show_message)
+	}
+
+	resetIntOpcCycleCounters()
+
+	return true
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
 )
 
 // ---------------------------- Library Function ---------------------------- //
@@ -173,9 +175,37 @@ func debug_decode_opc(bytes uint16) string {
 		opc_string += " [3 bytes]"
 	}
 
+	// Insert the disassembled instruction
+	text, _ := Disassemble(PC)
+	opc_string += " " + text
+
 	return opc_string
 }
 
+// Disassemble the instruction at mem_addr, returning the text and the number of bytes
+func Disassemble(mem_addr uint16) (string, uint16) {
+
+	line := disassembler.Decode(func(addr uint16) byte { return Memory[addr] }, mem_addr, disassembler.Options{
+		Variant:      disassembler.NMOS,
+		Undocumented: true,
+		Symbols:      Symbols,
+	})
+
+	return line.Text, line.Size()
+}
+
+// Address shown in the debug messages, with its symbol when known
+func debug_addr(addr uint16) string {
+
+	if Symbols != nil {
+		if name, ok := Symbols(addr); ok {
+			return fmt.Sprintf("0x%02X %s", addr, name)
+		}
+	}
+
+	return fmt.Sprintf("0x%02X", addr)
+}
+
 // Decode opcode for debug messages
 func Debug_decode_console(bytes byte, mem_addr uint16) (string, string, string) {
 
@@ -231,6 +261,7 @@ func resetIntOpcCycleCounters() {
 	Opc_cycle_extra = 0
 
 	NewInstruction = true
+	exec_reported = false
 
 	// Update IPS
 	IPS++
@@ -240,13 +271,50 @@ func resetIntOpcCycleCounters() {
 func dataBUS_Read(memAddr uint16) byte {
 	data_value := Memory[memAddr]
 
+	if memory_mapped && memory_perm[memAddr]&PERM_READ == 0 {
+		data_value = memoryViolation(ACCESS_READ, memAddr, data_value)
+	} else if devices_mapped && device_index[memAddr] != 0 {
+		d := &devices[device_index[memAddr]-1]
+		data_value = d.device.Read(d.offset(memAddr))
+	}
+
+	if hooks_active {
+		hooks_Read(memAddr, data_value)
+	}
+
+	if bus_mapper != nil {
+		bus_mapper.Read(memAddr, data_value)
+	}
+
 	return data_value
 }
 
 // Data Bus - WRITE to Memory Operations
 func dataBUS_Write(memAddr uint16, data_value byte) byte {
 
-	Memory[memAddr] = data_value
+	old := Memory[memAddr] // Before a bank switch of the mapper
+
+	// Writes consumed by the mapper or without permission don't change the memory
+	writable := true
+	if bus_mapper != nil && bus_mapper.Write(memAddr, data_value) {
+		writable = false
+	} else if memory_mapped && memory_perm[memAddr]&PERM_WRITE == 0 {
+		writable = false
+		memoryViolation(ACCESS_WRITE, memAddr, data_value)
+	}
+
+	if hooks_active {
+		hooks_Write(memAddr, old, data_value)
+	}
+
+	if writable {
+		if devices_mapped && device_index[memAddr] != 0 {
+			d := &devices[device_index[memAddr]-1]
+			d.device.Write(d.offset(memAddr), data_value)
+		} else {
+			Memory[memAddr] = data_value
+		}
+	}
 
 	return data_value
 }
@@ -0,0 +1,151 @@
+package CPU_6502
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ------------------------------- Loaders -------------------------------- //
+//
+// The loaders parse and validate the whole file before changing the memory,
+// so a file with errors leaves the machine untouched. By default they write
+// directly to Memory: the hooks, the memory map, the devices and the mapper
+// don't see the writes. With LoadThroughBus they write through the CPU data
+// BUS, like a program copying the file to memory.
+
+// LoadThroughBus sends the writes of the loaders through the data BUS: the
+// mapper sees them (bank switches), the devices receive them, the write hooks
+// run and write protected regions of the memory map keep their contents
+var LoadThroughBus bool
+
+// LoadSegment is an address range written by a loader
+type LoadSegment struct {
+	Address uint16
+	Size    int
+}
+
+// LoadImage describes what a loader wrote in memory
+type LoadImage struct {
+	Segments []LoadSegment
+	Start    uint16 // Start address found in the file
+	HasStart bool   // The file has a start address (PC was set)
+
+	Init []uint16 // Atari XEX INITAD routines, in load order
+}
+
+// Block of data parsed from a file, written once the file is validated
+type load_block struct {
+	address int // May exceed 16 bits in the file, checked before loading
+	data    []byte
+}
+
+// Write the blocks, merging contiguous ones in the segments, and set PC from the start address
+func (img *LoadImage) apply(blocks []load_block) {
+
+	for _, b := range blocks {
+
+		for i, v := range b.data {
+			memory_Load(uint16(b.address+i), v)
+		}
+
+		n := len(img.Segments)
+		if n > 0 && int(img.Segments[n-1].Address)+img.Segments[n-1].Size == b.address {
+			img.Segments[n-1].Size += len(b.data)
+		} else if len(b.data) > 0 {
+			img.Segments = append(img.Segments, LoadSegment{Address: uint16(b.address), Size: len(b.data)})
+		}
+	}
+
+	if img.HasStart {
+		PC = img.Start
+		PC_as_argument = img.Start // Kept by Reset()
+	}
+}
+
+// LoadFile loads a file with its own addresses, choosing the format from the extension:
+// .hex .ihx (Intel HEX), .s19 .s28 .s37 .srec .mot (S-record), .prg, .xex .com (Atari)
+// and .o65 (at the addresses of the file, without imports)
+// Raw binaries need LoadBinary and Apple DOS 3.3 files LoadAppleBinary
+// Without LoadThroughBus the data is written directly to Memory: a ROM image is
+// loaded whole even where a mapper has its registers or a device is attached,
+// without bank switches, device side effects, write hooks or write protection
+func LoadFile(filename string) (*LoadImage, error) {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".hex", ".ihx", ".ihex":
+		return LoadIntelHex(filename)
+	case ".s19", ".s28", ".s37", ".srec", ".mot":
+		return LoadSRecord(filename)
+	case ".prg":
+		return LoadPRG(filename)
+	case ".xex", ".com":
+		return LoadXEX(filename)
+	case ".o65":
+		img, _, err := LoadO65(filename, nil, nil)
+		return img, err
+	}
+
+	return nil, fmt.Errorf("%s: unknown file format, a load address is needed", filename)
+}
+
+// Memory write used by the loaders
+func memory_Load(addr uint16, value byte) {
+	if LoadThroughBus {
+		dataBUS_Write(addr, value)
+		return
+	}
+	Memory[addr] = value
+}
+
+// Open a file and run a parser over it, adding the file name to the errors
+func loadFile(filename string, parse func(r io.Reader) (*LoadImage, error)) (*LoadImage, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return img, nil
+}
+
+// Value of the hexadecimal digits pairs of a record
+func decodeHexBytes(text string) ([]byte, error) {
+
+	if len(text)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hexadecimal digits")
+	}
+
+	data := make([]byte, len(text)/2)
+
+	for i := range data {
+		hi, ok1 := hexDigit(text[2*i])
+		lo, ok2 := hexDigit(text[2*i+1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid hexadecimal digits %q", text[2*i:2*i+2])
+		}
+		data[i] = hi<<4 | lo
+	}
+
+	return data, nil
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,242 @@
+package CPU_6502
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// --------------------------- Binary Containers ---------------------------- //
+
+// LoadOptions places a raw binary in memory
+type LoadOptions struct {
+	Address uint16 // Load address
+	Offset  int    // Bytes skipped at the start of the file (e.g. a header)
+	Length  int    // Bytes loaded after Offset (0 = up to the end of the file)
+
+	// Repeat the data up to this address (inclusive), e.g. a 2KB cartridge at
+	// $F000 mirrored to $FFFF. 0 disables the mirroring.
+	MirrorEnd uint16
+}
+
+// LoadBinary loads a raw binary file, ReadROM with a load address, length and mirroring
+func LoadBinary(filename string, opts LoadOptions) (*LoadImage, error) {
+	return loadFile(filename, func(r io.Reader) (*LoadImage, error) {
+		return ReadBinary(r, opts)
+	})
+}
+
+// ReadBinary loads a raw binary from r
+func ReadBinary(r io.Reader, opts LoadOptions) (*LoadImage, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Offset < 0 || opts.Offset > len(data) {
+		return nil, fmt.Errorf("offset %d beyond the %d bytes of the file", opts.Offset, len(data))
+	}
+	data = data[opts.Offset:]
+
+	if opts.Length < 0 || opts.Length > len(data) {
+		return nil, fmt.Errorf("length %d beyond the %d bytes after the offset", opts.Length, len(data))
+	}
+	if opts.Length > 0 {
+		data = data[:opts.Length]
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to load")
+	}
+
+	addr := int(opts.Address)
+
+	if addr+len(data) > 0x10000 {
+		return nil, fmt.Errorf("%d bytes at $%04X don't fit in the 64KB address space", len(data), addr)
+	}
+
+	blocks := []load_block{{addr, data}}
+
+	if opts.MirrorEnd != 0 {
+
+		end := int(opts.MirrorEnd)
+		if end < addr+len(data)-1 {
+			return nil, fmt.Errorf("mirror end $%04X inside the data at $%04X-$%04X", end, addr, addr+len(data)-1)
+		}
+
+		for a := addr + len(data); a <= end; a += len(data) {
+			n := len(data)
+			if a+n > end+1 {
+				n = end + 1 - a
+			}
+			blocks = append(blocks, load_block{a, data[:n]})
+		}
+	}
+
+	var img LoadImage
+	img.apply(blocks)
+
+	return &img, nil
+}
+
+// ---------------------------------- PRG ----------------------------------- //
+
+// LoadPRG loads a Commodore .prg file (2 bytes load address header)
+func LoadPRG(filename string) (*LoadImage, error) {
+	return loadFile(filename, ReadPRG)
+}
+
+// ReadPRG loads a Commodore .prg from r
+func ReadPRG(r io.Reader) (*LoadImage, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2 {
+		return nil, fmt.Errorf("missing the load address header")
+	}
+
+	addr := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+
+	if addr+len(data) > 0x10000 {
+		return nil, fmt.Errorf("%d bytes at $%04X don't fit in the 64KB address space", len(data), addr)
+	}
+
+	var img LoadImage
+	img.apply([]load_block{{addr, data}})
+
+	return &img, nil
+}
+
+// -------------------------------- Atari XEX ------------------------------- //
+
+// Atari DOS vectors
+const (
+	xex_RUNAD  = 0x02E0 // Run address, used after the whole file is loaded
+	xex_INITAD = 0x02E2 // Init address, called after the segment that sets it
+)
+
+// LoadXEX loads an Atari 8-bit executable (.xex / .com)
+// PC is set from RUNAD, the INITAD routines are returned in LoadImage.Init
+// in load order (the loader doesn't run them)
+func LoadXEX(filename string) (*LoadImage, error) {
+	return loadFile(filename, ReadXEX)
+}
+
+// ReadXEX loads an Atari 8-bit executable from r
+func ReadXEX(r io.Reader) (*LoadImage, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xFF {
+		return nil, fmt.Errorf("missing the $FFFF header")
+	}
+
+	var (
+		img    LoadImage
+		blocks []load_block
+	)
+
+	// RUNAD and INITAD as written by the segments
+	var vectors [4]byte
+	var written [4]bool
+
+	for pos, segment := 0, 1; pos < len(data); segment++ {
+
+		// The $FFFF marker is optional after the first segment
+		if pos+1 < len(data) && data[pos] == 0xFF && data[pos+1] == 0xFF {
+			pos += 2
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("segment %d: truncated header", segment)
+		}
+
+		start := int(binary.LittleEndian.Uint16(data[pos:]))
+		end := int(binary.LittleEndian.Uint16(data[pos+2:]))
+		pos += 4
+
+		if end < start {
+			return nil, fmt.Errorf("segment %d: end $%04X lower than start $%04X", segment, end, start)
+		}
+
+		size := end - start + 1
+		if pos+size > len(data) {
+			return nil, fmt.Errorf("segment %d: $%04X-$%04X needs %d bytes, %d left in the file", segment, start, end, size, len(data)-pos)
+		}
+
+		blocks = append(blocks, load_block{start, data[pos : pos+size]})
+
+		init_set := false
+		for i := range vectors {
+			if a := xex_RUNAD + i; a >= start && a <= end {
+				vectors[i] = data[pos+a-start]
+				written[i] = true
+				init_set = init_set || a >= xex_INITAD
+			}
+		}
+
+		if init_set {
+			img.Init = append(img.Init, uint16(vectors[2])|uint16(vectors[3])<<8)
+			vectors[2], vectors[3] = 0, 0 // Cleared by DOS before the next segment
+		}
+
+		pos += size
+	}
+
+	if written[0] || written[1] {
+		img.Start = uint16(vectors[0]) | uint16(vectors[1])<<8
+		img.HasStart = true
+	}
+
+	img.apply(blocks)
+
+	return &img, nil
+}
+
+// ----------------------------- Apple DOS 3.3 ------------------------------ //
+
+// LoadAppleBinary loads an Apple DOS 3.3 binary file ("B" type: 2 bytes load
+// address, 2 bytes length, data), setting PC to the load address like BRUN
+func LoadAppleBinary(filename string) (*LoadImage, error) {
+	return loadFile(filename, ReadAppleBinary)
+}
+
+// ReadAppleBinary loads an Apple DOS 3.3 binary file from r
+// Bytes after the length in the header (the rest of the last sector) are ignored
+func ReadAppleBinary(r io.Reader) (*LoadImage, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("missing the address and length header")
+	}
+
+	addr := int(binary.LittleEndian.Uint16(data))
+	length := int(binary.LittleEndian.Uint16(data[2:]))
+	data = data[4:]
+
+	if length > len(data) {
+		return nil, fmt.Errorf("header length %d bigger than the %d bytes of data", length, len(data))
+	}
+	data = data[:length]
+
+	if addr+len(data) > 0x10000 {
+		return nil, fmt.Errorf("%d bytes at $%04X don't fit in the 64KB address space", len(data), addr)
+	}
+
+	img := LoadImage{Start: uint16(addr), HasStart: true}
+	img.apply([]load_block{{addr, data}})
+
+	return &img, nil
+}
@@ -0,0 +1,129 @@
+package CPU_6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ------------------------------ Intel HEX ------------------------------- //
+//
+// :LLAAAATT<data>CC records, LL data length, AAAA address, TT type, CC the
+// two's complement of the sum of the other bytes.
+//
+//	00 Data
+//	01 End of file
+//	02 Extended segment address (segment * 16 added to the addresses)
+//	03 Start segment address (CS:IP)
+//	04 Extended linear address (upper 16 bits of the addresses)
+//	05 Start linear address
+
+// LoadIntelHex loads an Intel HEX file, setting PC from its start address record
+func LoadIntelHex(filename string) (*LoadImage, error) {
+	return loadFile(filename, ReadIntelHex)
+}
+
+// ReadIntelHex loads Intel HEX records from r
+func ReadIntelHex(r io.Reader) (*LoadImage, error) {
+
+	var (
+		img    LoadImage
+		blocks []load_block
+		base   int // Extended segment / linear address
+		eof    bool
+	)
+
+	scanner := bufio.NewScanner(r)
+
+	for num := 1; !eof && scanner.Scan(); num++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if line[0] != ':' {
+			return nil, fmt.Errorf("line %d: record doesn't start with ':'", num)
+		}
+
+		rec, err := decodeHexBytes(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+
+		if len(rec) < 5 {
+			return nil, fmt.Errorf("line %d: record too short", num)
+		}
+
+		length := int(rec[0])
+		if len(rec) != length+5 {
+			return nil, fmt.Errorf("line %d: record length %d doesn't match its %d data bytes", num, length, len(rec)-5)
+		}
+
+		var sum byte
+		for _, b := range rec[:len(rec)-1] {
+			sum += b
+		}
+		if expected := -sum; rec[len(rec)-1] != expected {
+			return nil, fmt.Errorf("line %d: checksum mismatch (record has $%02X, computed $%02X)", num, rec[len(rec)-1], expected)
+		}
+
+		address := int(rec[1])<<8 | int(rec[2])
+		kind := rec[3]
+		data := rec[4 : 4+length]
+
+		switch kind {
+
+		case 0x00: // Data
+			addr := base + address
+			if addr+length > 0x10000 {
+				return nil, fmt.Errorf("line %d: data at $%X-$%X is outside the 64KB address space", num, addr, addr+length-1)
+			}
+			blocks = append(blocks, load_block{addr, data})
+
+		case 0x01: // End of file
+			eof = true
+
+		case 0x02: // Extended segment address
+			if length != 2 {
+				return nil, fmt.Errorf("line %d: extended segment address record with %d data bytes", num, length)
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 4
+
+		case 0x04: // Extended linear address
+			if length != 2 {
+				return nil, fmt.Errorf("line %d: extended linear address record with %d data bytes", num, length)
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 16
+
+		case 0x03, 0x05: // Start segment address (CS:IP), start linear address
+			if length != 4 {
+				return nil, fmt.Errorf("line %d: start address record with %d data bytes", num, length)
+			}
+			start := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+			if kind == 0x03 {
+				start = (start>>16)<<4 + start&0xFFFF
+			}
+			if start > 0xFFFF {
+				return nil, fmt.Errorf("line %d: start address $%X is outside the 64KB address space", num, start)
+			}
+			img.Start, img.HasStart = uint16(start), true
+
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type $%02X", num, kind)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !eof {
+		return nil, fmt.Errorf("missing end of file record (:00000001FF)")
+	}
+
+	img.apply(blocks)
+
+	return &img, nil
+}
@@ -0,0 +1,409 @@
+package CPU_6502
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// --------------------------- o65 Relocatable Files ------------------------ //
+//
+// xa65 o65 format: header, header options, text and data segments, the names
+// of the undefined (imported) symbols, the relocation tables of text and data
+// and the exported symbols. Only 6502 files are loaded (no 65816 segments).
+
+// o65 mode bits
+const (
+	O65_CPU65816  = 0x8000 // 65816 code
+	O65_PAGEWISE  = 0x4000 // Page-wise relocation (no low byte with HIGH entries)
+	O65_SIZE32    = 0x2000 // 32 bits addresses and sizes
+	O65_OBJECT    = 0x1000 // Object file (not executable)
+	O65_SIMPLE    = 0x0800 // Segments contiguous in the file addresses
+	O65_CHAIN     = 0x0400 // Another file follows (not loaded)
+	O65_BSSZERO   = 0x0200 // The bss segment must be cleared
+	o65_SEG_UNDEF = 0
+	o65_SEG_ABS   = 1
+	o65_SEG_TEXT  = 2
+	o65_SEG_DATA  = 3
+	o65_SEG_BSS   = 4
+	o65_SEG_ZERO  = 5
+)
+
+// O65Layout holds the base addresses of the segments
+type O65Layout struct {
+	Text uint16
+	Data uint16
+	BSS  uint16
+	Zero uint16
+}
+
+// O65Option is a header option (0 file name, 1 OS, 2 assembler, 3 author, 4 date)
+type O65Option struct {
+	Type byte
+	Data []byte
+}
+
+// O65Symbol is an exported symbol, Value relative to the base of its segment in the file
+type O65Symbol struct {
+	Name    string
+	Segment byte
+	Value   uint16
+}
+
+// O65 is a parsed o65 file, ready to be relocated and loaded
+type O65 struct {
+	Mode    uint16
+	Base    O65Layout // Addresses the file was assembled for
+	TextLen int
+	DataLen int
+	BSSLen  int
+	ZeroLen int
+	Stack   int
+
+	Options   []O65Option
+	Text      []byte
+	Data      []byte
+	Undefined []string    // Imported symbols
+	Exports   []O65Symbol // Exported symbols
+
+	text_reloc []o65_reloc
+	data_reloc []o65_reloc
+}
+
+// Relocation entry
+type o65_reloc struct {
+	offset  int  // From the start of the segment
+	kind    byte // $80 word, $40 high byte, $20 low byte
+	segment byte
+	undef   int  // Index of the undefined symbol
+	low     byte // Low byte of the value of byte-wise HIGH entries
+}
+
+// ReadO65 parses an o65 file, only the first file of a chain is read
+func ReadO65(r io.Reader) (*O65, error) {
+
+	p := &o65_parser{r: bufio.NewReader(r)}
+	o := &O65{}
+
+	// Header
+	magic := p.bytes(6)
+	if p.err != nil || magic[0] != 0x01 || magic[1] != 0x00 || string(magic[2:5]) != "o65" {
+		return nil, fmt.Errorf("not an o65 file")
+	}
+	if magic[5] != 0 {
+		return nil, fmt.Errorf("unsupported o65 version %d", magic[5])
+	}
+
+	o.Mode = p.word()
+	if o.Mode&O65_CPU65816 != 0 {
+		return nil, fmt.Errorf("65816 o65 files are not supported")
+	}
+	p.size32 = o.Mode&O65_SIZE32 != 0
+
+	tbase, tlen := p.value(), p.value()
+	dbase, dlen := p.value(), p.value()
+	bbase, blen := p.value(), p.value()
+	zbase, zlen := p.value(), p.value()
+	o.Stack = p.value()
+
+	if p.err != nil {
+		return nil, fmt.Errorf("truncated header")
+	}
+
+	for _, v := range []int{tbase + tlen, dbase + dlen, bbase + blen} {
+		if v > 0x10000 {
+			return nil, fmt.Errorf("segment outside the 64KB address space")
+		}
+	}
+	if zbase+zlen > 0x100 {
+		return nil, fmt.Errorf("zero page segment $%X-$%X outside the zero page", zbase, zbase+zlen-1)
+	}
+
+	o.Base = O65Layout{uint16(tbase), uint16(dbase), uint16(bbase), uint16(zbase)}
+	o.TextLen, o.DataLen, o.BSSLen, o.ZeroLen = tlen, dlen, blen, zlen
+
+	// Header options
+	for {
+		length := int(p.byte())
+		if p.err != nil {
+			return nil, fmt.Errorf("truncated header options")
+		}
+		if length == 0 {
+			break
+		}
+		if length < 2 {
+			return nil, fmt.Errorf("invalid header option length %d", length)
+		}
+		kind := p.byte()
+		o.Options = append(o.Options, O65Option{Type: kind, Data: p.bytes(length - 2)})
+	}
+
+	// Segments
+	o.Text = p.bytes(tlen)
+	o.Data = p.bytes(dlen)
+	if p.err != nil {
+		return nil, fmt.Errorf("truncated text or data segment")
+	}
+
+	// Imported symbols
+	count := p.value()
+	for i := 0; i < count && p.err == nil; i++ {
+		o.Undefined = append(o.Undefined, p.name())
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("truncated undefined symbols list")
+	}
+
+	var err error
+
+	if o.text_reloc, err = p.relocations(tlen, len(o.Undefined), o.Mode&O65_PAGEWISE != 0); err != nil {
+		return nil, fmt.Errorf("text relocation table: %v", err)
+	}
+	if o.data_reloc, err = p.relocations(dlen, len(o.Undefined), o.Mode&O65_PAGEWISE != 0); err != nil {
+		return nil, fmt.Errorf("data relocation table: %v", err)
+	}
+
+	// Exported symbols
+	count = p.value()
+	for i := 0; i < count && p.err == nil; i++ {
+		name := p.name()
+		segment := p.byte()
+		value := p.value()
+		if p.err == nil && (segment < o65_SEG_ABS || segment > o65_SEG_ZERO) {
+			return nil, fmt.Errorf("exported symbol %q in invalid segment %d", name, segment)
+		}
+		o.Exports = append(o.Exports, O65Symbol{Name: name, Segment: segment, Value: uint16(value)})
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("truncated exported symbols list")
+	}
+
+	return o, nil
+}
+
+// Relocate returns the text and data segments relocated to layout and the exported
+// symbols at their new addresses, imports resolves the undefined symbols
+func (o *O65) Relocate(layout O65Layout, imports func(name string) (uint16, bool)) (text, data []byte, exports map[string]uint16, err error) {
+
+	if int(layout.Text)+o.TextLen > 0x10000 || int(layout.Data)+o.DataLen > 0x10000 || int(layout.BSS)+o.BSSLen > 0x10000 {
+		return nil, nil, nil, fmt.Errorf("segment outside the 64KB address space")
+	}
+	if int(layout.Zero)+o.ZeroLen > 0x100 {
+		return nil, nil, nil, fmt.Errorf("zero page segment outside the zero page")
+	}
+
+	// Value added to the addresses of each segment, undefined symbols are resolved
+	delta := map[byte]int{
+		o65_SEG_ABS:  0,
+		o65_SEG_TEXT: int(layout.Text) - int(o.Base.Text),
+		o65_SEG_DATA: int(layout.Data) - int(o.Base.Data),
+		o65_SEG_BSS:  int(layout.BSS) - int(o.Base.BSS),
+		o65_SEG_ZERO: int(layout.Zero) - int(o.Base.Zero),
+	}
+
+	resolved := make([]int, len(o.Undefined))
+	for i, name := range o.Undefined {
+		addr, ok := uint16(0), false
+		if imports != nil {
+			addr, ok = imports(name)
+		}
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("undefined symbol %q", name)
+		}
+		resolved[i] = int(addr)
+	}
+
+	text = append([]byte(nil), o.Text...)
+	data = append([]byte(nil), o.Data...)
+
+	for _, seg := range []struct {
+		data   []byte
+		relocs []o65_reloc
+	}{{text, o.text_reloc}, {data, o.data_reloc}} {
+
+		for _, r := range seg.relocs {
+
+			d := delta[r.segment]
+			if r.segment == o65_SEG_UNDEF {
+				d = resolved[r.undef]
+			}
+
+			b := seg.data
+
+			switch r.kind {
+			case 0x80: // Word
+				v := int(b[r.offset]) | int(b[r.offset+1])<<8 + d
+				b[r.offset], b[r.offset+1] = byte(v), byte(v>>8)
+			case 0x40: // High byte
+				v := int(b[r.offset])<<8 | int(r.low) + d
+				b[r.offset] = byte(v >> 8)
+			case 0x20: // Low byte
+				b[r.offset] = byte(int(b[r.offset]) + d)
+			}
+		}
+	}
+
+	exports = make(map[string]uint16, len(o.Exports))
+	for _, s := range o.Exports {
+		exports[s.Name] = uint16(int(s.Value) + delta[s.Segment])
+	}
+
+	return text, data, exports, nil
+}
+
+// Load relocates the file to layout and writes the text and data segments
+// (and clears bss when the file asks for it), returning the exported symbols
+func (o *O65) Load(layout O65Layout, imports func(name string) (uint16, bool)) (*LoadImage, map[string]uint16, error) {
+
+	text, data, exports, err := o.Relocate(layout, imports)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks := []load_block{{int(layout.Text), text}, {int(layout.Data), data}}
+	if o.Mode&O65_BSSZERO != 0 {
+		blocks = append(blocks, load_block{int(layout.BSS), make([]byte, o.BSSLen)})
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].address < blocks[j].address })
+
+	var img LoadImage
+	img.apply(blocks)
+
+	return &img, exports, nil
+}
+
+// LoadO65 loads an o65 file at the addresses of layout (nil keeps the addresses
+// of the file), imports resolves the undefined symbols (e.g. symbols.Table.Resolve)
+func LoadO65(filename string, layout *O65Layout, imports func(name string) (uint16, bool)) (*LoadImage, map[string]uint16, error) {
+
+	var exports map[string]uint16
+
+	img, err := loadFile(filename, func(r io.Reader) (*LoadImage, error) {
+
+		o, err := ReadO65(r)
+		if err != nil {
+			return nil, err
+		}
+
+		l := o.Base
+		if layout != nil {
+			l = *layout
+		}
+
+		var img *LoadImage
+		img, exports, err = o.Load(l, imports)
+
+		return img, err
+	})
+
+	return img, exports, err
+}
+
+// --------------------------------- Parser --------------------------------- //
+
+type o65_parser struct {
+	r      *bufio.Reader
+	size32 bool
+	err    error // First read error, the reads return zero values after it
+}
+
+func (p *o65_parser) byte() byte {
+	if p.err != nil {
+		return 0
+	}
+	b, err := p.r.ReadByte()
+	if err != nil {
+		p.err = err
+	}
+	return b
+}
+
+func (p *o65_parser) bytes(n int) []byte {
+	data := make([]byte, n)
+	if p.err == nil {
+		_, p.err = io.ReadFull(p.r, data)
+	}
+	return data
+}
+
+func (p *o65_parser) word() uint16 {
+	return binary.LittleEndian.Uint16(p.bytes(2))
+}
+
+// Address or size, 16 or 32 bits depending on the mode
+func (p *o65_parser) value() int {
+	if p.size32 {
+		return int(binary.LittleEndian.Uint32(p.bytes(4)))
+	}
+	return int(p.word())
+}
+
+// Zero terminated name
+func (p *o65_parser) name() string {
+	var name []byte
+	for b := p.byte(); b != 0 && p.err == nil; b = p.byte() {
+		name = append(name, b)
+	}
+	return string(name)
+}
+
+// Relocation table of a segment of size bytes
+func (p *o65_parser) relocations(size int, undefined int, pagewise bool) ([]o65_reloc, error) {
+
+	var relocs []o65_reloc
+
+	offset := -1
+
+	for {
+		step := int(p.byte())
+		if p.err != nil {
+			return nil, fmt.Errorf("truncated")
+		}
+		if step == 0 {
+			return relocs, nil
+		}
+		if step == 255 {
+			offset += 254
+			continue
+		}
+		offset += step
+
+		typ := p.byte()
+		r := o65_reloc{offset: offset, kind: typ & 0xE0, segment: typ & 0x1F}
+
+		if r.segment == o65_SEG_UNDEF {
+			r.undef = p.value()
+			if r.undef >= undefined {
+				return nil, fmt.Errorf("reference to undefined symbol %d of %d", r.undef, undefined)
+			}
+		} else if r.segment > o65_SEG_ZERO {
+			return nil, fmt.Errorf("invalid segment %d at offset %d", r.segment, offset)
+		}
+
+		width := 1
+
+		switch r.kind {
+		case 0x80:
+			width = 2
+		case 0x40:
+			if !pagewise {
+				r.low = p.byte()
+			}
+		case 0x20:
+		default:
+			return nil, fmt.Errorf("unsupported relocation type $%02X at offset %d", r.kind, offset)
+		}
+
+		if p.err != nil {
+			return nil, fmt.Errorf("truncated")
+		}
+		if offset+width > size {
+			return nil, fmt.Errorf("relocation at offset %d outside the %d bytes segment", offset, size)
+		}
+
+		relocs = append(relocs, r)
+	}
+}
@@ -0,0 +1,115 @@
+package CPU_6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ---------------------------- Motorola S-record --------------------------- //
+//
+// S<type><count><address><data><checksum> records, count is the number of
+// bytes after it and the checksum the ones' complement of their sum.
+//
+//	S0        Header (ignored)
+//	S1 S2 S3  Data with 16, 24 and 32 bits addresses (S19 / S28 / S37 files)
+//	S5 S6     Number of data records (16 / 24 bits), validated
+//	S7 S8 S9  Start address (32 / 24 / 16 bits), address 0 means no start address
+
+// LoadSRecord loads a Motorola S-record file, setting PC from its start address record
+func LoadSRecord(filename string) (*LoadImage, error) {
+	return loadFile(filename, ReadSRecord)
+}
+
+// ReadSRecord loads Motorola S-records from r
+func ReadSRecord(r io.Reader) (*LoadImage, error) {
+
+	var (
+		img     LoadImage
+		blocks  []load_block
+		records int // Data records, for the S5 / S6 count
+	)
+
+	// Address size of each record type
+	address_size := map[byte]int{'0': 2, '1': 2, '2': 3, '3': 4, '5': 2, '6': 3, '7': 4, '8': 3, '9': 2}
+
+	scanner := bufio.NewScanner(r)
+
+	for num := 1; scanner.Scan(); num++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if len(line) < 2 || line[0] != 'S' && line[0] != 's' {
+			return nil, fmt.Errorf("line %d: record doesn't start with 'S'", num)
+		}
+
+		kind := line[1]
+		size, ok := address_size[kind]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown record type S%c", num, kind)
+		}
+
+		rec, err := decodeHexBytes(line[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+
+		if len(rec) < 1 || int(rec[0]) != len(rec)-1 {
+			return nil, fmt.Errorf("line %d: record count doesn't match its %d bytes", num, len(rec)-1)
+		}
+		if len(rec) < 1+size+1 {
+			return nil, fmt.Errorf("line %d: record too short for a %d bytes address", num, size)
+		}
+
+		var sum byte
+		for _, b := range rec[:len(rec)-1] {
+			sum += b
+		}
+		if expected := ^sum; rec[len(rec)-1] != expected {
+			return nil, fmt.Errorf("line %d: checksum mismatch (record has $%02X, computed $%02X)", num, rec[len(rec)-1], expected)
+		}
+
+		address := 0
+		for _, b := range rec[1 : 1+size] {
+			address = address<<8 | int(b)
+		}
+		data := rec[1+size : len(rec)-1]
+
+		switch kind {
+
+		case '0': // Header
+
+		case '1', '2', '3':
+			if address+len(data) > 0x10000 {
+				return nil, fmt.Errorf("line %d: data at $%X-$%X is outside the 64KB address space", num, address, address+len(data)-1)
+			}
+			blocks = append(blocks, load_block{address, data})
+			records++
+
+		case '5', '6':
+			if address != records {
+				return nil, fmt.Errorf("line %d: record count %d doesn't match the %d data records", num, address, records)
+			}
+
+		case '7', '8', '9':
+			if address > 0xFFFF {
+				return nil, fmt.Errorf("line %d: start address $%X is outside the 64KB address space", num, address)
+			}
+			if address != 0 {
+				img.Start, img.HasStart = uint16(address), true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	img.apply(blocks)
+
+	return &img, nil
+}
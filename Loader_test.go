@@ -0,0 +1,455 @@
+package CPU_6502
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ------------------------------- Loaders -------------------------------- //
+
+// Intel HEX file writing $11 at $0200 (RAM), $22 at $6000 (device) and $33 at $F000 (ROM)
+const test_load_hex = `:0102000011EC
+:01600000227D
+:01F0000033DC
+:00000001FF
+`
+
+// Loads test_load_hex with a device at $6000 and a ROM region at $F000
+func testLoadBus(t *testing.T, bus bool) *test_device {
+
+	Initialize()
+	Memory[0xF000] = 0xEA
+
+	d := &test_device{}
+	if err := AttachDevice(d, 0x6000, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := MapRegion(NewRegion("rom", 0xF000, 0xFFFF, REGION_ROM)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DetachDevice(d)
+		ClearMemoryMap()
+		LoadThroughBus = false
+	})
+
+	LoadThroughBus = bus
+	if _, err := ReadIntelHex(strings.NewReader(test_load_hex)); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestLoadDirect(t *testing.T) {
+
+	d := testLoadBus(t, false)
+
+	if Memory[0x0200] != 0x11 || Memory[0x6000] != 0x22 || Memory[0xF000] != 0x33 {
+		t.Errorf("memory $%02X $%02X $%02X, want $11 $22 $33", Memory[0x0200], Memory[0x6000], Memory[0xF000])
+	}
+	if len(d.log) != 0 {
+		t.Errorf("device accesses %q, want none", d.log)
+	}
+}
+
+func TestLoadThroughBus(t *testing.T) {
+
+	d := testLoadBus(t, true)
+
+	if Memory[0x0200] != 0x11 {
+		t.Errorf("RAM $%02X, want $11", Memory[0x0200])
+	}
+	if Memory[0xF000] != 0xEA {
+		t.Errorf("ROM $%02X, want $EA (write protected)", Memory[0xF000])
+	}
+	if Memory[0x6000] != 0x00 || len(d.log) != 1 || !strings.HasPrefix(d.log[0], "write 00=22") {
+		t.Errorf("device accesses %q and memory $%02X, want the write of $22 on the device", d.log, Memory[0x6000])
+	}
+}
+
+// Contiguous records merged, extended segment and linear addresses, start address
+// Everything after the end of file record is ignored
+const test_intel_hex = `:02030000A90151
+:02030200851064
+:020000020100FB
+:0100000042BD
+:020000040000FA
+:0400000500000300F4
+:00000001FF
+not a record
+`
+
+// Same contents as test_intel_hex: header, S1 and S2 data, record count and S9 start
+const test_srecord = `S00600004844521B
+S1050300A9014D
+S1050302851060
+S20500100042A8
+S5030003F9
+S9030300F9
+`
+
+func TestLoadRecords(t *testing.T) {
+
+	for _, test := range []struct {
+		name string
+		read func(r io.Reader) (*LoadImage, error)
+		text string
+	}{
+		{"Intel HEX", ReadIntelHex, test_intel_hex},
+		{"S-record", ReadSRecord, test_srecord},
+	} {
+		Initialize()
+
+		img, err := test.read(strings.NewReader(test.text))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		want := []LoadSegment{{0x0300, 4}, {0x1000, 1}}
+		if !reflect.DeepEqual(img.Segments, want) || !img.HasStart || img.Start != 0x0300 || PC != 0x0300 {
+			t.Errorf("%s: segments %v, start $%04X (%v), PC $%04X", test.name, img.Segments, img.Start, img.HasStart, PC)
+		}
+		if !bytes.Equal(Memory[0x0300:0x0304], []byte{0xA9, 0x01, 0x85, 0x10}) || Memory[0x1000] != 0x42 {
+			t.Errorf("%s: memory % X and $%02X", test.name, Memory[0x0300:0x0304], Memory[0x1000])
+		}
+	}
+
+	// CS:IP start address, S9 with address 0 has no start address
+	if img, err := ReadIntelHex(strings.NewReader(":0400000300100005E4\n:00000001FF\n")); err != nil || img.Start != 0x0105 {
+		t.Errorf("start segment address: %v, %v", img, err)
+	}
+	if img, err := ReadSRecord(strings.NewReader("S9030000FC\n")); err != nil || img.HasStart {
+		t.Errorf("S9 without start address: %v, %v", img, err)
+	}
+}
+
+// The errors are reported with their line and the memory is left untouched
+func TestLoadRecordErrors(t *testing.T) {
+
+	const data_hex = ":02030000A90151\n" // Valid data record before the error
+	const data_srec = "S1050300A9014D\n"
+
+	for _, test := range []struct {
+		read func(r io.Reader) (*LoadImage, error)
+		text string
+		err  string
+	}{
+		{ReadIntelHex, data_hex + "02030000A90151\n", "line 2: record doesn't start with ':'"},
+		{ReadIntelHex, data_hex + ":0203000\n", "line 2: odd number of hexadecimal digits"},
+		{ReadIntelHex, data_hex + ":0203000ZA90151\n", "invalid hexadecimal digits"},
+		{ReadIntelHex, data_hex + ":00000001\n", "record too short"},
+		{ReadIntelHex, data_hex + ":03030000A90151\n", "record length 3 doesn't match its 2 data bytes"},
+		{ReadIntelHex, data_hex + ":02030000A90152\n", "checksum mismatch (record has $52, computed $51)"},
+		{ReadIntelHex, data_hex + ":020000040001F9\n:02FFFF000102FD\n", "line 3: data at $1FFFF-$20000 is outside"},
+		{ReadIntelHex, data_hex + ":0100000400FB\n", "extended linear address record with 1 data bytes"},
+		{ReadIntelHex, data_hex + ":0400000500010000F6\n", "start address $10000 is outside"},
+		{ReadIntelHex, data_hex + ":00000006FA\n", "unknown record type $06"},
+		{ReadIntelHex, data_hex, "missing end of file record"},
+		{ReadSRecord, data_srec + "X1050300A9014D\n", "line 2: record doesn't start with 'S'"},
+		{ReadSRecord, data_srec + "S4050300A9014D\n", "unknown record type S4"},
+		{ReadSRecord, data_srec + "S1060300A9014D\n", "record count doesn't match its 5 bytes"},
+		{ReadSRecord, data_srec + "S10200FD\n", "record too short for a 2 bytes address"},
+		{ReadSRecord, data_srec + "S1050300A9014E\n", "checksum mismatch (record has $4E, computed $4D)"},
+		{ReadSRecord, data_srec + "S3070000FFFF0102F7\n", "data at $FFFF-$10000 is outside"},
+		{ReadSRecord, data_srec + "S5030002FA\n", "record count 2 doesn't match the 1 data records"},
+		{ReadSRecord, data_srec + "S70500010000F9\n", "start address $10000 is outside"},
+	} {
+		Initialize()
+		PC = 0x1234
+
+		if _, err := test.read(strings.NewReader(test.text)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error %v, want %q", test.text, err, test.err)
+		}
+		if Memory[0x0300] != 0 || PC != 0x1234 {
+			t.Errorf("%q: memory $%02X and PC $%04X changed", test.text, Memory[0x0300], PC)
+		}
+	}
+}
+
+// The format comes from the extension and the file name is added to the errors
+func TestLoadFile(t *testing.T) {
+
+	dir := t.TempDir()
+	for name, text := range map[string]string{"ok.ihx": test_intel_hex, "ok.s19": test_srecord, "bad.hex": ":00000001FE\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"ok.ihx", "ok.s19"} {
+		Initialize()
+		if img, err := LoadFile(filepath.Join(dir, name)); err != nil || len(img.Segments) != 2 {
+			t.Errorf("%s: %v, %v", name, img, err)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.hex")
+	if _, err := LoadFile(bad); err == nil || !strings.HasPrefix(err.Error(), bad+": line 1: checksum mismatch") {
+		t.Errorf("error %v", err)
+	}
+	if _, err := LoadFile(filepath.Join(dir, "image.bin")); err == nil || !strings.Contains(err.Error(), "a load address is needed") {
+		t.Errorf("raw binary: %v", err)
+	}
+}
+
+// Raw binary with offset, length and mirroring, PRG, XEX and Apple DOS 3.3 containers
+func TestLoadContainers(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		read     func(r io.Reader) (*LoadImage, error)
+		data     []byte
+		segments []LoadSegment
+		start    int // -1 without start address
+		memory   uint16
+		want     []byte
+		init     []uint16
+	}{
+		{
+			"binary",
+			func(r io.Reader) (*LoadImage, error) {
+				return ReadBinary(r, LoadOptions{Address: 0xF000, Offset: 1, Length: 3, MirrorEnd: 0xF007})
+			},
+			[]byte{1, 2, 3, 4, 5},
+			[]LoadSegment{{0xF000, 8}}, -1,
+			0xF000, []byte{2, 3, 4, 2, 3, 4, 2, 3}, nil,
+		},
+		{
+			"PRG", ReadPRG,
+			[]byte{0x01, 0x08, 0xA9, 0x00},
+			[]LoadSegment{{0x0801, 2}}, -1,
+			0x0801, []byte{0xA9, 0x00}, nil,
+		},
+		{
+			"XEX", ReadXEX,
+			[]byte{
+				0xFF, 0xFF, 0x00, 0x06, 0x01, 0x06, 0xA9, 0x00, // $0600-$0601
+				0xE2, 0x02, 0xE3, 0x02, 0x00, 0x06, //             INITAD without the $FFFF marker
+				0xFF, 0xFF, 0x02, 0x06, 0x02, 0x06, 0x60, //       $0602
+				0xE0, 0x02, 0xE1, 0x02, 0x01, 0x06, //             RUNAD
+			},
+			[]LoadSegment{{0x0600, 2}, {0x02E2, 2}, {0x0602, 1}, {0x02E0, 2}}, 0x0601,
+			0x0600, []byte{0xA9, 0x00, 0x60}, []uint16{0x0600},
+		},
+		{
+			"Apple DOS 3.3", ReadAppleBinary,
+			[]byte{0x00, 0x03, 0x02, 0x00, 0xA9, 0x00, 0xFF},
+			[]LoadSegment{{0x0300, 2}}, 0x0300,
+			0x0300, []byte{0xA9, 0x00, 0x00}, nil,
+		},
+	} {
+		Initialize()
+
+		img, err := test.read(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(img.Segments, test.segments) || !reflect.DeepEqual(img.Init, test.init) {
+			t.Errorf("%s: segments %v and init %v, want %v and %v", test.name, img.Segments, img.Init, test.segments, test.init)
+		}
+		if test.start < 0 && img.HasStart || test.start >= 0 && (!img.HasStart || int(img.Start) != test.start || int(PC) != test.start) {
+			t.Errorf("%s: start $%04X (%v) and PC $%04X", test.name, img.Start, img.HasStart, PC)
+		}
+		if got := Memory[test.memory : int(test.memory)+len(test.want)]; !bytes.Equal(got, test.want) {
+			t.Errorf("%s: memory % X, want % X", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadContainerErrors(t *testing.T) {
+
+	binary := func(opts LoadOptions) func(r io.Reader) (*LoadImage, error) {
+		return func(r io.Reader) (*LoadImage, error) { return ReadBinary(r, opts) }
+	}
+
+	for _, test := range []struct {
+		read func(r io.Reader) (*LoadImage, error)
+		data []byte
+		err  string
+	}{
+		{binary(LoadOptions{Offset: 4}), []byte{1, 2, 3}, "offset 4 beyond the 3 bytes of the file"},
+		{binary(LoadOptions{Offset: 1, Length: 3}), []byte{1, 2, 3}, "length 3 beyond the 2 bytes after the offset"},
+		{binary(LoadOptions{Offset: 3}), []byte{1, 2, 3}, "no data to load"},
+		{binary(LoadOptions{Address: 0xFFFE}), []byte{1, 2, 3}, "3 bytes at $FFFE don't fit"},
+		{binary(LoadOptions{Address: 0x0300, MirrorEnd: 0x0301}), []byte{1, 2, 3}, "mirror end $0301 inside the data at $0300-$0302"},
+		{ReadPRG, []byte{0x01}, "missing the load address header"},
+		{ReadPRG, []byte{0xFF, 0xFF, 1, 2}, "2 bytes at $FFFF don't fit"},
+		{ReadXEX, []byte{0x00, 0x03, 0x00, 0x03, 0x60}, "missing the $FFFF header"},
+		{ReadXEX, []byte{0xFF, 0xFF, 0x00, 0x03, 0x00}, "segment 1: truncated header"},
+		{ReadXEX, []byte{0xFF, 0xFF, 0x00, 0x03, 0x00, 0x03, 0x60, 0x01, 0x03, 0x00, 0x03}, "segment 2: end $0300 lower than start $0301"},
+		{ReadXEX, []byte{0xFF, 0xFF, 0x00, 0x03, 0x02, 0x03, 0x60}, "segment 1: $0300-$0302 needs 3 bytes, 1 left"},
+		{ReadAppleBinary, []byte{0x00, 0x03, 0x01}, "missing the address and length header"},
+		{ReadAppleBinary, []byte{0x00, 0x03, 0x02, 0x00, 0x60}, "header length 2 bigger than the 1 bytes"},
+		{ReadAppleBinary, []byte{0xFF, 0xFF, 0x02, 0x00, 0x60, 0x60}, "2 bytes at $FFFF don't fit"},
+	} {
+		Initialize()
+
+		if _, err := test.read(bytes.NewReader(test.data)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("% X: error %v, want %q", test.data, err, test.err)
+		}
+		if Memory[0x0300] != 0 {
+			t.Errorf("% X: memory changed", test.data)
+		}
+	}
+}
+
+// ---------------------------------- o65 ----------------------------------- //
+
+// Parts of an o65 file, modified by the error tests
+type test_o65 struct {
+	header, options, text, data, undefined, text_reloc, data_reloc, exports []byte
+}
+
+// Text at $1000 referencing itself, the data and the zero page, data at $2000
+// with the address of the imported chrout, bss at $3000 cleared on load
+func testO65() test_o65 {
+	return test_o65{
+		header: []byte{
+			0x01, 0x00, 'o', '6', '5', 0x00,
+			0x00, 0x02, // Mode: BSSZERO
+			0x00, 0x10, 0x08, 0x00, // Text $1000, 8 bytes
+			0x00, 0x20, 0x02, 0x00, // Data $2000, 2 bytes
+			0x00, 0x30, 0x02, 0x00, // BSS $3000, 2 bytes
+			0x10, 0x00, 0x02, 0x00, // Zero $10, 2 bytes
+			0x00, 0x00, // Stack
+		},
+		options: []byte{0x05, 0x00, 'a', 'b', 'c', 0x00},
+		text: []byte{
+			0x20, 0x06, 0x10, // JSR $1006
+			0xA9, 0x20, //       LDA #>data
+			0xA5, 0x10, //       LDA zero
+			0x60, //             RTS
+		},
+		data:      []byte{0x00, 0x00},
+		undefined: []byte{0x01, 0x00, 'c', 'h', 'r', 'o', 'u', 't', 0x00},
+		text_reloc: []byte{
+			0x02, 0x82, //       Word at 1, text
+			0x03, 0x43, 0x00, // High byte at 4, data, low byte $00
+			0x02, 0x25, //       Low byte at 6, zero page
+			0x00,
+		},
+		data_reloc: []byte{0x01, 0x80, 0x00, 0x00, 0x00}, // Word at 0, undefined symbol 0
+		exports:    []byte{0x02, 0x00, 'm', 'a', 'i', 'n', 0x00, 0x02, 0x00, 0x10, 'b', 'u', 'f', 0x00, 0x04, 0x00, 0x30},
+	}
+}
+
+func (f test_o65) bytes() []byte {
+	return bytes.Join([][]byte{f.header, f.options, f.text, f.data, f.undefined, f.text_reloc, f.data_reloc, f.exports}, nil)
+}
+
+func testImports(name string) (uint16, bool) {
+	if name == "chrout" {
+		return 0xFFD2, true
+	}
+	return 0, false
+}
+
+func TestO65(t *testing.T) {
+
+	o, err := ReadO65(bytes.NewReader(testO65().bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Base != (O65Layout{0x1000, 0x2000, 0x3000, 0x10}) || o.BSSLen != 2 || o.ZeroLen != 2 {
+		t.Errorf("layout %+v, bss %d, zero page %d", o.Base, o.BSSLen, o.ZeroLen)
+	}
+	if len(o.Options) != 1 || string(o.Options[0].Data) != "abc" || !reflect.DeepEqual(o.Undefined, []string{"chrout"}) {
+		t.Errorf("options %v, undefined %v", o.Options, o.Undefined)
+	}
+
+	Initialize()
+	Memory[0xC200], Memory[0xC201] = 0xAA, 0xAA
+
+	img, exports, err := o.Load(O65Layout{Text: 0xC000, Data: 0xC100, BSS: 0xC200, Zero: 0x80}, testImports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []byte{0x20, 0x06, 0xC0, 0xA9, 0xC1, 0xA5, 0x80, 0x60}; !bytes.Equal(Memory[0xC000:0xC008], want) {
+		t.Errorf("text % X, want % X", Memory[0xC000:0xC008], want)
+	}
+	if !bytes.Equal(Memory[0xC100:0xC104], []byte{0xD2, 0xFF, 0x00, 0x00}) {
+		t.Errorf("data and bss % X", Memory[0xC100:0xC104])
+	}
+	if want := []LoadSegment{{0xC000, 8}, {0xC100, 2}, {0xC200, 2}}; !reflect.DeepEqual(img.Segments, want) {
+		t.Errorf("segments %v, want %v", img.Segments, want)
+	}
+	if want := map[string]uint16{"main": 0xC000, "buf": 0xC200}; !reflect.DeepEqual(exports, want) {
+		t.Errorf("exports %v, want %v", exports, want)
+	}
+
+	// At the addresses of the file
+	file := filepath.Join(t.TempDir(), "test.o65")
+	if err := os.WriteFile(file, testO65().bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Initialize()
+	if _, exports, err := LoadO65(file, nil, testImports); err != nil || exports["main"] != 0x1000 || Memory[0x1002] != 0x10 || Memory[0x1004] != 0x20 {
+		t.Errorf("load at the file addresses: %v, exports %v", err, exports)
+	}
+	if _, _, err := LoadO65(file, nil, nil); err == nil || !strings.HasPrefix(err.Error(), file+": undefined symbol \"chrout\"") {
+		t.Errorf("missing import: %v", err)
+	}
+}
+
+func TestO65Errors(t *testing.T) {
+
+	for _, test := range []struct {
+		name   string
+		change func(f *test_o65)
+		err    string
+	}{
+		{"magic", func(f *test_o65) { f.header[2] = 'O' }, "not an o65 file"},
+		{"version", func(f *test_o65) { f.header[5] = 1 }, "unsupported o65 version 1"},
+		{"65816", func(f *test_o65) { f.header[7] |= 0x80 }, "65816 o65 files are not supported"},
+		{"header", func(f *test_o65) { f.header = f.header[:20]; f.options = nil; f.text = nil }, "truncated header"},
+		{"segment", func(f *test_o65) { f.header[11] = 0xFF }, "segment outside the 64KB address space"},
+		{"zero page", func(f *test_o65) { f.header[20] = 0xFF }, "zero page segment $FF-$100 outside the zero page"},
+		{"option", func(f *test_o65) { f.options = []byte{0x01, 0x00} }, "invalid header option length 1"},
+		{"text", func(f *test_o65) {
+			f.text = f.text[:4]
+			f.data, f.undefined, f.text_reloc, f.data_reloc, f.exports = nil, nil, nil, nil, nil
+		}, "truncated text or data segment"},
+		{"undefined", func(f *test_o65) {
+			f.undefined, f.text_reloc, f.data_reloc, f.exports = []byte{0x02, 0x00, 'x', 0x00}, nil, nil, nil
+		}, "truncated undefined symbols list"},
+		{"reloc index", func(f *test_o65) { f.data_reloc = []byte{0x01, 0x80, 0x01, 0x00, 0x00} }, "data relocation table: reference to undefined symbol 1 of 1"},
+		{"reloc segment", func(f *test_o65) { f.text_reloc = []byte{0x02, 0x86, 0x00} }, "text relocation table: invalid segment 6 at offset 1"},
+		{"reloc type", func(f *test_o65) { f.text_reloc = []byte{0x02, 0x62, 0x00} }, "unsupported relocation type $60 at offset 1"},
+		{"reloc offset", func(f *test_o65) { f.text_reloc = []byte{0x08, 0x82, 0x00} }, "relocation at offset 7 outside the 8 bytes segment"},
+		{"reloc long", func(f *test_o65) { f.text_reloc = []byte{0xFF, 0x01, 0x82, 0x00} }, "relocation at offset 254 outside"},
+		{"reloc truncated", func(f *test_o65) { f.data_reloc, f.exports = []byte{0x01, 0x80, 0x00}, nil }, "data relocation table: truncated"},
+		{"export segment", func(f *test_o65) { f.exports = []byte{0x01, 0x00, 'x', 0x00, 0x06, 0x00, 0x00} }, "exported symbol \"x\" in invalid segment 6"},
+		{"exports", func(f *test_o65) { f.exports = f.exports[:8] }, "truncated exported symbols list"},
+	} {
+		f := testO65()
+		test.change(&f)
+
+		if _, err := ReadO65(bytes.NewReader(f.bytes())); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	// Layouts checked before loading
+	o, _ := ReadO65(bytes.NewReader(testO65().bytes()))
+	Initialize()
+	if _, _, err := o.Load(O65Layout{Text: 0xFFFC, Data: 0xC100, BSS: 0xC200, Zero: 0x80}, testImports); err == nil || !strings.Contains(err.Error(), "outside the 64KB") {
+		t.Errorf("text at $FFFC: %v", err)
+	}
+	if _, _, err := o.Load(O65Layout{Text: 0xC000, Data: 0xC100, BSS: 0xC200, Zero: 0xFF}, testImports); err == nil || !strings.Contains(err.Error(), "outside the zero page") {
+		t.Errorf("zero page at $FF: %v", err)
+	}
+	if Memory[0xC000] != 0 {
+		t.Errorf("memory changed by a failed load")
+	}
+}
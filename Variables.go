@@ -60,9 +60,15 @@ var (
 	// Enable or disable CPU during WSYNC
 	CPU_Enabled bool
 
+	// Opcode not implemented at PC, the CPU stopped (like the NMOS JAM opcodes) until Reset
+	Halted bool
+
 	// Pause
 	Pause bool = true
 
 	// Debug
 	Debug bool = true
+
+	// Optional symbol lookup, used by Disassemble and the debug messages (JSR init_screen)
+	Symbols func(addr uint16) (string, bool)
 )
@@ -0,0 +1,421 @@
+// Package acia emulates the MOS 6551 Asynchronous Communications Interface
+// Adapter, with the serial side connected to a host stream (stdin / stdout,
+// a pipe or a TCP connection):
+//
+//	a, err := acia.Attach(0x8000, conn)
+//
+// Registers: 0 data (write transmit, read receive), 1 status (write:
+// programmed reset), 2 command, 3 control. The characters take the time of
+// their frame (start bit, data bits, parity and stop bits) at the baud rate
+// of the control register, counted in CPU cycles at CPUClock. The 16x external
+// clock (rate 0) runs at ExternalBaud.
+//
+// With W65C51 set the chip has the bug of the WDC part: TDRE is always set,
+// the transmitter never interrupts and a write while a character is being
+// sent replaces it, so the software has to wait the character time.
+//
+// The stream is written in the background: while the host is behind by more
+// than the buffer, the transmitter holds its character and TDRE stays clear.
+package acia
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Registers
+const (
+	DATA    = 0x0
+	STATUS  = 0x1
+	COMMAND = 0x2
+	CONTROL = 0x3
+)
+
+// Status bits
+const (
+	ST_PARITY  = 0x01 // Never set, the host bytes have no errors
+	ST_FRAMING = 0x02
+	ST_OVERRUN = 0x04
+	ST_RDRF    = 0x08 // Receive data register full
+	ST_TDRE    = 0x10 // Transmit data register empty
+	ST_DCD     = 0x20 // Carrier and data set ready are always asserted (low)
+	ST_DSR     = 0x40
+	ST_IRQ     = 0x80
+)
+
+// Baud rates of the control register bits 0-3
+var rates = [16]float64{0, 50, 75, 109.92, 134.58, 150, 300, 600, 1200, 1800, 2400, 3600, 4800, 7200, 9600, 19200}
+
+// Chip state, saved in the snapshots (the bytes buffered from the stream aren't)
+type state struct {
+	Control, Command byte
+	TDR, TSR         byte // Transmit data and shift registers
+	RDR, RSR         byte // Receive data and shift registers
+	TDRFull          bool
+	RDRF             bool
+	Overrun          bool
+	IRQ              bool
+	TXCount          uint32 // Cycles left of the character being sent (0 = idle)
+	RXCount          uint32 // Cycles left of the character being received
+}
+
+// ACIA is a 6551 chip
+type ACIA struct {
+	s    state
+	irq  *CPU_6502.InterruptLine
+	base uint16
+
+	rx   chan byte     // Bytes read from the stream
+	tx   chan byte     // Bytes to write to the stream
+	done chan struct{} // Closed by Detach, stops the reader
+	stop sync.Once
+
+	stream io.ReadWriter
+
+	CPUClock     int  // CPU frequency in Hz (default 1 MHz)
+	ExternalBaud int  // Baud rate of the external clock (default 115200)
+	W65C51       bool // WDC transmitter bug
+}
+
+// New creates a chip in the reset state connected to stream, reading and
+// writing it in the background
+func New(stream io.ReadWriter) *ACIA {
+
+	a := &ACIA{
+		irq:          CPU_6502.NewIRQLine(),
+		rx:           make(chan byte, 4096),
+		tx:           make(chan byte, 4096),
+		done:         make(chan struct{}),
+		stream:       stream,
+		CPUClock:     1000000,
+		ExternalBaud: 115200,
+	}
+	a.Reset()
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := stream.Read(buf); err != nil {
+				close(a.rx)
+				return
+			}
+			select {
+			case a.rx <- buf[0]:
+			case <-a.done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for b := range a.tx {
+			if _, err := stream.Write([]byte{b}); err != nil {
+				// Keep draining, the serial line has no flow control
+				continue
+			}
+		}
+	}()
+
+	return a
+}
+
+// Attach creates a chip connected to stream and maps it at base (4 registers)
+func Attach(base uint16, stream io.ReadWriter) (*ACIA, error) {
+
+	a := New(stream)
+
+	if err := CPU_6502.AttachDevice(a, base, 4); err != nil {
+		a.close()
+		return nil, err
+	}
+
+	a.base = base
+	CPU_6502.RegisterSnapshotChunk(a.snapshotTag(), a.save, a.restore)
+
+	return a, nil
+}
+
+// Detach removes the chip from the bus and stops reading and writing the
+// stream (closing it is up to the caller). It can be called more than once
+func (a *ACIA) Detach() {
+	CPU_6502.DetachDevice(a)
+	CPU_6502.UnregisterSnapshotChunk(a.snapshotTag())
+	a.irq.Set(false)
+	a.close()
+}
+
+// Stop the background reader and writer. A read in progress is interrupted on
+// streams with a deadline (network connections, pipes), otherwise the reader
+// stops when it returns
+func (a *ACIA) close() {
+	a.stop.Do(func() {
+		close(a.done)
+		close(a.tx)
+		if d, ok := a.stream.(interface{ SetReadDeadline(time.Time) error }); ok {
+			_ = d.SetReadDeadline(time.Now())
+		}
+	})
+}
+
+// Snapshot chunk tag from the base address ("A800" for $8000)
+func (a *ACIA) snapshotTag() string {
+	return fmt.Sprintf("A%03X", a.base>>4)
+}
+
+// Reset is the hardware reset: control and command cleared, transmitter empty
+func (a *ACIA) Reset() {
+	a.s.Control, a.s.Command = 0, 0
+	a.s.TDRFull, a.s.RDRF, a.s.Overrun, a.s.IRQ = false, false, false, false
+	a.s.TXCount, a.s.RXCount = 0, 0
+	a.updateIRQ()
+}
+
+// Cycles of a character frame
+func (a *ACIA) frame() uint32 {
+
+	baud := rates[a.s.Control&0x0F]
+	if baud == 0 {
+		baud = float64(a.ExternalBaud)
+	}
+
+	bits := 1 + a.wordLength()
+	if a.s.Command&0x20 != 0 { // Parity
+		bits++
+	}
+	if a.s.Control&0x80 != 0 {
+		bits += 2
+	} else {
+		bits++
+	}
+
+	cycles := uint32(float64(bits) * float64(a.CPUClock) / baud)
+	if cycles == 0 {
+		cycles = 1
+	}
+
+	return cycles
+}
+
+// Data bits per character (5 to 8)
+func (a *ACIA) wordLength() int {
+	return 8 - int(a.s.Control>>5&3)
+}
+
+func (a *ACIA) dataMask() byte {
+	return byte(0xFF >> (8 - a.wordLength()))
+}
+
+// Transmitter interrupt enabled (command bits 2-3 = 01)
+func (a *ACIA) txIRQ() bool {
+	return a.s.Command&0x0C == 0x04 && !a.W65C51
+}
+
+// Receiver and its interrupt enabled (DTR on, bit 1 clear)
+func (a *ACIA) rxIRQ() bool {
+	return a.s.Command&0x03 == 0x01
+}
+
+func (a *ACIA) updateIRQ() {
+	a.irq.Set(a.s.IRQ && a.s.Command&0x01 != 0)
+}
+
+// ------------------------------- Registers -------------------------------- //
+
+// Read a register (CPU data BUS)
+func (a *ACIA) Read(offset uint16) byte {
+
+	switch offset & 3 {
+
+	case DATA:
+		a.s.RDRF, a.s.Overrun = false, false
+		return a.s.RDR
+
+	case STATUS:
+		var value byte
+		if a.s.Overrun {
+			value |= ST_OVERRUN
+		}
+		if a.s.RDRF {
+			value |= ST_RDRF
+		}
+		if !a.s.TDRFull || a.W65C51 {
+			value |= ST_TDRE
+		}
+		if a.s.IRQ {
+			value |= ST_IRQ
+		}
+		a.s.IRQ = false
+		a.updateIRQ()
+		return value
+
+	case COMMAND:
+		return a.s.Command
+
+	default:
+		return a.s.Control
+	}
+}
+
+// Write a register (CPU data BUS)
+func (a *ACIA) Write(offset uint16, value byte) {
+
+	switch offset & 3 {
+
+	case DATA:
+		if a.W65C51 {
+			a.s.TSR = value & a.dataMask()
+			a.s.TXCount = a.frame()
+			return
+		}
+		a.s.TDR = value & a.dataMask()
+		a.s.TDRFull = true
+
+	case STATUS: // Programmed reset
+		a.s.Command &= 0xE0
+		a.s.Overrun = false
+		a.updateIRQ()
+
+	case COMMAND:
+		a.s.Command = value
+		// Enabling the transmitter interrupt with an empty register requests it
+		if a.txIRQ() && !a.s.TDRFull {
+			a.s.IRQ = true
+		}
+		a.updateIRQ()
+
+	case CONTROL:
+		a.s.Control = value
+	}
+}
+
+// ------------------------------- Serial line ------------------------------ //
+
+// Clock runs one cycle (CPU_6502.Clocked)
+func (a *ACIA) Clock() {
+
+	// Transmitter, holding the character while the writer is behind
+	if a.s.TXCount > 0 {
+		if a.s.TXCount--; a.s.TXCount == 0 && !a.send(a.s.TSR) {
+			a.s.TXCount = 1
+		}
+	}
+
+	if a.s.TXCount == 0 && a.s.TDRFull {
+		a.s.TSR = a.s.TDR
+		a.s.TDRFull = false
+		a.s.TXCount = a.frame()
+		if a.txIRQ() {
+			a.s.IRQ = true
+			a.updateIRQ()
+		}
+	}
+
+	// Receiver, disabled while DTR is off
+	if a.s.RXCount > 0 {
+		if a.s.RXCount--; a.s.RXCount == 0 {
+			a.receive()
+		}
+		return
+	}
+
+	if a.s.Command&0x01 == 0 {
+		return
+	}
+
+	select {
+	case b, ok := <-a.rx:
+		if ok {
+			a.s.RSR = b
+			a.s.RXCount = a.frame()
+		}
+	default:
+	}
+}
+
+// ClockCycles runs several cycles (CPU_6502.MultiClocked)
+func (a *ACIA) ClockCycles(cycles int) {
+	for ; cycles > 0; cycles-- {
+		a.Clock()
+	}
+}
+
+// Character received: a full register is an overrun and keeps its data
+func (a *ACIA) receive() {
+
+	if a.s.RDRF {
+		a.s.Overrun = true
+		return
+	}
+
+	a.s.RDR = a.s.RSR & a.dataMask()
+	a.s.RDRF = true
+
+	// Echo mode, with the transmitter idle (command bits 2-3 = 00), lost while the writer is behind
+	if a.s.Command&0x1C == 0x10 {
+		a.send(a.s.RDR)
+	}
+
+	if a.rxIRQ() {
+		a.s.IRQ = true
+		a.updateIRQ()
+	}
+}
+
+// Queue a byte for the writer without blocking the CPU, false when the buffer is full
+// After Detach the bytes are dropped
+func (a *ACIA) send(b byte) bool {
+
+	select {
+	case <-a.done:
+		return true
+	default:
+	}
+
+	select {
+	case a.tx <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// ------------------------------- Snapshots -------------------------------- //
+
+func (a *ACIA) save() []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, &a.s)
+	return buf.Bytes()
+}
+
+func (a *ACIA) restore(data []byte) error {
+
+	var s state
+
+	if len(data) != binary.Size(&s) {
+		return fmt.Errorf("invalid ACIA state size %d", len(data))
+	}
+	_ = binary.Read(bytes.NewReader(data), binary.LittleEndian, &s)
+
+	a.s = s
+	a.updateIRQ()
+
+	return nil
+}
+
+// String shows the registers, for the monitors
+func (a *ACIA) String() string {
+
+	baud := fmt.Sprintf("%g", rates[a.s.Control&0x0F])
+	if a.s.Control&0x0F == 0 {
+		baud = fmt.Sprintf("%d (external)", a.ExternalBaud)
+	}
+
+	return fmt.Sprintf("ACIA $%04X  COMMAND %02X CONTROL %02X  %s baud %d bits  RDRF %t TDRE %t OVERRUN %t IRQ %t",
+		a.base, a.s.Command, a.s.Control, baud, a.wordLength(), a.s.RDRF, !a.s.TDRFull || a.W65C51, a.s.Overrun, a.s.IRQ)
+}
@@ -0,0 +1,247 @@
+package acia
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Host stream whose writes block until the reader side is read
+type test_stream struct {
+	io.Reader
+	io.Writer
+}
+
+// One cycle characters (8N1, external clock)
+func testACIA(stream io.ReadWriter) *ACIA {
+	a := New(stream)
+	a.CPUClock, a.ExternalBaud = 1000, 10000
+	a.Write(CONTROL, 0x00)
+	return a
+}
+
+// A host that doesn't read holds the transmitter instead of blocking the CPU
+func TestTransmitHostBehind(t *testing.T) {
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	a := testACIA(test_stream{pr, pw})
+	defer a.close()
+
+	sent := 0
+	for cycle := 0; cycle < 20000; cycle++ {
+		if a.Read(STATUS)&ST_TDRE != 0 {
+			a.Write(DATA, byte(sent))
+			sent++
+		}
+		a.Clock()
+	}
+
+	// The buffer, the shift and data registers and the byte the writer is blocked on (if it got it)
+	if max := cap(a.tx) + 3; sent < max-1 || sent > max {
+		t.Errorf("%d characters accepted, want %d or %d", sent, max-1, max)
+	}
+	if a.Read(STATUS)&ST_TDRE != 0 {
+		t.Errorf("TDRE set with the host behind")
+	}
+
+	// The host catches up
+	go io.Copy(io.Discard, pr)
+	for cycle := 0; cycle < 100000 && a.Read(STATUS)&ST_TDRE == 0; cycle++ {
+		a.Clock()
+		time.Sleep(time.Microsecond)
+	}
+	if a.Read(STATUS)&ST_TDRE == 0 {
+		t.Errorf("TDRE still clear after the host read the stream")
+	}
+}
+
+// Detach can be repeated and stops the reader blocked on the stream
+func TestDetach(t *testing.T) {
+
+	host, conn := net.Pipe()
+	defer host.Close()
+
+	a, err := Attach(0x8000, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Detach()
+	a.Detach()
+	a.Clock() // Transmitter and receiver idle, nothing to send
+
+	select {
+	case _, ok := <-a.rx:
+		if ok {
+			t.Errorf("byte received after Detach")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("reader still running after Detach")
+	}
+}
+
+// Clocks until cond, giving the background reader and writer time to run
+func testClockUntil(t *testing.T, a *ACIA, cond func() bool) {
+	t.Helper()
+	for i := 0; !cond(); i++ {
+		if i == 100000 {
+			t.Fatalf("condition not reached, %s", a)
+		}
+		a.Clock()
+		time.Sleep(time.Microsecond)
+	}
+}
+
+// Chip outside the bus, stopped and its IRQ line released at the end of the test
+func testChip(t *testing.T, stream io.ReadWriter) *ACIA {
+	a := testACIA(stream)
+	t.Cleanup(func() {
+		a.close()
+		a.irq.Set(false)
+	})
+	return a
+}
+
+// Received characters, their interrupt and the overrun of a full register
+func TestReceive(t *testing.T) {
+
+	a := testChip(t, test_stream{strings.NewReader("AB"), io.Discard})
+
+	a.Clock()
+	if a.s.RXCount != 0 || a.s.RDRF {
+		t.Fatalf("character received with DTR off")
+	}
+
+	a.Write(COMMAND, 0x09) // DTR, receiver interrupt, transmitter interrupt off
+	testClockUntil(t, a, func() bool { return a.s.RDRF })
+
+	if !a.irq.Active() {
+		t.Errorf("no IRQ on the received character")
+	}
+	if st := a.Read(STATUS); st != ST_IRQ|ST_TDRE|ST_RDRF || a.irq.Active() {
+		t.Errorf("status $%02X, IRQ %v after the status read", st, a.irq.Active())
+	}
+
+	// B arrives while A wasn't read
+	testClockUntil(t, a, func() bool { return a.s.Overrun })
+	if st := a.Read(STATUS); st&ST_OVERRUN == 0 || a.Read(DATA) != 'A' || a.Read(STATUS)&(ST_OVERRUN|ST_RDRF) != 0 {
+		t.Errorf("overrun status $%02X", st)
+	}
+
+	// Programmed reset clears the command bits 0-4
+	a.Write(COMMAND, 0xEB)
+	a.Write(STATUS, 0)
+	if a.Read(COMMAND) != 0xE0 {
+		t.Errorf("command $%02X after the programmed reset", a.Read(COMMAND))
+	}
+}
+
+// Word length, echo mode and the transmitter interrupt
+func TestTransmit(t *testing.T) {
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	a := testChip(t, test_stream{strings.NewReader("\xC1"), pw})
+	a.Write(CONTROL, 0x60) // 5 bits
+	a.Write(COMMAND, 0x13) // DTR, echo, receiver interrupt off
+	testClockUntil(t, a, func() bool { return a.s.RDRF })
+
+	echo := make([]byte, 1)
+	if _, err := io.ReadFull(pr, echo); err != nil || echo[0] != 0x01 || a.Read(DATA) != 0x01 || a.irq.Active() {
+		t.Errorf("received $%02X, echo $%02X", a.s.RDR, echo[0])
+	}
+
+	// Transmitter interrupt: requested when enabled with an empty register, then when the character moves to the shift register
+	a.Write(CONTROL, 0x00)
+	a.Write(COMMAND, 0x05)
+	if !a.irq.Active() || a.Read(STATUS)&ST_IRQ == 0 {
+		t.Errorf("no IRQ with the transmit register empty")
+	}
+	a.Write(DATA, 'Z')
+	if a.Read(STATUS)&ST_TDRE != 0 {
+		t.Errorf("TDRE set with a character to send")
+	}
+	a.Clock()
+	if !a.irq.Active() || a.Read(STATUS) != ST_IRQ|ST_TDRE {
+		t.Errorf("no IRQ when the character was sent to the shift register")
+	}
+	testClockUntil(t, a, func() bool { return a.s.TXCount == 0 })
+	if _, err := io.ReadFull(pr, echo); err != nil || echo[0] != 'Z' {
+		t.Errorf("sent %q, %v", echo, err)
+	}
+}
+
+// The W65C51 transmitter never interrupts and TDRE is always set
+func TestW65C51(t *testing.T) {
+
+	a := testChip(t, test_stream{strings.NewReader(""), io.Discard})
+	a.W65C51 = true
+
+	a.Write(COMMAND, 0x05)
+	a.Write(DATA, 'Z')
+	if a.irq.Active() || a.Read(STATUS)&ST_TDRE == 0 || a.s.TXCount == 0 {
+		t.Errorf("W65C51 transmitter: %s", a)
+	}
+}
+
+// Frame length in cycles from the baud rate, parity and stop bits
+func TestFrame(t *testing.T) {
+
+	a := testChip(t, test_stream{strings.NewReader(""), io.Discard})
+	a.CPUClock, a.ExternalBaud = 1000000, 115200
+
+	for _, test := range []struct {
+		control, command byte
+		cycles           uint32
+	}{
+		{0x1F, 0x00, 520},  // 19200 8N1
+		{0x1F, 0x20, 572},  // 19200 8E1
+		{0x9E, 0x00, 1145}, // 9600 8N2
+		{0x7E, 0x00, 729},  // 9600 5N1
+		{0x00, 0x00, 86},   // 115200 external
+	} {
+		a.Write(CONTROL, test.control)
+		a.Write(COMMAND, test.command)
+		if n := a.frame(); n != test.cycles {
+			t.Errorf("control $%02X command $%02X: %d cycles, want %d", test.control, test.command, n, test.cycles)
+		}
+	}
+}
+
+// The registers are saved in the snapshots
+func TestSnapshot(t *testing.T) {
+
+	CPU_6502.Initialize()
+
+	host, conn := net.Pipe()
+	defer host.Close()
+
+	a, err := Attach(0x8000, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Detach()
+
+	a.Write(CONTROL, 0x1E)
+	a.Write(COMMAND, 0x05)
+
+	snap := CPU_6502.Snapshot()
+	a.Reset()
+
+	if err := CPU_6502.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if a.Read(CONTROL) != 0x1E || a.Read(COMMAND) != 0x05 || !CPU_6502.IRQ() {
+		t.Errorf("restored %s", a)
+	}
+	if err := a.restore([]byte{1}); err == nil || err.Error() != "invalid ACIA state size 1" {
+		t.Errorf("error %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package apple1 is an Apple-1 built on the core: 4 or 8 KB of RAM, the
+// keyboard and the display on a 6820 PIA, and the Woz Monitor ROM.
+//
+//	$0000-$0FFF  RAM
+//	$D010-$D013  PIA (KBD, KBDCR, DSP, DSPCR), selected by A4 in $D000-$DFFF
+//	$E000-$EFFF  RAM of the 8 KB configuration (Integer BASIC)
+//	$FF00-$FFFF  ROM (Woz Monitor, a larger ROM file ends at $FFFF)
+//
+// The keyboard reads a host stream (lower case is converted, newlines are
+// RETURN and backspace / delete the "_" rubout), the display writes the
+// characters to a host stream. The CPU runs at 1 MHz.
+package apple1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/pia"
+)
+
+// PIA registers
+const (
+	KBD   = 0xD010
+	KBDCR = 0xD011
+	DSP   = 0xD012
+	DSPCR = 0xD013
+)
+
+const clock = 1000000 // Cycles per second
+
+// Config of the machine
+type Config struct {
+	ROM   string    // ROM file, mapped up to $FFFF (at most 4 KB)
+	RAM   int       // KB of RAM: 4 or 8
+	In    io.Reader // Keyboard (default os.Stdin)
+	Out   io.Writer // Display (default os.Stdout)
+	Turbo bool      // Run as fast as possible instead of 1 MHz
+}
+
+// Apple1 is the machine, the CPU is the core
+type Apple1 struct {
+	PIA *pia.PIA
+
+	keys  chan byte
+	out   io.Writer
+	turbo bool
+}
+
+// New builds the machine and resets the CPU
+func New(cfg Config) (*Apple1, error) {
+
+	if cfg.RAM != 4 && cfg.RAM != 8 {
+		return nil, fmt.Errorf("%d KB of RAM, the Apple-1 has 4 or 8", cfg.RAM)
+	}
+	if cfg.In == nil {
+		cfg.In = os.Stdin
+	}
+	if cfg.Out == nil {
+		cfg.Out = os.Stdout
+	}
+
+	rom, err := os.ReadFile(cfg.ROM)
+	if err != nil {
+		return nil, err
+	}
+	if len(rom) == 0 || len(rom) > 4096 {
+		return nil, fmt.Errorf("%s: ROM of %d bytes, up to 4 KB expected", cfg.ROM, len(rom))
+	}
+	start := uint16(0x10000 - len(rom))
+
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+
+	if _, err := CPU_6502.ReadBinary(bytes.NewReader(rom), CPU_6502.LoadOptions{Address: start}); err != nil {
+		return nil, fmt.Errorf("%s: %v", cfg.ROM, err)
+	}
+
+	// Memory map, the addresses without chips read the open bus
+	CPU_6502.ClearMemoryMap()
+	regions := []CPU_6502.Region{
+		CPU_6502.NewRegion("ram", 0x0000, 0x0FFF, CPU_6502.REGION_RAM),
+		CPU_6502.NewRegion("", 0x1000, 0xCFFF, CPU_6502.REGION_UNMAPPED),
+		CPU_6502.NewRegion("pia", 0xD000, 0xDFFF, CPU_6502.REGION_IO),
+		CPU_6502.NewRegion("", 0xE000, start-1, CPU_6502.REGION_UNMAPPED),
+		CPU_6502.NewRegion("rom", start, 0xFFFF, CPU_6502.REGION_ROM),
+	}
+	if cfg.RAM == 8 {
+		regions = append(regions, CPU_6502.NewRegion("ram", 0xE000, 0xEFFF, CPU_6502.REGION_RAM))
+	}
+	for _, r := range regions {
+		if err := CPU_6502.MapRegion(r); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := pia.AttachDecoded(0xF010, 0xD010)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Apple1{PIA: p, keys: make(chan byte, 256), out: cfg.Out, turbo: cfg.Turbo}
+
+	// Display: PB7 low (ready), the characters are taken when DA (CB2) goes low
+	// and acknowledged on RDA (CB1)
+	p.SetPortB(0x00)
+	p.OnCB2 = func(level bool) {
+		if !level {
+			m.display(p.PortB() & 0x7F)
+			p.SetCB1(false)
+			p.SetCB1(true)
+		}
+	}
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := cfg.In.Read(buf); err != nil {
+				close(m.keys)
+				return
+			}
+			m.keys <- buf[0]
+		}
+	}()
+
+	CPU_6502.Reset()
+
+	return m, nil
+}
+
+// Close removes the PIA and the memory map
+func (m *Apple1) Close() {
+	m.PIA.Detach()
+	CPU_6502.ClearMemoryMap()
+}
+
+func (m *Apple1) display(c byte) {
+	switch {
+	case c == 0x0D:
+		fmt.Fprint(m.out, "\n")
+	case c >= 0x20 && c < 0x7F:
+		fmt.Fprintf(m.out, "%c", c)
+	}
+}
+
+// Key pressed, ASCII with bit 7 set on PA0-PA7 and a strobe on CA1
+func (m *Apple1) key(c byte) {
+
+	switch {
+	case c == '\n':
+		c = 0x0D
+	case c == 0x08 || c == 0x7F:
+		c = '_'
+	case c >= 'a' && c <= 'z':
+		c -= 0x20
+	}
+
+	m.PIA.SetPortA(c | 0x80)
+	m.PIA.SetCA1(false)
+	m.PIA.SetCA1(true)
+}
+
+// Run executes until the keyboard input ends, plus one second to finish the output, or the CPU halts
+func (m *Apple1) Run() error {
+
+	var (
+		start    = time.Now()
+		first    = CPU_6502.Cycle
+		end      uint64
+		keys     = m.keys
+		carriage bool // "\r\n" is a single RETURN
+	)
+
+	for end == 0 || CPU_6502.Cycle < end {
+
+		if CPU_6502.Halted {
+			return fmt.Errorf("CPU halted on opcode $%02X at $%04X", CPU_6502.Memory[CPU_6502.PC], CPU_6502.PC)
+		}
+
+		CPU_6502.Run(1000)
+
+		// Next key once the PIA is configured and the last one was read
+		if cr := m.PIA.Read(pia.CRA); keys != nil && cr&0x04 != 0 && cr&0x80 == 0 {
+			select {
+			case c, ok := <-keys:
+				switch {
+				case !ok:
+					keys = nil
+					end = CPU_6502.Cycle + clock
+				case c == '\n' && carriage:
+					carriage = false
+				default:
+					carriage = c == '\r'
+					if carriage {
+						c = '\n'
+					}
+					m.key(c)
+				}
+			default:
+			}
+		}
+
+		if !m.turbo {
+			ahead := time.Duration(CPU_6502.Cycle-first)*time.Second/clock - time.Since(start)
+			if ahead > 10*time.Millisecond {
+				time.Sleep(ahead)
+			}
+		}
+	}
+
+	return nil
+}
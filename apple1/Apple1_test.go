@@ -0,0 +1,99 @@
+package apple1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Monitor ROM at $FF00 echoing the keys to the display
+func testROM(t *testing.T) string {
+
+	rom := make([]byte, 256)
+	copy(rom, []byte{
+		0xA0, 0x7F, 0x8C, 0x12, 0xD0, //       LDY #$7F, STY DSP (DDRB)
+		0xA9, 0xA7, 0x8D, 0x11, 0xD0, //       LDA #$A7, STA KBDCR
+		0x8D, 0x13, 0xD0, //                   STA DSPCR
+		0xAD, 0x11, 0xD0, 0x10, 0xFB, // next  LDA KBDCR, BPL next
+		0xAD, 0x10, 0xD0, //                   LDA KBD
+		0x2C, 0x12, 0xD0, 0x30, 0xFB, // echo  BIT DSP, BMI echo
+		0x8D, 0x12, 0xD0, //                   STA DSP
+		0x4C, 0x0D, 0xFF, //                   JMP next
+	})
+	rom[0xFC], rom[0xFD] = 0x00, 0xFF
+
+	file := filepath.Join(t.TempDir(), "monitor.bin")
+	if err := os.WriteFile(file, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// The keys typed on the host come back on the display until the input ends
+func TestRun(t *testing.T) {
+
+	var out strings.Builder
+
+	CPU_6502.Debug = false
+	m, err := New(Config{ROM: testROM(t), RAM: 4, In: strings.NewReader("Ab\r\nc\x7f\x08"), Out: &out, Turbo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+
+	start := CPU_6502.Cycle
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "AB\nC__" {
+		t.Errorf("display %q, want %q", got, "AB\nC__")
+	}
+	if cycles := CPU_6502.Cycle - start; cycles < clock {
+		t.Errorf("stopped %d cycles after the start, the output runs for a second", cycles)
+	}
+}
+
+func TestMemoryMap(t *testing.T) {
+
+	for _, test := range []struct {
+		ram  int
+		kind CPU_6502.RegionKind
+	}{{4, CPU_6502.REGION_UNMAPPED}, {8, CPU_6502.REGION_RAM}} {
+		m, err := New(Config{ROM: testROM(t), RAM: test.ram, In: strings.NewReader("")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, ok := CPU_6502.RegionAt(0xE000); !ok || r.Kind != test.kind {
+			t.Errorf("%d KB: $E000 in %v", test.ram, r)
+		}
+		if r, ok := CPU_6502.RegionAt(0xFF00); !ok || r.Kind != CPU_6502.REGION_ROM || CPU_6502.PC != 0xFF00 {
+			t.Errorf("%d KB: $FF00 in %v, PC $%04X", test.ram, r, CPU_6502.PC)
+		}
+		m.Close()
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	big := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(big, make([]byte, 4097), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		cfg Config
+		err string
+	}{
+		{Config{ROM: testROM(t), RAM: 16}, "16 KB of RAM, the Apple-1 has 4 or 8"},
+		{Config{ROM: big, RAM: 4}, big + ": ROM of 4097 bytes, up to 4 KB expected"},
+		{Config{ROM: filepath.Join(t.TempDir(), "none.bin"), RAM: 4}, "no such file or directory"},
+	} {
+		if _, err := New(test.cfg); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("error %v, want %q", err, test.err)
+		}
+	}
+}
@@ -0,0 +1,844 @@
+// Package assembler is a two-pass 6502 assembler for tests and tooling.
+//
+// It accepts ca65 and ACME style sources: labels (with or without colon),
+// cheap local labels (@name or .name, scoped to the previous global label),
+// expressions, origin (.org / *=), data directives and every addressing mode
+// of the NMOS 6502 (optionally with the undocumented opcodes) and the 65C02.
+package assembler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Options of the assembler
+type Options struct {
+	Variant      disassembler.Variant // Initial CPU (may be changed by .setcpu / !cpu)
+	Undocumented bool                 // Accept the NMOS undocumented opcodes
+	Origin       uint16               // Program counter used before the first .org
+
+	// Symbols defined before the assembly starts
+	Symbols map[string]uint16
+}
+
+// Segment is a contiguous block of assembled bytes
+type Segment struct {
+	Address uint16
+	Data    []byte
+}
+
+// LineInfo maps a source line to the bytes it generated
+type LineInfo struct {
+	File    string
+	Line    int
+	Address uint16
+	Size    uint16
+	Code    bool // Instruction (not data)
+}
+
+// Result of an assembly
+type Result struct {
+	Segments []Segment
+	Symbols  map[string]uint16
+	Lines    []LineInfo
+}
+
+// Error in a source line
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ErrorList collects all the errors found in a pass
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0].Error(), len(l)-1)
+}
+
+// ------------------------------- Public API ------------------------------- //
+
+// Assemble a source held in memory
+func Assemble(src string, opts Options) (*Result, error) {
+	return assembleLines("<source>", src, "", opts)
+}
+
+// AssembleFile assembles a source file (.include paths are relative to it)
+func AssembleFile(filename string, opts Options) (*Result, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return assembleLines(filename, string(data), filepath.Dir(filename), opts)
+}
+
+// Image returns the lowest address and a contiguous copy of all segments
+// Gaps between segments are filled with zeros
+func (r *Result) Image() (uint16, []byte) {
+
+	if len(r.Segments) == 0 {
+		return 0, nil
+	}
+
+	low, high := 0x10000, 0
+
+	for _, s := range r.Segments {
+		if int(s.Address) < low {
+			low = int(s.Address)
+		}
+		if end := int(s.Address) + len(s.Data); end > high {
+			high = end
+		}
+	}
+
+	image := make([]byte, high-low)
+	for _, s := range r.Segments {
+		copy(image[int(s.Address)-low:], s.Data)
+	}
+
+	return uint16(low), image
+}
+
+// Load copies the segments into a 64KB memory (e.g. &CPU_6502.Memory)
+func (r *Result) Load(memory *[65536]byte) {
+	for _, s := range r.Segments {
+		copy(memory[s.Address:], s.Data)
+	}
+}
+
+// Bytes returns the assembled bytes in source order, ignoring the addresses
+func (r *Result) Bytes() []byte {
+
+	var data []byte
+
+	for _, s := range r.Segments {
+		data = append(data, s.Data...)
+	}
+
+	return data
+}
+
+// SymbolNames returns the symbol names sorted by address
+func (r *Result) SymbolNames() []string {
+
+	names := make([]string, 0, len(r.Symbols))
+	for name := range r.Symbols {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if r.Symbols[names[i]] != r.Symbols[names[j]] {
+			return r.Symbols[names[i]] < r.Symbols[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// DebugInfo returns the line table and symbols for source-level debuggers
+// File names are made absolute so the information can be used from any directory
+func (r *Result) DebugInfo() *debuginfo.Info {
+
+	lines := make([]debuginfo.Line, len(r.Lines))
+
+	for i, l := range r.Lines {
+		file := l.File
+		if abs, err := filepath.Abs(file); err == nil && file != "<source>" {
+			file = abs
+		}
+		lines[i] = debuginfo.Line{File: file, Line: l.Line, Address: l.Address, Size: l.Size, Code: l.Code}
+	}
+
+	symbols := make(map[string]uint16, len(r.Symbols))
+	for name, addr := range r.Symbols {
+		symbols[name] = addr
+	}
+
+	return debuginfo.New(lines, symbols)
+}
+
+// ------------------------------- Assembler -------------------------------- //
+
+type sourceLine struct {
+	file string
+	num  int
+	text string
+}
+
+type assembler struct {
+	opts Options
+
+	// CPU selection
+	variant      disassembler.Variant
+	undocumented bool
+
+	pass    int
+	pc      int
+	scope   string         // Last global label (for local labels)
+	symbols map[string]int // Labels and constants
+	defined map[string]int // Pass in which each symbol was last defined
+	modes   map[int]disassembler.Mode
+	stmt    int // Statement counter (used to keep the pass 1 addressing mode)
+
+	// Current source position
+	file string
+	line int
+	code bool // Emitting an instruction
+
+	result *Result
+	errors ErrorList
+}
+
+func assembleLines(name, src, dir string, opts Options) (*Result, error) {
+
+	lines, err := readSource(name, src, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &assembler{
+		opts:    opts,
+		symbols: map[string]int{},
+		defined: map[string]int{},
+		modes:   map[int]disassembler.Mode{},
+	}
+
+	for symbol, v := range opts.Symbols {
+		a.symbols[symbol] = int(v)
+		a.defined[symbol] = -1 // Predefined, valid in both passes
+	}
+
+	for a.pass = 1; a.pass <= 2; a.pass++ {
+
+		a.variant = opts.Variant
+		a.undocumented = opts.Undocumented
+		a.pc = int(opts.Origin)
+		a.scope = ""
+		a.stmt = 0
+		a.result = &Result{}
+
+		for _, l := range lines {
+			a.file, a.line = l.file, l.num
+			stop, err := a.statement(l.text)
+			if err != nil {
+				a.errors = append(a.errors, &Error{File: l.file, Line: l.num, Msg: err.Error()})
+			}
+			if stop {
+				break
+			}
+		}
+
+		if len(a.errors) > 0 {
+			return nil, a.errors
+		}
+	}
+
+	// Export the symbol table
+	a.result.Symbols = map[string]uint16{}
+	for symbol, v := range a.symbols {
+		a.result.Symbols[symbol] = uint16(v)
+	}
+
+	return a.result, nil
+}
+
+// Split the source in lines, expanding the include directives
+func readSource(name, src, dir string, depth int) ([]sourceLine, error) {
+
+	if depth > 16 {
+		return nil, fmt.Errorf("%s: includes nested too deeply", name)
+	}
+
+	var lines []sourceLine
+
+	for i, text := range strings.Split(src, "\n") {
+
+		tokens, err := tokenize(text)
+		tokens = joinDirective(tokens)
+		if err == nil && len(tokens) == 2 && tokens[1].kind == tkString && isInclude(tokens[0].text) {
+			path := tokens[1].text
+			if dir != "" && !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, &Error{File: name, Line: i + 1, Msg: err.Error()}
+			}
+			included, err := readSource(path, string(data), filepath.Dir(path), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, included...)
+			continue
+		}
+
+		lines = append(lines, sourceLine{file: name, num: i + 1, text: text})
+	}
+
+	return lines, nil
+}
+
+// ACME directives (!byte, !8) are a single token for the statement parser
+func joinDirective(tokens []token) []token {
+
+	if len(tokens) >= 2 && isOpToken(tokens[0], "!") && tokens[1].kind != tkOp && tokens[1].kind != tkString && tokens[1].pos == tokens[0].pos+1 {
+		return append([]token{{kind: tkIdent, text: "!" + tokens[1].text, pos: tokens[0].pos}}, tokens[2:]...)
+	}
+
+	return tokens
+}
+
+func isInclude(directive string) bool {
+	switch strings.ToLower(directive) {
+	case ".include", ".incsrc", "!source", "!src":
+		return true
+	}
+	return false
+}
+
+// Assemble one source line, returns true on .end
+func (a *assembler) statement(text string) (bool, error) {
+
+	tokens, err := tokenize(text)
+	if err != nil {
+		return false, err
+	}
+
+	tokens = joinDirective(tokens)
+
+	if len(tokens) == 0 {
+		return false, nil
+	}
+
+	// Origin: *= expr
+	if isOpToken(tokens[0], "*") && len(tokens) > 1 && isOpToken(tokens[1], "=") {
+		return false, a.setOrigin(tokens[2:])
+	}
+
+	// Constants: name = expr, name := expr, name equ expr
+	if len(tokens) > 1 && tokens[0].kind == tkIdent &&
+		(isOpToken(tokens[1], "=") || isOpToken(tokens[1], ":=") ||
+			(tokens[1].kind == tkIdent && strings.EqualFold(tokens[1].text, "equ")) ||
+			(tokens[1].kind == tkIdent && strings.EqualFold(tokens[1].text, ".equ"))) {
+		return false, a.constant(tokens[0].text, tokens[2:])
+	}
+
+	// Labels: "name:" anywhere, or "name" in the first column when it is not a keyword
+	if tokens[0].kind == tkIdent {
+		if len(tokens) > 1 && isOpToken(tokens[1], ":") {
+			if err := a.label(tokens[0].text); err != nil {
+				return false, err
+			}
+			tokens = tokens[2:]
+		} else if tokens[0].pos == 0 && !a.isKeyword(tokens[0].text) {
+			if err := a.label(tokens[0].text); err != nil {
+				return false, err
+			}
+			tokens = tokens[1:]
+		}
+	}
+
+	if len(tokens) == 0 {
+		return false, nil
+	}
+
+	if tokens[0].kind != tkIdent {
+		return false, fmt.Errorf("unexpected %q", tokens[0].text)
+	}
+
+	// Directives
+	if name := tokens[0].text; name[0] == '.' || name[0] == '!' {
+		return a.directive(strings.ToLower(name[1:]), tokens[1:])
+	}
+
+	return false, a.assembleInstruction(tokens)
+}
+
+// Instructions and directives can't be used as labels in the first column
+func (a *assembler) isKeyword(name string) bool {
+
+	if _, modes := a.instruction(name); modes != nil {
+		return true
+	}
+
+	if name[0] == '!' {
+		return true
+	}
+
+	// ACME style mnemonic with address size suffix (lda+2) is tokenized as "lda"
+	if name[0] == '.' {
+		_, known := directives[strings.ToLower(name[1:])]
+		return known
+	}
+
+	return false
+}
+
+// ------------------------------- Symbols ---------------------------------- //
+
+// Expand local labels (@name or .name) with the current scope
+func (a *assembler) symbolName(name string) string {
+	if name[0] == '@' || name[0] == '.' {
+		return a.scope + name
+	}
+	return name
+}
+
+func (a *assembler) define(name string, v int) error {
+
+	if pass, ok := a.defined[name]; ok {
+		if pass == a.pass || pass == -1 {
+			return fmt.Errorf("symbol %q already defined", name)
+		}
+		if old := a.symbols[name]; a.pass == 2 && old != v {
+			// Only happens if the code size changed between the passes
+			return fmt.Errorf("symbol %q changed value between passes ($%04X -> $%04X)", name, old, v)
+		}
+	}
+
+	a.symbols[name] = v
+	a.defined[name] = a.pass
+
+	return nil
+}
+
+func (a *assembler) label(name string) error {
+
+	if name[0] != '@' && name[0] != '.' {
+		a.scope = name
+	}
+
+	return a.define(a.symbolName(name), a.pc)
+}
+
+func (a *assembler) constant(name string, expr []token) error {
+
+	v, err := a.eval(expr)
+	if err != nil {
+		return err
+	}
+
+	if !v.known {
+		if a.pass == 2 {
+			return fmt.Errorf("constant %q depends on undefined symbols", name)
+		}
+		return nil
+	}
+
+	return a.define(a.symbolName(name), v.v)
+}
+
+// Value of a symbol in an expression
+func (a *assembler) lookup(name string) (value, error) {
+
+	name = a.symbolName(name)
+
+	if v, ok := a.symbols[name]; ok {
+		return value{v: v, known: true}, nil
+	}
+
+	if a.pass == 1 {
+		return value{}, nil
+	}
+
+	return value{}, fmt.Errorf("undefined symbol %q", name)
+}
+
+// ------------------------------ Code Output ------------------------------- //
+
+func (a *assembler) emit(data ...byte) error {
+
+	if a.pc+len(data) > 0x10000 {
+		return fmt.Errorf("program counter overflow ($%X)", a.pc+len(data))
+	}
+
+	// Keep the line information for debuggers
+	if a.pass == 2 && len(data) > 0 {
+		a.result.Lines = append(a.result.Lines, LineInfo{File: a.file, Line: a.line, Address: uint16(a.pc), Size: uint16(len(data)), Code: a.code})
+	}
+
+	if a.pass == 2 {
+		n := len(a.result.Segments)
+		if n > 0 && int(a.result.Segments[n-1].Address)+len(a.result.Segments[n-1].Data) == a.pc {
+			a.result.Segments[n-1].Data = append(a.result.Segments[n-1].Data, data...)
+		} else {
+			a.result.Segments = append(a.result.Segments, Segment{Address: uint16(a.pc), Data: append([]byte(nil), data...)})
+		}
+	}
+
+	a.pc += len(data)
+
+	return nil
+}
+
+func (a *assembler) emitInstruction(data ...byte) error {
+
+	a.code = true
+	err := a.emit(data...)
+	a.code = false
+
+	return err
+}
+
+// Evaluate an expression required to be known in pass 2
+func (a *assembler) evalFinal(tokens []token) (int, error) {
+
+	v, err := a.eval(tokens)
+	if err != nil {
+		return 0, err
+	}
+
+	return v.v, nil
+}
+
+func (a *assembler) byteValue(v int) (byte, error) {
+	if a.pass == 2 && (v < -128 || v > 0xFF) {
+		return 0, fmt.Errorf("value $%X does not fit in a byte", v)
+	}
+	return byte(v), nil
+}
+
+func (a *assembler) wordValue(v int) (uint16, error) {
+	if a.pass == 2 && (v < -32768 || v > 0xFFFF) {
+		return 0, fmt.Errorf("value $%X does not fit in a word", v)
+	}
+	return uint16(v), nil
+}
+
+// ------------------------------ Instructions ------------------------------ //
+
+func (a *assembler) assembleInstruction(tokens []token) error {
+
+	mnemonic, modes := a.instruction(tokens[0].text)
+	if modes == nil {
+		return fmt.Errorf("unknown instruction %q", tokens[0].text)
+	}
+
+	operandTokens := tokens[1:]
+
+	// ACME address size suffix (lda+1 zeropage, lda+2 absolute)
+	var force syntax
+	if len(operandTokens) >= 2 && isOpToken(operandTokens[0], "+") && operandTokens[0].pos == tokens[0].pos+len(tokens[0].text) && operandTokens[1].kind == tkNumber {
+		switch operandTokens[1].value {
+		case 1:
+			force = synForceZp
+		case 2:
+			force = synForceAbs
+		}
+		operandTokens = operandTokens[2:]
+	}
+
+	op, err := parseOperand(operandTokens)
+	if err != nil {
+		return err
+	}
+	if force != synNone {
+		op.force = force
+	}
+
+	// Keep the addressing mode chosen in pass 1, so the labels don't move
+	// Counted before the evaluation, which only fails in pass 2
+	a.stmt++
+
+	var v value
+	if op.expr != nil {
+		if v, err = a.eval(op.expr); err != nil {
+			return err
+		}
+	}
+
+	mode, ok := a.modes[a.stmt]
+	if !ok || a.pass == 1 {
+		if mode, err = chooseMode(modes, op, v); err != nil {
+			return fmt.Errorf("%s: %v", mnemonic, err)
+		}
+		a.modes[a.stmt] = mode
+	}
+
+	opc := modes[mode]
+
+	switch mode {
+
+	case disassembler.Implied, disassembler.Accumulator:
+		return a.emitInstruction(opc)
+
+	case disassembler.Relative:
+		offset := v.v - (a.pc + 2)
+		if a.pass == 2 && (offset < -128 || offset > 127) {
+			return fmt.Errorf("branch out of range (%d bytes)", offset)
+		}
+		return a.emitInstruction(opc, byte(offset))
+
+	case disassembler.ZeropageRelative:
+		target, err := a.eval(op.expr2)
+		if err != nil {
+			return err
+		}
+		zp, err := a.zeropage(v.v)
+		if err != nil {
+			return err
+		}
+		offset := target.v - (a.pc + 3)
+		if a.pass == 2 && (offset < -128 || offset > 127) {
+			return fmt.Errorf("branch out of range (%d bytes)", offset)
+		}
+		return a.emitInstruction(opc, zp, byte(offset))
+	}
+
+	if mode.Bytes() == 2 {
+		var b byte
+		if mode == disassembler.Immediate {
+			b, err = a.byteValue(v.v)
+		} else {
+			b, err = a.zeropage(v.v)
+		}
+		if err != nil {
+			return err
+		}
+		return a.emitInstruction(opc, b)
+	}
+
+	w, err := a.wordValue(v.v)
+	if err != nil {
+		return err
+	}
+
+	return a.emitInstruction(opc, byte(w), byte(w>>8))
+}
+
+func (a *assembler) zeropage(v int) (byte, error) {
+	if a.pass == 2 && (v < 0 || v > 0xFF) {
+		return 0, fmt.Errorf("address $%X is not in the zeropage", v)
+	}
+	return byte(v), nil
+}
+
+// ------------------------------- Directives ------------------------------- //
+
+type directiveFunc func(a *assembler, args []token) (bool, error)
+
+var directives map[string]directiveFunc
+
+func init() {
+
+	directives = map[string]directiveFunc{}
+
+	register := func(f directiveFunc, names ...string) {
+		for _, n := range names {
+			directives[n] = f
+		}
+	}
+
+	register(dirOrg, "org", "pc")
+	register(dirByte, "byte", "byt", "db", "by", "8", "dfb")
+	register(dirWord, "word", "dw", "wo", "16", "addr")
+	register(dirText, "text", "tx", "asc", "ascii")
+	register(dirAsciiz, "asciiz")
+	register(dirFill, "res", "ds", "fill", "fi", "skip")
+	register(dirAlign, "align")
+	register(dirCPU, "setcpu", "cpu")
+	register(func(a *assembler, args []token) (bool, error) { return a.selectCPU("6502") }, "p02")
+	register(func(a *assembler, args []token) (bool, error) { return a.selectCPU("65c02") }, "pc02")
+	register(func(a *assembler, args []token) (bool, error) { return true, nil }, "end", "eof")
+}
+
+func (a *assembler) directive(name string, args []token) (bool, error) {
+
+	f, ok := directives[name]
+	if !ok {
+		return false, fmt.Errorf("unknown directive %q", name)
+	}
+
+	return f(a, args)
+}
+
+func (a *assembler) setOrigin(args []token) error {
+
+	v, err := a.eval(args)
+	if err != nil {
+		return err
+	}
+
+	if !v.known {
+		return fmt.Errorf("origin must be known in the first pass")
+	}
+
+	if v.v < 0 || v.v > 0xFFFF {
+		return fmt.Errorf("origin $%X out of range", v.v)
+	}
+
+	a.pc = v.v
+
+	return nil
+}
+
+func dirOrg(a *assembler, args []token) (bool, error) {
+	return false, a.setOrigin(args)
+}
+
+func dirByte(a *assembler, args []token) (bool, error) {
+
+	for _, part := range splitComma(args) {
+
+		// Strings are emitted byte by byte
+		if len(part) == 1 && part[0].kind == tkString {
+			if err := a.emit([]byte(part[0].text)...); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		v, err := a.evalFinal(part)
+		if err != nil {
+			return false, err
+		}
+		b, err := a.byteValue(v)
+		if err != nil {
+			return false, err
+		}
+		if err := a.emit(b); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func dirWord(a *assembler, args []token) (bool, error) {
+
+	for _, part := range splitComma(args) {
+		v, err := a.evalFinal(part)
+		if err != nil {
+			return false, err
+		}
+		w, err := a.wordValue(v)
+		if err != nil {
+			return false, err
+		}
+		if err := a.emit(byte(w), byte(w>>8)); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func dirText(a *assembler, args []token) (bool, error) {
+	return dirByte(a, args)
+}
+
+func dirAsciiz(a *assembler, args []token) (bool, error) {
+
+	if _, err := dirByte(a, args); err != nil {
+		return false, err
+	}
+
+	return false, a.emit(0)
+}
+
+// .res count[,value]
+func dirFill(a *assembler, args []token) (bool, error) {
+
+	parts := splitComma(args)
+	if len(parts) > 2 {
+		return false, fmt.Errorf("too many arguments")
+	}
+
+	count, err := a.eval(parts[0])
+	if err != nil {
+		return false, err
+	}
+	if !count.known {
+		return false, fmt.Errorf("fill size must be known in the first pass")
+	}
+	if count.v < 0 {
+		return false, fmt.Errorf("negative fill size")
+	}
+
+	var fill byte
+	if len(parts) == 2 {
+		v, err := a.evalFinal(parts[1])
+		if err != nil {
+			return false, err
+		}
+		if fill, err = a.byteValue(v); err != nil {
+			return false, err
+		}
+	}
+
+	data := make([]byte, count.v)
+	for i := range data {
+		data[i] = fill
+	}
+
+	return false, a.emit(data...)
+}
+
+// .align boundary[,value]
+func dirAlign(a *assembler, args []token) (bool, error) {
+
+	parts := splitComma(args)
+
+	boundary, err := a.eval(parts[0])
+	if err != nil {
+		return false, err
+	}
+	if !boundary.known || boundary.v <= 0 {
+		return false, fmt.Errorf("invalid alignment")
+	}
+
+	count := (boundary.v - a.pc%boundary.v) % boundary.v
+
+	fillArgs := []token{{kind: tkNumber, value: count, text: fmt.Sprint(count)}}
+	if len(parts) > 1 {
+		fillArgs = append(fillArgs, token{kind: tkOp, text: ","})
+		fillArgs = append(fillArgs, parts[1]...)
+	}
+
+	return dirFill(a, fillArgs)
+}
+
+func dirCPU(a *assembler, args []token) (bool, error) {
+
+	if len(args) != 1 {
+		return false, fmt.Errorf("expected CPU name")
+	}
+
+	return a.selectCPU(args[0].text)
+}
+
+func (a *assembler) selectCPU(name string) (bool, error) {
+
+	switch strings.ToLower(name) {
+	case "6502", "6507":
+		a.variant, a.undocumented = disassembler.NMOS, false
+	case "6502x", "6510", "nmos6502":
+		a.variant, a.undocumented = disassembler.NMOS, true
+	case "65c02", "65sc02", "w65c02", "r65c02":
+		a.variant, a.undocumented = disassembler.CMOS, false
+	default:
+		return false, fmt.Errorf("unsupported CPU %q", name)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,155 @@
+package assembler
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Every opcode disassembled and assembled again gives the same instruction
+// Undocumented opcodes with duplicates (NOP, JAM, SBC $EB) only keep the text,
+// the 65C02 reserved opcodes go through as .byte
+func TestRoundTrip(t *testing.T) {
+
+	for _, v := range []disassembler.Variant{disassembler.NMOS, disassembler.CMOS} {
+		for opc := 0; opc < 256; opc++ {
+
+			code := []byte{byte(opc), 0x34, 0x12}
+			dis := disassembler.Options{Variant: v, Undocumented: v == disassembler.NMOS}
+
+			line := disassembler.Bytes(code, 0xC000, dis)[0]
+			if line.Instruction.Mode == disassembler.Relative || line.Instruction.Mode == disassembler.ZeropageRelative {
+				// Branch targets in range of the origin
+				line = disassembler.Bytes([]byte{byte(opc), 0x10, 0x10}, 0xC000, dis)[0]
+			}
+
+			r, err := Assemble(line.Text, Options{Variant: v, Undocumented: dis.Undocumented, Origin: 0xC000})
+			if err != nil {
+				t.Errorf("variant %d $%02X %q: %v", v, opc, line.Text, err)
+				continue
+			}
+
+			again := disassembler.Bytes(r.Bytes(), 0xC000, dis)
+			if len(again) != 1 || again[0].Text != line.Text {
+				t.Errorf("variant %d $%02X %q: assembled to % X", v, opc, line.Text, r.Bytes())
+				continue
+			}
+			if !line.Instruction.Undocumented && !bytes.Equal(r.Bytes(), line.Bytes) {
+				t.Errorf("variant %d $%02X %q: assembled to % X, want % X", v, opc, line.Text, r.Bytes(), line.Bytes)
+			}
+		}
+	}
+}
+
+// Labels, local labels, constants, forward references, expressions and data directives
+func TestProgram(t *testing.T) {
+
+	src := `
+screen = $0400
+        .org $C000
+start:  ldx #0
+@loop:  lda message,x
+        beq done
+        sta screen,x
+        inx
+        bne @loop
+done:   jmp (vector)
+        lda counter          ; forward reference, stays absolute
+vector: .word start, <done, >done
+message: .text "HI", 0
+        .byte 1+2*3, %101, 'A'
+counter = $80
+        lda counter
+        lda a:counter
+`
+
+	r, err := Assemble(src, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0xA2, 0x00, // ldx #0
+		0xBD, 0x19, 0xC0, // lda message,x
+		0xF0, 0x06, // beq done
+		0x9D, 0x00, 0x04, // sta screen,x
+		0xE8,       // inx
+		0xD0, 0xF5, // bne @loop
+		0x6C, 0x13, 0xC0, // jmp (vector)
+		0xAD, 0x80, 0x00, // lda counter
+		0x00, 0xC0, 0x0D, 0x00, 0xC0, 0x00, // .word start, <done, >done
+		0x48, 0x49, 0x00, // .text
+		0x07, 0x05, 0x41, // .byte
+		0xA5, 0x80, // lda counter
+		0xAD, 0x80, 0x00, // lda a:counter
+	}
+
+	if addr, image := r.Image(); addr != 0xC000 || !bytes.Equal(image, want) {
+		t.Errorf("image at $%04X:\n% X\nwant:\n% X", addr, image, want)
+	}
+
+	if r.Symbols["start@loop"] != 0xC002 || r.Symbols["done"] != 0xC00D || r.Symbols["screen"] != 0x0400 {
+		t.Errorf("symbols %v", r.Symbols)
+	}
+}
+
+// All the errors of a pass are reported with their line
+func TestErrors(t *testing.T) {
+
+	src := `
+        lda missing
+        bne far
+        lda #$100
+label:  nop
+label:  nop
+        xyz
+        .org $200
+far:    nop
+`
+
+	_, err := Assemble(src, Options{Origin: 0x0100})
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("error %v, want an ErrorList", err)
+	}
+
+	want := []struct {
+		line int
+		msg  string
+	}{
+		{6, "already defined"},
+		{7, "unknown instruction"},
+	}
+
+	// Pass 1 stops on the duplicate label and the unknown instruction
+	if len(list) != len(want) {
+		t.Fatalf("errors %v", list)
+	}
+	for i, w := range want {
+		if list[i].Line != w.line || !strings.Contains(list[i].Msg, w.msg) {
+			t.Errorf("error %d: %v, want line %d %q", i, list[i], w.line, w.msg)
+		}
+	}
+
+	// Pass 2 reports the undefined symbols, the out of range branches and values
+	_, err = Assemble(`
+        lda missing
+        bne far
+        lda #$100
+        .org $200
+far:    nop
+`, Options{Origin: 0x0100})
+
+	if !errors.As(err, &list) || len(list) != 3 {
+		t.Fatalf("errors %q, want 3", list)
+	}
+	for i, msg := range []string{"undefined symbol", "branch out of range", "does not fit in a byte"} {
+		if !strings.Contains(list[i].Msg, msg) {
+			t.Errorf("error %d: %v, want %q", i, list[i], msg)
+		}
+	}
+}
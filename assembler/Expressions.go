@@ -0,0 +1,368 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------- Tokens --------------------------------- //
+
+type tokenKind byte
+
+const (
+	tkEOF tokenKind = iota
+	tkIdent
+	tkNumber
+	tkString
+	tkOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value int
+	pos   int // Column where the token starts
+}
+
+// Operators with two characters must be tested before the single ones
+var operators2 = []string{"<<", ">>", "<=", ">=", "==", "!=", "<>", "&&", "||", ":="}
+
+const operators1 = "+-*/&|^~!<>=(),#:[]"
+
+// Split a source line into tokens, stopping at the comment
+func tokenize(line string) ([]token, error) {
+
+	var tokens []token
+
+	i := 0
+	for i < len(line) {
+		c := line[i]
+
+		switch {
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case c == ';':
+			return tokens, nil
+
+		case c == '"':
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tkString, text: line[i+1 : i+1+end], pos: i})
+			i += end + 2
+
+		case c == '\'':
+			// Character constant ('A' or 'A without the closing quote, as in ACME)
+			if i+1 >= len(line) {
+				return nil, fmt.Errorf("unterminated character constant")
+			}
+			tokens = append(tokens, token{kind: tkNumber, text: line[i : i+2], value: int(line[i+1]), pos: i})
+			i += 2
+			if i < len(line) && line[i] == '\'' {
+				i++
+			}
+
+		case c == '$' || c == '%' || isDigit(c):
+			start := i
+			base := 10
+			if c == '$' {
+				base = 16
+				i++
+			} else if c == '%' {
+				base = 2
+				i++
+			} else if c == '0' && i+1 < len(line) && (line[i+1] == 'x' || line[i+1] == 'X') {
+				base = 16
+				i += 2
+			}
+			digits := i
+			for i < len(line) && isIdentChar(line[i]) {
+				i++
+			}
+			value, err := strconv.ParseInt(line[digits:i], base, 64)
+			if err != nil || digits == i {
+				return nil, fmt.Errorf("invalid number %q", line[start:i])
+			}
+			tokens = append(tokens, token{kind: tkNumber, text: line[start:i], value: int(value), pos: start})
+
+		case isIdentStart(c):
+			start := i
+			i++
+			for i < len(line) && isIdentChar(line[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tkIdent, text: line[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators2 {
+				if strings.HasPrefix(line[i:], op) {
+					tokens = append(tokens, token{kind: tkOp, text: op, pos: i})
+					i += 2
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if strings.IndexByte(operators1, c) >= 0 {
+				tokens = append(tokens, token{kind: tkOp, text: string(c), pos: i})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '.' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+// ------------------------------- Expressions ------------------------------ //
+
+// Result of an expression evaluation
+// known is false when a symbol is still undefined (forward reference in pass 1)
+type value struct {
+	v     int
+	known bool
+}
+
+// Recursive descent parser over a slice of tokens
+type exprParser struct {
+	a      *assembler
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tkEOF}
+}
+
+func (p *exprParser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tkOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate a complete expression
+func (a *assembler) eval(tokens []token) (value, error) {
+
+	if len(tokens) == 0 {
+		return value{}, fmt.Errorf("missing expression")
+	}
+
+	p := &exprParser{a: a, tokens: tokens}
+
+	v, err := p.parseBinary(0)
+	if err != nil {
+		return value{}, err
+	}
+
+	if p.pos != len(tokens) {
+		return value{}, fmt.Errorf("unexpected %q in expression", p.peek().text)
+	}
+
+	return v, nil
+}
+
+// Binary operators grouped by precedence (lowest first)
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!=", "<>", "="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *exprParser) parseBinary(level int) (value, error) {
+
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return value{}, err
+	}
+
+	for p.isOp(precedence[level]...) {
+		op := p.next().text
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return value{}, err
+		}
+
+		result := value{known: left.known && right.known}
+
+		switch op {
+		case "||":
+			result.v = boolInt(left.v != 0 || right.v != 0)
+		case "&&":
+			result.v = boolInt(left.v != 0 && right.v != 0)
+		case "|":
+			result.v = left.v | right.v
+		case "^":
+			result.v = left.v ^ right.v
+		case "&":
+			result.v = left.v & right.v
+		case "==", "=":
+			result.v = boolInt(left.v == right.v)
+		case "!=", "<>":
+			result.v = boolInt(left.v != right.v)
+		case "<":
+			result.v = boolInt(left.v < right.v)
+		case ">":
+			result.v = boolInt(left.v > right.v)
+		case "<=":
+			result.v = boolInt(left.v <= right.v)
+		case ">=":
+			result.v = boolInt(left.v >= right.v)
+		case "<<":
+			result.v = left.v << uint(right.v&63)
+		case ">>":
+			result.v = left.v >> uint(right.v&63)
+		case "+":
+			result.v = left.v + right.v
+		case "-":
+			result.v = left.v - right.v
+		case "*":
+			result.v = left.v * right.v
+		case "/":
+			if right.v == 0 {
+				if right.known {
+					return value{}, fmt.Errorf("division by zero")
+				}
+				break
+			}
+			result.v = left.v / right.v
+		}
+
+		left = result
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (value, error) {
+
+	if p.isOp("-", "+", "~", "!", "<", ">") {
+		op := p.next().text
+
+		v, err := p.parseUnary()
+		if err != nil {
+			return value{}, err
+		}
+
+		switch op {
+		case "-":
+			v.v = -v.v
+		case "~":
+			v.v = ^v.v
+		case "!":
+			v.v = boolInt(v.v == 0)
+		case "<": // Low byte
+			v.v = v.v & 0xFF
+		case ">": // High byte
+			v.v = (v.v >> 8) & 0xFF
+		}
+
+		return v, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (value, error) {
+
+	t := p.next()
+
+	switch t.kind {
+
+	case tkNumber:
+		return value{v: t.value, known: true}, nil
+
+	case tkIdent:
+		return p.a.lookup(t.text)
+
+	case tkString:
+		// Single character strings can be used as numbers
+		if len(t.text) == 1 {
+			return value{v: int(t.text[0]), known: true}, nil
+		}
+		return value{}, fmt.Errorf("string %q used in expression", t.text)
+
+	case tkOp:
+		switch t.text {
+		case "*": // Current program counter
+			return value{v: int(p.a.pc), known: true}, nil
+
+		case "(", "[":
+			closing := ")"
+			if t.text == "[" {
+				closing = "]"
+			}
+			v, err := p.parseBinary(0)
+			if err != nil {
+				return value{}, err
+			}
+			if !p.isOp(closing) {
+				return value{}, fmt.Errorf("missing %q", closing)
+			}
+			p.next()
+			return v, nil
+		}
+	}
+
+	if t.kind == tkEOF {
+		return value{}, fmt.Errorf("unexpected end of expression")
+	}
+
+	return value{}, fmt.Errorf("unexpected %q in expression", t.text)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,305 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// ----------------------------- Opcode Lookup ------------------------------ //
+
+// Opcodes indexed by mnemonic and addressing mode
+type opcodeSet map[string]map[disassembler.Mode]byte
+
+// Alternative names used by other assemblers for the undocumented opcodes
+var aliases = map[string]string{
+	"ISB": "ISC", "INS": "ISC", "DCM": "DCP", "ASO": "SLO", "LSE": "SRE",
+	"AXS": "SBX", "ASR": "ALR", "XAA": "ANE", "AHX": "SHA", "AXA": "SHA",
+	"SHS": "TAS", "XAS": "TAS", "LAR": "LAS", "KIL": "JAM", "HLT": "JAM",
+	"DOP": "NOP", "TOP": "NOP", "SKB": "NOP", "SKW": "NOP",
+}
+
+var (
+	set6502  = buildOpcodeSet(disassembler.NMOS, false)
+	set6502X = buildOpcodeSet(disassembler.NMOS, true)
+	set65C02 = buildOpcodeSet(disassembler.CMOS, false)
+)
+
+// Build the reverse of a disassembler table
+// Documented opcodes always win over undocumented duplicates (SBC $E9 vs $EB, NOP $EA)
+func buildOpcodeSet(v disassembler.Variant, undocumented bool) opcodeSet {
+
+	set := opcodeSet{}
+	table := disassembler.Table(v)
+
+	for pass := 0; pass < 2; pass++ {
+		for opc, ins := range table {
+			if ins.Undocumented != (pass == 1) || (ins.Undocumented && !undocumented) {
+				continue
+			}
+			if set[ins.Mnemonic] == nil {
+				set[ins.Mnemonic] = map[disassembler.Mode]byte{}
+			}
+			if _, exists := set[ins.Mnemonic][ins.Mode]; !exists {
+				set[ins.Mnemonic][ins.Mode] = byte(opc)
+			}
+		}
+	}
+
+	// USBC is just SBC immediate
+	if undocumented {
+		set["USBC"] = map[disassembler.Mode]byte{disassembler.Immediate: 0xEB}
+	}
+
+	return set
+}
+
+// Current opcode set, according with the selected CPU
+func (a *assembler) opcodes() opcodeSet {
+	switch {
+	case a.variant == disassembler.CMOS:
+		return set65C02
+	case a.undocumented:
+		return set6502X
+	default:
+		return set6502
+	}
+}
+
+// Find the modes of a mnemonic (nil if not an instruction)
+func (a *assembler) instruction(name string) (string, map[disassembler.Mode]byte) {
+
+	name = strings.ToUpper(name)
+
+	set := a.opcodes()
+	if modes, ok := set[name]; ok {
+		return name, modes
+	}
+	if alias, ok := aliases[name]; ok && a.undocumented {
+		return alias, set[alias]
+	}
+
+	return name, nil
+}
+
+// --------------------------- Addressing Modes ----------------------------- //
+
+// Syntax of the operand, before choosing between zeropage and absolute
+type syntax byte
+
+const (
+	synNone     syntax = iota // INX
+	synAcc                    // ASL A
+	synImm                    // LDA #expr
+	synDirect                 // LDA expr
+	synX                      // LDA expr,X
+	synY                      // LDA expr,Y
+	synInd                    // JMP (expr)
+	synIndX                   // LDA (expr,X)
+	synIndY                   // LDA (expr),Y
+	synZpRel                  // BBR0 zp,target
+	synForceAbs               // (flag) a:expr or LDA+2
+	synForceZp                // (flag) z:expr or LDA+1
+)
+
+// Parsed operand
+type operand struct {
+	syn   syntax
+	force syntax  // synForceAbs, synForceZp or synNone
+	expr  []token // Main expression
+	expr2 []token // Branch target of BBRn/BBSn
+}
+
+// Recognize the operand syntax from the tokens following the mnemonic
+func parseOperand(tokens []token) (operand, error) {
+
+	var op operand
+
+	if len(tokens) == 0 {
+		op.syn = synNone
+		return op, nil
+	}
+
+	// ca65 address size override (a:expr, z:expr)
+	if len(tokens) > 2 && tokens[0].kind == tkIdent && tokens[1].kind == tkOp && tokens[1].text == ":" {
+		switch strings.ToLower(tokens[0].text) {
+		case "a":
+			op.force = synForceAbs
+			tokens = tokens[2:]
+		case "z":
+			op.force = synForceZp
+			tokens = tokens[2:]
+		}
+	}
+
+	// Accumulator
+	if len(tokens) == 1 && tokens[0].kind == tkIdent && strings.EqualFold(tokens[0].text, "A") {
+		op.syn = synAcc
+		return op, nil
+	}
+
+	// Immediate
+	if isOpToken(tokens[0], "#") {
+		op.syn = synImm
+		op.expr = tokens[1:]
+		return op, nil
+	}
+
+	// Indirect modes: the whole operand (or all but ",Y") is wrapped in parentheses
+	if isOpToken(tokens[0], "(") {
+		closing := matchParen(tokens, 0)
+		if closing == len(tokens)-1 {
+			inner := tokens[1:closing]
+			if n := len(inner); n >= 2 && isOpToken(inner[n-2], ",") && isRegister(inner[n-1], "X") {
+				op.syn = synIndX
+				op.expr = inner[:n-2]
+				return op, nil
+			}
+			op.syn = synInd
+			op.expr = inner
+			return op, nil
+		}
+		if closing == len(tokens)-3 && isOpToken(tokens[closing+1], ",") && isRegister(tokens[closing+2], "Y") {
+			op.syn = synIndY
+			op.expr = tokens[1:closing]
+			return op, nil
+		}
+	}
+
+	// Indexed modes
+	parts := splitComma(tokens)
+	switch len(parts) {
+	case 1:
+		op.syn = synDirect
+		op.expr = parts[0]
+	case 2:
+		if len(parts[1]) == 1 && isRegister(parts[1][0], "X") {
+			op.syn = synX
+		} else if len(parts[1]) == 1 && isRegister(parts[1][0], "Y") {
+			op.syn = synY
+		} else {
+			op.syn = synZpRel
+			op.expr2 = parts[1]
+		}
+		op.expr = parts[0]
+	default:
+		return op, fmt.Errorf("invalid operand")
+	}
+
+	return op, nil
+}
+
+// Candidate modes for each syntax, in order of preference (short form first)
+func candidates(syn syntax) []disassembler.Mode {
+	switch syn {
+	case synNone:
+		return []disassembler.Mode{disassembler.Implied, disassembler.Accumulator}
+	case synAcc:
+		return []disassembler.Mode{disassembler.Accumulator}
+	case synImm:
+		return []disassembler.Mode{disassembler.Immediate}
+	case synDirect:
+		return []disassembler.Mode{disassembler.Relative, disassembler.Zeropage, disassembler.Absolute}
+	case synX:
+		return []disassembler.Mode{disassembler.ZeropageX, disassembler.AbsoluteX}
+	case synY:
+		return []disassembler.Mode{disassembler.ZeropageY, disassembler.AbsoluteY}
+	case synInd:
+		return []disassembler.Mode{disassembler.ZeropageIndirect, disassembler.Indirect}
+	case synIndX:
+		return []disassembler.Mode{disassembler.IndirectX, disassembler.AbsoluteIndirectX}
+	case synIndY:
+		return []disassembler.Mode{disassembler.IndirectY}
+	case synZpRel:
+		return []disassembler.Mode{disassembler.ZeropageRelative}
+	}
+	return nil
+}
+
+// Choose the addressing mode, preferring zeropage when the address is known to fit
+func chooseMode(modes map[disassembler.Mode]byte, op operand, v value) (disassembler.Mode, error) {
+
+	var available []disassembler.Mode
+
+	for _, m := range candidates(op.syn) {
+		if _, ok := modes[m]; ok {
+			available = append(available, m)
+		}
+	}
+
+	if len(available) == 0 {
+		return 0, fmt.Errorf("addressing mode not supported by the instruction")
+	}
+
+	if len(available) == 1 {
+		return available[0], nil
+	}
+
+	zp, abs := available[0], available[1]
+
+	switch {
+	case op.force == synForceZp:
+		return zp, nil
+	case op.force == synForceAbs:
+		return abs, nil
+	case v.known && v.v >= 0 && v.v <= 0xFF:
+		return zp, nil
+	default:
+		// Unknown (forward reference) or 16 bit address
+		return abs, nil
+	}
+}
+
+// ------------------------------ Token Helpers ----------------------------- //
+
+func isOpToken(t token, op string) bool {
+	return t.kind == tkOp && t.text == op
+}
+
+func isRegister(t token, reg string) bool {
+	return t.kind == tkIdent && strings.EqualFold(t.text, reg)
+}
+
+// Index of the parenthesis closing the one at start (-1 if unbalanced)
+func matchParen(tokens []token, start int) int {
+
+	depth := 0
+
+	for i := start; i < len(tokens); i++ {
+		if isOpToken(tokens[i], "(") {
+			depth++
+		} else if isOpToken(tokens[i], ")") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// Split tokens on the commas outside parentheses
+func splitComma(tokens []token) [][]token {
+
+	var (
+		parts [][]token
+		depth int
+		start int
+	)
+
+	for i, t := range tokens {
+		switch {
+		case isOpToken(t, "(") || isOpToken(t, "["):
+			depth++
+		case isOpToken(t, ")") || isOpToken(t, "]"):
+			depth--
+		case isOpToken(t, ",") && depth == 0:
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, tokens[start:])
+}
@@ -0,0 +1,58 @@
+// Apple-1 emulator: the Woz Monitor with the keyboard on stdin and the display on stdout.
+//
+//	apple1 -rom wozmon.bin [-ram 4|8] [-turbo] [-load file -at address]
+//
+// Piped input runs to its end, e.g. echo "FF00.FF0F" | apple1 -rom wozmon.bin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/apple1"
+)
+
+func main() {
+
+	rom := flag.String("rom", "", "ROM file ending at $FFFF (the 256 bytes Woz Monitor)")
+	ram := flag.Int("ram", 4, "KB of RAM (4, or 8 with $E000-$EFFF for Integer BASIC)")
+	turbo := flag.Bool("turbo", false, "run as fast as possible instead of 1 MHz")
+	load := flag.String("load", "", "binary file loaded in RAM")
+	at := flag.String("at", "E000", "load address of -load (hex)")
+	flag.Parse()
+
+	if *rom == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	CPU_6502.Debug = false
+	CPU_6502.Pause = false
+
+	m, err := apple1.New(apple1.Config{ROM: *rom, RAM: *ram, Turbo: *turbo})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	if *load != "" {
+		addr, err := strconv.ParseUint(*at, 16, 16)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -at value %q\n", *at)
+			os.Exit(2)
+		}
+		if _, err := CPU_6502.LoadBinary(*load, CPU_6502.LoadOptions{Address: uint16(addr)}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := m.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
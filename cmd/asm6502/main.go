@@ -0,0 +1,73 @@
+// Assembler for the 6502 core.
+//
+//	asm6502 [-o program.bin] [-g program.dbg.json] [-u] [-c] source.s
+//
+// The binary starts at the lowest assembled address (printed on exit), the
+// debug information maps source lines and symbols for the DAP server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/assembler"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+func main() {
+
+	output := flag.String("o", "", "binary output file (default: source name with .bin)")
+	debug := flag.String("g", "", "debug information output file")
+	undocumented := flag.Bool("u", false, "accept the NMOS undocumented opcodes")
+	cmos := flag.Bool("c", false, "assemble for the 65C02")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: asm6502 [-o program.bin] [-g program.dbg.json] [-u] [-c] source.s")
+		os.Exit(2)
+	}
+
+	source := flag.Arg(0)
+
+	opts := assembler.Options{Undocumented: *undocumented}
+	if *cmos {
+		opts.Variant = disassembler.CMOS
+	}
+
+	result, err := assembler.AssembleFile(source, opts)
+	if err != nil {
+		if list, ok := err.(assembler.ErrorList); ok {
+			for _, e := range list {
+				fmt.Fprintln(os.Stderr, e)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(source, ".s") + ".bin"
+		if *output == source {
+			*output = source + ".bin"
+		}
+	}
+
+	origin, image := result.Image()
+
+	if err := os.WriteFile(*output, image, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *debug != "" {
+		if err := result.DebugInfo().Save(*debug); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("%s: $%04X-$%04X (%d bytes)\n", *output, origin, int(origin)+len(image)-1, len(image))
+}
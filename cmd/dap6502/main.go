@@ -0,0 +1,50 @@
+// Debug Adapter Protocol server for the 6502 core.
+//
+//	dap6502              serve a session on stdin / stdout (editor launched adapter)
+//	dap6502 -listen addr serve sessions over TCP (e.g. localhost:4711)
+//
+// The launch request takes a "program" binary and / or an assembly "source",
+// see the dap package documentation for all the arguments.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cassianoperin/6502_GO_Core/dap"
+)
+
+func main() {
+
+	listen := flag.String("listen", "", "TCP address to listen on (default: stdin / stdout)")
+	logfile := flag.String("log", "", "log the protocol messages to a file")
+	flag.Parse()
+
+	var log io.Writer
+
+	if *logfile != "" {
+		f, err := os.Create(*logfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		log = f
+	}
+
+	var err error
+
+	if *listen != "" {
+		fmt.Fprintf(os.Stderr, "Listening on %s\n", *listen)
+		err = dap.ListenAndServe(*listen, log)
+	} else {
+		err = dap.Serve(os.Stdin, os.Stdout, log)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
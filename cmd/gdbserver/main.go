@@ -0,0 +1,61 @@
+// GDB Remote Serial Protocol server for the 6502 core.
+//
+//	gdbserver [-listen localhost:2159] [-pc address] [-history n] [-v] [rom file]
+//
+// Connect with any RSP front-end, e.g. gdb: target remote localhost:2159
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+	"github.com/cassianoperin/6502_GO_Core/gdbstub"
+)
+
+func main() {
+
+	listen := flag.String("listen", "localhost:2159", "TCP address to listen on")
+	pc := flag.String("pc", "", "initial program counter (hex), overrides the reset vector")
+	verbose := flag.Bool("v", false, "log the packets exchanged")
+	history := flag.Int("history", 0, "instructions recorded for reverse execution (0 = off)")
+	flag.Parse()
+
+	CPU_6502.Debug = false
+	CPU_6502.Pause = false
+
+	CPU_6502.Initialize()
+
+	if flag.NArg() > 0 {
+		CPU_6502.ReadROM(flag.Arg(0))
+	}
+
+	if *pc != "" {
+		value, err := strconv.ParseUint(*pc, 16, 16)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -pc value %q\n", *pc)
+			os.Exit(2)
+		}
+		CPU_6502.PC_as_argument = uint16(value)
+	}
+
+	CPU_6502.Reset()
+
+	dbg := debugger.New()
+	dbg.EnableHistory(*history)
+
+	server := gdbstub.NewServer(dbg)
+	if *verbose {
+		server.Log = os.Stderr
+	}
+
+	fmt.Printf("Listening on %s\n", *listen)
+
+	if err := server.ListenAndServe(*listen); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
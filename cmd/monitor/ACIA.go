@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/acia"
+)
+
+// ------------------------------- ACIA ------------------------------------- //
+
+// ACIA and its TCP connection
+type acia_conn struct {
+	chip *acia.ACIA
+	conn net.Conn
+}
+
+func (m *monitor) cmdACIA(args []string) error {
+
+	switch {
+
+	case len(args) == 1:
+		if len(m.acias) == 0 {
+			fmt.Fprintln(m.out, "no ACIA")
+		}
+		for _, a := range m.acias {
+			fmt.Fprintf(m.out, "%v  %v\n", a.chip, a.conn.RemoteAddr())
+		}
+		return nil
+
+	case len(args) == 2 && strings.EqualFold(args[1], "off"):
+		for _, a := range m.acias {
+			a.chip.Detach()
+			a.conn.Close()
+		}
+		m.acias = nil
+		return nil
+
+	case len(args) != 3:
+		return fmt.Errorf("usage: acia [address \"host:port\"|off]")
+	}
+
+	base, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("tcp", unquote(args[2]))
+	if err != nil {
+		return err
+	}
+
+	a, err := acia.Attach(base, conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	m.acias = append(m.acias, acia_conn{a, conn})
+
+	return nil
+}
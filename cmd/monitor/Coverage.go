@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/coverage"
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+)
+
+// ------------------------------- Coverage --------------------------------- //
+
+func (m *monitor) cmdCoverage(args []string) error {
+
+	usage := fmt.Errorf("usage: coverage on|off|reset|report [\"debug info\"]|lcov \"file\" \"debug info\"")
+
+	if len(args) < 2 {
+		return usage
+	}
+
+	switch strings.ToLower(args[1]) {
+
+	case "on":
+		if m.coverage == nil {
+			m.coverage = coverage.New()
+		}
+		return nil
+
+	case "off":
+		if m.coverage != nil {
+			m.coverage.Close()
+			m.coverage = nil
+		}
+		return nil
+	}
+
+	if m.coverage == nil {
+		return fmt.Errorf("the coverage is off, enable it with: coverage on")
+	}
+
+	switch strings.ToLower(args[1]) {
+
+	case "reset":
+		m.coverage.Reset()
+
+	case "report":
+		var info *debuginfo.Info
+		if len(args) > 2 {
+			var err error
+			if info, err = debuginfo.Load(unquote(args[2])); err != nil {
+				return err
+			}
+		}
+		return m.coverage.Report(m.out, info)
+
+	case "lcov":
+		if len(args) != 4 {
+			return usage
+		}
+		info, err := debuginfo.Load(unquote(args[3]))
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(unquote(args[2]))
+		if err != nil {
+			return err
+		}
+		if err := m.coverage.WriteLCOV(f, info, ""); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+
+	default:
+		return usage
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/symbols"
+)
+
+// ------------------------------- Labels ----------------------------------- //
+
+// Labels used in the disassembly, the conditions and as .name arguments
+var labels = symbols.New()
+
+func (m *monitor) cmdLoadLabels(args []string) error {
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: ll \"file\"")
+	}
+
+	t, err := symbols.Load(unquote(args[1]))
+	if err != nil {
+		return err
+	}
+
+	labels.Merge(t)
+	fmt.Fprintf(m.out, "loaded %d labels\n", t.Len())
+
+	return nil
+}
+
+func (m *monitor) cmdAddLabel(args []string) error {
+
+	if len(args) != 3 || !strings.HasPrefix(args[2], ".") || len(args[2]) < 2 {
+		return fmt.Errorf("usage: al address .label")
+	}
+
+	addr, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	labels.Add(args[2][1:], addr)
+
+	return nil
+}
+
+func (m *monitor) cmdShowLabels(args []string) error {
+
+	filter := ""
+	if len(args) > 1 {
+		filter = strings.ToLower(args[1])
+	}
+
+	for _, name := range labels.Names() {
+		if strings.Contains(strings.ToLower(name), filter) {
+			addr, _ := labels.Resolve(name)
+			fmt.Fprintf(m.out, "$%04x .%s\n", addr, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/symbols"
+)
+
+// ------------------------------- Loaders ---------------------------------- //
+
+func (m *monitor) cmdLoad(args []string) error {
+
+	if len(args) == 2 {
+		return m.loadRecords(unquote(args[1]))
+	}
+
+	if len(args) != 3 {
+		return fmt.Errorf("usage: l \"file\" [address]")
+	}
+
+	addr, err := parseAddress(args[2])
+	if err != nil {
+		return err
+	}
+
+	img, err := CPU_6502.LoadBinary(unquote(args[1]), CPU_6502.LoadOptions{Address: addr})
+	if err != nil {
+		return err
+	}
+
+	size := img.Segments[0].Size
+	fmt.Fprintf(m.out, "loaded $%04x-$%04x (%d bytes)\n", addr, int(addr)+size-1, size)
+
+	return nil
+}
+
+func (m *monitor) cmdLoadBus(args []string) error {
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: loadbus on|off")
+	}
+
+	CPU_6502.LoadThroughBus = strings.EqualFold(args[1], "on")
+
+	return nil
+}
+
+// Load a file with its own addresses (format from the extension)
+func (m *monitor) loadRecords(filename string) error {
+
+	img, err := CPU_6502.LoadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range img.Segments {
+		fmt.Fprintf(m.out, "loaded $%04x-$%04x (%d bytes)\n", s.Address, int(s.Address)+s.Size-1, s.Size)
+	}
+
+	for _, a := range img.Init {
+		fmt.Fprintf(m.out, "init routine $%04x (not run)\n", a)
+	}
+
+	if img.HasStart {
+		fmt.Fprintf(m.out, "start address $%04x\n", img.Start)
+		m.next_dis = img.Start
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdLoadO65(args []string) error {
+
+	if len(args) != 2 && len(args) != 6 {
+		return fmt.Errorf("usage: lo65 \"file\" [text data bss zero]")
+	}
+
+	var layout *CPU_6502.O65Layout
+
+	if len(args) == 6 {
+		var bases [4]uint16
+		for i := range bases {
+			addr, err := parseAddress(args[2+i])
+			if err != nil {
+				return err
+			}
+			bases[i] = addr
+		}
+		layout = &CPU_6502.O65Layout{Text: bases[0], Data: bases[1], BSS: bases[2], Zero: bases[3]}
+	}
+
+	img, exports, err := CPU_6502.LoadO65(unquote(args[1]), layout, labels.Resolve)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range img.Segments {
+		fmt.Fprintf(m.out, "loaded $%04x-$%04x (%d bytes)\n", s.Address, int(s.Address)+s.Size-1, s.Size)
+	}
+
+	labels.Merge(symbols.FromMap(exports))
+	fmt.Fprintf(m.out, "%d exported labels\n", len(exports))
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/mappers"
+)
+
+// ------------------------------- Mappers ---------------------------------- //
+
+func (m *monitor) cmdMapper(args []string) error {
+
+	switch {
+
+	case len(args) == 1:
+		if mp := mappers.Attached(); mp != nil {
+			fmt.Fprintf(m.out, "%s banks %v\n", mp.Name(), mp.Banks())
+		} else {
+			fmt.Fprintln(m.out, "no mapper")
+		}
+		return nil
+
+	case len(args) == 2 && strings.EqualFold(args[1], "off"):
+		mappers.Detach()
+		return nil
+
+	case len(args) > 3:
+		return fmt.Errorf("usage: mapper [\"file\" [%s]|off]", strings.Join(mappers.Schemes(), "|"))
+	}
+
+	filename := unquote(args[1])
+
+	var mp mappers.Mapper
+
+	if len(args) == 2 {
+		if !strings.EqualFold(filepath.Ext(filename), ".nes") {
+			return fmt.Errorf("a scheme is needed: %s", strings.Join(mappers.Schemes(), " "))
+		}
+		rom, err := mappers.LoadINES(filename)
+		if err != nil {
+			return err
+		}
+		mp = rom.Mapper
+	} else {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if mp, err = mappers.New(args[2], data); err != nil {
+			return err
+		}
+	}
+
+	mappers.Detach()
+	mappers.Attach(mp)
+
+	fmt.Fprintf(m.out, "%s banks %v, reset vector $%04x\n", mp.Name(), mp.Banks(), uint16(m.cpu.ReadMemory(0xFFFD))<<8|uint16(m.cpu.ReadMemory(0xFFFC)))
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// ------------------------------- Memory map ------------------------------- //
+
+func (m *monitor) cmdMap(args []string) error {
+
+	usage := fmt.Errorf("usage: map [clear|start end ram|rom|io|unmapped [rwx] [name]]")
+
+	switch {
+
+	case len(args) == 1:
+		for _, r := range CPU_6502.MemoryMap() {
+			fmt.Fprintln(m.out, r)
+		}
+		return nil
+
+	case len(args) == 2 && strings.EqualFold(args[1], "clear"):
+		CPU_6502.ClearMemoryMap()
+		return nil
+
+	case len(args) < 4 || len(args) > 6:
+		return usage
+	}
+
+	start, end, err := parseRange(args[1:3])
+	if err != nil {
+		return err
+	}
+
+	kinds := map[string]CPU_6502.RegionKind{
+		"ram":      CPU_6502.REGION_RAM,
+		"rom":      CPU_6502.REGION_ROM,
+		"io":       CPU_6502.REGION_IO,
+		"unmapped": CPU_6502.REGION_UNMAPPED,
+	}
+
+	kind, ok := kinds[strings.ToLower(args[3])]
+	if !ok {
+		return usage
+	}
+
+	r := CPU_6502.NewRegion("", start, end, kind)
+
+	rest := args[4:]
+
+	// Permissions, "-" for none
+	if len(rest) > 0 && strings.Trim(rest[0], "rwx-") == "" {
+		r.Perm = 0
+		for _, c := range rest[0] {
+			switch c {
+			case 'r':
+				r.Perm |= CPU_6502.PERM_READ
+			case 'w':
+				r.Perm |= CPU_6502.PERM_WRITE
+			case 'x':
+				r.Perm |= CPU_6502.PERM_EXEC
+			}
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 {
+		r.Name = unquote(rest[0])
+	}
+
+	return CPU_6502.MapRegion(r)
+}
+
+func (m *monitor) cmdViolations(args []string) error {
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: violations on|off")
+	}
+
+	CPU_6502.MemoryViolations = CPU_6502.VIOLATION_HARDWARE
+	if strings.EqualFold(args[1], "on") {
+		CPU_6502.MemoryViolations = CPU_6502.VIOLATION_EVENT
+	}
+
+	return nil
+}
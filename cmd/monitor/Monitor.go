@@ -0,0 +1,1114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/assembler"
+	"github.com/cassianoperin/6502_GO_Core/coverage"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+	"github.com/cassianoperin/6502_GO_Core/profiler"
+	"github.com/cassianoperin/6502_GO_Core/riot"
+	"github.com/cassianoperin/6502_GO_Core/via"
+)
+
+type monitor struct {
+	cpu CPU_6502.Machine
+	dbg *debugger.Debugger
+	out io.Writer
+
+	next_mem  uint16 // Address of the next "m" without arguments
+	next_dis  uint16 // Address of the next "d" without arguments
+	limit     uint64 // Cycle limit of "g" (0 = no limit)
+	interrupt chan os.Signal
+
+	profiler *profiler.Profiler // nil when not profiling
+	coverage *coverage.Coverage // nil when not recording the coverage
+
+	vias  []*via.VIA  // Chips attached with "via"
+	riot  *riot.RIOT  // Chip attached with "riot"
+	acias []acia_conn // Chips attached with "acia"
+}
+
+type command struct {
+	names []string
+	args  string
+	help  string
+	run   func(m *monitor, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{[]string{"help", "?"}, "", "show this help", (*monitor).cmdHelp},
+		{[]string{"r", "registers"}, "[reg=value ...]", "show or change registers (A X Y SP PC P) and flags (C Z I D V N)", (*monitor).cmdRegisters},
+		{[]string{"m", "mem"}, "[start [end]]", "display memory", (*monitor).cmdMemory},
+		{[]string{">"}, "address byte ...", "change memory", (*monitor).cmdChange},
+		{[]string{"d", "disass"}, "[start [end]]", "disassemble", (*monitor).cmdDisassemble},
+		{[]string{"a", "assemble"}, "address [instruction]", "assemble in place (empty line leaves the assembly mode)", nil},
+		{[]string{"z", "step"}, "[count]", "execute instructions, entering subroutines", (*monitor).cmdStep},
+		{[]string{"n", "next"}, "[count]", "execute instructions, running subroutines to their return", (*monitor).cmdNext},
+		{[]string{"ret", "finish"}, "", "run until the current subroutine returns", (*monitor).cmdFinish},
+		{[]string{"g", "goto"}, "[address]", "continue execution (optionally from address)", (*monitor).cmdGo},
+		{[]string{"history"}, "[instructions|off]", "record the execution to step back (default 100000 instructions)", (*monitor).cmdHistory},
+		{[]string{"back", "bs"}, "[count]", "step back instructions", (*monitor).cmdBack},
+		{[]string{"rc", "rcontinue"}, "", "run backwards to the previous breakpoint or watched write", (*monitor).cmdReverseContinue},
+		{[]string{"rewind"}, "cycles", "step back until the number of cycles (decimal) is undone", (*monitor).cmdRewind},
+		{[]string{"un", "until"}, "address", "run until address", (*monitor).cmdUntil},
+		{[]string{"limit"}, "[cycles]", "show or set the cycle limit of g (0 = no limit)", (*monitor).cmdLimit},
+		{[]string{"break", "bk"}, "[address [if condition]]", "list breakpoints or add an execution breakpoint", (*monitor).cmdBreak},
+		{[]string{"tbreak"}, "address [if condition]", "add a temporary breakpoint", (*monitor).cmdBreak},
+		{[]string{"watch", "w"}, "[load|store] start [end] [if condition]", "add a watchpoint (default: load and store)", (*monitor).cmdWatch},
+		{[]string{"opbreak"}, "opcode [if condition]", "break on an opcode", (*monitor).cmdOpcodeBreak},
+		{[]string{"intbreak"}, "[if condition]", "break on BRK / IRQ / NMI entry", (*monitor).cmdInterruptBreak},
+		{[]string{"cond", "condition"}, "id [condition]", "set or remove the condition of a breakpoint", (*monitor).cmdCondition},
+		{[]string{"ignore"}, "id count", "ignore the next hits of a breakpoint", (*monitor).cmdIgnore},
+		{[]string{"enable"}, "id", "enable a breakpoint", (*monitor).cmdEnable},
+		{[]string{"disable"}, "id", "disable a breakpoint", (*monitor).cmdEnable},
+		{[]string{"del", "delete"}, "[id]", "delete a breakpoint (all without id)", (*monitor).cmdDelete},
+		{[]string{"f", "fill"}, "start end byte ...", "fill memory with a pattern", (*monitor).cmdFill},
+		{[]string{"t", "move"}, "start end destination", "copy memory", (*monitor).cmdMove},
+		{[]string{"c", "compare"}, "start end destination", "compare memory", (*monitor).cmdCompare},
+		{[]string{"h", "hunt"}, "start end byte ...", "search memory", (*monitor).cmdHunt},
+		{[]string{"l", "load"}, "\"file\" [address]", "load a binary file (Intel HEX, S-record, .prg and .xex files without address)", (*monitor).cmdLoad},
+		{[]string{"loadbus"}, "on|off", "load files through the data bus (mapper, devices, write protection)", (*monitor).cmdLoadBus},
+		{[]string{"lo65"}, "\"file\" [text data bss zero]", "load an o65 relocatable file (imports from and exports to the labels)", (*monitor).cmdLoadO65},
+		{[]string{"s", "save"}, "\"file\" start end", "save memory to a binary file", (*monitor).cmdSave},
+		{[]string{"reset"}, "", "reset the CPU (PC from the reset vector)", (*monitor).cmdReset},
+		{[]string{"profile"}, "on|off|reset|report [lines]|callgrind \"file\"", "count the cycles per address and subroutine", (*monitor).cmdProfile},
+		{[]string{"coverage"}, "on|off|reset|report [\"debug info\"]|lcov \"file\" \"debug info\"", "record the bytes executed, read and written", (*monitor).cmdCoverage},
+		{[]string{"ll", "load_labels"}, "\"file\"", "load labels (VICE, ca65 .dbg, ACME / 64tass)", (*monitor).cmdLoadLabels},
+		{[]string{"al", "add_label"}, "address .label", "define a label", (*monitor).cmdAddLabel},
+		{[]string{"shl", "show_labels"}, "[text]", "list the labels (containing text)", (*monitor).cmdShowLabels},
+		{[]string{"mapper"}, "[\"file\" [scheme]|off]", "show or attach a bank switching mapper (.nes files need no scheme)", (*monitor).cmdMapper},
+		{[]string{"map"}, "[clear|start end ram|rom|io|unmapped [rwx] [name]]", "list or declare the memory regions", (*monitor).cmdMap},
+		{[]string{"acia"}, "[address \"host:port\"|off]", "show the 6551 ACIAs or attach one talking to a TCP server (e.g. nc -l 6551)", (*monitor).cmdACIA},
+		{[]string{"riot"}, "[2600|off]", "show the 6532 RIOT or attach it with the Atari 2600 decoding", (*monitor).cmdRIOT},
+		{[]string{"via"}, "[address|off]", "show the 6522 VIAs or attach one at address", (*monitor).cmdVIA},
+		{[]string{"violations"}, "on|off", "stop on accesses without permission of the memory map", (*monitor).cmdViolations},
+		{[]string{"trace"}, "on|off", "print the core debug messages while executing", (*monitor).cmdTrace},
+		{[]string{"x", "q", "quit", "exit"}, "", "leave the monitor", nil},
+	}
+}
+
+func newMonitor(cpu CPU_6502.Machine, dbg *debugger.Debugger, out io.Writer) *monitor {
+
+	m := &monitor{
+		cpu:       cpu,
+		dbg:       dbg,
+		out:       out,
+		next_mem:  cpu.Registers().PC,
+		next_dis:  cpu.Registers().PC,
+		interrupt: make(chan os.Signal, 1),
+	}
+
+	signal.Notify(m.interrupt, os.Interrupt)
+
+	// Also used by the core debug messages of "trace on"
+	CPU_6502.Symbols = labels.Lookup
+	dbg.Symbols = labels.Resolve
+
+	return m
+}
+
+// Read-eval-print loop
+func (m *monitor) run(in *bufio.Scanner) {
+
+	m.printRegisters()
+
+	for {
+		fmt.Fprintf(m.out, "(C:$%04x) ", m.cpu.Registers().PC)
+
+		if !in.Scan() {
+			fmt.Fprintln(m.out)
+			return
+		}
+
+		args, err := splitArgs(in.Text())
+		if err != nil {
+			fmt.Fprintln(m.out, "error:", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(args[0])
+
+		// "> c000 01 02" may be typed without the space
+		if strings.HasPrefix(name, ">") && len(name) > 1 {
+			args = append([]string{">", name[1:]}, args[1:]...)
+			name = ">"
+		}
+
+		switch name {
+		case "x", "q", "quit", "exit":
+			return
+		case "a", "assemble":
+			if err := m.cmdAssemble(args[1:], in); err != nil {
+				fmt.Fprintln(m.out, "error:", err)
+			}
+			continue
+		}
+
+		cmd := findCommand(name)
+		if cmd == nil {
+			fmt.Fprintf(m.out, "unknown command %q, type help\n", name)
+			continue
+		}
+
+		// Commands are looked up by name, the first argument keeps it
+		if err := cmd.run(m, args); err != nil {
+			fmt.Fprintln(m.out, "error:", err)
+		}
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		for _, n := range commands[i].names {
+			if n == name {
+				return &commands[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Split a command line in words, quoted strings are kept together with their quotes
+func splitArgs(line string) ([]string, error) {
+
+	var (
+		args []string
+		word strings.Builder
+		in   bool // Inside a word
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '"':
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			word.WriteString(line[i : i+end+2])
+			i += end + 1
+			in = true
+
+		case c == ' ' || c == '\t':
+			if in {
+				args = append(args, word.String())
+				word.Reset()
+				in = false
+			}
+
+		default:
+			word.WriteByte(c)
+			in = true
+		}
+	}
+
+	if in {
+		args = append(args, word.String())
+	}
+
+	return args, nil
+}
+
+// ------------------------------- Arguments -------------------------------- //
+
+// Numbers are hexadecimal by default: c000, $c000, 0xc000, +49152 (decimal), %1010
+// Labels are prefixed by a dot: .init_screen
+func parseValue(text string) (int, error) {
+
+	var (
+		v   uint64
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(text, ".") && len(text) > 1:
+		addr, ok := labels.Resolve(text[1:])
+		if !ok {
+			return 0, fmt.Errorf("unknown label %q", text)
+		}
+		return int(addr), nil
+	case strings.HasPrefix(text, "$"):
+		v, err = strconv.ParseUint(text[1:], 16, 32)
+	case strings.HasPrefix(text, "0x"):
+		v, err = strconv.ParseUint(text[2:], 16, 32)
+	case strings.HasPrefix(text, "+"):
+		v, err = strconv.ParseUint(text[1:], 10, 32)
+	case strings.HasPrefix(text, "%"):
+		v, err = strconv.ParseUint(text[1:], 2, 32)
+	default:
+		v, err = strconv.ParseUint(text, 16, 32)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+
+	return int(v), nil
+}
+
+func parseAddress(text string) (uint16, error) {
+	v, err := parseValue(text)
+	if err == nil && v > 0xFFFF {
+		err = fmt.Errorf("address %q out of range", text)
+	}
+	return uint16(v), err
+}
+
+func parseByte(text string) (byte, error) {
+	v, err := parseValue(text)
+	if err == nil && v > 0xFF {
+		err = fmt.Errorf("value %q is not a byte", text)
+	}
+	return byte(v), err
+}
+
+// Parse a list of bytes (hex values or quoted strings)
+func parseBytes(args []string) ([]byte, error) {
+
+	var data []byte
+
+	for _, a := range args {
+		if strings.HasPrefix(a, "\"") {
+			data = append(data, unquote(a)...)
+			continue
+		}
+		b, err := parseByte(a)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("missing bytes")
+	}
+
+	return data, nil
+}
+
+func unquote(text string) string {
+	return strings.Trim(text, "\"")
+}
+
+// Parse start and end of a range, end must not be lower than start
+func parseRange(args []string) (uint16, uint16, error) {
+
+	if len(args) < 2 {
+		return 0, 0, fmt.Errorf("missing range")
+	}
+
+	start, err := parseAddress(args[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseAddress(args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("end of the range lower than start")
+	}
+
+	return start, end, nil
+}
+
+// Split "... if condition" arguments
+func splitCondition(args []string) ([]string, string) {
+	for i, a := range args {
+		if strings.EqualFold(a, "if") {
+			return args[:i], strings.Join(args[i+1:], " ")
+		}
+	}
+	return args, ""
+}
+
+// ------------------------------- Display ---------------------------------- //
+
+func (m *monitor) printRegisters() {
+
+	r := m.cpu.Registers()
+
+	fmt.Fprintln(m.out, "  ADDR A  X  Y  SP NV-BDIZC   CYCLE")
+	fmt.Fprintf(m.out, ".;%04x %02x %02x %02x %02x %08b %7d\n", r.PC, r.A, r.X, r.Y, r.SP, r.P, m.cpu.Cycles())
+}
+
+func (m *monitor) printStop(s *debugger.Stop) {
+
+	if s.Reason != debugger.ReasonStep {
+		fmt.Fprintln(m.out, s)
+	}
+
+	line := disassembler.Decode(m.cpu.ReadMemory, m.cpu.Registers().PC, disassembler.Options{Undocumented: true, Symbols: labels.Lookup})
+	fmt.Fprintln(m.out, line)
+
+	m.printRegisters()
+
+	m.next_dis = m.cpu.Registers().PC
+}
+
+// ------------------------------- Commands --------------------------------- //
+
+func (m *monitor) cmdHelp(args []string) error {
+
+	for _, c := range commands {
+		fmt.Fprintf(m.out, "  %-22s %-40s %s\n", strings.Join(c.names, ", "), c.args, c.help)
+	}
+
+	fmt.Fprintln(m.out, "\n  Numbers are hexadecimal ($c000, c000), +123 is decimal and %1010 binary.")
+	fmt.Fprintln(m.out, "  Conditions: A==$40 && [$D012]>$80 (registers, flags, [memory], VALUE of watchpoints)")
+
+	return nil
+}
+
+func (m *monitor) cmdRegisters(args []string) error {
+
+	r := m.cpu.Registers()
+
+	for _, a := range args[1:] {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected reg=value, got %q", a)
+		}
+
+		v, err := parseValue(parts[1])
+		if err != nil {
+			return err
+		}
+
+		flags := map[string]uint{"C": 0, "Z": 1, "I": 2, "D": 3, "B": 4, "V": 6, "N": 7}
+
+		switch name := strings.ToUpper(parts[0]); name {
+		case "PC":
+			r.PC = uint16(v)
+		case "A":
+			r.A = byte(v)
+		case "X":
+			r.X = byte(v)
+		case "Y":
+			r.Y = byte(v)
+		case "SP":
+			r.SP = byte(v)
+		case "P":
+			r.P = byte(v)
+		default:
+			bit, ok := flags[name]
+			if !ok {
+				return fmt.Errorf("unknown register %q", parts[0])
+			}
+			r.P = r.P&^(1<<bit) | byte(v&1)<<bit
+		}
+	}
+
+	m.cpu.SetRegisters(r)
+	m.printRegisters()
+
+	return nil
+}
+
+func (m *monitor) cmdMemory(args []string) error {
+
+	start, end := m.next_mem, m.next_mem+0x7F
+
+	switch len(args) {
+	case 1:
+	case 2:
+		addr, err := parseAddress(args[1])
+		if err != nil {
+			return err
+		}
+		start, end = addr, addr+0x7F
+	default:
+		var err error
+		if start, end, err = parseRange(args[1:]); err != nil {
+			return err
+		}
+	}
+
+	if end < start { // Wrapped around the end of the memory
+		end = 0xFFFF
+	}
+
+	for row := uint32(start); row <= uint32(end); row += 16 {
+
+		var hex, ascii strings.Builder
+
+		for i := uint32(0); i < 16 && row+i <= uint32(end); i++ {
+			b := m.cpu.ReadMemory(uint16(row + i))
+			fmt.Fprintf(&hex, "%02x ", b)
+			if b >= 0x20 && b < 0x7F {
+				ascii.WriteByte(b)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+
+		fmt.Fprintf(m.out, ">C:%04x  %-48s %s\n", row, hex.String(), ascii.String())
+	}
+
+	m.next_mem = end + 1
+
+	return nil
+}
+
+func (m *monitor) cmdChange(args []string) error {
+
+	if len(args) < 3 {
+		return fmt.Errorf("usage: > address byte ...")
+	}
+
+	addr, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	data, err := parseBytes(args[2:])
+	if err != nil {
+		return err
+	}
+
+	for i, b := range data {
+		m.cpu.WriteMemory(addr+uint16(i), b)
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdDisassemble(args []string) error {
+
+	start := m.next_dis
+	var end uint16
+
+	switch len(args) {
+	case 1, 2:
+		if len(args) == 2 {
+			addr, err := parseAddress(args[1])
+			if err != nil {
+				return err
+			}
+			start = addr
+		}
+		end = start + 0x1F
+		if end < start {
+			end = 0xFFFF
+		}
+	default:
+		var err error
+		if start, end, err = parseRange(args[1:]); err != nil {
+			return err
+		}
+	}
+
+	lines := disassembler.Range(m.cpu.ReadMemory, start, end, disassembler.Options{Undocumented: true, Symbols: labels.Lookup})
+
+	for _, l := range lines {
+		marker := "  "
+		if l.Address == m.cpu.Registers().PC {
+			marker = "> "
+		}
+		fmt.Fprintf(m.out, "%s.C:%s\n", marker, l.String())
+	}
+
+	if len(lines) > 0 {
+		last := lines[len(lines)-1]
+		m.next_dis = last.Address + last.Size()
+	}
+
+	return nil
+}
+
+// Assemble one instruction (a c000 lda #$01) or enter the assembly mode (a c000)
+func (m *monitor) cmdAssemble(args []string, in *bufio.Scanner) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: a address [instruction]")
+	}
+
+	addr, err := parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+
+	assemble := func(text string) error {
+		result, err := assembler.Assemble(" "+text, assembler.Options{Origin: addr, Undocumented: true})
+		if err != nil {
+			return err
+		}
+		for i, b := range result.Bytes() {
+			m.cpu.WriteMemory(addr+uint16(i), b)
+		}
+		addr += uint16(len(result.Bytes()))
+		return nil
+	}
+
+	if len(args) > 1 {
+		return assemble(strings.Join(args[1:], " "))
+	}
+
+	for {
+		fmt.Fprintf(m.out, ".%04x  ", addr)
+
+		if !in.Scan() || strings.TrimSpace(in.Text()) == "" {
+			return nil
+		}
+
+		if err := assemble(in.Text()); err != nil {
+			fmt.Fprintln(m.out, "error:", err)
+		}
+	}
+}
+
+// Repeat count of step, next and back, at least 1
+func (m *monitor) count(args []string) (int, error) {
+
+	if len(args) < 2 {
+		return 1, nil
+	}
+
+	n, err := parseValue(args[1])
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("invalid count %q", args[1])
+	}
+
+	return n, nil
+}
+
+func (m *monitor) cmdStep(args []string) error {
+
+	n, err := m.count(args)
+	if err != nil {
+		return err
+	}
+
+	var s *debugger.Stop
+	for i := 0; i < n; i++ {
+		if s = m.dbg.Step(); s.Reason != debugger.ReasonStep {
+			break
+		}
+	}
+
+	m.printStop(s)
+
+	return nil
+}
+
+func (m *monitor) cmdNext(args []string) error {
+
+	n, err := m.count(args)
+	if err != nil {
+		return err
+	}
+
+	var s *debugger.Stop
+	for i := 0; i < n; i++ {
+		if s = m.dbg.StepOver(m.limit); s.Reason != debugger.ReasonStep {
+			break
+		}
+	}
+
+	m.printStop(s)
+
+	return nil
+}
+
+func (m *monitor) cmdFinish(args []string) error {
+	m.printStop(m.dbg.StepOut(m.limit))
+	return nil
+}
+
+// Run in slices so Ctrl-C can stop the execution
+func (m *monitor) continueExecution() *debugger.Stop {
+
+	const slice = 100000
+
+	start := m.cpu.Cycles()
+
+	// Discard a Ctrl-C typed at the prompt
+	select {
+	case <-m.interrupt:
+	default:
+	}
+
+	for {
+		s := m.dbg.Continue(slice)
+
+		if s.Reason != debugger.ReasonLimit {
+			return s
+		}
+
+		if m.limit != 0 && m.cpu.Cycles()-start >= m.limit {
+			return s
+		}
+
+		select {
+		case <-m.interrupt:
+			fmt.Fprintln(m.out, "interrupted")
+			s.Reason = debugger.ReasonStep
+			return s
+		default:
+		}
+	}
+}
+
+func (m *monitor) cmdGo(args []string) error {
+
+	if len(args) > 1 {
+		addr, err := parseAddress(args[1])
+		if err != nil {
+			return err
+		}
+		r := m.cpu.Registers()
+		r.PC = addr
+		m.cpu.SetRegisters(r)
+	}
+
+	m.printStop(m.continueExecution())
+
+	return nil
+}
+
+func (m *monitor) cmdUntil(args []string) error {
+
+	if len(args) < 2 {
+		return fmt.Errorf("usage: until address")
+	}
+
+	addr, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	b := m.dbg.AddTemporaryBreakpoint(addr)
+	s := m.continueExecution()
+	_ = m.dbg.Delete(b.ID)
+
+	m.printStop(s)
+
+	return nil
+}
+
+func (m *monitor) cmdHistory(args []string) error {
+
+	size := 100000
+
+	if len(args) > 1 {
+		if strings.EqualFold(args[1], "off") {
+			size = 0
+		} else {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 0 {
+				return fmt.Errorf("invalid number of instructions %q", args[1])
+			}
+			size = v
+		}
+	}
+
+	m.dbg.EnableHistory(size)
+
+	if size == 0 {
+		fmt.Fprintln(m.out, "history off")
+	} else {
+		fmt.Fprintf(m.out, "recording the last %d instructions\n", size)
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdBack(args []string) error {
+
+	if m.dbg.History() == nil {
+		return fmt.Errorf("history is off, enable it with: history")
+	}
+
+	n, err := m.count(args)
+	if err != nil {
+		return err
+	}
+
+	var s *debugger.Stop
+	for i := 0; i < n; i++ {
+		if s = m.dbg.StepBack(); s.Reason != debugger.ReasonStep {
+			break
+		}
+	}
+
+	m.printStop(s)
+
+	return nil
+}
+
+func (m *monitor) cmdReverseContinue(args []string) error {
+
+	if m.dbg.History() == nil {
+		return fmt.Errorf("history is off, enable it with: history")
+	}
+
+	m.printStop(m.dbg.ReverseContinue())
+
+	return nil
+}
+
+func (m *monitor) cmdRewind(args []string) error {
+
+	if m.dbg.History() == nil {
+		return fmt.Errorf("history is off, enable it with: history")
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: rewind cycles")
+	}
+
+	cycles, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || cycles < 1 {
+		return fmt.Errorf("invalid cycle count %q", args[1])
+	}
+
+	m.printStop(m.dbg.Rewind(cycles))
+
+	return nil
+}
+
+func (m *monitor) cmdLimit(args []string) error {
+
+	if len(args) > 1 {
+		v, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cycle count %q", args[1])
+		}
+		m.limit = v
+	}
+
+	fmt.Fprintf(m.out, "cycle limit: %d\n", m.limit)
+
+	return nil
+}
+
+func (m *monitor) cmdBreak(args []string) error {
+
+	if len(args) == 1 {
+		for _, b := range m.dbg.Breakpoints() {
+			fmt.Fprintln(m.out, b)
+		}
+		return nil
+	}
+
+	words, cond := splitCondition(args[1:])
+	if len(words) != 1 {
+		return fmt.Errorf("usage: break address [if condition]")
+	}
+
+	addr, err := parseAddress(words[0])
+	if err != nil {
+		return err
+	}
+
+	b := m.dbg.AddBreakpoint(addr)
+	b.Temporary = args[0] == "tbreak"
+
+	return m.finishBreakpoint(b, cond)
+}
+
+func (m *monitor) finishBreakpoint(b *debugger.Breakpoint, cond string) error {
+
+	if err := m.dbg.SetCondition(b, cond); err != nil {
+		_ = m.dbg.Delete(b.ID)
+		return err
+	}
+
+	fmt.Fprintln(m.out, b)
+
+	return nil
+}
+
+func (m *monitor) cmdWatch(args []string) error {
+
+	words, cond := splitCondition(args[1:])
+
+	kind := debugger.Access
+	if len(words) > 0 {
+		switch strings.ToLower(words[0]) {
+		case "load", "r":
+			kind, words = debugger.Read, words[1:]
+		case "store", "w":
+			kind, words = debugger.Write, words[1:]
+		}
+	}
+
+	if len(words) == 1 {
+		words = append(words, words[0])
+	}
+
+	start, end, err := parseRange(words)
+	if err != nil {
+		return err
+	}
+
+	b, err := m.dbg.AddWatchpoint(kind, start, end)
+	if err != nil {
+		return err
+	}
+
+	return m.finishBreakpoint(b, cond)
+}
+
+func (m *monitor) cmdOpcodeBreak(args []string) error {
+
+	words, cond := splitCondition(args[1:])
+	if len(words) != 1 {
+		return fmt.Errorf("usage: opbreak opcode [if condition]")
+	}
+
+	opc, err := parseByte(words[0])
+	if err != nil {
+		return err
+	}
+
+	return m.finishBreakpoint(m.dbg.AddOpcodeBreakpoint(opc), cond)
+}
+
+func (m *monitor) cmdInterruptBreak(args []string) error {
+	_, cond := splitCondition(args[1:])
+	return m.finishBreakpoint(m.dbg.AddInterruptBreakpoint(), cond)
+}
+
+func (m *monitor) breakpointArg(args []string) (*debugger.Breakpoint, error) {
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("missing breakpoint id")
+	}
+
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid breakpoint id %q", args[1])
+	}
+
+	return m.dbg.Breakpoint(id)
+}
+
+func (m *monitor) cmdCondition(args []string) error {
+
+	b, err := m.breakpointArg(args)
+	if err != nil {
+		return err
+	}
+
+	if err := m.dbg.SetCondition(b, strings.Join(args[2:], " ")); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(m.out, b)
+
+	return nil
+}
+
+func (m *monitor) cmdIgnore(args []string) error {
+
+	b, err := m.breakpointArg(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return fmt.Errorf("usage: ignore id count")
+	}
+
+	count, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid count %q", args[2])
+	}
+
+	// Ignore the next hits, counting from now
+	b.IgnoreCount = b.Hits + count
+	fmt.Fprintln(m.out, b)
+
+	return nil
+}
+
+func (m *monitor) cmdEnable(args []string) error {
+
+	b, err := m.breakpointArg(args)
+	if err != nil {
+		return err
+	}
+
+	b.Enabled = args[0] == "enable"
+	fmt.Fprintln(m.out, b)
+
+	return nil
+}
+
+func (m *monitor) cmdDelete(args []string) error {
+
+	if len(args) == 1 {
+		m.dbg.DeleteAll()
+		return nil
+	}
+
+	b, err := m.breakpointArg(args)
+	if err != nil {
+		return err
+	}
+
+	return m.dbg.Delete(b.ID)
+}
+
+func (m *monitor) cmdFill(args []string) error {
+
+	if len(args) < 4 {
+		return fmt.Errorf("usage: f start end byte ...")
+	}
+
+	start, end, err := parseRange(args[1:])
+	if err != nil {
+		return err
+	}
+
+	pattern, err := parseBytes(args[3:])
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); uint32(start)+i <= uint32(end); i++ {
+		m.cpu.WriteMemory(start+uint16(i), pattern[int(i)%len(pattern)])
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdMove(args []string) error {
+
+	if len(args) != 4 {
+		return fmt.Errorf("usage: t start end destination")
+	}
+
+	start, end, err := parseRange(args[1:])
+	if err != nil {
+		return err
+	}
+	dest, err := parseAddress(args[3])
+	if err != nil {
+		return err
+	}
+
+	// Copy through a buffer, the ranges can overlap
+	data := make([]byte, int(end)-int(start)+1)
+	for i := range data {
+		data[i] = m.cpu.ReadMemory(start + uint16(i))
+	}
+	for i, b := range data {
+		m.cpu.WriteMemory(dest+uint16(i), b)
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdCompare(args []string) error {
+
+	if len(args) != 4 {
+		return fmt.Errorf("usage: c start end destination")
+	}
+
+	start, end, err := parseRange(args[1:])
+	if err != nil {
+		return err
+	}
+	dest, err := parseAddress(args[3])
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); uint32(start)+i <= uint32(end); i++ {
+		a, b := m.cpu.ReadMemory(start+uint16(i)), m.cpu.ReadMemory(dest+uint16(i))
+		if a != b {
+			fmt.Fprintf(m.out, "$%04x: %02x  $%04x: %02x\n", start+uint16(i), a, dest+uint16(i), b)
+		}
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdHunt(args []string) error {
+
+	if len(args) < 4 {
+		return fmt.Errorf("usage: h start end byte ...")
+	}
+
+	start, end, err := parseRange(args[1:])
+	if err != nil {
+		return err
+	}
+
+	pattern, err := parseBytes(args[3:])
+	if err != nil {
+		return err
+	}
+
+	for addr := uint32(start); addr+uint32(len(pattern))-1 <= uint32(end); addr++ {
+		found := true
+		for i, b := range pattern {
+			if m.cpu.ReadMemory(uint16(addr)+uint16(i)) != b {
+				found = false
+				break
+			}
+		}
+		if found {
+			fmt.Fprintf(m.out, "$%04x\n", addr)
+		}
+	}
+
+	return nil
+}
+
+func (m *monitor) cmdSave(args []string) error {
+
+	if len(args) != 4 {
+		return fmt.Errorf("usage: s \"file\" start end")
+	}
+
+	start, end, err := parseRange(args[2:])
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, int(end)-int(start)+1)
+	for i := range data {
+		data[i] = m.cpu.ReadMemory(start + uint16(i))
+	}
+
+	return os.WriteFile(unquote(args[1]), data, 0644)
+}
+
+func (m *monitor) cmdReset(args []string) error {
+
+	m.cpu.Reset()
+	m.printRegisters()
+
+	return nil
+}
+
+func (m *monitor) cmdTrace(args []string) error {
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: trace on|off")
+	}
+
+	m.cpu.SetTrace(strings.EqualFold(args[1], "on"))
+
+	return nil
+}
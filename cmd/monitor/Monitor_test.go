@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+)
+
+// Machine without the core
+type test_machine struct {
+	regs   CPU_6502.Registers
+	memory [65536]byte
+	resets int
+}
+
+func (t *test_machine) Registers() CPU_6502.Registers       { return t.regs }
+func (t *test_machine) SetRegisters(r CPU_6502.Registers)   { t.regs = r }
+func (t *test_machine) ReadMemory(addr uint16) byte         { return t.memory[addr] }
+func (t *test_machine) WriteMemory(addr uint16, value byte) { t.memory[addr] = value }
+func (t *test_machine) Cycles() uint64                      { return 1234 }
+func (t *test_machine) Reset()                              { t.resets++; t.regs.PC = 0xC000 }
+func (t *test_machine) SetTrace(on bool)                    {}
+
+// Runs the commands on a monitor driving cpu, returns the output
+func testMonitor(cpu CPU_6502.Machine, commands ...string) string {
+	var out bytes.Buffer
+	m := newMonitor(cpu, debugger.New(), &out)
+	m.run(bufio.NewScanner(strings.NewReader(strings.Join(commands, "\n"))))
+	return out.String()
+}
+
+// The commands use the machine given to the monitor, not the core
+func TestMonitorMachine(t *testing.T) {
+
+	cpu := &test_machine{}
+	cpu.regs.PC = 0x0400
+
+	out := testMonitor(cpu,
+		"f 1000 1003 aa bb",
+		"t 1000 1003 2000",
+		"r a=42 x=7",
+		"h 0 ffff aa bb aa",
+		"reset",
+	)
+
+	if !bytes.Equal(cpu.memory[0x2000:0x2004], []byte{0xAA, 0xBB, 0xAA, 0xBB}) {
+		t.Errorf("memory $2000 % X, want AA BB AA BB", cpu.memory[0x2000:0x2004])
+	}
+	if cpu.regs.A != 0x42 || cpu.regs.X != 0x07 {
+		t.Errorf("A $%02X X $%02X, want $42 $07", cpu.regs.A, cpu.regs.X)
+	}
+	if cpu.resets != 1 {
+		t.Errorf("%d resets, want 1", cpu.resets)
+	}
+	for _, want := range []string{"(C:$0400)", ".;0400 42 07 00 00", "1234", "$1000\n$2000\n", "(C:$c000)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output without %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/profiler"
+)
+
+// ------------------------------- Profiler --------------------------------- //
+
+func (m *monitor) cmdProfile(args []string) error {
+
+	if len(args) < 2 {
+		return fmt.Errorf("usage: profile on|off|reset|report [lines]|callgrind \"file\"")
+	}
+
+	switch strings.ToLower(args[1]) {
+
+	case "on":
+		if m.profiler == nil {
+			m.profiler = profiler.New()
+		}
+		return nil
+
+	case "off":
+		if m.profiler != nil {
+			m.profiler.Close()
+			m.profiler = nil
+		}
+		return nil
+	}
+
+	if m.profiler == nil {
+		return fmt.Errorf("the profiler is off, enable it with: profile on")
+	}
+
+	switch strings.ToLower(args[1]) {
+
+	case "reset":
+		m.profiler.Reset()
+
+	case "report":
+		lines := 20
+		if len(args) > 2 {
+			v, err := strconv.Atoi(args[2])
+			if err != nil || v < 0 {
+				return fmt.Errorf("invalid number of lines %q", args[2])
+			}
+			lines = v
+		}
+		return m.profiler.Report(m.out, lines)
+
+	case "callgrind":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: profile callgrind \"file\"")
+		}
+		f, err := os.Create(unquote(args[2]))
+		if err != nil {
+			return err
+		}
+		if err := m.profiler.WriteCallgrind(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+
+	default:
+		return fmt.Errorf("usage: profile on|off|reset|report [lines]|callgrind \"file\"")
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/riot"
+)
+
+// ------------------------------- RIOT ------------------------------------- //
+
+func (m *monitor) cmdRIOT(args []string) error {
+
+	switch {
+
+	case len(args) == 1:
+		if m.riot == nil {
+			fmt.Fprintln(m.out, "no RIOT")
+		} else {
+			fmt.Fprintln(m.out, m.riot)
+		}
+		return nil
+
+	case len(args) == 2 && strings.EqualFold(args[1], "off"):
+		if m.riot != nil {
+			m.riot.Detach()
+			m.riot = nil
+		}
+		return nil
+
+	case len(args) != 2 || args[1] != "2600":
+		return fmt.Errorf("usage: riot [2600|off]")
+	}
+
+	if m.riot != nil {
+		return fmt.Errorf("RIOT already attached")
+	}
+
+	r, err := riot.Attach2600()
+	if err != nil {
+		return err
+	}
+	m.riot = r
+
+	return nil
+}
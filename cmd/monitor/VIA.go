@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cassianoperin/6502_GO_Core/via"
+)
+
+// ------------------------------- VIA -------------------------------------- //
+
+func (m *monitor) cmdVIA(args []string) error {
+
+	switch {
+
+	case len(args) == 1:
+		if len(m.vias) == 0 {
+			fmt.Fprintln(m.out, "no VIA")
+		}
+		for _, v := range m.vias {
+			fmt.Fprintln(m.out, v)
+		}
+		return nil
+
+	case len(args) == 2 && strings.EqualFold(args[1], "off"):
+		for _, v := range m.vias {
+			v.Detach()
+		}
+		m.vias = nil
+		return nil
+
+	case len(args) > 2:
+		return fmt.Errorf("usage: via [address|off]")
+	}
+
+	base, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	v, err := via.Attach(base)
+	if err != nil {
+		return err
+	}
+	m.vias = append(m.vias, v)
+
+	return nil
+}
@@ -0,0 +1,61 @@
+// Machine-language monitor for the 6502 core, in the style of the VICE and
+// Apple II monitors.
+//
+//	monitor [-pc address] [-labels file] [rom file]
+//
+// Type "help" at the prompt for the list of commands.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+	"github.com/cassianoperin/6502_GO_Core/symbols"
+)
+
+func main() {
+
+	pc := flag.String("pc", "", "initial program counter (hex), overrides the reset vector")
+	label_file := flag.String("labels", "", "label file (VICE, ca65 .dbg, ACME / 64tass)")
+	flag.Parse()
+
+	if *label_file != "" {
+		t, err := symbols.Load(*label_file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		labels.Merge(t)
+	}
+
+	// Monitor output replaces the core debug messages
+	cpu := CPU_6502.Core()
+	cpu.SetTrace(false)
+
+	CPU_6502.Initialize()
+
+	if flag.NArg() > 0 {
+		CPU_6502.ReadROM(flag.Arg(0))
+	}
+
+	cpu.Reset()
+
+	if *pc != "" {
+		value, err := strconv.ParseUint(*pc, 16, 16)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -pc value %q\n", *pc)
+			os.Exit(2)
+		}
+		r := cpu.Registers()
+		r.PC = uint16(value)
+		cpu.SetRegisters(r)
+	}
+
+	m := newMonitor(cpu, debugger.New(), os.Stdout)
+	m.run(bufio.NewScanner(os.Stdin))
+}
@@ -0,0 +1,231 @@
+// Headless runner for the 6502 core, for scripted tests: loads a program,
+// runs it until a stop condition and prints the final registers on stderr.
+//
+//	run6502 [-a address] [-pc address] [-reset address] [-cpu 6502|6507]
+//	        [-cycles n] [-instructions n] [-putc address] [-getc address]
+//	        [-stop address] [-trap] [-brk] [-trace] file
+//
+// Files with their own addresses (.hex, .s19, .prg, .xex, .o65...) are
+// loaded by LoadFile, raw binaries need -a. Exit codes: 0 stopped by -stop,
+// -trap or -brk, 1 error, 2 limit reached, 3 CPU halted (opcode not
+// implemented, like the JAM opcodes).
+//
+// e.g. run6502 -a 0 -pc 400 -trap 6502_functional_test.bin
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Exit codes
+const (
+	EXIT_STOP   = 0
+	EXIT_ERROR  = 1
+	EXIT_LIMIT  = 2
+	EXIT_HALTED = 3
+)
+
+// Memory mapped stdio: writes go to out, reads come from in ($00 at the end of the input)
+type console struct {
+	in  *bufio.Reader
+	out *bufio.Writer
+}
+
+func (c *console) Read(offset uint16) byte {
+
+	if c.in == nil {
+		return 0
+	}
+
+	stdout.Flush()
+
+	value, err := c.in.ReadByte()
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+func (c *console) Write(offset uint16, value byte) {
+	if c.out != nil {
+		c.out.WriteByte(value)
+	}
+}
+
+var stdout = bufio.NewWriter(os.Stdout)
+
+func main() {
+
+	load := flag.String("a", "", "load address of a raw binary (hex)")
+	pc := flag.String("pc", "", "initial program counter (hex), overrides the reset vector")
+	reset := flag.String("reset", "", "write the reset vector (hex) before the reset")
+	cpu := flag.String("cpu", "6502", "CPU variant: 6502 or 6507")
+	max_cycles := flag.Uint64("cycles", 0, "cycle limit (0 = no limit)")
+	max_instructions := flag.Uint64("instructions", 0, "instruction limit (0 = no limit)")
+	putc := flag.String("putc", "", "address (hex) whose writes go to stdout")
+	getc := flag.String("getc", "", "address (hex) whose reads come from stdin")
+	stop := flag.String("stop", "", "stop when PC reaches this address (hex)")
+	trap := flag.Bool("trap", false, "stop on an instruction jumping or branching to itself")
+	brk := flag.Bool("brk", false, "stop on BRK")
+	trace := flag.Bool("trace", false, "print each instruction and the registers on stderr")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(EXIT_ERROR)
+	}
+
+	CPU_6502.Debug = false
+	CPU_6502.Pause = false
+
+	switch *cpu {
+	case "6502":
+		CPU_6502.CPU_MODE = 1
+	case "6507":
+		CPU_6502.CPU_MODE = 0
+	default:
+		fail(fmt.Errorf("unsupported CPU %q, the core emulates the 6502 and the 6507", *cpu))
+	}
+
+	CPU_6502.Initialize()
+
+	// Program
+	var (
+		img *CPU_6502.LoadImage
+		err error
+	)
+	if *load != "" {
+		img, err = CPU_6502.LoadBinary(flag.Arg(0), CPU_6502.LoadOptions{Address: hexAddress("-a", *load)})
+	} else {
+		img, err = CPU_6502.LoadFile(flag.Arg(0))
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if *reset != "" {
+		addr := hexAddress("-reset", *reset)
+		CPU_6502.WriteMemory(0xFFFC, byte(addr))
+		CPU_6502.WriteMemory(0xFFFD, byte(addr>>8))
+	}
+
+	CPU_6502.Reset()
+
+	if img.HasStart {
+		CPU_6502.PC = img.Start
+	}
+	if *pc != "" {
+		CPU_6502.PC = hexAddress("-pc", *pc)
+	}
+
+	// Stdio
+	stdin := bufio.NewReader(os.Stdin)
+	defer stdout.Flush()
+
+	switch {
+	case *putc != "" && *putc == *getc:
+		attach(&console{in: stdin, out: stdout}, hexAddress("-putc", *putc))
+	default:
+		if *putc != "" {
+			attach(&console{out: stdout}, hexAddress("-putc", *putc))
+		}
+		if *getc != "" {
+			attach(&console{in: stdin}, hexAddress("-getc", *getc))
+		}
+	}
+
+	stop_addr := -1
+	if *stop != "" {
+		stop_addr = int(hexAddress("-stop", *stop))
+	}
+
+	// Run
+	var (
+		instructions uint64
+		reason       string
+		code         = EXIT_LIMIT
+	)
+
+	for {
+		if *max_cycles != 0 && CPU_6502.Cycle >= *max_cycles {
+			reason = "cycle limit reached"
+			break
+		}
+		if *max_instructions != 0 && instructions >= *max_instructions {
+			reason = "instruction limit reached"
+			break
+		}
+		if int(CPU_6502.PC) == stop_addr {
+			reason, code = "stop address reached", EXIT_STOP
+			break
+		}
+		if *brk && CPU_6502.ReadMemory(CPU_6502.PC) == 0x00 {
+			reason, code = "BRK", EXIT_STOP
+			break
+		}
+
+		if *trace {
+			line := disassembler.Decode(CPU_6502.ReadMemory, CPU_6502.PC, disassembler.Options{})
+			fmt.Fprintf(os.Stderr, "%-32s %s\n", line, registers())
+		}
+
+		start := CPU_6502.PC
+		CPU_6502.Step()
+
+		if CPU_6502.Halted {
+			reason, code = fmt.Sprintf("CPU halted on opcode $%02X", CPU_6502.ReadMemory(CPU_6502.PC)), EXIT_HALTED
+			break
+		}
+
+		instructions++
+
+		if *trap && CPU_6502.PC == start {
+			reason, code = "trap (jump to itself)", EXIT_STOP
+			break
+		}
+	}
+
+	stdout.Flush()
+
+	fmt.Fprintf(os.Stderr, "%s at $%04X after %d instructions, %d cycles\n%s\n", reason, CPU_6502.PC, instructions, CPU_6502.Cycle, registers())
+
+	os.Exit(code)
+}
+
+func registers() string {
+	r := CPU_6502.GetRegisters()
+	return fmt.Sprintf("PC=%04X A=%02X X=%02X Y=%02X SP=%02X P=%08b (NV-BDIZC)", r.PC, r.A, r.X, r.Y, r.SP, r.P)
+}
+
+func attach(c *console, addr uint16) {
+	if err := CPU_6502.AttachDevice(c, addr, 1); err != nil {
+		fail(err)
+	}
+}
+
+func hexAddress(name, text string) uint16 {
+
+	value, err := strconv.ParseUint(text, 16, 16)
+	if err != nil {
+		fail(fmt.Errorf("invalid %s value %q", name, text))
+	}
+
+	return uint16(value)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(EXIT_ERROR)
+}
@@ -0,0 +1,57 @@
+// Runner for the programs of the cc65 sim6502 target, compatible with sim65:
+// the exit code is the one of the program.
+//
+//	sim6502 [-x max cycles] [-c] [-v] program [arguments]
+//
+// e.g. cl65 -t sim6502 test.c -o test && sim6502 test
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/sim65"
+)
+
+func main() {
+
+	max_cycles := flag.Uint64("x", 0, "exit with code 0x7E after this many cycles (0 = no limit)")
+	cycles := flag.Bool("c", false, "print the number of cycles executed")
+	verbose := flag.Bool("v", false, "print the program header")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] program [arguments]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(sim65.EXIT_ERROR)
+	}
+
+	CPU_6502.Debug = false
+	CPU_6502.Pause = false
+
+	p, err := sim65.LoadProgram(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(sim65.EXIT_ERROR)
+	}
+
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "Loaded %d bytes at $%04X, start $%04X, C stack pointer at $%02X\n", len(p.Code), p.Load, p.Start, p.SP)
+	}
+
+	code, err := sim65.Run(p, sim65.Config{Args: flag.Args(), MaxCycles: *max_cycles})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if *cycles {
+		fmt.Fprintf(os.Stderr, "%d cycles\n", CPU_6502.Cycle)
+	}
+
+	os.Exit(code)
+}
@@ -0,0 +1,255 @@
+// Package coverage records which bytes of the memory a 6502 program used:
+// executed as opcodes, fetched as operands, read or written as data, and the
+// directions taken by each branch.
+//
+// With the debug information of the program (debuginfo) the results are
+// mapped back to the source lines and exported in the lcov format.
+package coverage
+
+import (
+	"sort"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Flags tells how a byte was used
+type Flags byte
+
+const (
+	Opcode  Flags = 1 << iota // First byte of an executed instruction
+	Operand                   // Operand of an executed instruction
+	Read                      // Read as data
+	Written                   // Written as data
+)
+
+// Branch holds the outcomes of a conditional branch
+type Branch struct {
+	Address  uint16
+	Taken    uint64
+	NotTaken uint64
+}
+
+// Summary counts the bytes used
+type Summary struct {
+	Opcodes  int // Instructions executed (distinct addresses)
+	Operands int
+	Read     int
+	Written  int
+
+	Branches     int // Branches executed
+	BothTaken    int // Branches seen going both ways
+	TakenOnly    int
+	NotTakenOnly int
+}
+
+// Coverage collects the accesses through the CPU hooks
+type Coverage struct {
+	flags      []Flags
+	executions []uint64 // Executions of the instruction at each address
+	branches   map[uint16]*Branch
+
+	// Instruction in progress
+	running  bool
+	pending  bool // Not marked yet
+	last_pc  uint16
+	last_cyc uint64
+	last_len uint16 // Size of the instruction
+	branch   bool   // The instruction is a branch
+
+	table *[256]disassembler.Instruction
+	hooks *CPU_6502.Hooks
+}
+
+// New creates a coverage recorder attached to the CPU
+func New() *Coverage {
+
+	c := &Coverage{
+		flags:      make([]Flags, 0x10000),
+		executions: make([]uint64, 0x10000),
+		branches:   map[uint16]*Branch{},
+		table:      disassembler.Table(disassembler.NMOS),
+	}
+
+	c.hooks = &CPU_6502.Hooks{
+		Instruction: c.onInstruction,
+		Read:        c.onRead,
+		Write:       c.onWrite,
+	}
+
+	CPU_6502.AttachHooks(c.hooks)
+
+	return c
+}
+
+// Close detaches the recorder from the CPU (the results are kept)
+func (c *Coverage) Close() {
+	CPU_6502.DetachHooks(c.hooks)
+}
+
+// Reset clears the results
+func (c *Coverage) Reset() {
+
+	for i := range c.flags {
+		c.flags[i] = 0
+		c.executions[i] = 0
+	}
+
+	c.branches = map[uint16]*Branch{}
+	c.running = false
+	c.pending = false
+}
+
+// Flags returns how a byte was used
+func (c *Coverage) Flags(addr uint16) Flags {
+	c.sync()
+	return c.flags[addr]
+}
+
+// Executions returns how many times the instruction at addr was executed
+func (c *Coverage) Executions(addr uint16) uint64 {
+	c.sync()
+	return c.executions[addr]
+}
+
+// Branch returns the outcomes of the branch at addr (nil if never executed)
+func (c *Coverage) Branch(addr uint16) *Branch {
+	return c.branches[addr]
+}
+
+// Branches returns the branches executed, sorted by address
+func (c *Coverage) Branches() []Branch {
+
+	list := make([]Branch, 0, len(c.branches))
+	for _, b := range c.branches {
+		list = append(list, *b)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+
+	return list
+}
+
+// Summary counts the bytes used in the whole memory
+func (c *Coverage) Summary() Summary {
+
+	c.sync()
+
+	var s Summary
+
+	for _, f := range c.flags {
+		if f&Opcode != 0 {
+			s.Opcodes++
+		}
+		if f&Operand != 0 {
+			s.Operands++
+		}
+		if f&Read != 0 {
+			s.Read++
+		}
+		if f&Written != 0 {
+			s.Written++
+		}
+	}
+
+	for _, b := range c.branches {
+		s.Branches++
+		switch {
+		case b.Taken > 0 && b.NotTaken > 0:
+			s.BothTaken++
+		case b.Taken > 0:
+			s.TakenOnly++
+		default:
+			s.NotTakenOnly++
+		}
+	}
+
+	return s
+}
+
+// ---------------------------------- Hooks --------------------------------- //
+
+func (c *Coverage) onInstruction(pc uint16, opcode byte) bool {
+
+	cycle := CPU_6502.Cycle
+
+	if c.running {
+
+		// Same instruction seen again (the CPU was held by a debugger)
+		if cycle == c.last_cyc && pc == c.last_pc {
+			return false
+		}
+
+		// Outcome of the previous instruction (unknown if the time went backwards)
+		if cycle > c.last_cyc {
+			c.commit()
+			if c.branch {
+				c.branchOutcome(pc)
+			}
+		}
+	}
+
+	ins := c.table[opcode]
+
+	c.running = true
+	c.pending = true
+	c.last_pc = pc
+	c.last_cyc = cycle
+	c.last_len = uint16(ins.Bytes())
+	c.branch = ins.Mode == disassembler.Relative
+
+	return false
+}
+
+// Mark the instruction in progress, once its execution started (not held)
+func (c *Coverage) commit() {
+
+	if !c.pending {
+		return
+	}
+	c.pending = false
+
+	c.flags[c.last_pc] |= Opcode
+	c.executions[c.last_pc]++
+
+	for i := uint16(1); i < c.last_len; i++ {
+		c.flags[c.last_pc+i] |= Operand
+	}
+}
+
+// Commit the instruction in progress before the results are read
+func (c *Coverage) sync() {
+	if c.running && CPU_6502.Cycle > c.last_cyc {
+		c.commit()
+	}
+}
+
+func (c *Coverage) branchOutcome(pc uint16) {
+
+	b, ok := c.branches[c.last_pc]
+	if !ok {
+		b = &Branch{Address: c.last_pc}
+		c.branches[c.last_pc] = b
+	}
+
+	if pc == c.last_pc+2 {
+		b.NotTaken++
+	} else {
+		b.Taken++
+	}
+}
+
+// The instruction bytes are read through the data BUS by some addressing modes
+func (c *Coverage) instructionByte(addr uint16) bool {
+	return c.running && addr-c.last_pc < c.last_len
+}
+
+func (c *Coverage) onRead(addr uint16, value byte) {
+	if !c.instructionByte(addr) {
+		c.flags[addr] |= Read
+	}
+}
+
+func (c *Coverage) onWrite(addr uint16, old, value byte) {
+	c.flags[addr] |= Written
+}
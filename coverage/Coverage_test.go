@@ -0,0 +1,154 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/assembler"
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+)
+
+// The loop runs 3 times, the BEQ is always taken and never is never executed
+const test_source = `        .org $0200
+start:  ldx #0
+loop:   inx
+        stx $10
+        lda $20
+        cpx #3
+        bne loop
+        beq done
+never:  nop
+done:   jmp done
+`
+
+// Runs the test program until it reaches done, then executes the JMP
+func testCoverage(t *testing.T) (*Coverage, *debuginfo.Info) {
+
+	r, err := assembler.Assemble(test_source, assembler.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+	r.Load(&CPU_6502.Memory)
+	CPU_6502.PC = 0x0200
+	CPU_6502.SP = 0xFF
+
+	c := New()
+	t.Cleanup(c.Close)
+
+	for i := 0; CPU_6502.PC != 0x020E; i++ {
+		if i == 100 {
+			t.Fatalf("PC $%04X never reached done", CPU_6502.PC)
+		}
+		CPU_6502.Step()
+	}
+	CPU_6502.Step()
+
+	return c, r.DebugInfo()
+}
+
+// Bytes used, executions and branch outcomes
+func TestCoverage(t *testing.T) {
+
+	c, _ := testCoverage(t)
+
+	for _, test := range []struct {
+		addr  uint16
+		flags Flags
+		execs uint64
+	}{
+		{0x0200, Opcode, 1},
+		{0x0201, Operand, 0},
+		{0x0202, Opcode, 3},
+		{0x0209, Opcode, 3},
+		{0x020D, 0, 0},
+		{0x020E, Opcode, 1},
+		{0x0210, Operand, 0},
+		{0x0010, Written, 0},
+		{0x0020, Read, 0},
+	} {
+		if f, n := c.Flags(test.addr), c.Executions(test.addr); f != test.flags || n != test.execs {
+			t.Errorf("$%04X: flags %04b executed %d times, want %04b and %d", test.addr, f, n, test.flags, test.execs)
+		}
+	}
+
+	want := []Branch{{Address: 0x0209, Taken: 2, NotTaken: 1}, {Address: 0x020B, Taken: 1}}
+	if b := c.Branches(); len(b) != 2 || b[0] != want[0] || b[1] != want[1] {
+		t.Errorf("branches %+v, want %+v", b, want)
+	}
+
+	s := c.Summary()
+	if s != (Summary{Opcodes: 8, Operands: 8, Read: 1, Written: 1, Branches: 2, BothTaken: 1, TakenOnly: 1}) {
+		t.Errorf("summary %+v", s)
+	}
+
+	c.Reset()
+	if s := c.Summary(); s != (Summary{}) || c.Branch(0x0209) != nil {
+		t.Errorf("summary %+v after Reset", s)
+	}
+}
+
+// Line coverage in the report and in the lcov tracefile
+func TestSourceReports(t *testing.T) {
+
+	c, info := testCoverage(t)
+
+	var report bytes.Buffer
+	if err := c.Report(&report, info); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "<source>: 8/9 lines (88.9%), 1/2 branches both ways\n  not executed: 9\n") {
+		t.Errorf("report:\n%s", report.String())
+	}
+
+	var lcov bytes.Buffer
+	if err := c.WriteLCOV(&lcov, info, "loop"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `TN:loop
+SF:<source>
+FN:2,start
+FN:3,loop
+FN:9,never
+FN:10,done
+FNDA:1,start
+FNDA:3,loop
+FNDA:0,never
+FNDA:1,done
+FNF:4
+FNH:3
+BRDA:7,0,0,2
+BRDA:7,0,1,1
+BRDA:8,0,0,1
+BRDA:8,0,1,0
+BRF:4
+BRH:3
+DA:2,1
+DA:3,3
+DA:4,3
+DA:5,3
+DA:6,3
+DA:7,3
+DA:8,1
+DA:9,0
+DA:10,1
+LF:9
+LH:8
+end_of_record
+`
+	if lcov.String() != want {
+		t.Errorf("lcov:\n%s\nwant:\n%s", lcov.String(), want)
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	if got := lineRanges([]int{3, 7, 8, 9, 12}); got != "3, 7-9, 12" {
+		t.Errorf("line ranges %q", got)
+	}
+}
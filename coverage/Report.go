@@ -0,0 +1,259 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// ------------------------------ Source Lines ------------------------------ //
+
+// SourceLine is the coverage of an instruction line of the source
+type SourceLine struct {
+	Line       int
+	Executions uint64
+	Branch     *Branch // Outcomes when the line is a branch (nil if not executed)
+	IsBranch   bool
+}
+
+// SourceFile is the coverage of the instruction lines of a source file
+type SourceFile struct {
+	File    string
+	Lines   []SourceLine // Sorted by line number
+	Covered int          // Lines executed
+}
+
+// Sources maps the results to the instruction lines of the debug information
+// Data lines are ignored
+func (c *Coverage) Sources(info *debuginfo.Info) []SourceFile {
+
+	c.sync()
+
+	table := map[string]map[int]*SourceLine{}
+
+	for _, l := range info.Lines {
+
+		if !l.Code {
+			continue
+		}
+
+		lines, ok := table[l.File]
+		if !ok {
+			lines = map[int]*SourceLine{}
+			table[l.File] = lines
+		}
+
+		s, ok := lines[l.Line]
+		if !ok {
+			s = &SourceLine{Line: l.Line}
+			lines[l.Line] = s
+		}
+
+		// A line may generate code more than once (macros, repeats)
+		s.Executions += c.executions[l.Address]
+
+		if c.table[CPU_6502.ReadMemory(l.Address)].Mode == disassembler.Relative {
+			s.IsBranch = true
+			if b := c.branches[l.Address]; b != nil {
+				if s.Branch == nil {
+					s.Branch = &Branch{Address: b.Address}
+				}
+				s.Branch.Taken += b.Taken
+				s.Branch.NotTaken += b.NotTaken
+			}
+		}
+	}
+
+	files := make([]SourceFile, 0, len(table))
+
+	for file, lines := range table {
+
+		f := SourceFile{File: file}
+
+		for _, s := range lines {
+			f.Lines = append(f.Lines, *s)
+			if s.Executions > 0 {
+				f.Covered++
+			}
+		}
+
+		sort.Slice(f.Lines, func(i, j int) bool { return f.Lines[i].Line < f.Lines[j].Line })
+
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+
+	return files
+}
+
+// --------------------------------- Report --------------------------------- //
+
+func percent(value, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) * 100 / float64(total)
+}
+
+// Report writes the summary and, when the debug information is available
+// (info may be nil), the line coverage of each file with the lines not executed
+func (c *Coverage) Report(w io.Writer, info *debuginfo.Info) error {
+
+	out := bufio.NewWriter(w)
+	s := c.Summary()
+
+	fmt.Fprintf(out, "Instructions executed: %d\n", s.Opcodes)
+	fmt.Fprintf(out, "Operand bytes:         %d\n", s.Operands)
+	fmt.Fprintf(out, "Bytes read as data:    %d\n", s.Read)
+	fmt.Fprintf(out, "Bytes written:         %d\n", s.Written)
+	fmt.Fprintf(out, "Branches executed:     %d (both ways: %d, taken only: %d, not taken only: %d)\n",
+		s.Branches, s.BothTaken, s.TakenOnly, s.NotTakenOnly)
+
+	if info != nil {
+
+		for _, f := range c.Sources(info) {
+
+			var missed []int
+			branches, both := 0, 0
+
+			for _, l := range f.Lines {
+				if l.Executions == 0 {
+					missed = append(missed, l.Line)
+				}
+				if l.IsBranch {
+					branches++
+					if l.Branch != nil && l.Branch.Taken > 0 && l.Branch.NotTaken > 0 {
+						both++
+					}
+				}
+			}
+
+			fmt.Fprintf(out, "\n%s: %d/%d lines (%.1f%%), %d/%d branches both ways\n",
+				f.File, f.Covered, len(f.Lines), percent(f.Covered, len(f.Lines)), both, branches)
+
+			if len(missed) > 0 {
+				fmt.Fprintf(out, "  not executed: %s\n", lineRanges(missed))
+			}
+		}
+	}
+
+	return out.Flush()
+}
+
+// Format sorted line numbers as "3, 7-9, 12"
+func lineRanges(lines []int) string {
+
+	var parts []string
+
+	for i := 0; i < len(lines); {
+
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+
+		if i == j {
+			parts = append(parts, fmt.Sprint(lines[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ---------------------------------- LCOV ---------------------------------- //
+
+// WriteLCOV exports the line, branch and function (symbols at instruction
+// lines) coverage in the lcov tracefile format, test is the test name (TN)
+func (c *Coverage) WriteLCOV(w io.Writer, info *debuginfo.Info, test string) error {
+
+	c.sync()
+
+	out := bufio.NewWriter(w)
+
+	// Symbols at the first byte of an instruction line are functions
+	type function struct {
+		name string
+		line int
+		hits uint64
+	}
+	functions := map[string][]function{}
+
+	for _, l := range info.Lines {
+		if !l.Code {
+			continue
+		}
+		if name, ok := info.Symbol(l.Address); ok {
+			functions[l.File] = append(functions[l.File], function{name, l.Line, c.executions[l.Address]})
+		}
+	}
+
+	for _, f := range c.Sources(info) {
+
+		fmt.Fprintf(out, "TN:%s\n", test)
+		fmt.Fprintf(out, "SF:%s\n", f.File)
+
+		fns := functions[f.File]
+		sort.SliceStable(fns, func(i, j int) bool { return fns[i].line < fns[j].line })
+
+		hit := 0
+		for _, fn := range fns {
+			fmt.Fprintf(out, "FN:%d,%s\n", fn.line, fn.name)
+		}
+		for _, fn := range fns {
+			fmt.Fprintf(out, "FNDA:%d,%s\n", fn.hits, fn.name)
+			if fn.hits > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(out, "FNF:%d\nFNH:%d\n", len(fns), hit)
+
+		branches, taken := 0, 0
+
+		for _, l := range f.Lines {
+
+			if !l.IsBranch {
+				continue
+			}
+
+			// Branch 0 is taken, branch 1 falls through, "-" when never executed
+			outcomes := []string{"-", "-"}
+			if l.Executions > 0 && l.Branch != nil {
+				outcomes[0] = fmt.Sprint(l.Branch.Taken)
+				outcomes[1] = fmt.Sprint(l.Branch.NotTaken)
+				if l.Branch.Taken > 0 {
+					taken++
+				}
+				if l.Branch.NotTaken > 0 {
+					taken++
+				}
+			}
+
+			for i, o := range outcomes {
+				fmt.Fprintf(out, "BRDA:%d,0,%d,%s\n", l.Line, i, o)
+			}
+			branches += 2
+		}
+
+		fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", branches, taken)
+
+		for _, l := range f.Lines {
+			fmt.Fprintf(out, "DA:%d,%d\n", l.Line, l.Executions)
+		}
+
+		fmt.Fprintf(out, "LF:%d\nLH:%d\n", len(f.Lines), f.Covered)
+		fmt.Fprintln(out, "end_of_record")
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,191 @@
+package dap
+
+import (
+	"fmt"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+)
+
+// ------------------------------- Execution -------------------------------- //
+
+func (s *session) continueRequest(req *request) error {
+	s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+	s.running = true
+	return nil
+}
+
+// Step in executes one instruction
+func (s *session) stepIn(req *request) error {
+	s.respond(req, nil)
+	s.report(s.dbg.Step(), "step")
+	return nil
+}
+
+// Next runs subroutine calls (JSR) to their return, at the same stack depth (recursion)
+func (s *session) next(req *request) error {
+
+	s.respond(req, nil)
+
+	pc := CPU_6502.PC
+
+	if CPU_6502.ReadMemory(pc) != 0x20 { // JSR
+		s.report(s.dbg.Step(), "step")
+		return nil
+	}
+
+	b := s.dbg.AddBreakpoint(pc + 3)
+	b.Condition, _ = debugger.ParseCondition(fmt.Sprintf("SP>=%d", CPU_6502.SP), nil)
+
+	s.step_bps = []*debugger.Breakpoint{b}
+	s.step_out = false
+	s.running = true
+
+	return nil
+}
+
+// Step out runs until the current subroutine returns (RTS or RTI at the same stack depth)
+func (s *session) stepOut(req *request) error {
+
+	s.respond(req, nil)
+
+	if opc := CPU_6502.ReadMemory(CPU_6502.PC); opc == 0x60 || opc == 0x40 {
+		s.report(s.dbg.Step(), "step")
+		return nil
+	}
+
+	cond := fmt.Sprintf("SP>=%d", CPU_6502.SP)
+
+	for _, opc := range []byte{0x60, 0x40} {
+		b := s.dbg.AddOpcodeBreakpoint(opc)
+		b.Condition, _ = debugger.ParseCondition(cond, nil)
+		s.step_bps = append(s.step_bps, b)
+	}
+
+	s.step_out = true
+	s.running = true
+
+	return nil
+}
+
+// Step back undoes one instruction (needs the "history" launch argument)
+func (s *session) stepBack(req *request) error {
+
+	if s.dbg.History() == nil {
+		return fmt.Errorf("reverse execution needs the history launch argument")
+	}
+
+	s.respond(req, nil)
+	s.report(s.dbg.StepBack(), "step")
+
+	return nil
+}
+
+func (s *session) reverseContinue(req *request) error {
+
+	if s.dbg.History() == nil {
+		return fmt.Errorf("reverse execution needs the history launch argument")
+	}
+
+	s.respond(req, nil)
+	s.report(s.dbg.ReverseContinue(), "step")
+
+	return nil
+}
+
+func (s *session) pause(req *request) error {
+
+	s.respond(req, nil)
+
+	if s.running {
+		s.clearStep()
+		s.stopped("pause", nil, "")
+	}
+
+	return nil
+}
+
+// Run until the next stop, a slice at a time so requests are still answered
+func (s *session) runSlice() {
+
+	st := s.dbg.Continue(runSlice)
+
+	if st.Reason == debugger.ReasonLimit {
+		return
+	}
+
+	// Internal breakpoint of next / step out
+	for _, b := range s.step_bps {
+		if st.Breakpoint == b {
+			out := s.step_out
+			s.clearStep()
+			if out {
+				st = s.dbg.Step() // Execute the RTS / RTI
+			}
+			s.report(st, "step")
+			return
+		}
+	}
+
+	s.clearStep()
+	s.report(st, "")
+}
+
+func (s *session) clearStep() {
+
+	for _, b := range s.step_bps {
+		_ = s.dbg.Delete(b.ID)
+	}
+
+	s.step_bps = nil
+	s.step_out = false
+}
+
+// Send the stopped event of a debugger stop, reason is used for plain steps
+func (s *session) report(st *debugger.Stop, reason string) {
+
+	switch st.Reason {
+	case debugger.ReasonHistoryEnd:
+		s.stopped(reason, nil, st.String())
+		return
+	case debugger.ReasonViolation, debugger.ReasonHalted:
+		s.stopped("exception", nil, st.String())
+		return
+	case debugger.ReasonBreakpoint:
+	default:
+		s.stopped(reason, nil, "")
+		return
+	}
+
+	b := st.Breakpoint
+
+	switch b.Kind {
+	case debugger.Read, debugger.Write, debugger.Access:
+		s.stopped("data breakpoint", b, st.String())
+	case debugger.Interrupt:
+		s.stopped("exception", b, st.String())
+	default:
+		s.stopped("breakpoint", b, "")
+	}
+}
+
+func (s *session) stopped(reason string, b *debugger.Breakpoint, text string) {
+
+	s.running = false
+
+	body := map[string]interface{}{
+		"reason":            reason,
+		"threadId":          1,
+		"allThreadsStopped": true,
+	}
+
+	if b != nil {
+		body["hitBreakpointIds"] = []int{b.ID}
+	}
+	if text != "" {
+		body["description"] = text
+		body["text"] = text
+	}
+
+	s.event("stopped", body)
+}
@@ -0,0 +1,109 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ------------------------------- Messages -------------------------------- //
+
+// Base of all the protocol messages
+type message struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"` // request, response or event
+}
+
+type request struct {
+	message
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	message
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	message
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// ------------------------------- Transport -------------------------------- //
+
+// transport reads and writes messages framed with a Content-Length header
+type transport struct {
+	r *bufio.Reader
+
+	w       io.Writer
+	w_mutex sync.Mutex
+	seq     int
+}
+
+func newTransport(r io.Reader, w io.Writer) *transport {
+	return &transport{r: bufio.NewReader(r), w: w}
+}
+
+func (t *transport) read() (*request, error) {
+
+	header, err := textproto.NewReader(t.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(t.r, body); err != nil {
+		return nil, err
+	}
+
+	req := &request{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (t *transport) write(msg interface{}) error {
+
+	t.w_mutex.Lock()
+	defer t.w_mutex.Unlock()
+
+	t.seq++
+
+	switch m := msg.(type) {
+	case *response:
+		m.Seq, m.Type = t.seq, "response"
+	case *event:
+		m.Seq, m.Type = t.seq, "event"
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(t.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err = t.w.Write(body)
+
+	return err
+}
@@ -0,0 +1,856 @@
+package dap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/assembler"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Variables references of the scopes
+const (
+	refRegisters = 1
+	refFlags     = 2
+)
+
+var handlers map[string]func(s *session, req *request) error
+
+func init() {
+	handlers = map[string]func(s *session, req *request) error{
+		"initialize":                (*session).initialize,
+		"launch":                    (*session).launch,
+		"configurationDone":         (*session).configurationDone,
+		"setBreakpoints":            (*session).setBreakpoints,
+		"setInstructionBreakpoints": (*session).setInstructionBreakpoints,
+		"setExceptionBreakpoints":   (*session).setExceptionBreakpoints,
+		"threads":                   (*session).threads,
+		"stackTrace":                (*session).stackTrace,
+		"scopes":                    (*session).scopes,
+		"variables":                 (*session).variables,
+		"setVariable":               (*session).setVariable,
+		"evaluate":                  (*session).evaluate,
+		"readMemory":                (*session).readMemory,
+		"writeMemory":               (*session).writeMemory,
+		"disassemble":               (*session).disassemble,
+		"continue":                  (*session).continueRequest,
+		"next":                      (*session).next,
+		"stepIn":                    (*session).stepIn,
+		"stepOut":                   (*session).stepOut,
+		"stepBack":                  (*session).stepBack,
+		"reverseContinue":           (*session).reverseContinue,
+		"pause":                     (*session).pause,
+		"disconnect":                (*session).disconnect,
+		"terminate":                 (*session).disconnect,
+	}
+}
+
+func (s *session) dispatch(req *request) {
+
+	s.logf("<- %s %s", req.Command, req.Arguments)
+
+	h, ok := handlers[req.Command]
+	if !ok {
+		s.fail(req, fmt.Errorf("unsupported request %q", req.Command))
+		return
+	}
+
+	// Everything but launch and initialize needs a launched program
+	if s.dbg == nil && req.Command != "initialize" && req.Command != "launch" && req.Command != "disconnect" {
+		s.fail(req, fmt.Errorf("no program launched"))
+		return
+	}
+
+	if err := h(s, req); err != nil {
+		s.fail(req, err)
+	}
+}
+
+func decode(req *request, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, args)
+}
+
+// ------------------------------ Initialization ---------------------------- //
+
+func (s *session) initialize(req *request) error {
+
+	var args struct {
+		LinesStartAt1 *bool `json:"linesStartAt1"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+		s.line_base = 0
+	}
+
+	s.respond(req, map[string]interface{}{
+		"supportsConfigurationDoneRequest":  true,
+		"supportsConditionalBreakpoints":    true,
+		"supportsHitConditionalBreakpoints": true,
+		"supportsSetVariable":               true,
+		"supportsEvaluateForHovers":         true,
+		"supportsReadMemoryRequest":         true,
+		"supportsWriteMemoryRequest":        true,
+		"supportsDisassembleRequest":        true,
+		"supportsInstructionBreakpoints":    true,
+		"supportsSteppingGranularity":       true,
+		"supportsTerminateRequest":          true,
+		"supportsStepBack":                  true,
+		"exceptionBreakpointFilters": []map[string]interface{}{
+			{"filter": "interrupt", "label": "BRK / IRQ / NMI", "default": false},
+		},
+	})
+
+	return nil
+}
+
+type launchArguments struct {
+	Program      string      `json:"program"`
+	LoadAddress  interface{} `json:"loadAddress"`
+	Source       string      `json:"source"`
+	DebugInfo    string      `json:"debugInfo"`
+	PC           interface{} `json:"pc"`
+	StopOnEntry  bool        `json:"stopOnEntry"`
+	Undocumented bool        `json:"undocumented"`
+	History      int         `json:"history"`
+}
+
+func (s *session) launch(req *request) error {
+
+	var args launchArguments
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	if s.dbg != nil {
+		return fmt.Errorf("program already launched")
+	}
+
+	if args.Program == "" && args.Source == "" {
+		return fmt.Errorf("launch needs a program or a source")
+	}
+
+	CPU_6502.Pause = false
+	CPU_6502.Initialize()
+
+	// Binary image
+	if args.Program != "" {
+
+		load, _, err := addressArgument(args.LoadAddress)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(args.Program)
+		if err != nil {
+			return err
+		}
+
+		if int(load)+len(data) > 0x10000 {
+			return fmt.Errorf("%s does not fit in memory at $%04X (%d bytes)", args.Program, load, len(data))
+		}
+
+		copy(CPU_6502.Memory[load:], data)
+	}
+
+	// Assembly source, also provides the debug information
+	var entry uint16
+
+	if args.Source != "" {
+		result, err := assembler.AssembleFile(args.Source, assembler.Options{Undocumented: args.Undocumented})
+		if err != nil {
+			return err
+		}
+
+		result.Load(&CPU_6502.Memory)
+		s.info = result.DebugInfo()
+		entry, _ = result.Image()
+	}
+
+	if args.DebugInfo != "" {
+		info, err := debuginfo.Load(args.DebugInfo)
+		if err != nil {
+			return err
+		}
+		s.info = info
+	}
+
+	// Initial PC: argument, reset vector or start of the assembled code
+	pc, ok, err := addressArgument(args.PC)
+	if err != nil {
+		return err
+	}
+
+	CPU_6502.PC_as_argument = 0
+	if ok {
+		CPU_6502.PC_as_argument = pc
+	}
+	CPU_6502.Reset()
+
+	if !ok && CPU_6502.PC == 0 && args.Source != "" {
+		CPU_6502.PC = entry
+	}
+
+	s.dbg = debugger.New()
+	s.dbg.EnableHistory(args.History)
+	s.stack = debugger.NewCallStack()
+	s.undocumented = args.Undocumented
+	s.stop_on_entry = args.StopOnEntry
+
+	if s.info != nil {
+		s.dbg.Symbols = s.info.Resolve
+	}
+
+	s.respond(req, nil)
+	s.event("initialized", nil)
+
+	s.output("6502 loaded, PC=$%04X", CPU_6502.PC)
+
+	return nil
+}
+
+func (s *session) configurationDone(req *request) error {
+
+	s.respond(req, nil)
+
+	if s.stop_on_entry {
+		s.stopped("entry", nil, "")
+	} else {
+		s.running = true
+	}
+
+	return nil
+}
+
+func (s *session) disconnect(req *request) error {
+	s.running = false
+	s.respond(req, nil)
+	s.event("terminated", nil)
+	s.quit = true
+	return nil
+}
+
+// ------------------------------- Breakpoints ------------------------------ //
+
+type sourceBreakpoint struct {
+	Line         int    `json:"line"`
+	Condition    string `json:"condition"`
+	HitCondition string `json:"hitCondition"`
+}
+
+func (s *session) setBreakpoints(req *request) error {
+
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		} `json:"source"`
+		Breakpoints []sourceBreakpoint `json:"breakpoints"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	path := args.Source.Path
+	if path == "" {
+		path = args.Source.Name
+	}
+
+	// The client always sends the complete list of a source
+	for _, b := range s.source_bps[path] {
+		_ = s.dbg.Delete(b.ID)
+	}
+	delete(s.source_bps, path)
+
+	result := make([]map[string]interface{}, 0, len(args.Breakpoints))
+
+	for _, sb := range args.Breakpoints {
+
+		line := sb.Line - s.line_base + 1
+
+		if s.info == nil {
+			result = append(result, map[string]interface{}{"verified": false, "line": sb.Line, "message": "no debug information"})
+			continue
+		}
+
+		addrs, actual := s.info.Addresses(path, line)
+		if len(addrs) == 0 {
+			result = append(result, map[string]interface{}{"verified": false, "line": sb.Line, "message": "no code at this line"})
+			continue
+		}
+
+		var first *debugger.Breakpoint
+
+		for _, addr := range addrs {
+			b := s.dbg.AddBreakpoint(addr)
+			if err := s.configure(b, sb.Condition, sb.HitCondition); err != nil {
+				_ = s.dbg.Delete(b.ID)
+				first = nil
+				result = append(result, map[string]interface{}{"verified": false, "line": sb.Line, "message": err.Error()})
+				break
+			}
+			s.source_bps[path] = append(s.source_bps[path], b)
+			if first == nil {
+				first = b
+			}
+		}
+
+		if first != nil {
+			result = append(result, map[string]interface{}{
+				"id":                   first.ID,
+				"verified":             true,
+				"line":                 actual + s.line_base - 1,
+				"instructionReference": addressReference(first.Start),
+			})
+		}
+	}
+
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+
+	return nil
+}
+
+// Condition and hit count ("5" stops on the 5th hit) of a client breakpoint
+func (s *session) configure(b *debugger.Breakpoint, condition, hit string) error {
+
+	if err := s.dbg.SetCondition(b, condition); err != nil {
+		return err
+	}
+
+	if hit = strings.TrimLeft(strings.TrimSpace(hit), "=>"); hit != "" {
+		n, err := strconv.ParseUint(strings.TrimSpace(hit), 10, 64)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid hit count %q", hit)
+		}
+		b.IgnoreCount = n - 1
+	}
+
+	return nil
+}
+
+func (s *session) setInstructionBreakpoints(req *request) error {
+
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+			Condition            string `json:"condition"`
+			HitCondition         string `json:"hitCondition"`
+		} `json:"breakpoints"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	for _, b := range s.instruction_bps {
+		_ = s.dbg.Delete(b.ID)
+	}
+	s.instruction_bps = nil
+
+	result := make([]map[string]interface{}, 0, len(args.Breakpoints))
+
+	for _, ib := range args.Breakpoints {
+
+		addr, err := parseReference(ib.InstructionReference)
+		if err != nil {
+			result = append(result, map[string]interface{}{"verified": false, "message": err.Error()})
+			continue
+		}
+
+		b := s.dbg.AddBreakpoint(addr + uint16(ib.Offset))
+		if err := s.configure(b, ib.Condition, ib.HitCondition); err != nil {
+			_ = s.dbg.Delete(b.ID)
+			result = append(result, map[string]interface{}{"verified": false, "message": err.Error()})
+			continue
+		}
+
+		s.instruction_bps = append(s.instruction_bps, b)
+		result = append(result, map[string]interface{}{"id": b.ID, "verified": true, "instructionReference": addressReference(b.Start)})
+	}
+
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+
+	return nil
+}
+
+func (s *session) setExceptionBreakpoints(req *request) error {
+
+	var args struct {
+		Filters []string `json:"filters"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	for _, b := range s.exception_bps {
+		_ = s.dbg.Delete(b.ID)
+	}
+	s.exception_bps = nil
+
+	for _, f := range args.Filters {
+		if f == "interrupt" {
+			s.exception_bps = append(s.exception_bps, s.dbg.AddInterruptBreakpoint())
+		}
+	}
+
+	s.respond(req, nil)
+
+	return nil
+}
+
+// ------------------------------- Inspection ------------------------------- //
+
+func (s *session) threads(req *request) error {
+	s.respond(req, map[string]interface{}{
+		"threads": []map[string]interface{}{{"id": 1, "name": "6502"}},
+	})
+	return nil
+}
+
+func (s *session) stackTrace(req *request) error {
+
+	frames := s.stack.Frames()
+
+	// The innermost frame is the current PC, each call adds the address of its JSR
+	result := make([]map[string]interface{}, 0, len(frames)+1)
+
+	pc := CPU_6502.PC
+
+	for i := 0; i <= len(frames); i++ {
+
+		var name string
+
+		switch {
+		case i < len(frames):
+			name = s.frameName(frames[i].Entry)
+			if frames[i].Interrupt {
+				name += " [" + frames[i].Source.String() + "]"
+			}
+		default:
+			name = s.frameName(pc)
+		}
+
+		frame := map[string]interface{}{
+			"id":                          i + 1,
+			"name":                        name,
+			"line":                        0,
+			"column":                      0,
+			"instructionPointerReference": addressReference(pc),
+		}
+
+		if s.info != nil {
+			if l, ok := s.info.Lookup(pc); ok {
+				frame["source"] = map[string]interface{}{"name": filepath.Base(l.File), "path": l.File}
+				frame["line"] = l.Line + s.line_base - 1
+				frame["column"] = s.line_base
+			}
+		}
+
+		result = append(result, frame)
+
+		if i < len(frames) {
+			pc = frames[i].Call
+		}
+	}
+
+	s.respond(req, map[string]interface{}{"stackFrames": result, "totalFrames": len(result)})
+
+	return nil
+}
+
+// Name of a frame from its entry point (or current address for the outermost one)
+func (s *session) frameName(addr uint16) string {
+
+	if s.info != nil {
+		if name, offset, ok := s.info.Nearest(addr); ok {
+			if offset == 0 {
+				return name
+			}
+			return fmt.Sprintf("%s+%d", name, offset)
+		}
+	}
+
+	return fmt.Sprintf("$%04X", addr)
+}
+
+func (s *session) scopes(req *request) error {
+	s.respond(req, map[string]interface{}{
+		"scopes": []map[string]interface{}{
+			{"name": "Registers", "variablesReference": refRegisters, "expensive": false},
+			{"name": "Flags", "variablesReference": refFlags, "expensive": false},
+		},
+	})
+	return nil
+}
+
+var flagNames = []struct {
+	name string
+	bit  int
+}{{"N", 7}, {"V", 6}, {"B", 4}, {"D", 3}, {"I", 2}, {"Z", 1}, {"C", 0}}
+
+func (s *session) variables(req *request) error {
+
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	r := CPU_6502.GetRegisters()
+
+	byteVar := func(name string, v byte) map[string]interface{} {
+		return map[string]interface{}{"name": name, "value": fmt.Sprintf("$%02X (%d)", v, v), "variablesReference": 0}
+	}
+
+	var vars []map[string]interface{}
+
+	switch args.VariablesReference {
+	case refRegisters:
+		vars = []map[string]interface{}{
+			{"name": "PC", "value": fmt.Sprintf("$%04X", r.PC), "variablesReference": 0, "memoryReference": addressReference(r.PC)},
+			byteVar("A", r.A),
+			byteVar("X", r.X),
+			byteVar("Y", r.Y),
+			{"name": "SP", "value": fmt.Sprintf("$%02X", r.SP), "variablesReference": 0, "memoryReference": addressReference(0x100 | uint16(r.SP))},
+			{"name": "P", "value": fmt.Sprintf("$%02X (%08b)", r.P, r.P), "variablesReference": refFlags},
+		}
+	case refFlags:
+		for _, f := range flagNames {
+			vars = append(vars, map[string]interface{}{"name": f.name, "value": strconv.Itoa(int(r.P >> f.bit & 1)), "variablesReference": 0})
+		}
+	}
+
+	s.respond(req, map[string]interface{}{"variables": vars})
+
+	return nil
+}
+
+func (s *session) setVariable(req *request) error {
+
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	v, err := s.evalValue(args.Value)
+	if err != nil {
+		return err
+	}
+
+	r := CPU_6502.GetRegisters()
+
+	switch args.Name {
+	case "PC":
+		r.PC = uint16(v)
+	case "A":
+		r.A = byte(v)
+	case "X":
+		r.X = byte(v)
+	case "Y":
+		r.Y = byte(v)
+	case "SP":
+		r.SP = byte(v)
+	case "P":
+		r.P = byte(v)
+	default:
+		found := false
+		for _, f := range flagNames {
+			if f.name == args.Name {
+				r.P = r.P&^(1<<f.bit) | byte(v&1)<<f.bit
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown variable %q", args.Name)
+		}
+	}
+
+	CPU_6502.SetRegisters(r)
+
+	s.respond(req, map[string]interface{}{"value": strings.TrimSpace(args.Value)})
+
+	return nil
+}
+
+// Expressions use the debugger condition syntax: A+1, [$0200], label
+func (s *session) evalValue(expr string) (int, error) {
+
+	c, err := debugger.ParseCondition(expr, s.dbg.Symbols)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Eval(), nil
+}
+
+func (s *session) evaluate(req *request) error {
+
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	v, err := s.evalValue(args.Expression)
+	if err != nil {
+		return err
+	}
+
+	s.respond(req, map[string]interface{}{
+		"result":             fmt.Sprintf("$%X (%d)", v, v),
+		"variablesReference": 0,
+		"memoryReference":    addressReference(uint16(v)),
+	})
+
+	return nil
+}
+
+func (s *session) readMemory(req *request) error {
+
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	base, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return err
+	}
+
+	start := int(base) + args.Offset
+	count := args.Count
+
+	// Clip to the 64KB address space
+	if start < 0 {
+		count += start
+		start = 0
+	}
+	if start+count > 0x10000 {
+		count = 0x10000 - start
+	}
+	if count < 0 {
+		count = 0
+	}
+
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = CPU_6502.ReadMemory(uint16(start + i))
+	}
+
+	body := map[string]interface{}{
+		"address": addressReference(uint16(start)),
+		"data":    base64.StdEncoding.EncodeToString(data),
+	}
+	if unreadable := args.Count - count; unreadable > 0 {
+		body["unreadableBytes"] = unreadable
+	}
+
+	s.respond(req, body)
+
+	return nil
+}
+
+func (s *session) writeMemory(req *request) error {
+
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Data            string `json:"data"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	base, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return err
+	}
+
+	start := int(base) + args.Offset
+	if start < 0 || start+len(data) > 0x10000 {
+		return fmt.Errorf("write outside of the address space")
+	}
+
+	for i, b := range data {
+		CPU_6502.WriteMemory(uint16(start+i), b)
+	}
+
+	s.respond(req, map[string]interface{}{"offset": args.Offset, "bytesWritten": len(data)})
+
+	return nil
+}
+
+func (s *session) disassemble(req *request) error {
+
+	var args struct {
+		MemoryReference   string `json:"memoryReference"`
+		Offset            int    `json:"offset"`
+		InstructionOffset int    `json:"instructionOffset"`
+		InstructionCount  int    `json:"instructionCount"`
+	}
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+
+	base, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return err
+	}
+
+	opts := disassembler.Options{Undocumented: s.undocumented}
+	if s.info != nil {
+		opts.Symbols = s.info.Symbol
+	}
+
+	addr := int(base) + args.Offset
+
+	// Instructions before the reference: decode from a few bytes earlier so the
+	// listing synchronizes with the instruction stream before reaching it
+	var lines []disassembler.Line
+	if args.InstructionOffset < 0 {
+		before := -args.InstructionOffset
+		start := addr - before*3
+		if start < 0 {
+			start = 0
+		}
+		var prev []disassembler.Line
+		for a := start; a < addr; {
+			l := disassembler.Decode(CPU_6502.ReadMemory, uint16(a), opts)
+			prev = append(prev, l)
+			a += int(l.Size())
+		}
+		if len(prev) > before {
+			prev = prev[len(prev)-before:]
+		}
+		lines = prev
+	} else {
+		for i := 0; i < args.InstructionOffset && addr < 0x10000; i++ {
+			addr += int(disassembler.Decode(CPU_6502.ReadMemory, uint16(addr), opts).Size())
+		}
+	}
+
+	for len(lines) < args.InstructionCount && addr < 0x10000 {
+		l := disassembler.Decode(CPU_6502.ReadMemory, uint16(addr), opts)
+		lines = append(lines, l)
+		addr += int(l.Size())
+	}
+
+	result := make([]map[string]interface{}, 0, args.InstructionCount)
+
+	for _, l := range lines {
+
+		var hex strings.Builder
+		for i, b := range l.Bytes {
+			if i > 0 {
+				hex.WriteByte(' ')
+			}
+			fmt.Fprintf(&hex, "%02X", b)
+		}
+
+		ins := map[string]interface{}{
+			"address":          addressReference(l.Address),
+			"instructionBytes": hex.String(),
+			"instruction":      l.Text,
+		}
+
+		if s.info != nil {
+			if name, ok := s.info.Symbol(l.Address); ok {
+				ins["symbol"] = name
+			}
+			if src, ok := s.info.Lookup(l.Address); ok {
+				ins["location"] = map[string]interface{}{"name": filepath.Base(src.File), "path": src.File}
+				ins["line"] = src.Line + s.line_base - 1
+			}
+		}
+
+		result = append(result, ins)
+	}
+
+	// Pad past the end of the address space
+	for len(result) < args.InstructionCount {
+		result = append(result, map[string]interface{}{"address": "0x10000", "instruction": "", "presentationHint": "invalid"})
+	}
+
+	s.respond(req, map[string]interface{}{"instructions": result})
+
+	return nil
+}
+
+// -------------------------------- Helpers --------------------------------- //
+
+func addressReference(addr uint16) string {
+	return fmt.Sprintf("0x%04X", addr)
+}
+
+// Memory and instruction references: 0xC000, $C000 or decimal
+func parseReference(ref string) (uint16, error) {
+
+	ref = strings.TrimSpace(ref)
+
+	var (
+		v   uint64
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(ref, "0x") || strings.HasPrefix(ref, "0X"):
+		v, err = strconv.ParseUint(ref[2:], 16, 16)
+	case strings.HasPrefix(ref, "$"):
+		v, err = strconv.ParseUint(ref[1:], 16, 16)
+	default:
+		v, err = strconv.ParseUint(ref, 10, 16)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", ref)
+	}
+
+	return uint16(v), nil
+}
+
+// Launch addresses may be JSON numbers or strings, returns false when absent
+func addressArgument(v interface{}) (uint16, bool, error) {
+
+	switch a := v.(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		if a < 0 || a > 0xFFFF {
+			return 0, false, fmt.Errorf("address %v out of range", a)
+		}
+		return uint16(a), true, nil
+	case string:
+		addr, err := parseReference(a)
+		return addr, err == nil, err
+	}
+
+	return 0, false, fmt.Errorf("invalid address %v", v)
+}
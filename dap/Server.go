@@ -0,0 +1,202 @@
+// Package dap is a Debug Adapter Protocol server for editor-integrated
+// debugging of 6502 programs: launch a ROM or an assembly source, source and
+// instruction breakpoints (mapped through debuginfo), step in / over / out,
+// registers and flags as variables, memory, disassembly and the call stack.
+//
+// Launch arguments:
+//
+//	program      binary file loaded at loadAddress (default 0, like ReadROM)
+//	loadAddress  load address of program ("$C000", "0xC000" or a number)
+//	source       assembly file, assembled on launch (provides the debug information)
+//	debugInfo    debug information file saved with debuginfo.Info.Save
+//	pc           initial program counter (default: reset vector)
+//	stopOnEntry  stop before the first instruction
+//	undocumented accept and show the NMOS undocumented opcodes
+//	history      instructions recorded for step back / reverse continue (0 = off)
+package dap
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+	"github.com/cassianoperin/6502_GO_Core/debuginfo"
+)
+
+// Cycles executed between checks for new requests while running
+const runSlice = 100000
+
+// Serve runs a debug session over a stream (e.g. stdin / stdout)
+// log receives the messages exchanged (may be nil)
+func Serve(r io.Reader, w io.Writer, log io.Writer) error {
+
+	// The core debug messages are printed on stdout, which may be the protocol stream
+	CPU_6502.Debug = false
+
+	s := &session{
+		t:          newTransport(r, w),
+		log:        log,
+		line_base:  1,
+		source_bps: map[string][]*debugger.Breakpoint{},
+		requests:   make(chan *request),
+		done:       make(chan struct{}),
+	}
+
+	return s.run()
+}
+
+// ListenAndServe accepts editor connections on addr, one session at a time
+func ListenAndServe(addr string, log io.Writer) error {
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		err = Serve(c, c, log)
+		c.Close()
+
+		if err != nil && log != nil {
+			fmt.Fprintln(log, "dap:", err)
+		}
+	}
+}
+
+// --------------------------------- Session -------------------------------- //
+
+type session struct {
+	t   *transport
+	log io.Writer
+
+	dbg   *debugger.Debugger
+	stack *debugger.CallStack
+	info  *debuginfo.Info
+
+	// Client options
+	line_base    int // 1 when lines start at 1
+	undocumented bool
+
+	// Breakpoints set by the client
+	source_bps      map[string][]*debugger.Breakpoint
+	instruction_bps []*debugger.Breakpoint
+	exception_bps   []*debugger.Breakpoint
+
+	// Internal breakpoints of step over / step out
+	step_bps []*debugger.Breakpoint
+	step_out bool
+
+	stop_on_entry bool
+	running       bool
+	quit          bool
+
+	requests chan *request
+	done     chan struct{}
+	read_err error
+}
+
+func (s *session) run() error {
+
+	go s.readLoop()
+
+	defer close(s.done)
+	defer s.close()
+
+	for !s.quit {
+
+		if s.running {
+			select {
+			case req, ok := <-s.requests:
+				if !ok {
+					return s.readError()
+				}
+				s.dispatch(req)
+			default:
+				s.runSlice()
+			}
+			continue
+		}
+
+		req, ok := <-s.requests
+		if !ok {
+			return s.readError()
+		}
+		s.dispatch(req)
+	}
+
+	return nil
+}
+
+func (s *session) readLoop() {
+
+	defer close(s.requests)
+
+	for {
+		req, err := s.t.read()
+		if err != nil {
+			s.read_err = err
+			return
+		}
+
+		select {
+		case s.requests <- req:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *session) readError() error {
+	if s.read_err == io.EOF {
+		return nil
+	}
+	return s.read_err
+}
+
+// Detach from the CPU at the end of the session
+func (s *session) close() {
+	if s.dbg != nil {
+		s.dbg.Close()
+	}
+	if s.stack != nil {
+		s.stack.Close()
+	}
+}
+
+func (s *session) logf(format string, args ...interface{}) {
+	if s.log != nil {
+		fmt.Fprintf(s.log, format+"\n", args...)
+	}
+}
+
+func (s *session) respond(req *request, body interface{}) {
+	s.send(&response{RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *session) fail(req *request, err error) {
+	s.send(&response{RequestSeq: req.Seq, Success: false, Command: req.Command, Message: err.Error()})
+}
+
+func (s *session) event(name string, body interface{}) {
+	s.send(&event{Event: name, Body: body})
+}
+
+func (s *session) send(msg interface{}) {
+	if err := s.t.write(msg); err != nil {
+		s.logf("write: %v", err)
+		s.quit = true
+	}
+}
+
+// Print a message in the debug console
+func (s *session) output(format string, args ...interface{}) {
+	s.event("output", map[string]interface{}{"category": "console", "output": fmt.Sprintf(format, args...) + "\n"})
+}
@@ -0,0 +1,224 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const test_source = `        .org $0200
+start:  ldx #0
+loop:   inx
+        stx $10
+        cpx #3
+        bne loop
+        jsr sub
+done:   jmp done
+sub:    lda #$42
+        rts
+`
+
+// Client side of a session, the messages are decoded as generic JSON
+type test_client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+}
+
+type test_message map[string]interface{}
+
+func (m test_message) body() test_message {
+	b, _ := m["body"].(map[string]interface{})
+	return b
+}
+
+func (m test_message) list(name string) []test_message {
+	var list []test_message
+	items, _ := m[name].([]interface{})
+	for _, item := range items {
+		list = append(list, item.(map[string]interface{}))
+	}
+	return list
+}
+
+// Starts a session on a source assembled from test_source
+func testClient(t *testing.T) (*test_client, string) {
+
+	source := filepath.Join(t.TempDir(), "test.s")
+	if err := os.WriteFile(source, []byte(test_source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, cw := io.Pipe()
+	sr, sw := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(cr, sw, nil)
+		sw.Close()
+	}()
+
+	t.Cleanup(func() {
+		cw.Close()
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+
+	return &test_client{t: t, w: cw, r: bufio.NewReader(sr)}, source
+}
+
+func (c *test_client) read() test_message {
+
+	c.t.Helper()
+
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatal(err)
+	}
+
+	var m test_message
+	if err := json.Unmarshal(body, &m); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return m
+}
+
+// Sends a request and returns its successful response
+func (c *test_client) request(command string, args interface{}) test_message {
+
+	c.t.Helper()
+
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	for {
+		m := c.read()
+		if m["type"] != "response" {
+			continue
+		}
+		if m["command"] != command || m["request_seq"] != float64(c.seq) {
+			c.t.Fatalf("response %v to %s", m, command)
+		}
+		if m["success"] != true {
+			c.t.Fatalf("%s failed: %v", command, m["message"])
+		}
+		return m.body()
+	}
+}
+
+// Reads until the event, skipping the output events
+func (c *test_client) event(name string) test_message {
+
+	c.t.Helper()
+
+	for {
+		m := c.read()
+		if m["type"] == "event" && m["event"] == name {
+			return m.body()
+		}
+		if m["type"] != "event" || m["event"] != "output" {
+			c.t.Fatalf("%v while waiting for the %s event", m, name)
+		}
+	}
+}
+
+// Stopped event and the innermost frame
+func (c *test_client) stopped(reason string) test_message {
+
+	c.t.Helper()
+
+	if e := c.event("stopped"); e["reason"] != reason {
+		c.t.Fatalf("stopped %v, want reason %q", e, reason)
+	}
+
+	return c.request("stackTrace", map[string]interface{}{"threadId": 1}).list("stackFrames")[0]
+}
+
+func (c *test_client) frameAt(frame test_message, name string, line int) {
+	c.t.Helper()
+	if frame["name"] != name || frame["line"] != float64(line) {
+		c.t.Errorf("frame %s line %v, want %s line %d", frame["name"], frame["line"], name, line)
+	}
+}
+
+// Launch on an assembly source, source breakpoints with hit count and the inspection requests
+func TestSession(t *testing.T) {
+
+	c, source := testClient(t)
+
+	if caps := c.request("initialize", map[string]interface{}{"adapterID": "6502"}); caps["supportsStepBack"] != true {
+		t.Errorf("capabilities %v", caps)
+	}
+
+	c.request("launch", map[string]interface{}{"source": source, "stopOnEntry": true, "history": 100})
+	c.event("initialized")
+
+	bps := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": source},
+		"breakpoints": []map[string]interface{}{{"line": 4, "hitCondition": "2"}, {"line": 20}},
+	}).list("breakpoints")
+	if len(bps) != 2 || bps[0]["verified"] != true || bps[0]["instructionReference"] != "0x0203" || bps[1]["verified"] != false {
+		t.Errorf("breakpoints %v", bps)
+	}
+
+	c.request("configurationDone", nil)
+	c.frameAt(c.stopped("entry"), "start", 2)
+
+	// Second hit of line 4
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	c.frameAt(c.stopped("breakpoint"), "loop+1", 4)
+
+	vars := c.request("variables", map[string]interface{}{"variablesReference": refRegisters}).list("variables")
+	if vars[2]["name"] != "X" || vars[2]["value"] != "$02 (2)" {
+		t.Errorf("variables %v", vars)
+	}
+
+	// The STX is undone
+	c.request("stepIn", map[string]interface{}{"threadId": 1})
+	c.frameAt(c.stopped("step"), "loop+3", 5)
+	c.request("stepBack", map[string]interface{}{"threadId": 1})
+	c.frameAt(c.stopped("step"), "loop+1", 4)
+	if mem := c.request("readMemory", map[string]interface{}{"memoryReference": "$0010", "count": 1}); mem["data"] != "AQ==" {
+		t.Errorf("memory %v after the step back, want $01", mem)
+	}
+
+	// In the subroutine, with the caller frame
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": source},
+		"breakpoints": []map[string]interface{}{{"line": 9}},
+	})
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	c.frameAt(c.stopped("breakpoint"), "sub", 9)
+
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1}).list("stackFrames")
+	if len(frames) != 2 {
+		t.Fatalf("frames %v", frames)
+	}
+	c.frameAt(frames[1], "loop+7", 7)
+
+	c.request("stepOut", map[string]interface{}{"threadId": 1})
+	c.frameAt(c.stopped("step"), "done", 8)
+
+	if v := c.request("evaluate", map[string]interface{}{"expression": "A+1"}); v["result"] != "$43 (67)" {
+		t.Errorf("evaluate %v", v)
+	}
+
+	c.request("disconnect", nil)
+	c.event("terminated")
+}
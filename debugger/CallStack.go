@@ -0,0 +1,129 @@
+package debugger
+
+import (
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// ------------------------------- Call Stack ------------------------------- //
+
+// Frame is a subroutine call (JSR) or an interrupt handler being executed
+type Frame struct {
+	Entry     uint16 // Address of the subroutine or handler
+	Call      uint16 // Address of the JSR (interrupts: interrupted instruction)
+	Return    uint16 // Address where the execution continues after the return
+	SP        byte   // Stack Pointer before the call
+	Interrupt bool
+	Source    CPU_6502.Interrupt
+}
+
+// CallStack follows JSR / RTS and interrupt entries to rebuild the 6502 call stack
+// Frames are removed when the Stack Pointer goes back above their SP, so
+// RTS, RTI and code discarding the return address (PLA / PLA, TXS) are handled
+type CallStack struct {
+	frames []Frame
+	hooks  *CPU_6502.Hooks
+
+	// JSR seen by the Instruction hook, confirmed on the next instruction
+	jsr_pending bool
+	jsr_pc      uint16
+	jsr_sp      byte
+}
+
+// NewCallStack creates a call stack tracker attached to the CPU
+func NewCallStack() *CallStack {
+
+	c := &CallStack{}
+
+	c.hooks = &CPU_6502.Hooks{
+		Instruction: c.onInstruction,
+		Interrupt:   c.onInterrupt,
+	}
+
+	CPU_6502.AttachHooks(c.hooks)
+
+	return c
+}
+
+// Close detaches the tracker from the CPU
+func (c *CallStack) Close() {
+	CPU_6502.DetachHooks(c.hooks)
+}
+
+// Reset forgets all the frames (after a CPU reset or a program load)
+func (c *CallStack) Reset() {
+	c.frames = c.frames[:0]
+	c.jsr_pending = false
+}
+
+// Frames returns the active calls, innermost first
+func (c *CallStack) Frames() []Frame {
+
+	// The last instruction may not have been seen by the hook yet
+	c.update(CPU_6502.PC)
+
+	frames := make([]Frame, len(c.frames))
+
+	for i := range c.frames {
+		frames[i] = c.frames[len(c.frames)-1-i]
+	}
+
+	return frames
+}
+
+func (c *CallStack) onInstruction(pc uint16, opcode byte) bool {
+
+	c.update(pc)
+
+	if opcode == 0x20 { // JSR
+		c.jsr_pending = true
+		c.jsr_pc = pc
+		c.jsr_sp = CPU_6502.SP
+	}
+
+	return false
+}
+
+// Push the frame of a completed JSR and remove the frames already returned
+func (c *CallStack) update(pc uint16) {
+
+	// The previous instruction was a JSR: a frame if it pushed the return address
+	// The hook may run twice for the same instruction when a debugger holds the CPU
+	if c.jsr_pending && pc != c.jsr_pc {
+		c.jsr_pending = false
+		if CPU_6502.SP == c.jsr_sp-2 {
+			c.frames = append(c.frames, Frame{Entry: pc, Call: c.jsr_pc, Return: c.jsr_pc + 3, SP: c.jsr_sp})
+		}
+	}
+
+	// Returned (or the return address was dropped)
+	for len(c.frames) > 0 && CPU_6502.SP >= c.frames[len(c.frames)-1].SP {
+		c.frames = c.frames[:len(c.frames)-1]
+	}
+}
+
+func (c *CallStack) onInterrupt(source CPU_6502.Interrupt, vector uint16, return_addr uint16) {
+
+	// Interrupt taken right after a JSR: the subroutine was entered
+	if c.jsr_pending {
+		c.jsr_pending = false
+		if CPU_6502.SP+3 == c.jsr_sp-2 {
+			c.frames = append(c.frames, Frame{Entry: return_addr, Call: c.jsr_pc, Return: c.jsr_pc + 3, SP: c.jsr_sp})
+		}
+	}
+
+	// BRK is a 2 bytes instruction, IRQ and NMI return to the interrupted one
+	call := return_addr
+	if source == CPU_6502.INT_BRK {
+		call -= 2
+	}
+
+	// PC and P were already pushed
+	c.frames = append(c.frames, Frame{
+		Entry:     vector,
+		Call:      call,
+		Return:    return_addr,
+		SP:        CPU_6502.SP + 3,
+		Interrupt: true,
+		Source:    source,
+	})
+}
@@ -0,0 +1,380 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Condition is a compiled expression over registers, flags and memory
+//
+//	A==$40 && [$D012]>$80
+//
+// Identifiers: A X Y SP PC P (registers), C Z I D B V N (flags),
+// VALUE and ADDR (data and address of the access that hit a watchpoint)
+// in uppercase, then symbols, then the register names in other cases:
+// labels like "a" or "c" take precedence over the lowercase registers
+// Memory: [expr] is the byte at the address
+// Numbers: $FF, 0xFF, %1010, 255
+// Operators (C precedence): || && | ^ & == != < <= > >= << >> + - * / ! ~ -
+type Condition struct {
+	text string
+	root node
+}
+
+// Values available to the condition beyond the CPU state
+type evalContext struct {
+	value   byte
+	address uint16
+}
+
+type node func(ctx *evalContext) int
+
+// ParseCondition compiles a condition expression
+// resolve is used for identifiers that are not registers or flags (may be nil)
+func ParseCondition(text string, resolve func(name string) (uint16, bool)) (*Condition, error) {
+
+	tokens, err := condTokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &condParser{tokens: tokens, resolve: resolve}
+
+	root, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(tokens) {
+		return nil, fmt.Errorf("unexpected %q in condition", p.tokens[p.pos])
+	}
+
+	return &Condition{text: text, root: root}, nil
+}
+
+// Eval returns the value of the expression with the current CPU state
+func (c *Condition) Eval() int {
+	return c.root(&evalContext{})
+}
+
+func (c *Condition) eval(ctx *evalContext) bool {
+	return c.root(ctx) != 0
+}
+
+func (c *Condition) String() string {
+	return c.text
+}
+
+// --------------------------------- Tokens --------------------------------- //
+
+var condOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<<", ">>", "|", "^", "&", "<", ">", "+", "-", "*", "/", "!", "~", "(", ")", "[", "]"}
+
+func condTokenize(text string) ([]string, error) {
+
+	var tokens []string
+
+	i := 0
+	for i < len(text) {
+		c := text[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '$' || c == '%' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(text) && isWordChar(text[i]) {
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+
+		case isWordChar(c):
+			start := i
+			for i < len(text) && isWordChar(text[i]) {
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+
+		default:
+			matched := false
+			for _, op := range condOperators {
+				if strings.HasPrefix(text[i:], op) {
+					tokens = append(tokens, op)
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q in condition", c)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// --------------------------------- Parser --------------------------------- //
+
+type condParser struct {
+	tokens  []string
+	pos     int
+	resolve func(name string) (uint16, bool)
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+var condPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *condParser) parseBinary(level int) (node, error) {
+
+	if level == len(condPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		found := false
+		for _, candidate := range condPrecedence[level] {
+			if op == candidate {
+				found = true
+			}
+		}
+		if !found {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryNode(op, left, right)
+	}
+}
+
+func binaryNode(op string, l, r node) node {
+	switch op {
+	case "||":
+		return func(c *evalContext) int { return boolInt(l(c) != 0 || r(c) != 0) }
+	case "&&":
+		return func(c *evalContext) int { return boolInt(l(c) != 0 && r(c) != 0) }
+	case "|":
+		return func(c *evalContext) int { return l(c) | r(c) }
+	case "^":
+		return func(c *evalContext) int { return l(c) ^ r(c) }
+	case "&":
+		return func(c *evalContext) int { return l(c) & r(c) }
+	case "==":
+		return func(c *evalContext) int { return boolInt(l(c) == r(c)) }
+	case "!=":
+		return func(c *evalContext) int { return boolInt(l(c) != r(c)) }
+	case "<":
+		return func(c *evalContext) int { return boolInt(l(c) < r(c)) }
+	case "<=":
+		return func(c *evalContext) int { return boolInt(l(c) <= r(c)) }
+	case ">":
+		return func(c *evalContext) int { return boolInt(l(c) > r(c)) }
+	case ">=":
+		return func(c *evalContext) int { return boolInt(l(c) >= r(c)) }
+	case "<<":
+		return func(c *evalContext) int { return l(c) << uint(r(c)&63) }
+	case ">>":
+		return func(c *evalContext) int { return l(c) >> uint(r(c)&63) }
+	case "+":
+		return func(c *evalContext) int { return l(c) + r(c) }
+	case "-":
+		return func(c *evalContext) int { return l(c) - r(c) }
+	case "*":
+		return func(c *evalContext) int { return l(c) * r(c) }
+	default: // "/"
+		return func(c *evalContext) int {
+			if d := r(c); d != 0 {
+				return l(c) / d
+			}
+			return 0
+		}
+	}
+}
+
+func (p *condParser) parseUnary() (node, error) {
+
+	switch op := p.peek(); op {
+	case "!", "~", "-":
+		p.pos++
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "!":
+			return func(c *evalContext) int { return boolInt(n(c) == 0) }, nil
+		case "~":
+			return func(c *evalContext) int { return ^n(c) }, nil
+		default:
+			return func(c *evalContext) int { return -n(c) }, nil
+		}
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (node, error) {
+
+	t := p.peek()
+	if t == "" {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+
+	switch {
+
+	case t == "(" || t == "[":
+		inner, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		closing := ")"
+		if t == "[" {
+			closing = "]"
+		}
+		if p.peek() != closing {
+			return nil, fmt.Errorf("missing %q in condition", closing)
+		}
+		p.pos++
+
+		if t == "[" { // Memory contents
+			return func(c *evalContext) int { return int(CPU_6502.ReadMemory(uint16(inner(c)))) }, nil
+		}
+		return inner, nil
+
+	case t[0] == '$' || t[0] == '%' || (t[0] >= '0' && t[0] <= '9'):
+		v, err := ParseNumber(t)
+		if err != nil {
+			return nil, err
+		}
+		return func(*evalContext) int { return v }, nil
+	}
+
+	if n := registerNode(t); n != nil {
+		return n, nil
+	}
+
+	if p.resolve != nil {
+		if v, ok := p.resolve(t); ok {
+			return func(*evalContext) int { return int(v) }, nil
+		}
+	}
+
+	if n := registerNode(strings.ToUpper(t)); n != nil {
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unknown identifier %q in condition", t)
+}
+
+// Registers and flags (uppercase names) are read when the condition is evaluated
+func registerNode(name string) node {
+
+	flag := func(bit int) node {
+		return func(*evalContext) int { return int(CPU_6502.P[bit]) }
+	}
+
+	switch name {
+	case "A":
+		return func(*evalContext) int { return int(CPU_6502.A) }
+	case "X":
+		return func(*evalContext) int { return int(CPU_6502.X) }
+	case "Y":
+		return func(*evalContext) int { return int(CPU_6502.Y) }
+	case "SP":
+		return func(*evalContext) int { return int(CPU_6502.SP) }
+	case "PC":
+		return func(*evalContext) int { return int(CPU_6502.PC) }
+	case "P":
+		return func(*evalContext) int { return int(CPU_6502.PackP()) }
+	case "C":
+		return flag(0)
+	case "Z":
+		return flag(1)
+	case "I":
+		return flag(2)
+	case "D":
+		return flag(3)
+	case "B":
+		return flag(4)
+	case "V":
+		return flag(6)
+	case "N":
+		return flag(7)
+	case "VALUE":
+		return func(c *evalContext) int { return int(c.value) }
+	case "ADDR":
+		return func(c *evalContext) int { return int(c.address) }
+	}
+
+	return nil
+}
+
+// ParseNumber accepts $FF, 0xFF, %1010 and decimal values
+func ParseNumber(text string) (int, error) {
+
+	var (
+		v   int64
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(text, "$"):
+		v, err = strconv.ParseInt(text[1:], 16, 64)
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		v, err = strconv.ParseInt(text[2:], 16, 64)
+	case strings.HasPrefix(text, "%"):
+		v, err = strconv.ParseInt(text[1:], 2, 64)
+	default:
+		v, err = strconv.ParseInt(text, 10, 64)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+
+	return int(v), nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package debugger
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+func TestConditionRegistersAndSymbols(t *testing.T) {
+
+	symbols := map[string]uint16{"a": 0x1234, "c": 0x10}
+	resolve := func(name string) (uint16, bool) {
+		v, ok := symbols[name]
+		return v, ok
+	}
+
+	CPU_6502.A, CPU_6502.X = 0x40, 0x05
+	CPU_6502.P[0] = 1
+
+	for _, test := range []struct {
+		text string
+		want int
+	}{
+		{"A", 0x40},   // Register
+		{"a", 0x1234}, // Label before the lowercase register
+		{"C", 1},      // Flag
+		{"c+1", 0x11}, // Label
+		{"x", 0x05},   // No label, lowercase register
+		{"A==$40 && X<6", 1},
+	} {
+		c, err := ParseCondition(test.text, resolve)
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+			continue
+		}
+		if got := c.Eval(); got != test.want {
+			t.Errorf("%s = $%X, want $%X", test.text, got, test.want)
+		}
+	}
+
+	if _, err := ParseCondition("b2", resolve); err == nil {
+		t.Errorf("unknown identifier accepted")
+	}
+}
@@ -0,0 +1,557 @@
+// Package debugger adds execution control on top of the CPU core:
+// breakpoints, watchpoints on address ranges, opcode and interrupt
+// breakpoints, conditions, hit counts and temporary breakpoints.
+//
+// The debugger works both when driven by its own Step/Continue methods and
+// when the host keeps calling CPU_Interpreter: on a hit the CPU is held
+// before the instruction and CPU_6502.Pause is set.
+package debugger
+
+import (
+	"fmt"
+	"sort"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// ------------------------------ Breakpoints ------------------------------- //
+
+// Kind of breakpoint
+type Kind byte
+
+const (
+	Execute   Kind = iota // PC reaches an address
+	Read                  // Data read in the address range
+	Write                 // Data write in the address range
+	Access                // Read or write in the address range
+	Opcode                // An opcode is about to be executed
+	Interrupt             // Entry of BRK / IRQ / NMI handlers
+)
+
+var kindNames = [...]string{"break", "watch read", "watch write", "watch access", "opcode", "interrupt"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// Breakpoint is any kind of stop condition
+type Breakpoint struct {
+	ID          int
+	Kind        Kind
+	Start, End  uint16 // Address range (inclusive), Start only for Execute
+	Opcode      byte   // Opcode breakpoints
+	Condition   *Condition
+	Enabled     bool
+	Temporary   bool   // Deleted after the first stop
+	IgnoreCount uint64 // Number of hits ignored before stopping
+	Hits        uint64 // Number of times the breakpoint matched (with condition true)
+}
+
+func (b *Breakpoint) String() string {
+
+	var where string
+
+	switch b.Kind {
+	case Execute:
+		where = fmt.Sprintf("$%04X", b.Start)
+	case Read, Write, Access:
+		if b.Start == b.End {
+			where = fmt.Sprintf("$%04X", b.Start)
+		} else {
+			where = fmt.Sprintf("$%04X-$%04X", b.Start, b.End)
+		}
+	case Opcode:
+		where = fmt.Sprintf("opcode $%02X", b.Opcode)
+	case Interrupt:
+		where = "BRK/IRQ/NMI"
+	}
+
+	s := fmt.Sprintf("#%d %s %s hits:%d", b.ID, b.Kind, where, b.Hits)
+
+	if b.Condition != nil {
+		s += " if " + b.Condition.String()
+	}
+	if b.IgnoreCount > 0 {
+		s += fmt.Sprintf(" ignore:%d", b.IgnoreCount)
+	}
+	if b.Temporary {
+		s += " temporary"
+	}
+	if !b.Enabled {
+		s += " disabled"
+	}
+
+	return s
+}
+
+func (b *Breakpoint) contains(addr uint16) bool {
+	return addr >= b.Start && addr <= b.End
+}
+
+// --------------------------------- Stops ---------------------------------- //
+
+// Reason why the execution stopped
+type Reason byte
+
+const (
+	ReasonStep       Reason = iota // Single step finished
+	ReasonBreakpoint               // Any Breakpoint hit
+	ReasonLimit                    // Cycle limit reached in Continue
+	ReasonHistoryEnd               // No more recorded history to step back
+	ReasonViolation                // Memory map violation (CPU_6502.VIOLATION_EVENT)
+	ReasonHalted                   // Opcode not implemented (CPU_6502.Halted)
+)
+
+// Stop describes where and why the CPU stopped
+type Stop struct {
+	Reason     Reason
+	Breakpoint *Breakpoint // Nil for steps and limits
+	PC         uint16      // Next instruction to be executed
+
+	// Watchpoints: accessed address and value, Interrupts: vector and source
+	Address uint16
+	Value   byte
+	Write   bool
+	Source  CPU_6502.Interrupt
+
+	Violation CPU_6502.MemoryViolation // Memory map violations
+}
+
+func (s *Stop) String() string {
+
+	switch s.Reason {
+	case ReasonStep:
+		return fmt.Sprintf("step at $%04X", s.PC)
+	case ReasonLimit:
+		return fmt.Sprintf("cycle limit reached at $%04X", s.PC)
+	case ReasonHistoryEnd:
+		return fmt.Sprintf("start of the history at $%04X", s.PC)
+	case ReasonViolation:
+		return fmt.Sprintf("memory violation: %s, stopped at $%04X", s.Violation, s.PC)
+	case ReasonHalted:
+		return fmt.Sprintf("CPU halted on opcode $%02X at $%04X", CPU_6502.ReadMemory(s.PC), s.PC)
+	}
+
+	b := s.Breakpoint
+
+	switch b.Kind {
+	case Read, Write, Access:
+		access := "read"
+		if s.Write {
+			access = "write"
+		}
+		return fmt.Sprintf("watchpoint #%d: %s $%02X at $%04X, stopped at $%04X", b.ID, access, s.Value, s.Address, s.PC)
+	case Interrupt:
+		return fmt.Sprintf("interrupt breakpoint #%d: %s vector $%04X", b.ID, s.Source, s.Address)
+	case Opcode:
+		return fmt.Sprintf("opcode breakpoint #%d: $%02X at $%04X", b.ID, b.Opcode, s.PC)
+	}
+
+	return fmt.Sprintf("breakpoint #%d at $%04X", b.ID, s.PC)
+}
+
+// -------------------------------- Debugger -------------------------------- //
+
+// Debugger holds the breakpoints and the execution state
+type Debugger struct {
+	breakpoints []*Breakpoint
+	next_id     int
+
+	hooks    *CPU_6502.Hooks
+	skip_pc  int   // Address allowed to execute once after a stop (-1 for none)
+	limit_pc int   // Address where Continue reached the cycle limit (-1 for none)
+	pending  *Stop // Watchpoint or interrupt hit, stop before the next instruction
+	stopped  *Stop // Stop raised by the Instruction hook
+	history  *History
+
+	// Optional symbol resolver for the conditions
+	Symbols func(name string) (uint16, bool)
+
+	// Called on every stop (also when the host drives CPU_Interpreter)
+	OnStop func(s *Stop)
+}
+
+// New creates a debugger attached to the CPU
+func New() *Debugger {
+
+	d := &Debugger{skip_pc: -1, limit_pc: -1, next_id: 1}
+
+	d.hooks = &CPU_6502.Hooks{
+		Instruction: d.onInstruction,
+		Read:        d.onRead,
+		Write:       d.onWrite,
+		Interrupt:   d.onInterrupt,
+		Violation:   d.onViolation,
+	}
+
+	CPU_6502.AttachHooks(d.hooks)
+
+	return d
+}
+
+// Close detaches the debugger from the CPU
+func (d *Debugger) Close() {
+	CPU_6502.DetachHooks(d.hooks)
+	d.EnableHistory(0)
+}
+
+// ---------------------------- Breakpoint API ------------------------------ //
+
+func (d *Debugger) add(b *Breakpoint) *Breakpoint {
+	b.ID = d.next_id
+	b.Enabled = true
+	d.next_id++
+	d.breakpoints = append(d.breakpoints, b)
+	return b
+}
+
+// AddBreakpoint stops before executing the instruction at addr
+func (d *Debugger) AddBreakpoint(addr uint16) *Breakpoint {
+	return d.add(&Breakpoint{Kind: Execute, Start: addr, End: addr})
+}
+
+// AddTemporaryBreakpoint stops once at addr, then deletes itself
+func (d *Debugger) AddTemporaryBreakpoint(addr uint16) *Breakpoint {
+	b := d.AddBreakpoint(addr)
+	b.Temporary = true
+	return b
+}
+
+// AddWatchpoint stops after an instruction accesses the address range
+func (d *Debugger) AddWatchpoint(kind Kind, start, end uint16) (*Breakpoint, error) {
+
+	if kind != Read && kind != Write && kind != Access {
+		return nil, fmt.Errorf("invalid watchpoint kind %q", kind)
+	}
+	if end < start {
+		return nil, fmt.Errorf("invalid range $%04X-$%04X", start, end)
+	}
+
+	return d.add(&Breakpoint{Kind: kind, Start: start, End: end}), nil
+}
+
+// AddOpcodeBreakpoint stops before executing any instruction with the opcode
+func (d *Debugger) AddOpcodeBreakpoint(opcode byte) *Breakpoint {
+	return d.add(&Breakpoint{Kind: Opcode, Opcode: opcode})
+}
+
+// AddInterruptBreakpoint stops at the first instruction of interrupt handlers
+func (d *Debugger) AddInterruptBreakpoint() *Breakpoint {
+	return d.add(&Breakpoint{Kind: Interrupt})
+}
+
+// SetCondition compiles and sets the condition of a breakpoint ("" removes it)
+func (d *Debugger) SetCondition(b *Breakpoint, expr string) error {
+
+	if expr == "" {
+		b.Condition = nil
+		return nil
+	}
+
+	c, err := ParseCondition(expr, d.Symbols)
+	if err != nil {
+		return err
+	}
+
+	b.Condition = c
+
+	return nil
+}
+
+// Breakpoint returns a breakpoint by ID
+func (d *Debugger) Breakpoint(id int) (*Breakpoint, error) {
+	for _, b := range d.breakpoints {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("no breakpoint #%d", id)
+}
+
+// Breakpoints returns all breakpoints sorted by ID
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	list := append([]*Breakpoint(nil), d.breakpoints...)
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Delete removes a breakpoint
+func (d *Debugger) Delete(id int) error {
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no breakpoint #%d", id)
+}
+
+// DeleteAll removes every breakpoint
+func (d *Debugger) DeleteAll() {
+	d.breakpoints = nil
+}
+
+// ---------------------------- Execution Control --------------------------- //
+
+// Step executes one instruction, ignoring a breakpoint at the current PC
+// Returns the watchpoint or interrupt stop raised by the instruction, if any
+func (d *Debugger) Step() *Stop {
+
+	d.skip_pc = int(CPU_6502.PC)
+	d.limit_pc = -1
+	d.stopped = nil
+
+	CPU_6502.Step()
+
+	if s := d.takeStop(); s != nil {
+		return s
+	}
+
+	if s := d.pending; s != nil {
+		d.pending = nil
+		d.skip_pc = -1
+		s.PC = CPU_6502.PC
+		d.notify(s)
+		return s
+	}
+
+	if CPU_6502.Halted {
+		return &Stop{Reason: ReasonHalted, PC: CPU_6502.PC}
+	}
+
+	return &Stop{Reason: ReasonStep, PC: CPU_6502.PC}
+}
+
+// Continue runs until a breakpoint is hit or maxCycles are executed (0 = no limit)
+func (d *Debugger) Continue(maxCycles uint64) *Stop {
+
+	start := CPU_6502.Cycle
+
+	// A breakpoint at PC was not checked yet when the previous call hit the limit
+	if d.limit_pc != int(CPU_6502.PC) {
+		d.skip_pc = int(CPU_6502.PC)
+	}
+	d.limit_pc = -1
+	d.stopped = nil
+
+	for maxCycles == 0 || CPU_6502.Cycle-start < maxCycles {
+
+		CPU_6502.Step()
+
+		if s := d.takeStop(); s != nil {
+			return s
+		}
+
+		if CPU_6502.Halted {
+			return &Stop{Reason: ReasonHalted, PC: CPU_6502.PC}
+		}
+	}
+
+	d.limit_pc = int(CPU_6502.PC)
+
+	return &Stop{Reason: ReasonLimit, PC: CPU_6502.PC}
+}
+
+// RunUntil runs until PC reaches addr (or any other stop), using a temporary breakpoint
+func (d *Debugger) RunUntil(addr uint16, maxCycles uint64) *Stop {
+
+	b := d.AddTemporaryBreakpoint(addr)
+	s := d.Continue(maxCycles)
+
+	// Remove the breakpoint if the execution stopped elsewhere
+	_ = d.Delete(b.ID)
+
+	return s
+}
+
+// Stopped returns the last stop raised by the hooks (nil if running)
+// Useful for hosts calling CPU_Interpreter directly
+func (d *Debugger) Stopped() *Stop {
+	return d.stopped
+}
+
+func (d *Debugger) takeStop() *Stop {
+	s := d.stopped
+	d.stopped = nil
+	return s
+}
+
+func (d *Debugger) notify(s *Stop) {
+	if d.OnStop != nil {
+		d.OnStop(s)
+	}
+}
+
+// Count the hit, returns true if the breakpoint must stop the execution
+func (d *Debugger) hit(b *Breakpoint, ctx *evalContext) bool {
+
+	if !b.Enabled {
+		return false
+	}
+
+	if b.Condition != nil && !b.Condition.eval(ctx) {
+		return false
+	}
+
+	b.Hits++
+
+	if b.Hits <= b.IgnoreCount {
+		return false
+	}
+
+	if b.Temporary {
+		_ = d.Delete(b.ID)
+	}
+
+	return true
+}
+
+// --------------------------------- Hooks ---------------------------------- //
+
+func (d *Debugger) onInstruction(pc uint16, opcode byte) bool {
+
+	// Resume from a stop at this address
+	skip := d.skip_pc == int(pc)
+	d.skip_pc = -1
+
+	if skip {
+		return false
+	}
+
+	// Watchpoints and interrupts hit by the previous instruction
+	if s := d.pending; s != nil {
+		d.pending = nil
+		s.PC = pc
+		return d.hold(s)
+	}
+
+	ctx := &evalContext{}
+
+	for _, b := range d.breakpoints {
+		switch {
+		case b.Kind == Execute && b.Start == pc, b.Kind == Opcode && b.Opcode == opcode:
+			if d.hit(b, ctx) {
+				return d.hold(&Stop{Reason: ReasonBreakpoint, Breakpoint: b, PC: pc})
+			}
+		}
+	}
+
+	return false
+}
+
+func (d *Debugger) hold(s *Stop) bool {
+	d.stopped = s
+	d.skip_pc = int(s.PC)
+	d.notify(s)
+	return true
+}
+
+func (d *Debugger) onRead(addr uint16, value byte) {
+	d.watch(addr, value, false)
+}
+
+func (d *Debugger) onWrite(addr uint16, old, value byte) {
+	d.watch(addr, value, true)
+}
+
+func (d *Debugger) watch(addr uint16, value byte, write bool) {
+
+	if d.pending != nil {
+		return
+	}
+
+	ctx := &evalContext{value: value, address: addr}
+
+	for _, b := range d.breakpoints {
+		if !b.contains(addr) {
+			continue
+		}
+		if b.Kind == Access || (b.Kind == Read && !write) || (b.Kind == Write && write) {
+			if d.hit(b, ctx) {
+				d.pending = &Stop{Reason: ReasonBreakpoint, Breakpoint: b, Address: addr, Value: value, Write: write}
+				return
+			}
+		}
+	}
+}
+
+func (d *Debugger) onInterrupt(source CPU_6502.Interrupt, vector uint16, return_addr uint16) {
+
+	if d.pending != nil {
+		return
+	}
+
+	ctx := &evalContext{address: vector}
+
+	for _, b := range d.breakpoints {
+		if b.Kind == Interrupt && d.hit(b, ctx) {
+			d.pending = &Stop{Reason: ReasonBreakpoint, Breakpoint: b, Address: vector, Source: source}
+			return
+		}
+	}
+}
+
+// Data accesses stop before the next instruction, fetches before executing the opcode
+func (d *Debugger) onViolation(v CPU_6502.MemoryViolation) {
+
+	if d.pending != nil {
+		return
+	}
+
+	d.pending = &Stop{Reason: ReasonViolation, Address: v.Address, Value: v.Value, Write: v.Access == CPU_6502.ACCESS_WRITE, Violation: v}
+}
+
+// StepOver executes one instruction, running subroutine calls (JSR) to their return
+func (d *Debugger) StepOver(maxCycles uint64) *Stop {
+
+	pc := CPU_6502.PC
+
+	if CPU_6502.ReadMemory(pc) != 0x20 { // JSR
+		return d.Step()
+	}
+
+	// Return to the instruction after JSR, at the same stack depth (recursion)
+	b := d.AddTemporaryBreakpoint(pc + 3)
+	b.Condition, _ = ParseCondition(fmt.Sprintf("SP>=%d", CPU_6502.SP), nil)
+
+	s := d.Continue(maxCycles)
+
+	_ = d.Delete(b.ID)
+
+	if s.Breakpoint == b {
+		s.Reason, s.Breakpoint = ReasonStep, nil
+	}
+
+	return s
+}
+
+// StepOut runs until the current subroutine returns (RTS or RTI at the same stack depth)
+func (d *Debugger) StepOut(maxCycles uint64) *Stop {
+
+	// Already on the return instruction
+	if opc := CPU_6502.ReadMemory(CPU_6502.PC); opc == 0x60 || opc == 0x40 {
+		return d.Step()
+	}
+
+	cond := fmt.Sprintf("SP>=%d", CPU_6502.SP)
+
+	rts := d.AddOpcodeBreakpoint(0x60)
+	rti := d.AddOpcodeBreakpoint(0x40)
+	rts.Condition, _ = ParseCondition(cond, nil)
+	rti.Condition, _ = ParseCondition(cond, nil)
+
+	s := d.Continue(maxCycles)
+
+	_ = d.Delete(rts.ID)
+	_ = d.Delete(rti.ID)
+
+	// Execute the return instruction
+	if s.Breakpoint == rts || s.Breakpoint == rti {
+		return d.Step()
+	}
+
+	return s
+}
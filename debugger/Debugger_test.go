@@ -0,0 +1,154 @@
+package debugger
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Counts X from 1 to 5 in $0300, then calls a subroutine and breaks
+var test_program = []byte{
+	0xA2, 0x00, // $0200 LDX #0
+	0xE8,             // $0202 INX
+	0x8E, 0x00, 0x03, // $0203 STX $0300
+	0xE0, 0x05, // $0206 CPX #5
+	0xD0, 0xF8, // $0208 BNE $0202
+	0x20, 0x10, 0x02, // $020A JSR $0210
+	0x00, 0xEA, 0xEA, // $020D BRK
+	0xAD, 0x00, 0x03, // $0210 LDA $0300
+	0x60, // $0213 RTS
+}
+
+// Clean CPU with a program at $0200, BRK handler at $0400
+func testCPU(program ...byte) {
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+	copy(CPU_6502.Memory[0x0200:], program)
+	CPU_6502.Memory[0x0400] = 0xEA
+	CPU_6502.Memory[0xFFFE], CPU_6502.Memory[0xFFFF] = 0x00, 0x04
+	CPU_6502.PC = 0x0200
+	CPU_6502.SP = 0xFF
+}
+
+func testDebugger(t *testing.T) *Debugger {
+
+	testCPU(test_program...)
+
+	d := New()
+	t.Cleanup(d.Close)
+
+	return d
+}
+
+func testStop(t *testing.T, s *Stop, reason Reason, b *Breakpoint, pc uint16) {
+	t.Helper()
+	if s.Reason != reason || s.Breakpoint != b || s.PC != pc {
+		t.Fatalf("stop %q (reason %d), want reason %d at $%04X", s, s.Reason, reason, pc)
+	}
+}
+
+// The CPU stops before the instruction and resumes over the breakpoint
+func TestExecuteBreakpoint(t *testing.T) {
+
+	d := testDebugger(t)
+	b := d.AddBreakpoint(0x0206)
+
+	for i := 1; i <= 2; i++ {
+		testStop(t, d.Continue(0), ReasonBreakpoint, b, 0x0206)
+		if CPU_6502.X != byte(i) || b.Hits != uint64(i) {
+			t.Errorf("stop %d with X=%d and %d hits", i, CPU_6502.X, b.Hits)
+		}
+	}
+
+	b.Enabled = false
+	if s := d.Continue(1000); s.Reason != ReasonLimit {
+		t.Errorf("disabled breakpoint: %q", s)
+	}
+}
+
+// Conditions and ignore counts are checked before stopping
+func TestConditionAndIgnore(t *testing.T) {
+
+	d := testDebugger(t)
+
+	b := d.AddBreakpoint(0x0203)
+	if err := d.SetCondition(b, "X>=2"); err != nil {
+		t.Fatal(err)
+	}
+	b.IgnoreCount = 2
+
+	testStop(t, d.Continue(0), ReasonBreakpoint, b, 0x0203)
+	if CPU_6502.X != 4 || b.Hits != 3 {
+		t.Errorf("stop with X=%d and %d hits, want X=4 and 3 hits", CPU_6502.X, b.Hits)
+	}
+}
+
+// Watchpoints stop after the access, before the next instruction
+func TestWatchpoint(t *testing.T) {
+
+	d := testDebugger(t)
+
+	if _, err := d.AddWatchpoint(Write, 0x0300, 0x02FF); err == nil {
+		t.Errorf("inverted range accepted")
+	}
+
+	r, _ := d.AddWatchpoint(Read, 0x02FF, 0x0301)
+	w, _ := d.AddWatchpoint(Write, 0x02FF, 0x0301)
+
+	s := d.Continue(0)
+	testStop(t, s, ReasonBreakpoint, w, 0x0206)
+	if s.Address != 0x0300 || s.Value != 1 || !s.Write {
+		t.Errorf("watchpoint stop %q", s)
+	}
+
+	w.Enabled = false
+	s = d.Continue(0)
+	testStop(t, s, ReasonBreakpoint, r, 0x0213)
+	if s.Address != 0x0300 || s.Value != 5 || s.Write {
+		t.Errorf("watchpoint stop %q", s)
+	}
+}
+
+// Temporary, opcode and interrupt breakpoints
+func TestTemporaryOpcodeInterrupt(t *testing.T) {
+
+	d := testDebugger(t)
+
+	if s := d.RunUntil(0x020A, 0); s.Reason != ReasonBreakpoint || !s.Breakpoint.Temporary || s.PC != 0x020A {
+		t.Fatalf("run until $020A: %q", s)
+	}
+	if len(d.Breakpoints()) != 0 {
+		t.Errorf("temporary breakpoint not deleted: %v", d.Breakpoints())
+	}
+
+	o := d.AddOpcodeBreakpoint(0x60)
+	testStop(t, d.Continue(0), ReasonBreakpoint, o, 0x0213)
+	d.Delete(o.ID)
+
+	i := d.AddInterruptBreakpoint()
+	s := d.Continue(0)
+	testStop(t, s, ReasonBreakpoint, i, 0x0400)
+	if s.Address != 0x0400 || s.Source != CPU_6502.INT_BRK {
+		t.Errorf("interrupt stop %q", s)
+	}
+}
+
+// StepOver runs the whole subroutine, StepOut returns from it
+func TestStepOverOut(t *testing.T) {
+
+	d := testDebugger(t)
+	d.RunUntil(0x020A, 0)
+
+	if s := d.StepOver(0); s.Reason != ReasonStep || s.PC != 0x020D || CPU_6502.A != 5 {
+		t.Errorf("step over: %q, A=%d", s, CPU_6502.A)
+	}
+
+	testCPU(test_program...)
+	d.RunUntil(0x0210, 0)
+
+	if s := d.StepOut(0); s.Reason != ReasonStep || s.PC != 0x020D || CPU_6502.SP != 0xFF {
+		t.Errorf("step out: %q, SP=$%02X", s, CPU_6502.SP)
+	}
+}
@@ -0,0 +1,292 @@
+package debugger
+
+import (
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// -------------------------------- History --------------------------------- //
+
+// HistoryWrite is a memory byte changed by an instruction
+type HistoryWrite struct {
+	Address uint16
+	Old     byte
+	Value   byte
+}
+
+// HistoryEntry holds what is needed to undo one instruction or interrupt sequence
+type HistoryEntry struct {
+	Registers CPU_6502.Registers       // Before the instruction
+	Cycle     uint64                   // Cycle counter before the instruction
+	Writes    []HistoryWrite           // Old values of the bytes written, in order
+	Chunks    []CPU_6502.SnapshotChunk // Mapper, device and interrupt state before the instruction
+	Interrupt bool                     // Interrupt sequence instead of an instruction
+}
+
+// History records per-instruction deltas in a ring buffer, so the execution
+// can be stepped back without full snapshots
+// Only the writes made through the data BUS are recorded (not WriteMemory).
+// The memory bytes are restored directly, while the mappers and devices get
+// back the state saved in their snapshot chunks (devices without one keep
+// their current state)
+type History struct {
+	entries []HistoryEntry
+	first   int // Oldest entry
+	count   int
+	hooks   *CPU_6502.Hooks
+}
+
+// NewHistory creates a recorder attached to the CPU keeping the last size instructions
+func NewHistory(size int) *History {
+
+	if size < 1 {
+		size = 1
+	}
+
+	h := &History{entries: make([]HistoryEntry, size)}
+
+	h.hooks = &CPU_6502.Hooks{
+		Instruction:    h.onInstruction,
+		InterruptStart: h.onInterruptStart,
+		Write:          h.onWrite,
+	}
+
+	CPU_6502.AttachHooks(h.hooks)
+
+	return h
+}
+
+// Close detaches the recorder from the CPU
+func (h *History) Close() {
+	CPU_6502.DetachHooks(h.hooks)
+}
+
+// Len returns the number of instructions recorded
+func (h *History) Len() int {
+	return h.count
+}
+
+// Clear forgets the recorded instructions (e.g. after loading a program)
+func (h *History) Clear() {
+	h.first, h.count = 0, 0
+}
+
+// Entry returns a recorded instruction, 0 is the most recent
+func (h *History) Entry(i int) *HistoryEntry {
+	if i < 0 || i >= h.count {
+		return nil
+	}
+	return &h.entries[(h.first+h.count-1-i)%len(h.entries)]
+}
+
+func (h *History) last() *HistoryEntry {
+	return h.Entry(0)
+}
+
+func (h *History) onInstruction(pc uint16, opcode byte) bool {
+
+	h.record(false)
+	return false
+}
+
+// The pushes of an interrupt sequence are undone on their own
+func (h *History) onInterruptStart(pc uint16) {
+	h.record(true)
+}
+
+// Save the state before an instruction or interrupt sequence
+func (h *History) record(interrupt bool) {
+
+	e := h.last()
+
+	// The last entry wasn't executed (the CPU was held by a debugger)
+	if e == nil || e.Cycle != CPU_6502.Cycle {
+		if h.count < len(h.entries) {
+			h.count++
+		} else {
+			h.first = (h.first + 1) % len(h.entries) // Overwrite the oldest
+		}
+		e = h.last()
+	}
+
+	e.Registers = CPU_6502.GetRegisters()
+	e.Cycle = CPU_6502.Cycle
+	e.Writes = e.Writes[:0]
+	e.Chunks = CPU_6502.SaveChunks(e.Chunks[:0])
+	e.Interrupt = interrupt
+}
+
+func (h *History) onWrite(addr uint16, old, value byte) {
+	if e := h.last(); e != nil {
+		e.Writes = append(e.Writes, HistoryWrite{Address: addr, Old: old, Value: value})
+	}
+}
+
+// StepBack undoes the last executed instruction, returns false when the history is empty
+// An instruction in progress is undone to its first cycle
+func (h *History) StepBack() bool {
+
+	h.discardPending()
+
+	e := h.last()
+	if e == nil {
+		return false
+	}
+
+	h.count--
+	h.undo(e)
+
+	return true
+}
+
+// Drop the entries recorded but not executed yet (CPU held by a debugger)
+func (h *History) discardPending() {
+	for e := h.last(); e != nil && e.Cycle >= CPU_6502.Cycle; e = h.last() {
+		h.count--
+	}
+}
+
+func (h *History) undo(e *HistoryEntry) {
+
+	// The writes received by a device didn't change the memory, the device
+	// state comes back with the chunks, like the banks of the mapper
+	for i := len(e.Writes) - 1; i >= 0; i-- {
+		if _, _, device := CPU_6502.DeviceAt(e.Writes[i].Address); !device {
+			CPU_6502.WriteMemory(e.Writes[i].Address, e.Writes[i].Old)
+		}
+	}
+
+	// Saved by the same handlers, only fails if a device was replaced
+	_ = CPU_6502.RestoreChunks(e.Chunks)
+
+	CPU_6502.SetRegisters(e.Registers)
+	CPU_6502.Cycle = e.Cycle
+
+	// Instruction boundary
+	CPU_6502.Opc_cycle_count = 1
+	CPU_6502.Opc_cycle_extra = 0
+	CPU_6502.NewInstruction = true
+}
+
+// Rewind steps back until at least cycles were undone, returns the instructions undone
+func (h *History) Rewind(cycles uint64) int {
+
+	target := uint64(0)
+	if CPU_6502.Cycle > cycles {
+		target = CPU_6502.Cycle - cycles
+	}
+
+	n := 0
+
+	for CPU_6502.Cycle > target && h.StepBack() {
+		n++
+	}
+
+	return n
+}
+
+// ------------------------- Debugger Reverse Control ----------------------- //
+
+// EnableHistory starts recording the last size instructions (0 disables it)
+func (d *Debugger) EnableHistory(size int) {
+
+	if d.history != nil {
+		d.history.Close()
+		d.history = nil
+	}
+
+	if size > 0 {
+		d.history = NewHistory(size)
+	}
+}
+
+// History returns the recorder (nil when disabled)
+func (d *Debugger) History() *History {
+	return d.history
+}
+
+// StepBack undoes one instruction
+func (d *Debugger) StepBack() *Stop {
+
+	if d.history == nil || !d.history.StepBack() {
+		return &Stop{Reason: ReasonHistoryEnd, PC: CPU_6502.PC}
+	}
+
+	d.resetResume()
+
+	return &Stop{Reason: ReasonStep, PC: CPU_6502.PC}
+}
+
+// Rewind undoes instructions until cycles were undone
+func (d *Debugger) Rewind(cycles uint64) *Stop {
+
+	if d.history == nil || d.history.Rewind(cycles) == 0 {
+		return &Stop{Reason: ReasonHistoryEnd, PC: CPU_6502.PC}
+	}
+
+	d.resetResume()
+
+	return &Stop{Reason: ReasonStep, PC: CPU_6502.PC}
+}
+
+// ReverseContinue steps back until an execution breakpoint at PC matches or an
+// undone instruction wrote to a write / access watchpoint
+// Conditions are evaluated on the restored state, hit counts are not changed
+func (d *Debugger) ReverseContinue() *Stop {
+
+	if d.history == nil {
+		return &Stop{Reason: ReasonHistoryEnd, PC: CPU_6502.PC}
+	}
+
+	for {
+		// Writes of the instruction about to be undone
+		var written []HistoryWrite
+		interrupt := false
+
+		d.history.discardPending()
+		if last := d.history.last(); last != nil {
+			written = append(written, last.Writes...)
+			interrupt = last.Interrupt
+		}
+
+		if !d.history.StepBack() {
+			d.resetResume()
+			return &Stop{Reason: ReasonHistoryEnd, PC: CPU_6502.PC}
+		}
+
+		pc := CPU_6502.PC
+
+		for _, b := range d.breakpoints {
+
+			if !b.Enabled {
+				continue
+			}
+
+			switch b.Kind {
+
+			// Forward execution reaches the breakpoint after the interrupt sequence
+			case Execute:
+				if !interrupt && b.Start == pc && (b.Condition == nil || b.Condition.eval(&evalContext{})) {
+					d.resetResume()
+					return &Stop{Reason: ReasonBreakpoint, Breakpoint: b, PC: pc}
+				}
+
+			case Write, Access:
+				for _, w := range written {
+					ctx := &evalContext{value: w.Value, address: w.Address}
+					if b.contains(w.Address) && (b.Condition == nil || b.Condition.eval(ctx)) {
+						d.resetResume()
+						return &Stop{Reason: ReasonBreakpoint, Breakpoint: b, PC: pc, Address: w.Address, Value: w.Value, Write: true}
+					}
+				}
+			}
+		}
+	}
+}
+
+// After moving back in time, resume at PC without hitting its breakpoint again
+func (d *Debugger) resetResume() {
+	d.pending = nil
+	d.stopped = nil
+	d.skip_pc = int(CPU_6502.PC)
+	d.limit_pc = -1
+}
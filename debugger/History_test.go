@@ -0,0 +1,119 @@
+package debugger
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/mappers"
+	"github.com/cassianoperin/6502_GO_Core/riot"
+)
+
+// Clean CPU with a program at $0200 and a history recorder
+func testHistory(t *testing.T, program ...byte) *History {
+
+	testCPU(program...)
+
+	h := NewHistory(16)
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+func testStep(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		if CPU_6502.Step() == 0 {
+			t.Fatalf("CPU held or halted at $%04X", CPU_6502.PC)
+		}
+	}
+}
+
+// Writes to the RIOT RAM and to the F8 hotspot are undone through the device and the mapper
+func TestHistoryDeviceAndMapper(t *testing.T) {
+
+	h := testHistory(t,
+		0xA9, 0x42, // LDA #$42
+		0x85, 0x85, // STA $85 (RIOT RAM)
+		0x8D, 0xF8, 0x1F, // STA $1FF8 (bank 0)
+	)
+
+	r, err := riot.Attach2600()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Detach)
+	r.Write(0x05, 0x11)
+
+	rom := make([]byte, 8192)
+	for i := range rom {
+		rom[i] = byte(0xB0 + i/4096)
+	}
+	m, err := mappers.NewF8(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mappers.Attach(m)
+	t.Cleanup(mappers.Detach)
+
+	testStep(t, 3)
+	if r.Read(0x05) != 0x42 || m.Banks()[0] != 0 || CPU_6502.Memory[0x1000] != 0xB0 {
+		t.Fatalf("before the step back: RAM $%02X, bank %d, $1000=$%02X", r.Read(0x05), m.Banks()[0], CPU_6502.Memory[0x1000])
+	}
+
+	h.StepBack()
+	if m.Banks()[0] != 1 || CPU_6502.Memory[0x1000] != 0xB1 {
+		t.Errorf("bank %d and $1000=$%02X after undoing STA $1FF8, want 1 and $B1", m.Banks()[0], CPU_6502.Memory[0x1000])
+	}
+
+	h.StepBack()
+	if r.Read(0x05) != 0x11 {
+		t.Errorf("RIOT RAM $%02X after undoing STA $85, want $11", r.Read(0x05))
+	}
+	if CPU_6502.Memory[0x85] != 0x00 {
+		t.Errorf("memory $85=$%02X, the write went to the RIOT", CPU_6502.Memory[0x85])
+	}
+	if CPU_6502.PC != 0x0202 {
+		t.Errorf("PC $%04X, want $0202", CPU_6502.PC)
+	}
+}
+
+// The interrupt sequence has its own entry and its state is restored
+func TestHistoryInterrupt(t *testing.T) {
+
+	h := testHistory(t, 0xEA, 0xEA, 0xEA) // NOP
+	CPU_6502.Memory[0xFFFA], CPU_6502.Memory[0xFFFB] = 0x00, 0x03
+	CPU_6502.Memory[0x0300] = 0xEA
+
+	nmi := CPU_6502.NewNMILine()
+
+	testStep(t, 1)
+	nmi.Set(true)
+
+	// Undo the sequence in progress
+	for i := 0; i < 3; i++ {
+		CPU_6502.CPU_Interpreter()
+	}
+	h.StepBack()
+	if CPU_6502.PC != 0x0201 || h.Len() != 1 {
+		t.Fatalf("PC $%04X and %d entries after undoing a partial sequence, want $0201 and 1", CPU_6502.PC, h.Len())
+	}
+
+	// Run it again from its first cycle
+	if cycles := CPU_6502.Step(); cycles != 7 || CPU_6502.PC != 0x0300 {
+		t.Fatalf("interrupt sequence of %d cycles to $%04X, want 7 to $0300", cycles, CPU_6502.PC)
+	}
+	if e := h.Entry(0); !e.Interrupt || len(e.Writes) != 3 {
+		t.Fatalf("interrupt entry %v with %d writes, want the 3 pushes", e.Interrupt, len(e.Writes))
+	}
+	if e := h.Entry(1); e.Interrupt || len(e.Writes) != 0 {
+		t.Errorf("NOP entry %v with %d writes, want none", e.Interrupt, len(e.Writes))
+	}
+
+	// Undone, the NMI is taken again
+	h.StepBack()
+	if CPU_6502.PC != 0x0201 || CPU_6502.SP != 0xFF || CPU_6502.Memory[0x01FF] != 0x00 {
+		t.Errorf("PC $%04X, SP $%02X, stack $%02X after undoing the sequence", CPU_6502.PC, CPU_6502.SP, CPU_6502.Memory[0x01FF])
+	}
+	if cycles := CPU_6502.Step(); cycles != 7 || CPU_6502.PC != 0x0300 {
+		t.Errorf("NMI not taken again: %d cycles to $%04X", cycles, CPU_6502.PC)
+	}
+}
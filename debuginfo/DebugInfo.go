@@ -0,0 +1,234 @@
+// Package debuginfo maps source lines to addresses and addresses to symbols,
+// for source-level debuggers. The information is produced by the assembler
+// and can be saved to and loaded from a JSON file.
+package debuginfo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Line maps a source line to the bytes it generated
+type Line struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Address uint16 `json:"address"`
+	Size    uint16 `json:"size"`
+	Code    bool   `json:"code,omitempty"` // Instruction (not data)
+}
+
+// Info holds the line table and the symbols of a program
+type Info struct {
+	Lines   []Line            `json:"lines"`
+	Symbols map[string]uint16 `json:"symbols"`
+
+	by_address []Line   // Lines sorted by address
+	by_symbol  []string // Symbol names sorted by address
+}
+
+// New creates the debug information from a line table and symbols
+func New(lines []Line, symbols map[string]uint16) *Info {
+
+	info := &Info{Lines: lines, Symbols: symbols}
+	info.index()
+
+	return info
+}
+
+// Load reads a debug information file saved with Save
+// Relative file names are resolved from the directory of the file
+func Load(filename string) (*Info, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(filename)
+	for i := range info.Lines {
+		if !filepath.IsAbs(info.Lines[i].File) {
+			info.Lines[i].File = filepath.Join(dir, info.Lines[i].File)
+		}
+	}
+
+	info.index()
+
+	return info, nil
+}
+
+// Save writes the debug information as JSON
+func (info *Info) Save(filename string) error {
+
+	data, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func (info *Info) index() {
+
+	if info.Symbols == nil {
+		info.Symbols = map[string]uint16{}
+	}
+
+	info.by_address = append([]Line(nil), info.Lines...)
+	sort.SliceStable(info.by_address, func(i, j int) bool {
+		return info.by_address[i].Address < info.by_address[j].Address
+	})
+
+	info.by_symbol = info.by_symbol[:0]
+	for name := range info.Symbols {
+		info.by_symbol = append(info.by_symbol, name)
+	}
+	sort.Slice(info.by_symbol, func(i, j int) bool {
+		a, b := info.Symbols[info.by_symbol[i]], info.Symbols[info.by_symbol[j]]
+		if a != b {
+			return a < b
+		}
+		return info.by_symbol[i] < info.by_symbol[j]
+	})
+}
+
+// ---------------------------------- Lines ---------------------------------- //
+
+// Lookup returns the source line that generated the byte at addr
+func (info *Info) Lookup(addr uint16) (Line, bool) {
+
+	// Last line starting at or before addr
+	i := sort.Search(len(info.by_address), func(i int) bool {
+		return info.by_address[i].Address > addr
+	}) - 1
+
+	for ; i >= 0; i-- {
+		l := info.by_address[i]
+		if int(addr) < int(l.Address)+int(l.Size) {
+			return l, true
+		}
+		// Lines are sorted by start address, an earlier line may still be longer
+		if addr-l.Address > 0xFF {
+			break
+		}
+	}
+
+	return Line{}, false
+}
+
+// Addresses returns the start address of a source line
+// When the line has no code the next line with code is used, the returned line
+// is the one actually found (0 if none)
+func (info *Info) Addresses(file string, line int) ([]uint16, int) {
+
+	best := 0
+
+	for _, l := range info.Lines {
+		if l.Line >= line && SameFile(l.File, file) && (best == 0 || l.Line < best) {
+			best = l.Line
+		}
+	}
+
+	if best == 0 {
+		return nil, 0
+	}
+
+	var (
+		addrs []uint16
+		end   = -1 // End of the previous piece of the line
+	)
+
+	for _, l := range info.Lines {
+		if l.Line == best && SameFile(l.File, file) {
+			// Data directives emit a line in several pieces, keep its first address
+			if int(l.Address) != end {
+				addrs = append(addrs, l.Address)
+			}
+			end = int(l.Address) + int(l.Size)
+		}
+	}
+
+	return addrs, best
+}
+
+// Files returns the source files in the line table
+func (info *Info) Files() []string {
+
+	seen := map[string]bool{}
+	var files []string
+
+	for _, l := range info.Lines {
+		if !seen[l.File] {
+			seen[l.File] = true
+			files = append(files, l.File)
+		}
+	}
+
+	return files
+}
+
+// SameFile compares two file names, a relative name matches the end of an absolute one
+func SameFile(a, b string) bool {
+
+	a, b = filepath.Clean(a), filepath.Clean(b)
+
+	if a == b {
+		return true
+	}
+
+	if filepath.IsAbs(a) && filepath.IsAbs(b) {
+		return false
+	}
+
+	long, short := a, b
+	if len(short) > len(long) {
+		long, short = short, long
+	}
+
+	return strings.HasSuffix(long, string(filepath.Separator)+short)
+}
+
+// --------------------------------- Symbols --------------------------------- //
+
+// Symbol returns the name of a symbol at exactly addr
+func (info *Info) Symbol(addr uint16) (string, bool) {
+
+	i := sort.Search(len(info.by_symbol), func(i int) bool {
+		return info.Symbols[info.by_symbol[i]] >= addr
+	})
+
+	if i < len(info.by_symbol) && info.Symbols[info.by_symbol[i]] == addr {
+		return info.by_symbol[i], true
+	}
+
+	return "", false
+}
+
+// Nearest returns the closest symbol at or before addr and the offset from it
+func (info *Info) Nearest(addr uint16) (string, uint16, bool) {
+
+	i := sort.Search(len(info.by_symbol), func(i int) bool {
+		return info.Symbols[info.by_symbol[i]] > addr
+	}) - 1
+
+	if i < 0 {
+		return "", 0, false
+	}
+
+	name := info.by_symbol[i]
+
+	return name, addr - info.Symbols[name], true
+}
+
+// Resolve returns the address of a symbol (for debugger conditions)
+func (info *Info) Resolve(name string) (uint16, bool) {
+	addr, ok := info.Symbols[name]
+	return addr, ok
+}
@@ -0,0 +1,233 @@
+// Package disassembler renders 6502 machine code as assembly source.
+//
+// It works over any memory view (a read function or a byte slice with its
+// origin) so it can be used by the core debug output and by front-ends.
+package disassembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options controls how the instructions are decoded and formatted
+type Options struct {
+	Variant      Variant // Opcode table (NMOS 6502 or 65C02)
+	Undocumented bool    // Name undocumented opcodes instead of emitting them as .byte
+
+	// Optional symbol lookup, used to replace addresses by labels (JSR init_screen)
+	Symbols func(addr uint16) (string, bool)
+}
+
+// Line is one decoded instruction (or data byte)
+type Line struct {
+	Address     uint16
+	Bytes       []byte
+	Instruction Instruction
+	Operand     uint16 // Raw operand (8 or 16 bits, zeropage address for ZeropageRelative)
+	Target      uint16 // Resolved branch target or referenced address
+	HasTarget   bool   // Target is meaningful (not for implied, accumulator and immediate)
+	Data        bool   // Byte not decoded as an instruction (.byte)
+	Text        string // Mnemonic and formatted operand
+}
+
+// String formats the line as "C000  A9 10     LDA #$10"
+func (l Line) String() string {
+
+	var hex string
+
+	for i, b := range l.Bytes {
+		if i > 0 {
+			hex += " "
+		}
+		hex += fmt.Sprintf("%02X", b)
+	}
+
+	return fmt.Sprintf("%04X  %-8s  %s", l.Address, hex, l.Text)
+}
+
+// Size returns the number of bytes used by the line
+func (l Line) Size() uint16 {
+	return uint16(len(l.Bytes))
+}
+
+// Decode disassembles the instruction at addr using the read function to access memory
+func Decode(read func(addr uint16) byte, addr uint16, opts Options) Line {
+
+	opc := read(addr)
+	ins := Table(opts.Variant)[opc]
+
+	// Undocumented opcodes are data unless requested
+	if ins.Undocumented && !opts.Undocumented {
+		return Line{
+			Address:     addr,
+			Bytes:       []byte{opc},
+			Instruction: ins,
+			Data:        true,
+			Text:        fmt.Sprintf(".byte $%02X", opc),
+		}
+	}
+
+	line := Line{
+		Address:     addr,
+		Bytes:       make([]byte, ins.Bytes()),
+		Instruction: ins,
+	}
+
+	for i := range line.Bytes {
+		line.Bytes[i] = read(addr + uint16(i))
+	}
+
+	// Operand value (Little Endian)
+	switch len(line.Bytes) {
+	case 2:
+		line.Operand = uint16(line.Bytes[1])
+	case 3:
+		line.Operand = uint16(line.Bytes[2])<<8 | uint16(line.Bytes[1])
+	}
+
+	line.Text = ins.Mnemonic + format(&line, opts)
+
+	return line
+}
+
+// Range disassembles memory from start to end (inclusive)
+func Range(read func(addr uint16) byte, start, end uint16, opts Options) []Line {
+
+	var lines []Line
+
+	addr := uint32(start)
+
+	for addr <= uint32(end) {
+		line := Decode(read, uint16(addr), opts)
+
+		// Do not read past the end of the range, emit the remaining bytes as data
+		if addr+uint32(line.Size())-1 > uint32(end) {
+			line = dataLine(uint16(addr), read(uint16(addr)))
+		}
+
+		lines = append(lines, line)
+		addr += uint32(line.Size())
+	}
+
+	return lines
+}
+
+// Bytes disassembles a byte slice loaded at origin
+func Bytes(data []byte, origin uint16, opts Options) []Line {
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	read := func(addr uint16) byte {
+		offset := int(addr - origin)
+		if offset < len(data) {
+			return data[offset]
+		}
+		return 0
+	}
+
+	return Range(read, origin, origin+uint16(len(data)-1), opts)
+}
+
+// Listing renders the lines as text, one per line
+func Listing(lines []Line) string {
+
+	var sb strings.Builder
+
+	for _, l := range lines {
+		sb.WriteString(l.String())
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+func dataLine(addr uint16, value byte) Line {
+	return Line{
+		Address: addr,
+		Bytes:   []byte{value},
+		Data:    true,
+		Text:    fmt.Sprintf(".byte $%02X", value),
+	}
+}
+
+// ------------------------------- Formatting ------------------------------- //
+
+// Format the operand according with the addressing mode
+func format(l *Line, opts Options) string {
+
+	switch l.Instruction.Mode {
+
+	case Implied:
+		return ""
+
+	case Accumulator:
+		return " A"
+
+	case Immediate:
+		return fmt.Sprintf(" #$%02X", l.Operand)
+
+	case Zeropage, ZeropageX, ZeropageY, IndirectX, IndirectY, ZeropageIndirect:
+		l.Target, l.HasTarget = l.Operand, true
+		name := address(l.Operand, 2, opts)
+
+		switch l.Instruction.Mode {
+		case ZeropageX:
+			return " " + name + ",X"
+		case ZeropageY:
+			return " " + name + ",Y"
+		case IndirectX:
+			return " (" + name + ",X)"
+		case IndirectY:
+			return " (" + name + "),Y"
+		case ZeropageIndirect:
+			return " (" + name + ")"
+		}
+		return " " + name
+
+	case Absolute, AbsoluteX, AbsoluteY, Indirect, AbsoluteIndirectX:
+		l.Target, l.HasTarget = l.Operand, true
+		name := address(l.Operand, 4, opts)
+
+		switch l.Instruction.Mode {
+		case AbsoluteX:
+			return " " + name + ",X"
+		case AbsoluteY:
+			return " " + name + ",Y"
+		case Indirect:
+			return " (" + name + ")"
+		case AbsoluteIndirectX:
+			return " (" + name + ",X)"
+		}
+		return " " + name
+
+	case Relative:
+		// Branches needs the Two Complement of the offset value
+		l.Target = l.Address + 2 + uint16(int8(l.Bytes[1]))
+		l.HasTarget = true
+		return " " + address(l.Target, 4, opts)
+
+	case ZeropageRelative:
+		// BBRn/BBSn: zeropage address followed by the branch offset
+		zp := uint16(l.Bytes[1])
+		l.Operand = zp
+		l.Target = l.Address + 3 + uint16(int8(l.Bytes[2]))
+		l.HasTarget = true
+		return " " + address(zp, 2, opts) + "," + address(l.Target, 4, opts)
+	}
+
+	return ""
+}
+
+// Replace the address by a symbol when available
+func address(addr uint16, digits int, opts Options) string {
+
+	if opts.Symbols != nil {
+		if name, ok := opts.Symbols(addr); ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("$%0*X", digits, addr)
+}
@@ -0,0 +1,101 @@
+package disassembler
+
+import "testing"
+
+// One instruction of each addressing mode and its formatted line
+func TestBytes(t *testing.T) {
+
+	for _, c := range []struct {
+		variant Variant
+		code    []byte
+		text    string
+		target  uint16
+	}{
+		{NMOS, []byte{0xEA}, "NOP", 0},
+		{NMOS, []byte{0x0A}, "ASL A", 0},
+		{NMOS, []byte{0xA9, 0x10}, "LDA #$10", 0},
+		{NMOS, []byte{0xA5, 0x10}, "LDA $10", 0x0010},
+		{NMOS, []byte{0xB5, 0x10}, "LDA $10,X", 0x0010},
+		{NMOS, []byte{0xB6, 0x10}, "LDX $10,Y", 0x0010},
+		{NMOS, []byte{0xAD, 0x34, 0x12}, "LDA $1234", 0x1234},
+		{NMOS, []byte{0xBD, 0x34, 0x12}, "LDA $1234,X", 0x1234},
+		{NMOS, []byte{0xB9, 0x34, 0x12}, "LDA $1234,Y", 0x1234},
+		{NMOS, []byte{0x6C, 0x34, 0x12}, "JMP ($1234)", 0x1234},
+		{NMOS, []byte{0xA1, 0x10}, "LDA ($10,X)", 0x0010},
+		{NMOS, []byte{0xB1, 0x10}, "LDA ($10),Y", 0x0010},
+		{NMOS, []byte{0xD0, 0x10}, "BNE $C012", 0xC012},
+		{NMOS, []byte{0xD0, 0xFE}, "BNE $C000", 0xC000},
+		{NMOS, []byte{0x10, 0x80}, "BPL $BF82", 0xBF82},
+		{CMOS, []byte{0xB2, 0x10}, "LDA ($10)", 0x0010},
+		{CMOS, []byte{0x7C, 0x34, 0x12}, "JMP ($1234,X)", 0x1234},
+		{CMOS, []byte{0x8F, 0x10, 0xFD}, "BBS0 $10,$C000", 0xC000},
+		{CMOS, []byte{0x80, 0x02}, "BRA $C004", 0xC004},
+	} {
+
+		lines := Bytes(c.code, 0xC000, Options{Variant: c.variant})
+
+		if len(lines) != 1 {
+			t.Errorf("% X: %d lines, want 1", c.code, len(lines))
+			continue
+		}
+		if l := lines[0]; l.Text != c.text || l.Target != c.target || int(l.Size()) != len(c.code) {
+			t.Errorf("% X: %q to $%04X (%d bytes), want %q to $%04X (%d bytes)", c.code, l.Text, l.Target, l.Size(), c.text, c.target, len(c.code))
+		}
+	}
+}
+
+// Undocumented opcodes are data unless requested, and named after "No More Secrets"
+func TestUndocumented(t *testing.T) {
+
+	code := []byte{0xA7, 0x10} // LAX $10
+
+	if l := Bytes(code, 0x0200, Options{}); len(l) != 2 || !l[0].Data || l[0].Text != ".byte $A7" {
+		t.Errorf("documented only: %q", Listing(l))
+	}
+	if l := Bytes(code, 0x0200, Options{Undocumented: true}); len(l) != 1 || l[0].Text != "LAX $10" {
+		t.Errorf("undocumented: %q", Listing(l))
+	}
+
+	// The 65C02 has a NOP of the same size
+	if l := Bytes([]byte{0x44, 0x10}, 0x0200, Options{Variant: CMOS, Undocumented: true}); len(l) != 1 || l[0].Text != "NOP $10" {
+		t.Errorf("65C02: %q", Listing(l))
+	}
+}
+
+// An instruction crossing the end of the range is emitted as data and the
+// decoding goes on with the next byte
+func TestRangeEnd(t *testing.T) {
+
+	lines := Bytes([]byte{0xEA, 0x20, 0x00}, 0x0200, Options{}) // NOP, JSR cut
+
+	if len(lines) != 3 || lines[0].Text != "NOP" || !lines[1].Data || lines[2].Text != "BRK" {
+		t.Errorf("got:\n%s", Listing(lines))
+	}
+
+	// The range can end at $FFFF without wrapping
+	memory := make([]byte, 0x10000)
+	memory[0xFFFF] = 0xEA
+	lines = Range(func(addr uint16) byte { return memory[addr] }, 0xFFFE, 0xFFFF, Options{})
+	if len(lines) != 2 || lines[1].Address != 0xFFFF {
+		t.Errorf("got:\n%s", Listing(lines))
+	}
+}
+
+// Addresses are replaced by the symbols and the line is formatted for listings
+func TestSymbolsAndListing(t *testing.T) {
+
+	opts := Options{Symbols: func(addr uint16) (string, bool) {
+		if addr == 0xFFD2 {
+			return "chrout", true
+		}
+		return "", false
+	}}
+
+	lines := Bytes([]byte{0x20, 0xD2, 0xFF, 0xA9, 0x41}, 0xC000, opts)
+
+	want := "C000  20 D2 FF  JSR chrout\n" +
+		"C003  A9 41     LDA #$41\n"
+	if got := Listing(lines); got != want {
+		t.Errorf("got:\n%swant:\n%s", got, want)
+	}
+}
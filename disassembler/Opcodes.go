@@ -0,0 +1,267 @@
+package disassembler
+
+// ------------------------------ CPU Variants ------------------------------ //
+
+// Variant selects the opcode table used to decode the instructions
+type Variant byte
+
+const (
+	NMOS Variant = iota // MOS 6502 / 6507 (documented + undocumented opcodes)
+	CMOS                // WDC 65C02 (including the Rockwell bit instructions)
+)
+
+// ---------------------------- Addressing Modes ---------------------------- //
+
+// Mode is the addressing mode of an instruction
+type Mode byte
+
+const (
+	Implied           Mode = iota // BRK
+	Accumulator                   // ASL A
+	Immediate                     // LDA #$10
+	Zeropage                      // LDA $10
+	ZeropageX                     // LDA $10,X
+	ZeropageY                     // LDX $10,Y
+	Absolute                      // LDA $1234
+	AbsoluteX                     // LDA $1234,X
+	AbsoluteY                     // LDA $1234,Y
+	Indirect                      // JMP ($1234)
+	IndirectX                     // LDA ($10,X)
+	IndirectY                     // LDA ($10),Y
+	Relative                      // BNE $C010
+	ZeropageIndirect              // LDA ($10)          (65C02)
+	AbsoluteIndirectX             // JMP ($1234,X)      (65C02)
+	ZeropageRelative              // BBR0 $10,$C010     (65C02)
+)
+
+// Names used by the core debug messages
+var modeNames = [...]string{
+	Implied:           "Implied",
+	Accumulator:       "Accumulator",
+	Immediate:         "Immediate",
+	Zeropage:          "Zeropage",
+	ZeropageX:         "Zeropage,X",
+	ZeropageY:         "Zeropage,Y",
+	Absolute:          "Absolute",
+	AbsoluteX:         "Absolute,X",
+	AbsoluteY:         "Absolute,Y",
+	Indirect:          "Indirect",
+	IndirectX:         "(Indirect,X)",
+	IndirectY:         "(Indirect),Y",
+	Relative:          "Relative",
+	ZeropageIndirect:  "(Zeropage)",
+	AbsoluteIndirectX: "(Absolute,X)",
+	ZeropageRelative:  "Zeropage,Relative",
+}
+
+func (m Mode) String() string {
+	if int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return "Unknown"
+}
+
+// Bytes returns the instruction size (opcode + operand) for the addressing mode
+func (m Mode) Bytes() byte {
+	switch m {
+	case Implied, Accumulator:
+		return 1
+	case Absolute, AbsoluteX, AbsoluteY, Indirect, AbsoluteIndirectX, ZeropageRelative:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// ------------------------------ Instructions ------------------------------ //
+
+// Instruction describes one entry of an opcode table
+type Instruction struct {
+	Mnemonic     string
+	Mode         Mode
+	Cycles       byte // Base cycles, without page cross or branch penalties
+	Undocumented bool // Illegal opcode on the NMOS 6502, reserved NOP on the 65C02
+}
+
+// Bytes returns the instruction size (opcode + operand)
+func (i Instruction) Bytes() byte {
+	return i.Mode.Bytes()
+}
+
+// Table returns the opcode table of the CPU variant
+func Table(v Variant) *[256]Instruction {
+	if v == CMOS {
+		return &table65C02
+	}
+	return &table6502
+}
+
+func op(mnemonic string, mode Mode, cycles byte) Instruction {
+	return Instruction{mnemonic, mode, cycles, false}
+}
+
+func un(mnemonic string, mode Mode, cycles byte) Instruction {
+	return Instruction{mnemonic, mode, cycles, true}
+}
+
+// ---------------------------- NMOS 6502 Table ----------------------------- //
+
+// Undocumented names follow "No More Secrets" (NMOS 6510 Unintended Opcodes)
+var table6502 = [256]Instruction{
+	0x00: op("BRK", Implied, 7), 0x01: op("ORA", IndirectX, 6), 0x02: un("JAM", Implied, 0), 0x03: un("SLO", IndirectX, 8),
+	0x04: un("NOP", Zeropage, 3), 0x05: op("ORA", Zeropage, 3), 0x06: op("ASL", Zeropage, 5), 0x07: un("SLO", Zeropage, 5),
+	0x08: op("PHP", Implied, 3), 0x09: op("ORA", Immediate, 2), 0x0A: op("ASL", Accumulator, 2), 0x0B: un("ANC", Immediate, 2),
+	0x0C: un("NOP", Absolute, 4), 0x0D: op("ORA", Absolute, 4), 0x0E: op("ASL", Absolute, 6), 0x0F: un("SLO", Absolute, 6),
+
+	0x10: op("BPL", Relative, 2), 0x11: op("ORA", IndirectY, 5), 0x12: un("JAM", Implied, 0), 0x13: un("SLO", IndirectY, 8),
+	0x14: un("NOP", ZeropageX, 4), 0x15: op("ORA", ZeropageX, 4), 0x16: op("ASL", ZeropageX, 6), 0x17: un("SLO", ZeropageX, 6),
+	0x18: op("CLC", Implied, 2), 0x19: op("ORA", AbsoluteY, 4), 0x1A: un("NOP", Implied, 2), 0x1B: un("SLO", AbsoluteY, 7),
+	0x1C: un("NOP", AbsoluteX, 4), 0x1D: op("ORA", AbsoluteX, 4), 0x1E: op("ASL", AbsoluteX, 7), 0x1F: un("SLO", AbsoluteX, 7),
+
+	0x20: op("JSR", Absolute, 6), 0x21: op("AND", IndirectX, 6), 0x22: un("JAM", Implied, 0), 0x23: un("RLA", IndirectX, 8),
+	0x24: op("BIT", Zeropage, 3), 0x25: op("AND", Zeropage, 3), 0x26: op("ROL", Zeropage, 5), 0x27: un("RLA", Zeropage, 5),
+	0x28: op("PLP", Implied, 4), 0x29: op("AND", Immediate, 2), 0x2A: op("ROL", Accumulator, 2), 0x2B: un("ANC", Immediate, 2),
+	0x2C: op("BIT", Absolute, 4), 0x2D: op("AND", Absolute, 4), 0x2E: op("ROL", Absolute, 6), 0x2F: un("RLA", Absolute, 6),
+
+	0x30: op("BMI", Relative, 2), 0x31: op("AND", IndirectY, 5), 0x32: un("JAM", Implied, 0), 0x33: un("RLA", IndirectY, 8),
+	0x34: un("NOP", ZeropageX, 4), 0x35: op("AND", ZeropageX, 4), 0x36: op("ROL", ZeropageX, 6), 0x37: un("RLA", ZeropageX, 6),
+	0x38: op("SEC", Implied, 2), 0x39: op("AND", AbsoluteY, 4), 0x3A: un("NOP", Implied, 2), 0x3B: un("RLA", AbsoluteY, 7),
+	0x3C: un("NOP", AbsoluteX, 4), 0x3D: op("AND", AbsoluteX, 4), 0x3E: op("ROL", AbsoluteX, 7), 0x3F: un("RLA", AbsoluteX, 7),
+
+	0x40: op("RTI", Implied, 6), 0x41: op("EOR", IndirectX, 6), 0x42: un("JAM", Implied, 0), 0x43: un("SRE", IndirectX, 8),
+	0x44: un("NOP", Zeropage, 3), 0x45: op("EOR", Zeropage, 3), 0x46: op("LSR", Zeropage, 5), 0x47: un("SRE", Zeropage, 5),
+	0x48: op("PHA", Implied, 3), 0x49: op("EOR", Immediate, 2), 0x4A: op("LSR", Accumulator, 2), 0x4B: un("ALR", Immediate, 2),
+	0x4C: op("JMP", Absolute, 3), 0x4D: op("EOR", Absolute, 4), 0x4E: op("LSR", Absolute, 6), 0x4F: un("SRE", Absolute, 6),
+
+	0x50: op("BVC", Relative, 2), 0x51: op("EOR", IndirectY, 5), 0x52: un("JAM", Implied, 0), 0x53: un("SRE", IndirectY, 8),
+	0x54: un("NOP", ZeropageX, 4), 0x55: op("EOR", ZeropageX, 4), 0x56: op("LSR", ZeropageX, 6), 0x57: un("SRE", ZeropageX, 6),
+	0x58: op("CLI", Implied, 2), 0x59: op("EOR", AbsoluteY, 4), 0x5A: un("NOP", Implied, 2), 0x5B: un("SRE", AbsoluteY, 7),
+	0x5C: un("NOP", AbsoluteX, 4), 0x5D: op("EOR", AbsoluteX, 4), 0x5E: op("LSR", AbsoluteX, 7), 0x5F: un("SRE", AbsoluteX, 7),
+
+	0x60: op("RTS", Implied, 6), 0x61: op("ADC", IndirectX, 6), 0x62: un("JAM", Implied, 0), 0x63: un("RRA", IndirectX, 8),
+	0x64: un("NOP", Zeropage, 3), 0x65: op("ADC", Zeropage, 3), 0x66: op("ROR", Zeropage, 5), 0x67: un("RRA", Zeropage, 5),
+	0x68: op("PLA", Implied, 4), 0x69: op("ADC", Immediate, 2), 0x6A: op("ROR", Accumulator, 2), 0x6B: un("ARR", Immediate, 2),
+	0x6C: op("JMP", Indirect, 5), 0x6D: op("ADC", Absolute, 4), 0x6E: op("ROR", Absolute, 6), 0x6F: un("RRA", Absolute, 6),
+
+	0x70: op("BVS", Relative, 2), 0x71: op("ADC", IndirectY, 5), 0x72: un("JAM", Implied, 0), 0x73: un("RRA", IndirectY, 8),
+	0x74: un("NOP", ZeropageX, 4), 0x75: op("ADC", ZeropageX, 4), 0x76: op("ROR", ZeropageX, 6), 0x77: un("RRA", ZeropageX, 6),
+	0x78: op("SEI", Implied, 2), 0x79: op("ADC", AbsoluteY, 4), 0x7A: un("NOP", Implied, 2), 0x7B: un("RRA", AbsoluteY, 7),
+	0x7C: un("NOP", AbsoluteX, 4), 0x7D: op("ADC", AbsoluteX, 4), 0x7E: op("ROR", AbsoluteX, 7), 0x7F: un("RRA", AbsoluteX, 7),
+
+	0x80: un("NOP", Immediate, 2), 0x81: op("STA", IndirectX, 6), 0x82: un("NOP", Immediate, 2), 0x83: un("SAX", IndirectX, 6),
+	0x84: op("STY", Zeropage, 3), 0x85: op("STA", Zeropage, 3), 0x86: op("STX", Zeropage, 3), 0x87: un("SAX", Zeropage, 3),
+	0x88: op("DEY", Implied, 2), 0x89: un("NOP", Immediate, 2), 0x8A: op("TXA", Implied, 2), 0x8B: un("ANE", Immediate, 2),
+	0x8C: op("STY", Absolute, 4), 0x8D: op("STA", Absolute, 4), 0x8E: op("STX", Absolute, 4), 0x8F: un("SAX", Absolute, 4),
+
+	0x90: op("BCC", Relative, 2), 0x91: op("STA", IndirectY, 6), 0x92: un("JAM", Implied, 0), 0x93: un("SHA", IndirectY, 6),
+	0x94: op("STY", ZeropageX, 4), 0x95: op("STA", ZeropageX, 4), 0x96: op("STX", ZeropageY, 4), 0x97: un("SAX", ZeropageY, 4),
+	0x98: op("TYA", Implied, 2), 0x99: op("STA", AbsoluteY, 5), 0x9A: op("TXS", Implied, 2), 0x9B: un("TAS", AbsoluteY, 5),
+	0x9C: un("SHY", AbsoluteX, 5), 0x9D: op("STA", AbsoluteX, 5), 0x9E: un("SHX", AbsoluteY, 5), 0x9F: un("SHA", AbsoluteY, 5),
+
+	0xA0: op("LDY", Immediate, 2), 0xA1: op("LDA", IndirectX, 6), 0xA2: op("LDX", Immediate, 2), 0xA3: un("LAX", IndirectX, 6),
+	0xA4: op("LDY", Zeropage, 3), 0xA5: op("LDA", Zeropage, 3), 0xA6: op("LDX", Zeropage, 3), 0xA7: un("LAX", Zeropage, 3),
+	0xA8: op("TAY", Implied, 2), 0xA9: op("LDA", Immediate, 2), 0xAA: op("TAX", Implied, 2), 0xAB: un("LXA", Immediate, 2),
+	0xAC: op("LDY", Absolute, 4), 0xAD: op("LDA", Absolute, 4), 0xAE: op("LDX", Absolute, 4), 0xAF: un("LAX", Absolute, 4),
+
+	0xB0: op("BCS", Relative, 2), 0xB1: op("LDA", IndirectY, 5), 0xB2: un("JAM", Implied, 0), 0xB3: un("LAX", IndirectY, 5),
+	0xB4: op("LDY", ZeropageX, 4), 0xB5: op("LDA", ZeropageX, 4), 0xB6: op("LDX", ZeropageY, 4), 0xB7: un("LAX", ZeropageY, 4),
+	0xB8: op("CLV", Implied, 2), 0xB9: op("LDA", AbsoluteY, 4), 0xBA: op("TSX", Implied, 2), 0xBB: un("LAS", AbsoluteY, 4),
+	0xBC: op("LDY", AbsoluteX, 4), 0xBD: op("LDA", AbsoluteX, 4), 0xBE: op("LDX", AbsoluteY, 4), 0xBF: un("LAX", AbsoluteY, 4),
+
+	0xC0: op("CPY", Immediate, 2), 0xC1: op("CMP", IndirectX, 6), 0xC2: un("NOP", Immediate, 2), 0xC3: un("DCP", IndirectX, 8),
+	0xC4: op("CPY", Zeropage, 3), 0xC5: op("CMP", Zeropage, 3), 0xC6: op("DEC", Zeropage, 5), 0xC7: un("DCP", Zeropage, 5),
+	0xC8: op("INY", Implied, 2), 0xC9: op("CMP", Immediate, 2), 0xCA: op("DEX", Implied, 2), 0xCB: un("SBX", Immediate, 2),
+	0xCC: op("CPY", Absolute, 4), 0xCD: op("CMP", Absolute, 4), 0xCE: op("DEC", Absolute, 6), 0xCF: un("DCP", Absolute, 6),
+
+	0xD0: op("BNE", Relative, 2), 0xD1: op("CMP", IndirectY, 5), 0xD2: un("JAM", Implied, 0), 0xD3: un("DCP", IndirectY, 8),
+	0xD4: un("NOP", ZeropageX, 4), 0xD5: op("CMP", ZeropageX, 4), 0xD6: op("DEC", ZeropageX, 6), 0xD7: un("DCP", ZeropageX, 6),
+	0xD8: op("CLD", Implied, 2), 0xD9: op("CMP", AbsoluteY, 4), 0xDA: un("NOP", Implied, 2), 0xDB: un("DCP", AbsoluteY, 7),
+	0xDC: un("NOP", AbsoluteX, 4), 0xDD: op("CMP", AbsoluteX, 4), 0xDE: op("DEC", AbsoluteX, 7), 0xDF: un("DCP", AbsoluteX, 7),
+
+	0xE0: op("CPX", Immediate, 2), 0xE1: op("SBC", IndirectX, 6), 0xE2: un("NOP", Immediate, 2), 0xE3: un("ISC", IndirectX, 8),
+	0xE4: op("CPX", Zeropage, 3), 0xE5: op("SBC", Zeropage, 3), 0xE6: op("INC", Zeropage, 5), 0xE7: un("ISC", Zeropage, 5),
+	0xE8: op("INX", Implied, 2), 0xE9: op("SBC", Immediate, 2), 0xEA: op("NOP", Implied, 2), 0xEB: un("USBC", Immediate, 2),
+	0xEC: op("CPX", Absolute, 4), 0xED: op("SBC", Absolute, 4), 0xEE: op("INC", Absolute, 6), 0xEF: un("ISC", Absolute, 6),
+
+	0xF0: op("BEQ", Relative, 2), 0xF1: op("SBC", IndirectY, 5), 0xF2: un("JAM", Implied, 0), 0xF3: un("ISC", IndirectY, 8),
+	0xF4: un("NOP", ZeropageX, 4), 0xF5: op("SBC", ZeropageX, 4), 0xF6: op("INC", ZeropageX, 6), 0xF7: un("ISC", ZeropageX, 6),
+	0xF8: op("SED", Implied, 2), 0xF9: op("SBC", AbsoluteY, 4), 0xFA: un("NOP", Implied, 2), 0xFB: un("ISC", AbsoluteY, 7),
+	0xFC: un("NOP", AbsoluteX, 4), 0xFD: op("SBC", AbsoluteX, 4), 0xFE: op("INC", AbsoluteX, 7), 0xFF: un("ISC", AbsoluteX, 7),
+}
+
+// ---------------------------- WDC 65C02 Table ----------------------------- //
+
+var table65C02 = build65C02()
+
+// The 65C02 keeps every documented NMOS opcode, replaces the illegal ones with
+// new instructions and turns the remaining holes into NOPs of various sizes
+func build65C02() [256]Instruction {
+
+	var t [256]Instruction
+
+	for i, ins := range table6502 {
+		if ins.Undocumented {
+			// Columns 3 and B are single byte, single cycle NOPs
+			t[i] = un("NOP", Implied, 1)
+		} else {
+			t[i] = ins
+		}
+	}
+
+	// Reserved opcodes that consume operands
+	for _, opc := range []byte{0x02, 0x22, 0x42, 0x62, 0x82, 0xC2, 0xE2} {
+		t[opc] = un("NOP", Immediate, 2)
+	}
+	t[0x44] = un("NOP", Zeropage, 3)
+	t[0x54] = un("NOP", ZeropageX, 4)
+	t[0xD4] = un("NOP", ZeropageX, 4)
+	t[0xF4] = un("NOP", ZeropageX, 4)
+	t[0x5C] = un("NOP", Absolute, 8)
+	t[0xDC] = un("NOP", Absolute, 4)
+	t[0xFC] = un("NOP", Absolute, 4)
+
+	// New instructions
+	t[0x04] = op("TSB", Zeropage, 5)
+	t[0x0C] = op("TSB", Absolute, 6)
+	t[0x14] = op("TRB", Zeropage, 5)
+	t[0x1C] = op("TRB", Absolute, 6)
+	t[0x1A] = op("INC", Accumulator, 2)
+	t[0x3A] = op("DEC", Accumulator, 2)
+	t[0x34] = op("BIT", ZeropageX, 4)
+	t[0x3C] = op("BIT", AbsoluteX, 4)
+	t[0x89] = op("BIT", Immediate, 2)
+	t[0x64] = op("STZ", Zeropage, 3)
+	t[0x74] = op("STZ", ZeropageX, 4)
+	t[0x9C] = op("STZ", Absolute, 4)
+	t[0x9E] = op("STZ", AbsoluteX, 5)
+	t[0x80] = op("BRA", Relative, 3)
+	t[0x5A] = op("PHY", Implied, 3)
+	t[0x7A] = op("PLY", Implied, 4)
+	t[0xDA] = op("PHX", Implied, 3)
+	t[0xFA] = op("PLX", Implied, 4)
+	t[0xCB] = op("WAI", Implied, 3)
+	t[0xDB] = op("STP", Implied, 3)
+	t[0x6C] = op("JMP", Indirect, 6)
+	t[0x7C] = op("JMP", AbsoluteIndirectX, 6)
+
+	// (zp) addressing mode
+	t[0x12] = op("ORA", ZeropageIndirect, 5)
+	t[0x32] = op("AND", ZeropageIndirect, 5)
+	t[0x52] = op("EOR", ZeropageIndirect, 5)
+	t[0x72] = op("ADC", ZeropageIndirect, 5)
+	t[0x92] = op("STA", ZeropageIndirect, 5)
+	t[0xB2] = op("LDA", ZeropageIndirect, 5)
+	t[0xD2] = op("CMP", ZeropageIndirect, 5)
+	t[0xF2] = op("SBC", ZeropageIndirect, 5)
+
+	// Rockwell / WDC bit instructions (RMBn, SMBn, BBRn, BBSn)
+	for bit := 0; bit < 8; bit++ {
+		n := string(rune('0' + bit))
+		t[bit<<4|0x07] = op("RMB"+n, Zeropage, 5)
+		t[0x80+bit<<4|0x07] = op("SMB"+n, Zeropage, 5)
+		t[bit<<4|0x0F] = op("BBR"+n, ZeropageRelative, 5)
+		t[0x80+bit<<4|0x0F] = op("BBS"+n, ZeropageRelative, 5)
+	}
+
+	return t
+}
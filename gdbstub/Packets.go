@@ -0,0 +1,179 @@
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// --------------------------- Packet framing ---------------------------- //
+
+// conn frames the RSP packets: $<data>#<checksum>, acknowledged with + or -
+// A reader goroutine delivers packets and interrupt requests (Ctrl-C, 0x03)
+type conn struct {
+	rw io.ReadWriter
+
+	write_mutex sync.Mutex
+	last        []byte      // Last packet sent, retransmitted on -
+	no_ack      atomic.Bool // QStartNoAckMode
+
+	packets    chan string
+	interrupts chan struct{}
+	done       chan struct{} // Closed when the session ends
+	err        error         // Read error that closed the packets channel
+}
+
+func newConn(rw io.ReadWriter) *conn {
+
+	c := &conn{
+		rw:         rw,
+		packets:    make(chan string),
+		interrupts: make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	go c.readLoop()
+
+	return c
+}
+
+func (c *conn) readLoop() {
+
+	r := bufio.NewReader(c.rw)
+
+	defer close(c.packets)
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		switch b {
+
+		case 0x03: // Interrupt the running target
+			select {
+			case c.interrupts <- struct{}{}:
+			default:
+			}
+
+		case '-': // Retransmission request
+			c.write_mutex.Lock()
+			if c.last != nil {
+				_, _ = c.rw.Write(c.last)
+			}
+			c.write_mutex.Unlock()
+
+		case '$':
+			data, err := r.ReadBytes('#')
+			if err != nil {
+				c.err = err
+				return
+			}
+			data = data[:len(data)-1]
+
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				c.err = err
+				return
+			}
+
+			if !c.no_ack.Load() {
+				var expected [1]byte
+				_, err := hex.Decode(expected[:], sum[:])
+
+				c.write_mutex.Lock()
+				if err != nil || expected[0] != checksum(data) {
+					_, _ = c.rw.Write([]byte{'-'})
+					c.write_mutex.Unlock()
+					continue
+				}
+				_, _ = c.rw.Write([]byte{'+'})
+				c.write_mutex.Unlock()
+			}
+
+			select {
+			case c.packets <- string(data):
+			case <-c.done:
+				return
+			}
+
+		default: // '+' and noise between packets
+		}
+	}
+}
+
+// Stop delivering packets, the reader exits with the connection
+func (c *conn) close() {
+	close(c.done)
+}
+
+// Send a packet, escaping the characters reserved by the protocol
+func (c *conn) send(data string) error {
+
+	packet := make([]byte, 0, len(data)+4)
+	packet = append(packet, '$')
+
+	for i := 0; i < len(data); i++ {
+		switch b := data[i]; b {
+		case '$', '#', '}', '*':
+			packet = append(packet, '}', b^0x20)
+		default:
+			packet = append(packet, b)
+		}
+	}
+
+	sum := checksum(packet[1:])
+	packet = append(packet, '#')
+	packet = append(packet, fmt.Sprintf("%02x", sum)...)
+
+	c.write_mutex.Lock()
+	defer c.write_mutex.Unlock()
+
+	c.last = packet
+	_, err := c.rw.Write(packet)
+
+	return err
+}
+
+// Take a pending interrupt request, if any
+func (c *conn) interrupted() bool {
+	select {
+	case <-c.interrupts:
+		return true
+	default:
+		return false
+	}
+}
+
+func checksum(data []byte) byte {
+
+	var sum byte
+
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
+
+// Decode the binary data of X packets (} escapes the next byte xor 0x20)
+func unescape(data string) []byte {
+
+	out := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^0x20)
+			continue
+		}
+		out = append(out, data[i])
+	}
+
+	return out
+}
@@ -0,0 +1,591 @@
+// Package gdbstub exposes the CPU to GDB Remote Serial Protocol front-ends
+// over TCP: register and memory access, single step, continue, software /
+// hardware breakpoints, read / write / access watchpoints and, when the
+// debugger history is enabled, reverse step and continue.
+//
+// Register numbers: 0 A, 1 X, 2 Y, 3 SP, 4 PC (16 bits, little endian), 5 P.
+// The layout is also described by the target.xml served with qXfer.
+package gdbstub
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+)
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.6502.core">
+    <reg name="a" bitsize="8" regnum="0" type="uint8" group="general"/>
+    <reg name="x" bitsize="8" regnum="1" type="uint8" group="general"/>
+    <reg name="y" bitsize="8" regnum="2" type="uint8" group="general"/>
+    <reg name="sp" bitsize="8" regnum="3" type="uint8" group="general"/>
+    <reg name="pc" bitsize="16" regnum="4" type="code_ptr" group="general"/>
+    <reg name="p" bitsize="8" regnum="5" type="uint8" group="general"/>
+  </feature>
+</target>
+`
+
+// Cycles executed between checks for a Ctrl-C from the front-end
+const runSlice = 100000
+
+// Packet size announced with qSupported (PacketSize), the memory reads
+// return at most half of it in bytes (two hex digits each)
+const packetSize = 0x4000
+
+// Server answers RSP requests using a debugger attached to the CPU
+type Server struct {
+	dbg *debugger.Debugger
+
+	// Optional log of the packets exchanged
+	Log io.Writer
+}
+
+// NewServer creates a server driving the CPU through dbg
+func NewServer(dbg *debugger.Debugger) *Server {
+	return &Server{dbg: dbg}
+}
+
+// ListenAndServe accepts front-end connections on addr (e.g. "localhost:2159"), one at a time
+func (s *Server) ListenAndServe(addr string) error {
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		err = s.Serve(c)
+		c.Close()
+
+		if err != nil && s.Log != nil {
+			fmt.Fprintln(s.Log, "gdbstub:", err)
+		}
+	}
+}
+
+// Point type of Z / z packets and its address range
+type pointKey struct {
+	typ  byte
+	addr uint16
+	size uint16
+}
+
+type session struct {
+	dbg    *debugger.Debugger
+	c      *conn
+	points map[pointKey]*debugger.Breakpoint
+	hw     map[*debugger.Breakpoint]bool // Breakpoints created with Z1
+	done   bool
+}
+
+// Serve handles one front-end connection until it detaches or disconnects
+// Breakpoints created by the front-end are removed at the end of the session
+func (s *Server) Serve(rw io.ReadWriter) error {
+
+	ss := &session{
+		dbg:    s.dbg,
+		c:      newConn(rw),
+		points: map[pointKey]*debugger.Breakpoint{},
+		hw:     map[*debugger.Breakpoint]bool{},
+	}
+
+	defer func() {
+		ss.c.close()
+		for _, b := range ss.points {
+			_ = ss.dbg.Delete(b.ID)
+		}
+	}()
+
+	for packet := range ss.c.packets {
+
+		s.logf("<- %s", packet)
+
+		reply := ss.handle(packet)
+
+		// Kill has no reply
+		if packet == "k" {
+			return nil
+		}
+
+		s.logf("-> %s", reply)
+
+		if err := ss.c.send(reply); err != nil {
+			return err
+		}
+
+		if ss.done {
+			return nil
+		}
+	}
+
+	if ss.c.err == io.EOF {
+		return nil
+	}
+
+	return ss.c.err
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
+
+// ------------------------------- Commands --------------------------------- //
+
+func (ss *session) handle(packet string) string {
+
+	if packet == "" {
+		return ""
+	}
+
+	args := packet[1:]
+
+	switch packet[0] {
+
+	case '?':
+		return "S05"
+
+	case 'g':
+		r := CPU_6502.GetRegisters()
+		return hex.EncodeToString([]byte{r.A, r.X, r.Y, r.SP, byte(r.PC), byte(r.PC >> 8), r.P})
+
+	case 'G':
+		data, err := hex.DecodeString(args)
+		if err != nil || len(data) < 7 {
+			return "E01"
+		}
+		CPU_6502.SetRegisters(CPU_6502.Registers{
+			A: data[0], X: data[1], Y: data[2], SP: data[3],
+			PC: uint16(data[4]) | uint16(data[5])<<8,
+			P:  data[6],
+		})
+		return "OK"
+
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 8)
+		if err != nil {
+			return "E01"
+		}
+		value, ok := readRegister(int(n))
+		if !ok {
+			return "E01"
+		}
+		return value
+
+	case 'P':
+		parts := strings.SplitN(args, "=", 2)
+		if len(parts) != 2 {
+			return "E01"
+		}
+		n, err := strconv.ParseUint(parts[0], 16, 8)
+		if err != nil {
+			return "E01"
+		}
+		data, err := hex.DecodeString(parts[1])
+		if err != nil || !writeRegister(int(n), data) {
+			return "E01"
+		}
+		return "OK"
+
+	case 'm':
+		addr, length, err := parseAddrLength(args)
+		if err != nil {
+			return "E01"
+		}
+		// Partial reads are allowed: up to the packet size and the end of the memory
+		if length > packetSize/2 {
+			length = packetSize / 2
+		}
+		if addr+length > 0x10000 {
+			length = 0x10000 - addr
+		}
+		data := make([]byte, length)
+		for i := range data {
+			data[i] = CPU_6502.ReadMemory(uint16(addr + i))
+		}
+		return hex.EncodeToString(data)
+
+	case 'M', 'X':
+		colon := strings.IndexByte(args, ':')
+		if colon < 0 {
+			return "E01"
+		}
+		addr, length, err := parseAddrLength(args[:colon])
+		if err != nil {
+			return "E01"
+		}
+
+		var data []byte
+		if packet[0] == 'M' {
+			data, err = hex.DecodeString(args[colon+1:])
+		} else {
+			data = unescape(args[colon+1:])
+		}
+		if err != nil || len(data) != length {
+			return "E01"
+		}
+
+		for i, b := range data {
+			CPU_6502.WriteMemory(uint16(addr+i), b)
+		}
+		return "OK"
+
+	case 's', 'c':
+		if args != "" {
+			addr, err := strconv.ParseUint(args, 16, 16)
+			if err != nil {
+				return "E01"
+			}
+			CPU_6502.PC = uint16(addr)
+		}
+		return ss.resume(packet[0] == 's')
+
+	case 'b': // Reverse execution (needs the debugger history)
+		if ss.dbg.History() == nil {
+			return "E01"
+		}
+		switch args {
+		case "s":
+			return ss.stopReply(ss.dbg.StepBack())
+		case "c":
+			return ss.stopReply(ss.dbg.ReverseContinue())
+		}
+		return ""
+
+	case 'v':
+		return ss.handleV(args)
+
+	case 'Z', 'z':
+		return ss.handlePoint(packet[0] == 'Z', args)
+
+	case 'q', 'Q':
+		return ss.handleQuery(packet)
+
+	case 'H', 'T':
+		// Single thread
+		return "OK"
+
+	case 'D':
+		ss.done = true
+		return "OK"
+
+	case 'k':
+		return ""
+	}
+
+	// Unsupported packet
+	return ""
+}
+
+func (ss *session) handleV(args string) string {
+
+	switch {
+	case args == "Cont?":
+		return "vCont;c;C;s;S"
+
+	case strings.HasPrefix(args, "Cont;"):
+		// Only the first action matters, there is a single thread
+		action := strings.SplitN(args[len("Cont;"):], ";", 2)[0]
+		if action == "" {
+			return "E01"
+		}
+		switch action[0] {
+		case 's', 'S':
+			return ss.resume(true)
+		case 'c', 'C':
+			return ss.resume(false)
+		}
+		return "E01"
+
+	case strings.HasPrefix(args, "MustReplyEmpty"):
+		return ""
+	}
+
+	return ""
+}
+
+func (ss *session) handleQuery(packet string) string {
+
+	switch {
+
+	case strings.HasPrefix(packet, "qSupported"):
+		features := fmt.Sprintf("PacketSize=%x;", packetSize) + "qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+"
+		if ss.dbg.History() != nil {
+			features += ";ReverseStep+;ReverseContinue+"
+		}
+		return features
+
+	case packet == "QStartNoAckMode":
+		ss.c.no_ack.Store(true)
+		return "OK"
+
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		offset, length, err := parseAddrLength(packet[len("qXfer:features:read:target.xml:"):])
+		if err != nil {
+			return "E01"
+		}
+		if offset >= len(targetXML) {
+			return "l"
+		}
+		end := offset + length
+		if end >= len(targetXML) {
+			return "l" + targetXML[offset:]
+		}
+		return "m" + targetXML[offset:end]
+
+	case packet == "qAttached":
+		return "1"
+
+	case packet == "qC":
+		return "QC1"
+
+	case packet == "qfThreadInfo":
+		return "m1"
+
+	case packet == "qsThreadInfo":
+		return "l"
+
+	case strings.HasPrefix(packet, "qRcmd,"):
+		cmd, err := hex.DecodeString(packet[len("qRcmd,"):])
+		if err != nil {
+			return "E01"
+		}
+		return ss.monitorCommand(strings.TrimSpace(string(cmd)))
+	}
+
+	return ""
+}
+
+// "monitor" commands typed in the front-end
+func (ss *session) monitorCommand(cmd string) string {
+
+	var out string
+
+	switch cmd {
+	case "reset":
+		CPU_6502.PC_as_argument = 0
+		CPU_6502.Reset()
+		out = fmt.Sprintf("reset, PC=$%04X\n", CPU_6502.PC)
+	case "breakpoints":
+		for _, b := range ss.dbg.Breakpoints() {
+			out += b.String() + "\n"
+		}
+	default:
+		out = "commands: reset, breakpoints\n"
+	}
+
+	return hex.EncodeToString([]byte(out))
+}
+
+// Z<type>,<addr>,<kind> inserts and z<type>,<addr>,<kind> removes
+// 0 software and 1 hardware breakpoints, 2 write, 3 read and 4 access watchpoints
+func (ss *session) handlePoint(insert bool, args string) string {
+
+	fields := strings.Split(args, ",")
+	if len(fields) < 3 || len(fields[0]) != 1 {
+		return "E01"
+	}
+
+	typ := fields[0][0]
+
+	addr, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	size, err := strconv.ParseUint(strings.SplitN(fields[2], ";", 2)[0], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+
+	key := pointKey{typ: typ, addr: uint16(addr), size: uint16(size)}
+
+	if !insert {
+		if b, ok := ss.points[key]; ok {
+			_ = ss.dbg.Delete(b.ID)
+			delete(ss.points, key)
+			delete(ss.hw, b)
+		}
+		return "OK"
+	}
+
+	if _, ok := ss.points[key]; ok {
+		return "OK"
+	}
+
+	var b *debugger.Breakpoint
+
+	switch typ {
+	case '0', '1':
+		b = ss.dbg.AddBreakpoint(uint16(addr))
+		ss.hw[b] = typ == '1'
+
+	case '2', '3', '4':
+		kind := map[byte]debugger.Kind{'2': debugger.Write, '3': debugger.Read, '4': debugger.Access}[typ]
+
+		end := addr
+		if size > 1 {
+			end = addr + size - 1
+		}
+		if end > 0xFFFF {
+			return "E01"
+		}
+
+		if b, err = ss.dbg.AddWatchpoint(kind, uint16(addr), uint16(end)); err != nil {
+			return "E01"
+		}
+
+	default:
+		return ""
+	}
+
+	ss.points[key] = b
+
+	return "OK"
+}
+
+// ------------------------------- Execution -------------------------------- //
+
+// Step or continue, returning the stop reply
+func (ss *session) resume(step bool) string {
+
+	if step {
+		return ss.stopReply(ss.dbg.Step())
+	}
+
+	// Run in slices to answer a Ctrl-C from the front-end
+	for {
+		s := ss.dbg.Continue(runSlice)
+
+		if s.Reason != debugger.ReasonLimit {
+			return ss.stopReply(s)
+		}
+
+		if ss.c.interrupted() {
+			return "S02" // SIGINT
+		}
+	}
+}
+
+func (ss *session) stopReply(s *debugger.Stop) string {
+
+	switch s.Reason {
+	case debugger.ReasonHistoryEnd:
+		return "T05replaylog:begin;"
+	case debugger.ReasonViolation:
+		return "S0B" // SIGSEGV
+	case debugger.ReasonHalted:
+		return "S04" // SIGILL
+	case debugger.ReasonBreakpoint:
+	default:
+		return "S05" // SIGTRAP
+	}
+
+	switch b := s.Breakpoint; b.Kind {
+	case debugger.Execute:
+		if ss.hw[b] {
+			return "T05hwbreak:;"
+		}
+		return "T05swbreak:;"
+	case debugger.Write:
+		return fmt.Sprintf("T05watch:%04x;", s.Address)
+	case debugger.Read:
+		return fmt.Sprintf("T05rwatch:%04x;", s.Address)
+	case debugger.Access:
+		return fmt.Sprintf("T05awatch:%04x;", s.Address)
+	}
+
+	return "S05"
+}
+
+// ------------------------------- Registers -------------------------------- //
+
+func readRegister(n int) (string, bool) {
+
+	r := CPU_6502.GetRegisters()
+
+	switch n {
+	case 0:
+		return fmt.Sprintf("%02x", r.A), true
+	case 1:
+		return fmt.Sprintf("%02x", r.X), true
+	case 2:
+		return fmt.Sprintf("%02x", r.Y), true
+	case 3:
+		return fmt.Sprintf("%02x", r.SP), true
+	case 4:
+		return hex.EncodeToString([]byte{byte(r.PC), byte(r.PC >> 8)}), true
+	case 5:
+		return fmt.Sprintf("%02x", r.P), true
+	}
+
+	return "", false
+}
+
+func writeRegister(n int, data []byte) bool {
+
+	r := CPU_6502.GetRegisters()
+
+	if len(data) == 0 || (n == 4 && len(data) < 2) {
+		return false
+	}
+
+	switch n {
+	case 0:
+		r.A = data[0]
+	case 1:
+		r.X = data[0]
+	case 2:
+		r.Y = data[0]
+	case 3:
+		r.SP = data[0]
+	case 4:
+		r.PC = uint16(data[0]) | uint16(data[1])<<8
+	case 5:
+		r.P = data[0]
+	default:
+		return false
+	}
+
+	CPU_6502.SetRegisters(r)
+
+	return true
+}
+
+// Parse "<addr>,<length>" (hexadecimal)
+func parseAddrLength(args string) (int, int, error) {
+
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected addr,length")
+	}
+
+	addr, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if addr > 0xFFFF || length > 0x10000 {
+		return 0, 0, fmt.Errorf("out of range")
+	}
+
+	return int(addr), int(length), nil
+}
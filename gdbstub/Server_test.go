@@ -0,0 +1,179 @@
+package gdbstub
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/debugger"
+)
+
+func testSession(t *testing.T) *session {
+
+	dbg := debugger.New()
+	t.Cleanup(dbg.Close)
+
+	return &session{
+		dbg:    dbg,
+		c:      &conn{interrupts: make(chan struct{}, 1)},
+		points: map[pointKey]*debugger.Breakpoint{},
+		hw:     map[*debugger.Breakpoint]bool{},
+	}
+}
+
+// Sends the packets and checks the replies, with the acknowledgements
+func TestFraming(t *testing.T) {
+
+	dbg := debugger.New()
+	defer dbg.Close()
+
+	host, client := net.Pipe()
+	defer client.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(dbg).Serve(host)
+		host.Close()
+	}()
+
+	r := bufio.NewReader(client)
+
+	for _, test := range []struct {
+		send  string
+		reply string
+	}{
+		{"$?#3f", "+$S05#b8"},
+		{"$?#00", "-"},             // Bad checksum
+		{"-", "$S05#b8"},           // Retransmission
+		{"+\x03$?#3f", "+$S05#b8"}, // Acknowledgement and interrupt between packets
+		{"$D#44", "+$OK#9a"},
+	} {
+		if _, err := client.Write([]byte(test.send)); err != nil {
+			t.Fatal(err)
+		}
+		reply := make([]byte, len(test.reply))
+		if _, err := io.ReadFull(r, reply); err != nil {
+			t.Fatal(err)
+		}
+		if string(reply) != test.reply {
+			t.Errorf("%q: reply %q, want %q", test.send, reply, test.reply)
+		}
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+// Reserved characters are escaped in the replies and the X packets
+func TestEscape(t *testing.T) {
+
+	var buf bytes.Buffer
+	c := &conn{rw: &buf}
+
+	if err := c.send("a}b#$*"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "$a}]b}\x03}\x04}\x0a#25"; buf.String() != want {
+		t.Errorf("packet %q, want %q", buf.String(), want)
+	}
+
+	if got := unescape("a}]b}\x03"); string(got) != "a}b#" {
+		t.Errorf("unescape %q", got)
+	}
+}
+
+// Registers are read and written as a block and one at a time
+func TestRegisters(t *testing.T) {
+
+	ss := testSession(t)
+
+	if reply := ss.handle("G0102030405060780"); reply != "OK" {
+		t.Fatalf("G: %q", reply)
+	}
+	if r := CPU_6502.GetRegisters(); r.A != 1 || r.X != 2 || r.Y != 3 || r.SP != 4 || r.PC != 0x0605 {
+		t.Errorf("registers after G: %+v", r)
+	}
+
+	if reply := ss.handle("P4=3412"); reply != "OK" || CPU_6502.PC != 0x1234 {
+		t.Errorf("P4: %q, PC $%04X", reply, CPU_6502.PC)
+	}
+	for packet, want := range map[string]string{"p0": "01", "p4": "3412", "p6": "E01", "P4=12": "E01", "G00": "E01"} {
+		if reply := ss.handle(packet); reply != want {
+			t.Errorf("%s: %q, want %q", packet, reply, want)
+		}
+	}
+}
+
+// Breakpoints and watchpoints inserted with Z and the stop replies
+func TestPoints(t *testing.T) {
+
+	ss := testSession(t)
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+	copy(CPU_6502.Memory[0x0200:], []byte{
+		0xA9, 0x01, // $0200 LDA #1
+		0x8D, 0x00, 0x03, // $0202 STA $0300
+		0xEA,             // $0205 NOP
+		0x4C, 0x06, 0x02, // $0206 JMP $0206
+	})
+	CPU_6502.PC = 0x0200
+
+	for _, test := range []struct {
+		packet string
+		reply  string
+		pc     uint16
+	}{
+		{"Z2,300,1", "OK", 0x0200},
+		{"c", "T05watch:0300;", 0x0205},
+		{"z2,300,1", "OK", 0x0205},
+		{"Z1,206,1", "OK", 0x0205},
+		{"s", "S05", 0x0206},
+		{"z1,206,1", "OK", 0x0206},
+		{"Z0,205,1", "OK", 0x0206},
+		{"c200", "T05swbreak:;", 0x0205},
+		{"Z5,200,1", "", 0x0205},
+		{"Z2,ffff,2", "E01", 0x0205},
+	} {
+		if reply := ss.handle(test.packet); reply != test.reply || CPU_6502.PC != test.pc {
+			t.Errorf("%s: %q at $%04X, want %q at $%04X", test.packet, reply, CPU_6502.PC, test.reply, test.pc)
+		}
+	}
+
+	if len(ss.points) != 1 || len(ss.dbg.Breakpoints()) != 1 {
+		t.Errorf("%d points and %d breakpoints, want 1", len(ss.points), len(ss.dbg.Breakpoints()))
+	}
+}
+
+// Memory reads are cut to the packet size and the end of the memory
+func TestReadMemoryLength(t *testing.T) {
+
+	ss := testSession(t)
+	CPU_6502.Memory[0xFFFF] = 0xAB
+
+	for _, test := range []struct {
+		packet string
+		length int // Bytes in the reply
+	}{
+		{"m200,10", 0x10},
+		{"m0,2000", packetSize / 2},
+		{"m0,10000", packetSize / 2},
+		{"mfff0,100", 0x10},
+	} {
+		if reply := ss.handle(test.packet); len(reply) != 2*test.length {
+			t.Errorf("%s: %d bytes, want %d", test.packet, len(reply)/2, test.length)
+		}
+	}
+
+	if reply := ss.handle("mffff,1"); reply != "ab" {
+		t.Errorf("mffff,1: %q, want \"ab\"", reply)
+	}
+	if reply := ss.handle("m10000,1"); reply != "E01" {
+		t.Errorf("m10000,1: %q, want E01", reply)
+	}
+}
@@ -0,0 +1,301 @@
+package mappers
+
+import (
+	"fmt"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// ------------------------------- Atari 2600 ------------------------------- //
+//
+// The 6507 has 13 address lines: the cartridge answers when A12 is set
+// ($1000-$1FFF), repeated every 8KB up to $F000-$FFFF. The windows are copied
+// to all the mirrors and the hotspots are matched on the 13 bits address, so
+// code assembled at $1000 or $F000 works the same way.
+
+var atari_mirrors = []uint16{0x0000, 0x2000, 0x4000, 0x6000, 0x8000, 0xA000, 0xC000, 0xE000}
+
+// Address as seen by the cartridge
+func atariAddress(addr uint16) uint16 {
+	return addr & 0x1FFF
+}
+
+// Cartridge space (ROM)
+func atariROM(addr uint16) bool {
+	return addr&0x1000 != 0
+}
+
+// --------------------------------- 2K / 4K -------------------------------- //
+
+// Standard is a 2K or 4K cartridge without bank switching (2K is repeated in the 4K space)
+type Standard struct {
+	windows
+}
+
+// NewStandard creates a 2K or 4K cartridge
+func NewStandard(rom []byte) (*Standard, error) {
+
+	switch len(rom) {
+	case 2048:
+		return &Standard{newWindows(rom, 2048, atari_mirrors, 0x1000, 0x1800)}, nil
+	case 4096:
+		return &Standard{newWindows(rom, 4096, atari_mirrors, 0x1000)}, nil
+	}
+
+	return nil, fmt.Errorf("standard cartridges have 2KB or 4KB, got %d bytes", len(rom))
+}
+
+func (m *Standard) Name() string {
+	return fmt.Sprintf("%dK", len(m.rom)/1024)
+}
+
+func (m *Standard) Reset() {
+	for i := range m.bank {
+		m.set(i, 0)
+	}
+}
+
+func (m *Standard) Read(addr uint16, value byte) {}
+
+func (m *Standard) Write(addr uint16, value byte) bool {
+	return atariROM(addr)
+}
+
+func (m *Standard) Save() []byte {
+	return m.save()
+}
+
+func (m *Standard) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// ------------------------------- F8 / F6 / F4 ----------------------------- //
+
+// Hotspot switches 4K banks on any access to consecutive hotspots:
+// F8 8K $1FF8-$1FF9, F6 16K $1FF6-$1FF9, F4 32K $1FF4-$1FFB
+// The last bank is mapped on power-on
+type Hotspot struct {
+	windows
+	name  string
+	first uint16 // First hotspot
+}
+
+func newHotspot(name string, rom []byte, banks int, first uint16) (*Hotspot, error) {
+
+	if err := checkSize(name, rom, 4096, banks, banks); err != nil {
+		return nil, err
+	}
+
+	return &Hotspot{newWindows(rom, 4096, atari_mirrors, 0x1000), name, first}, nil
+}
+
+// NewF8 creates an Atari 8K cartridge
+func NewF8(rom []byte) (*Hotspot, error) {
+	return newHotspot("F8", rom, 2, 0x1FF8)
+}
+
+// NewF6 creates an Atari 16K cartridge
+func NewF6(rom []byte) (*Hotspot, error) {
+	return newHotspot("F6", rom, 4, 0x1FF6)
+}
+
+// NewF4 creates an Atari 32K cartridge
+func NewF4(rom []byte) (*Hotspot, error) {
+	return newHotspot("F4", rom, 8, 0x1FF4)
+}
+
+func (m *Hotspot) Name() string {
+	return m.name
+}
+
+func (m *Hotspot) Reset() {
+	m.set(0, m.count()-1)
+}
+
+func (m *Hotspot) access(addr uint16) {
+	if a := atariAddress(addr); a >= m.first && int(a-m.first) < m.count() {
+		m.set(0, int(a-m.first))
+	}
+}
+
+func (m *Hotspot) Read(addr uint16, value byte) {
+	m.access(addr)
+}
+
+func (m *Hotspot) Write(addr uint16, value byte) bool {
+	m.access(addr)
+	return atariROM(addr)
+}
+
+func (m *Hotspot) Save() []byte {
+	return m.save()
+}
+
+func (m *Hotspot) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// ----------------------------------- E0 ----------------------------------- //
+
+// E0 is the Parker Brothers 8K cartridge: eight 1K slices, $1000, $1400 and
+// $1800 select them with the hotspots $1FE0-$1FE7, $1FE8-$1FEF and $1FF0-$1FF7,
+// $1C00 is fixed to the last slice. Slices 4, 5, 6 and 7 are mapped on power-on
+type E0 struct {
+	windows
+}
+
+// NewE0 creates a Parker Brothers 8K cartridge
+func NewE0(rom []byte) (*E0, error) {
+
+	if err := checkSize("E0", rom, 1024, 8, 8); err != nil {
+		return nil, err
+	}
+
+	return &E0{newWindows(rom, 1024, atari_mirrors, 0x1000, 0x1400, 0x1800, 0x1C00)}, nil
+}
+
+func (m *E0) Name() string {
+	return "E0"
+}
+
+func (m *E0) Reset() {
+	for i := range m.bank {
+		m.set(i, 4+i)
+	}
+}
+
+func (m *E0) access(addr uint16) {
+	if a := atariAddress(addr); a >= 0x1FE0 && a <= 0x1FF7 {
+		m.set(int(a-0x1FE0)/8, int(a&7))
+	}
+}
+
+func (m *E0) Read(addr uint16, value byte) {
+	m.access(addr)
+}
+
+func (m *E0) Write(addr uint16, value byte) bool {
+	m.access(addr)
+	return atariROM(addr)
+}
+
+func (m *E0) Save() []byte {
+	return m.save()
+}
+
+func (m *E0) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// ----------------------------------- 3F ----------------------------------- //
+
+// Tigervision selects the 2K bank at $1000 with writes to $00-$3F (also seen
+// by the TIA), $1800 is fixed to the last bank. Up to 512K (256 banks)
+type Tigervision struct {
+	windows
+}
+
+// New3F creates a Tigervision cartridge
+func New3F(rom []byte) (*Tigervision, error) {
+
+	if err := checkSize("3F", rom, 2048, 2, 256); err != nil {
+		return nil, err
+	}
+
+	return &Tigervision{newWindows(rom, 2048, atari_mirrors, 0x1000, 0x1800)}, nil
+}
+
+func (m *Tigervision) Name() string {
+	return "3F"
+}
+
+func (m *Tigervision) Reset() {
+	m.set(0, 0)
+	m.set(1, m.count()-1)
+}
+
+func (m *Tigervision) Read(addr uint16, value byte) {}
+
+func (m *Tigervision) Write(addr uint16, value byte) bool {
+
+	if atariAddress(addr) <= 0x3F {
+		m.set(0, int(value))
+	}
+
+	return atariROM(addr)
+}
+
+func (m *Tigervision) Save() []byte {
+	return m.save()
+}
+
+func (m *Tigervision) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// ----------------------------------- FE ----------------------------------- //
+
+// Activision switches its two 4K banks on JSR and RTS: the cartridge watches
+// the access to the stack at $01FE and takes bit 5 of the next byte on the
+// data BUS, the high byte of the new PC (set: bank 0 at $F000, clear: bank 1
+// at $D000). Bank 0 is mapped on power-on
+type Activision struct {
+	windows
+}
+
+// NewFE creates an Activision 8K cartridge
+func NewFE(rom []byte) (*Activision, error) {
+
+	if err := checkSize("FE", rom, 4096, 2, 2); err != nil {
+		return nil, err
+	}
+
+	return &Activision{newWindows(rom, 4096, atari_mirrors, 0x1000)}, nil
+}
+
+func (m *Activision) Name() string {
+	return "FE"
+}
+
+func (m *Activision) Reset() {
+	m.set(0, 0)
+}
+
+// Bank from the high byte of the new PC
+func (m *Activision) selectBank(high byte) {
+	if high&0x20 != 0 {
+		m.set(0, 0)
+	} else {
+		m.set(0, 1)
+	}
+}
+
+// RTS: the byte after $01FE on the stack is the high byte of the return address
+func (m *Activision) Read(addr uint16, value byte) {
+	if atariAddress(addr) == 0x01FE && CPU_6502.Memory[CPU_6502.PC] == 0x60 {
+		m.selectBank(CPU_6502.Memory[addr+1])
+	}
+}
+
+// JSR: the core pushes the return address before fetching the high byte of the target
+func (m *Activision) Write(addr uint16, value byte) bool {
+
+	if atariAddress(addr) == 0x01FE && CPU_6502.Memory[CPU_6502.PC] == 0x20 {
+		m.selectBank(CPU_6502.Memory[CPU_6502.PC+2])
+	}
+
+	return atariROM(addr)
+}
+
+func (m *Activision) Save() []byte {
+	return m.save()
+}
+
+func (m *Activision) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
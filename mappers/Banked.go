@@ -0,0 +1,83 @@
+package mappers
+
+import "fmt"
+
+// ------------------------------ Generic Banks ----------------------------- //
+
+// Window of a Banked mapper
+type Window struct {
+	Address  uint16 // Start of the window
+	Bank     int    // Power-on bank
+	Register uint16 // Writes to this address select the bank (value modulo the number of banks)
+	Fixed    bool   // No register, always Bank
+}
+
+// Banked maps a ROM split in banks of the same size (e.g. 16K or 8K) to
+// windows with a select register each. Writes inside the windows are consumed
+// (ROM), writes to registers outside them also reach the memory.
+type Banked struct {
+	windows
+	config []Window
+}
+
+// NewBanked creates a generic mapper with banks of size bytes
+func NewBanked(rom []byte, size int, config []Window) (*Banked, error) {
+
+	if size <= 0 || size > 0x10000 || len(rom) == 0 || len(rom)%size != 0 {
+		return nil, fmt.Errorf("ROM of %d bytes isn't a multiple of the %d bytes banks", len(rom), size)
+	}
+	if len(config) == 0 {
+		return nil, fmt.Errorf("no windows")
+	}
+
+	address := make([]uint16, len(config))
+
+	for i, w := range config {
+		if int(w.Address)+size > 0x10000 {
+			return nil, fmt.Errorf("window at $%04X ends outside the 64KB address space", w.Address)
+		}
+		if w.Bank < 0 || w.Bank >= len(rom)/size {
+			return nil, fmt.Errorf("window at $%04X: power-on bank %d outside the %d banks", w.Address, w.Bank, len(rom)/size)
+		}
+		address[i] = w.Address
+	}
+
+	return &Banked{newWindows(rom, size, []uint16{0}, address...), append([]Window(nil), config...)}, nil
+}
+
+func (m *Banked) Name() string {
+	return fmt.Sprintf("Banked %dK", m.size/1024)
+}
+
+func (m *Banked) Reset() {
+	for i, w := range m.config {
+		m.set(i, w.Bank)
+	}
+}
+
+func (m *Banked) Read(addr uint16, value byte) {}
+
+func (m *Banked) Write(addr uint16, value byte) bool {
+
+	rom := false
+
+	for i, w := range m.config {
+		if !w.Fixed && w.Register == addr {
+			m.set(i, int(value))
+		}
+		if addr >= w.Address && int(addr) < int(w.Address)+m.size {
+			rom = true
+		}
+	}
+
+	return rom
+}
+
+func (m *Banked) Save() []byte {
+	return m.save()
+}
+
+func (m *Banked) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
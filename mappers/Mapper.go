@@ -0,0 +1,199 @@
+// Package mappers implements cartridge and board bank switching on the data BUS.
+//
+// The switchable banks are copied into CPU_6502.Memory, so the core keeps its
+// flat memory: a bank switch costs a copy of the window, the other accesses
+// cost nothing. Writes to the ROM windows are consumed by the mappers.
+//
+//	Atari 2600   2K 4K F8 F6 F4 E0 3F FE (13 bits address bus, all mirrors)
+//	NES          NROM UxROM CNROM MMC1 (CPU side, $8000-$FFFF)
+//	Generic      Banked: windows of any bank size with a select register each
+//
+// Attach installs a mapper and adds its state to the snapshots:
+//
+//	m, err := mappers.NewF8(rom)
+//	mappers.Attach(m)
+//	CPU_6502.Reset()
+package mappers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Mapper is a bank switching scheme
+type Mapper interface {
+	CPU_6502.Mapper
+
+	Name() string
+	Reset()       // Power-on banks
+	Banks() []int // Current bank of each window
+
+	// Mapper state for the snapshots (banks and registers)
+	Save() []byte
+	Restore(data []byte) error
+}
+
+// Snapshot chunk of the attached mapper
+const snapshot_tag = "MAPR"
+
+var attached Mapper
+
+// Attach resets the mapper, installs it on the data BUS and adds its state to the snapshots
+func Attach(m Mapper) {
+
+	m.Reset()
+	attached = m
+	CPU_6502.SetMapper(m)
+
+	CPU_6502.RegisterSnapshotChunk(snapshot_tag, func() []byte {
+		name := m.Name()
+		return append(append([]byte{byte(len(name))}, name...), m.Save()...)
+	}, func(data []byte) error {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return fmt.Errorf("truncated mapper state")
+		}
+		if name := string(data[1 : 1+data[0]]); name != m.Name() {
+			return fmt.Errorf("snapshot of a %s mapper, attached %s", name, m.Name())
+		}
+		return m.Restore(data[1+data[0]:])
+	})
+}
+
+// Detach removes the attached mapper (the memory keeps the banks mapped)
+func Detach() {
+	if attached == nil {
+		return
+	}
+	attached = nil
+	CPU_6502.SetMapper(nil)
+	CPU_6502.UnregisterSnapshotChunk(snapshot_tag)
+}
+
+// Attached returns the attached mapper (nil if none)
+func Attached() Mapper {
+	return attached
+}
+
+// Constructors by scheme name
+var schemes = map[string]func(rom []byte) (Mapper, error){
+	"2K":    func(rom []byte) (Mapper, error) { return NewStandard(rom) },
+	"4K":    func(rom []byte) (Mapper, error) { return NewStandard(rom) },
+	"F8":    func(rom []byte) (Mapper, error) { return NewF8(rom) },
+	"F6":    func(rom []byte) (Mapper, error) { return NewF6(rom) },
+	"F4":    func(rom []byte) (Mapper, error) { return NewF4(rom) },
+	"E0":    func(rom []byte) (Mapper, error) { return NewE0(rom) },
+	"3F":    func(rom []byte) (Mapper, error) { return New3F(rom) },
+	"FE":    func(rom []byte) (Mapper, error) { return NewFE(rom) },
+	"NROM":  func(rom []byte) (Mapper, error) { return NewNROM(rom) },
+	"UXROM": func(rom []byte) (Mapper, error) { return NewUxROM(rom) },
+	"CNROM": func(rom []byte) (Mapper, error) { return NewCNROM(rom) },
+	"MMC1":  func(rom []byte) (Mapper, error) { return NewMMC1(rom) },
+}
+
+// New creates a mapper by scheme name (case insensitive), see Schemes
+func New(scheme string, rom []byte) (Mapper, error) {
+
+	create, ok := schemes[strings.ToUpper(scheme)]
+	if !ok {
+		return nil, fmt.Errorf("unknown mapper %q (%s)", scheme, strings.Join(Schemes(), " "))
+	}
+
+	return create(rom)
+}
+
+// Schemes lists the names accepted by New
+func Schemes() []string {
+
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// --------------------------------- Windows -------------------------------- //
+
+// ROM banks copied to address windows of the memory
+type windows struct {
+	rom     []byte
+	size    int      // Bank size
+	address []uint16 // Start of each window
+	mirrors []uint16 // Offsets where each window is repeated
+	bank    []int    // Current bank of each window
+}
+
+func newWindows(rom []byte, size int, mirrors []uint16, address ...uint16) windows {
+	return windows{rom: rom, size: size, address: address, mirrors: mirrors, bank: make([]int, len(address))}
+}
+
+// Number of banks in the ROM
+func (w *windows) count() int {
+	return len(w.rom) / w.size
+}
+
+// Map a bank (modulo the number of banks) in a window
+func (w *windows) set(window int, bank int) {
+
+	bank %= w.count()
+	w.bank[window] = bank
+
+	data := w.rom[bank*w.size : (bank+1)*w.size]
+
+	for _, m := range w.mirrors {
+		copy(CPU_6502.Memory[w.address[window]+m:], data)
+	}
+}
+
+func (w *windows) Banks() []int {
+	return append([]int(nil), w.bank...)
+}
+
+// Banks followed by the mapper registers
+func (w *windows) save(registers ...byte) []byte {
+
+	data := make([]byte, 2*len(w.bank), 2*len(w.bank)+len(registers))
+	for i, b := range w.bank {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(b))
+	}
+
+	return append(data, registers...)
+}
+
+// Validate and map the saved banks, returns the registers
+func (w *windows) restore(data []byte, registers int) ([]byte, error) {
+
+	if len(data) != 2*len(w.bank)+registers {
+		return nil, fmt.Errorf("invalid mapper state size %d", len(data))
+	}
+
+	for i := range w.bank {
+		if int(binary.LittleEndian.Uint16(data[2*i:])) >= w.count() {
+			return nil, fmt.Errorf("bank %d outside the %d banks of the ROM", binary.LittleEndian.Uint16(data[2*i:]), w.count())
+		}
+	}
+
+	for i := range w.bank {
+		w.set(i, int(binary.LittleEndian.Uint16(data[2*i:])))
+	}
+
+	return data[2*len(w.bank):], nil
+}
+
+// Check the ROM size is a multiple of the bank size, within a range of banks
+func checkSize(name string, rom []byte, size int, min, max int) error {
+
+	if len(rom) == 0 || len(rom)%size != 0 || len(rom)/size < min || len(rom)/size > max {
+		if min == max {
+			return fmt.Errorf("%s needs a %dKB ROM, got %d bytes", name, min*size/1024, len(rom))
+		}
+		return fmt.Errorf("%s needs %d to %d banks of %dKB, got %d bytes", name, min, max, size/1024, len(rom))
+	}
+
+	return nil
+}
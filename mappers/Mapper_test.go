@@ -0,0 +1,283 @@
+package mappers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// ROM of banks filled with their number
+func testROM(banks, size int) []byte {
+
+	rom := make([]byte, banks*size)
+	for i := range rom {
+		rom[i] = byte(i / size)
+	}
+
+	return rom
+}
+
+func testBanks(t *testing.T, m Mapper, want ...int) {
+	t.Helper()
+	if banks := m.Banks(); !reflect.DeepEqual(banks, want) {
+		t.Errorf("%s banks %v, want %v", m.Name(), banks, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	if m, err := New("f6", testROM(4, 4096)); err != nil || m.Name() != "F6" {
+		t.Errorf("New F6: %v, %v", m, err)
+	}
+	if _, err := New("F9", nil); err == nil || !strings.Contains(err.Error(), "unknown mapper \"F9\" (2K 3F 4K CNROM E0 F4 F6 F8 FE MMC1 NROM UXROM)") {
+		t.Errorf("unknown mapper: %v", err)
+	}
+
+	for _, test := range []struct {
+		scheme string
+		rom    []byte
+		err    string
+	}{
+		{"4K", make([]byte, 1024), "standard cartridges have 2KB or 4KB, got 1024 bytes"},
+		{"F8", testROM(1, 4096), "F8 needs a 8KB ROM, got 4096 bytes"},
+		{"3F", testROM(1, 2048), "3F needs 2 to 256 banks of 2KB, got 2048 bytes"},
+		{"UxROM", make([]byte, 20000), "UxROM needs 2 to 256 banks of 16KB, got 20000 bytes"},
+	} {
+		if _, err := New(test.scheme, test.rom); err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %q", test.scheme, err, test.err)
+		}
+	}
+
+	for _, test := range []struct {
+		size   int
+		config []Window
+		err    string
+	}{
+		{3000, []Window{{Address: 0x8000}}, "isn't a multiple of the 3000 bytes banks"},
+		{8192, nil, "no windows"},
+		{8192, []Window{{Address: 0xF000}}, "window at $F000 ends outside the 64KB address space"},
+		{8192, []Window{{Address: 0x8000, Bank: 4}}, "power-on bank 4 outside the 4 banks"},
+	} {
+		if _, err := NewBanked(testROM(4, 8192), test.size, test.config); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("banked: error %v, want %q", err, test.err)
+		}
+	}
+}
+
+// Hotspots on reads and writes, matched on the 13 bits address, banks copied to all the mirrors
+func TestAtari(t *testing.T) {
+
+	CPU_6502.Initialize()
+
+	f6, _ := NewF6(testROM(4, 4096))
+	f6.Reset()
+	testBanks(t, f6, 3)
+
+	f6.Read(0xFFF7, 0)
+	testBanks(t, f6, 1)
+	if CPU_6502.Memory[0x1000] != 1 || CPU_6502.Memory[0xF000] != 1 || CPU_6502.Memory[0x5FFF] != 1 {
+		t.Errorf("bank 1 not mapped in the mirrors")
+	}
+	if !f6.Write(0x1FF6, 0) || f6.Write(0x0080, 0) {
+		t.Errorf("writes consumed outside the cartridge space")
+	}
+	f6.Read(0xFFFA, 0)
+	testBanks(t, f6, 0)
+
+	e0, _ := NewE0(testROM(8, 1024))
+	e0.Reset()
+	testBanks(t, e0, 4, 5, 6, 7)
+	e0.Read(0x1FE9, 0)
+	e0.Write(0xFFF2, 0)
+	testBanks(t, e0, 4, 1, 2, 7)
+	if CPU_6502.Memory[0xF400] != 1 || CPU_6502.Memory[0xF800] != 2 {
+		t.Errorf("slices $%02X $%02X, want 1 and 2", CPU_6502.Memory[0xF400], CPU_6502.Memory[0xF800])
+	}
+
+	tv, _ := New3F(testROM(8, 2048))
+	tv.Reset()
+	testBanks(t, tv, 0, 7)
+	if tv.Write(0x003F, 5) {
+		t.Errorf("TIA write consumed")
+	}
+	tv.Write(0x0040, 2)
+	testBanks(t, tv, 5, 7)
+	tv.Write(0x2000, 9)
+	testBanks(t, tv, 1, 7)
+}
+
+// Activision banks switched by the stack accesses of JSR and RTS
+func TestActivision(t *testing.T) {
+
+	rom := testROM(2, 4096)
+	copy(rom, []byte{0x20, 0x10, 0xD0}) // $F000 JSR $D010 (bank 1)
+	rom[4096+0x10] = 0x60               // $D010 RTS
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+
+	m, _ := NewFE(rom)
+	Attach(m)
+	t.Cleanup(Detach)
+
+	CPU_6502.PC = 0xF000
+	CPU_6502.SP = 0xFF
+
+	CPU_6502.Step()
+	testBanks(t, m, 1)
+	if CPU_6502.PC != 0xD010 || CPU_6502.Memory[0xD010] != 0x60 {
+		t.Errorf("JSR to PC $%04X, opcode $%02X", CPU_6502.PC, CPU_6502.Memory[0xD010])
+	}
+
+	CPU_6502.Step()
+	testBanks(t, m, 0)
+	if CPU_6502.PC != 0xF003 {
+		t.Errorf("RTS to PC $%04X", CPU_6502.PC)
+	}
+}
+
+func TestNES(t *testing.T) {
+
+	CPU_6502.Initialize()
+
+	ux, _ := NewUxROM(testROM(4, 16384))
+	ux.Reset()
+	testBanks(t, ux, 0, 3)
+	if !ux.Write(0x8000, 6) || ux.Write(0x6000, 1) {
+		t.Errorf("writes consumed outside the ROM")
+	}
+	testBanks(t, ux, 2, 3)
+	if CPU_6502.Memory[0x8000] != 2 || CPU_6502.Memory[0xFFFF] != 3 {
+		t.Errorf("banks $%02X $%02X, want 2 and 3", CPU_6502.Memory[0x8000], CPU_6502.Memory[0xFFFF])
+	}
+
+	nrom, _ := NewNROM(testROM(1, 16384))
+	nrom.Reset()
+	testBanks(t, nrom, 0, 0)
+
+	cn, _ := NewCNROM(testROM(2, 16384))
+	cn.Reset()
+	cn.Write(0x8000, 0xFF)
+	if testBanks(t, cn, 0, 1); cn.CHRBank() != 3 {
+		t.Errorf("CHR bank %d, want 3", cn.CHRBank())
+	}
+
+	// Serial writes, bit 0 first
+	mmc1, _ := NewMMC1(testROM(4, 16384))
+	mmc1.Reset()
+	testBanks(t, mmc1, 0, 3)
+
+	serial := func(addr uint16, value byte) {
+		for i := 0; i < 5; i++ {
+			mmc1.Write(addr, value>>i&1)
+		}
+	}
+
+	serial(0xE000, 2) // PRG bank 2
+	testBanks(t, mmc1, 2, 3)
+	serial(0x8000, 0x1A) // 4K CHR, first bank fixed, vertical
+	testBanks(t, mmc1, 0, 2)
+	serial(0xA000, 5)
+	serial(0xC000, 6)
+	if c0, c1 := mmc1.CHRBanks(); mmc1.Mirroring() != 2 || c0 != 5 || c1 != 6 {
+		t.Errorf("mirroring %d, CHR banks %d %d", mmc1.Mirroring(), c0, c1)
+	}
+	serial(0x8000, 0x00) // 32K
+	testBanks(t, mmc1, 2, 3)
+
+	mmc1.Write(0x8000, 1)
+	mmc1.Write(0x8000, 0x80) // Shift register reset, PRG mode 3
+	testBanks(t, mmc1, 2, 3)
+	serial(0xE000, 1)
+	testBanks(t, mmc1, 1, 3)
+
+	b, _ := NewBanked(testROM(4, 8192), 8192, []Window{
+		{Address: 0x8000, Register: 0x5000},
+		{Address: 0xE000, Bank: 3, Fixed: true},
+	})
+	b.Reset()
+	if b.Write(0x5000, 6) || !b.Write(0x8000, 1) || !b.Write(0xFFFF, 1) {
+		t.Errorf("banked writes consumed outside the windows")
+	}
+	testBanks(t, b, 2, 3)
+}
+
+// The banks and the registers are saved in the CPU snapshots
+func TestSnapshot(t *testing.T) {
+
+	CPU_6502.Initialize()
+
+	m, _ := NewMMC1(testROM(4, 16384))
+	Attach(m)
+	t.Cleanup(Detach)
+
+	for i := 0; i < 5; i++ {
+		m.Write(0xE000, 1&(1>>i))
+	}
+	m.Write(0x8000, 1) // Register loading in progress
+
+	snap := CPU_6502.Snapshot()
+
+	m.Reset()
+	if err := CPU_6502.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	testBanks(t, m, 1, 3)
+	if CPU_6502.Memory[0x8000] != 1 || !bytes.Equal(m.Save(), []byte{1, 0, 3, 0, 1, 1, 0x0C, 0, 0, 1}) {
+		t.Errorf("restored state % X", m.Save())
+	}
+
+	if err := m.Restore([]byte{9, 0, 3, 0, 0, 0, 0x0C, 0, 0, 0}); err == nil || err.Error() != "bank 9 outside the 4 banks of the ROM" {
+		t.Errorf("bank error %v", err)
+	}
+	if err := m.Restore([]byte{0, 0, 3, 0, 0, 5, 0x0C, 0, 0, 0}); err == nil || err.Error() != "invalid MMC1 shift count 5" {
+		t.Errorf("shift count error %v", err)
+	}
+	if err := m.Restore([]byte{0, 0}); err == nil || err.Error() != "invalid mapper state size 2" {
+		t.Errorf("size error %v", err)
+	}
+
+	// A snapshot of another mapper is rejected
+	Detach()
+	f8, _ := NewF8(testROM(2, 4096))
+	Attach(f8)
+	if err := CPU_6502.Restore(snap); err == nil || !strings.Contains(err.Error(), "snapshot of a MMC1 mapper, attached F8") {
+		t.Errorf("error %v", err)
+	}
+}
+
+func TestINES(t *testing.T) {
+
+	header := []byte{'N', 'E', 'S', 0x1A, 2, 1, 0x13, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	file := bytes.Join([][]byte{header, testROM(2, 16384), make([]byte, 8192)}, nil)
+
+	rom, err := ReadINES(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rom.Number != 1 || rom.Mapper.Name() != "MMC1" || len(rom.PRG) != 32768 || len(rom.CHR) != 8192 || !rom.Vertical || !rom.Battery {
+		t.Errorf("mapper %d %s, PRG %d, CHR %d, vertical %v, battery %v", rom.Number, rom.Mapper.Name(), len(rom.PRG), len(rom.CHR), rom.Vertical, rom.Battery)
+	}
+
+	trainer := append([]byte(nil), header...)
+	trainer[6] = 0x04
+
+	for _, test := range []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte("NES"), "not an iNES file"},
+		{append(trainer, make([]byte, 100)...), "truncated trainer"},
+		{append(header, make([]byte, 100)...), "truncated PRG ROM (32768 bytes in the header)"},
+		{file[:len(file)-1], "truncated CHR ROM (8192 bytes in the header)"},
+		{append([]byte{'N', 'E', 'S', 0x1A, 2, 0, 0x40, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}, testROM(2, 16384)...), "unsupported iNES mapper 4"},
+	} {
+		if _, err := ReadINES(bytes.NewReader(test.data)); err == nil || err.Error() != test.err {
+			t.Errorf("error %v, want %q", err, test.err)
+		}
+	}
+}
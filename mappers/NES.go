@@ -0,0 +1,365 @@
+package mappers
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ---------------------------------- NES ----------------------------------- //
+//
+// CPU side of the NES boards: PRG ROM banks at $8000-$FFFF, selected by writes
+// to the ROM area. The CHR banks are kept for a host PPU (not emulated here).
+
+// NROM has 16K (repeated at $C000) or 32K of PRG ROM, without bank switching
+type NROM struct {
+	windows
+}
+
+// NewNROM creates a NROM board (iNES mapper 0)
+func NewNROM(prg []byte) (*NROM, error) {
+
+	if err := checkSize("NROM", prg, 16384, 1, 2); err != nil {
+		return nil, err
+	}
+
+	return &NROM{newWindows(prg, 16384, []uint16{0}, 0x8000, 0xC000)}, nil
+}
+
+func (m *NROM) Name() string {
+	return "NROM"
+}
+
+func (m *NROM) Reset() {
+	m.set(0, 0)
+	m.set(1, 1)
+}
+
+func (m *NROM) Read(addr uint16, value byte) {}
+
+func (m *NROM) Write(addr uint16, value byte) bool {
+	return addr >= 0x8000
+}
+
+func (m *NROM) Save() []byte {
+	return m.save()
+}
+
+func (m *NROM) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// --------------------------------- UxROM ---------------------------------- //
+
+// UxROM selects the 16K bank at $8000 with writes to $8000-$FFFF, $C000 is fixed to the last bank
+type UxROM struct {
+	windows
+}
+
+// NewUxROM creates an UxROM board (iNES mapper 2)
+func NewUxROM(prg []byte) (*UxROM, error) {
+
+	if err := checkSize("UxROM", prg, 16384, 2, 256); err != nil {
+		return nil, err
+	}
+
+	return &UxROM{newWindows(prg, 16384, []uint16{0}, 0x8000, 0xC000)}, nil
+}
+
+func (m *UxROM) Name() string {
+	return "UxROM"
+}
+
+func (m *UxROM) Reset() {
+	m.set(0, 0)
+	m.set(1, m.count()-1)
+}
+
+func (m *UxROM) Read(addr uint16, value byte) {}
+
+func (m *UxROM) Write(addr uint16, value byte) bool {
+
+	if addr < 0x8000 {
+		return false
+	}
+
+	m.set(0, int(value))
+
+	return true
+}
+
+func (m *UxROM) Save() []byte {
+	return m.save()
+}
+
+func (m *UxROM) Restore(data []byte) error {
+	_, err := m.restore(data, 0)
+	return err
+}
+
+// --------------------------------- CNROM ---------------------------------- //
+
+// CNROM has a fixed PRG ROM (like NROM), writes to $8000-$FFFF select the 8K CHR bank
+type CNROM struct {
+	NROM
+	chr byte
+}
+
+// NewCNROM creates a CNROM board (iNES mapper 3)
+func NewCNROM(prg []byte) (*CNROM, error) {
+
+	if err := checkSize("CNROM", prg, 16384, 1, 2); err != nil {
+		return nil, err
+	}
+
+	return &CNROM{NROM: NROM{newWindows(prg, 16384, []uint16{0}, 0x8000, 0xC000)}}, nil
+}
+
+func (m *CNROM) Name() string {
+	return "CNROM"
+}
+
+func (m *CNROM) Reset() {
+	m.NROM.Reset()
+	m.chr = 0
+}
+
+// CHRBank returns the selected 8K CHR bank
+func (m *CNROM) CHRBank() int {
+	return int(m.chr)
+}
+
+func (m *CNROM) Write(addr uint16, value byte) bool {
+
+	if addr < 0x8000 {
+		return false
+	}
+
+	m.chr = value & 3
+
+	return true
+}
+
+func (m *CNROM) Save() []byte {
+	return m.save(m.chr)
+}
+
+func (m *CNROM) Restore(data []byte) error {
+
+	regs, err := m.restore(data, 1)
+	if err != nil {
+		return err
+	}
+
+	m.chr = regs[0]
+
+	return nil
+}
+
+// ---------------------------------- MMC1 ---------------------------------- //
+
+// MMC1 loads its registers serially: five writes to $8000-$FFFF shift bit 0
+// in, the address of the fifth one selects the register (control, CHR bank 0,
+// CHR bank 1, PRG bank), a write with bit 7 set resets the shift register.
+// PRG modes: 0-1 32K at $8000, 2 first bank fixed at $8000 and 16K at $C000,
+// 3 (power-on) 16K at $8000 and last bank fixed at $C000.
+// Writes on consecutive cycles (read-modify-write instructions) are not ignored.
+type MMC1 struct {
+	windows
+	shift   byte
+	writes  byte // Bits in the shift register
+	control byte
+	chr0    byte
+	chr1    byte
+	prg     byte
+}
+
+// NewMMC1 creates a MMC1 board (iNES mapper 1), up to 256K of PRG ROM
+func NewMMC1(prg []byte) (*MMC1, error) {
+
+	if err := checkSize("MMC1", prg, 16384, 2, 16); err != nil {
+		return nil, err
+	}
+
+	return &MMC1{windows: newWindows(prg, 16384, []uint16{0}, 0x8000, 0xC000)}, nil
+}
+
+func (m *MMC1) Name() string {
+	return "MMC1"
+}
+
+func (m *MMC1) Reset() {
+	m.shift, m.writes = 0, 0
+	m.control, m.chr0, m.chr1, m.prg = 0x0C, 0, 0, 0
+	m.update()
+}
+
+// Map the PRG banks from the control and PRG registers
+func (m *MMC1) update() {
+
+	bank := int(m.prg & 0x0F)
+
+	switch (m.control >> 2) & 3 {
+	case 0, 1:
+		m.set(0, bank&^1)
+		m.set(1, bank|1)
+	case 2:
+		m.set(0, 0)
+		m.set(1, bank)
+	case 3:
+		m.set(0, bank)
+		m.set(1, m.count()-1)
+	}
+}
+
+func (m *MMC1) Read(addr uint16, value byte) {}
+
+func (m *MMC1) Write(addr uint16, value byte) bool {
+
+	if addr < 0x8000 {
+		return false
+	}
+
+	if value&0x80 != 0 {
+		m.shift, m.writes = 0, 0
+		m.control |= 0x0C
+		m.update()
+		return true
+	}
+
+	m.shift |= (value & 1) << m.writes
+	m.writes++
+
+	if m.writes == 5 {
+		switch (addr >> 13) & 3 {
+		case 0:
+			m.control = m.shift
+		case 1:
+			m.chr0 = m.shift
+		case 2:
+			m.chr1 = m.shift
+		case 3:
+			m.prg = m.shift
+		}
+		m.shift, m.writes = 0, 0
+		m.update()
+	}
+
+	return true
+}
+
+// Mirroring returns the nametable mirroring (0 one-screen low, 1 one-screen high, 2 vertical, 3 horizontal)
+func (m *MMC1) Mirroring() int {
+	return int(m.control & 3)
+}
+
+// CHRBanks returns the 4K CHR banks at PPU $0000 and $1000
+func (m *MMC1) CHRBanks() (int, int) {
+	if m.control&0x10 == 0 { // 8K mode
+		return int(m.chr0 &^ 1), int(m.chr0 | 1)
+	}
+	return int(m.chr0), int(m.chr1)
+}
+
+func (m *MMC1) Save() []byte {
+	return m.save(m.shift, m.writes, m.control, m.chr0, m.chr1, m.prg)
+}
+
+func (m *MMC1) Restore(data []byte) error {
+
+	regs, err := m.restore(data, 6)
+	if err != nil {
+		return err
+	}
+
+	if regs[1] > 4 {
+		return fmt.Errorf("invalid MMC1 shift count %d", regs[1])
+	}
+
+	m.shift, m.writes, m.control, m.chr0, m.chr1, m.prg = regs[0], regs[1], regs[2], regs[3], regs[4], regs[5]
+
+	return nil
+}
+
+// ---------------------------------- iNES ---------------------------------- //
+
+// INES is a NES ROM file
+type INES struct {
+	Mapper   Mapper
+	Number   int    // iNES mapper number
+	PRG      []byte // PRG ROM, mapped by Mapper
+	CHR      []byte // CHR ROM, for a host PPU (empty for CHR RAM)
+	Vertical bool   // Vertical nametable mirroring
+	Battery  bool   // Battery backed PRG RAM at $6000-$7FFF
+}
+
+// LoadINES reads a .nes file
+func LoadINES(filename string) (*INES, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rom, err := ReadINES(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return rom, nil
+}
+
+// ReadINES reads an iNES file, creating the mapper of its board (0 NROM, 1 MMC1, 2 UxROM, 3 CNROM)
+func ReadINES(r io.Reader) (*INES, error) {
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "NES\x1A" {
+		return nil, fmt.Errorf("not an iNES file")
+	}
+
+	rom := &INES{
+		Number:   int(header[6]>>4 | header[7]&0xF0),
+		Vertical: header[6]&0x01 != 0,
+		Battery:  header[6]&0x02 != 0,
+	}
+
+	// Trainer (loaded at $7000 by copiers, ignored)
+	if header[6]&0x04 != 0 {
+		if _, err := io.CopyN(io.Discard, r, 512); err != nil {
+			return nil, fmt.Errorf("truncated trainer")
+		}
+	}
+
+	rom.PRG = make([]byte, int(header[4])*16384)
+	rom.CHR = make([]byte, int(header[5])*8192)
+
+	if _, err := io.ReadFull(r, rom.PRG); err != nil {
+		return nil, fmt.Errorf("truncated PRG ROM (%d bytes in the header)", len(rom.PRG))
+	}
+	if _, err := io.ReadFull(r, rom.CHR); err != nil {
+		return nil, fmt.Errorf("truncated CHR ROM (%d bytes in the header)", len(rom.CHR))
+	}
+
+	var err error
+
+	switch rom.Number {
+	case 0:
+		rom.Mapper, err = NewNROM(rom.PRG)
+	case 1:
+		rom.Mapper, err = NewMMC1(rom.PRG)
+	case 2:
+		rom.Mapper, err = NewUxROM(rom.PRG)
+	case 3:
+		rom.Mapper, err = NewCNROM(rom.PRG)
+	default:
+		return nil, fmt.Errorf("unsupported iNES mapper %d", rom.Number)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rom, nil
+}
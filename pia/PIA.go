@@ -0,0 +1,369 @@
+// Package pia emulates the Motorola MC6820 / MOS 6520 Peripheral Interface Adapter.
+//
+// The chip has two 8 bits ports, each with a data direction register sharing
+// its address with the peripheral register (selected by bit 2 of the control
+// register), and the C1 / C2 control lines:
+//
+//	0 PRA / DDRA   1 CRA   2 PRB / DDRB   3 CRB
+//
+// Control registers: bit 0 C1 interrupt enable, bit 1 C1 positive edge, bit 2
+// peripheral register access, bits 3-5 C2 mode, bit 6 C2 flag, bit 7 C1 flag.
+// C2 modes: 0xx input (bit 3 interrupt enable, bit 4 positive edge), 100
+// handshake (low on a read of PRA / a write of PRB, high on the C1 active edge),
+// 101 pulse (low for one cycle) and 11x output of bit 3. Reading the peripheral
+// register clears both flags.
+package pia
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Registers
+const (
+	PRA = 0x0 // Peripheral register A or DDRA
+	CRA = 0x1 // Control register A
+	PRB = 0x2 // Peripheral register B or DDRB
+	CRB = 0x3 // Control register B
+)
+
+// Port state, saved in the snapshots
+type port struct {
+	OR, DDR, CR byte
+	In          byte // Levels driven on the pins by the peripherals
+	C1, C2      bool // Input levels
+	C2Out       bool // Output level
+	Pulse       byte // Cycles left of the C2 pulse
+}
+
+func (p *port) pins() byte {
+	return p.OR&p.DDR | p.In&^p.DDR
+}
+
+func (p *port) c2Mode() byte {
+	return (p.CR >> 3) & 7
+}
+
+func (p *port) irq() bool {
+	return p.CR&0x81 == 0x81 || (p.CR&0x48 == 0x48 && p.CR&0x20 == 0)
+}
+
+// PIA is a 6820 / 6520 chip
+type PIA struct {
+	s   [2]port // A and B
+	irq *CPU_6502.InterruptLine
+	tag string
+
+	// Output changes, for the peripherals (any can be nil)
+	OnPortA func(value byte)
+	OnPortB func(value byte)
+	OnCA2   func(level bool)
+	OnCB2   func(level bool)
+}
+
+// New creates a chip in the reset state
+func New() *PIA {
+
+	p := &PIA{}
+	for i := range p.s {
+		p.s[i].In = 0xFF
+		p.s[i].C1, p.s[i].C2 = true, true
+	}
+	p.Reset()
+
+	return p
+}
+
+// Attach creates a chip mapped at base (4 registers)
+func Attach(base uint16) (*PIA, error) {
+
+	p := New()
+
+	if err := CPU_6502.AttachDevice(p, base, 4); err != nil {
+		return nil, err
+	}
+
+	p.register(fmt.Sprintf("P%03X", base>>4))
+
+	return p, nil
+}
+
+// AttachDecoded creates a chip selected by addr & mask == match (the registers repeat every 4 bytes)
+func AttachDecoded(mask, match uint16) (*PIA, error) {
+
+	p := New()
+
+	if err := CPU_6502.AttachDecodedDevice(p, mask, match); err != nil {
+		return nil, err
+	}
+
+	p.register(fmt.Sprintf("P%03X", match>>4))
+
+	return p, nil
+}
+
+func (p *PIA) register(tag string) {
+	p.tag = tag
+	CPU_6502.RegisterSnapshotChunk(tag, p.save, p.restore)
+}
+
+// Detach removes the chip from the bus
+func (p *PIA) Detach() {
+	CPU_6502.DetachDevice(p)
+	CPU_6502.UnregisterSnapshotChunk(p.tag)
+	if p.irq != nil {
+		p.irq.Set(false)
+	}
+}
+
+// ConnectIRQ connects the IRQA and IRQB outputs to the CPU IRQ input
+func (p *PIA) ConnectIRQ() {
+	if p.irq == nil {
+		p.irq = CPU_6502.NewIRQLine()
+		p.updateIRQ()
+	}
+}
+
+// Reset clears all the registers
+func (p *PIA) Reset() {
+	for i := range p.s {
+		p.s[i].OR, p.s[i].DDR, p.s[i].CR = 0, 0, 0
+		p.s[i].C2Out, p.s[i].Pulse = true, 0
+	}
+	p.updateIRQ()
+}
+
+// ---------------------------------- Pins ---------------------------------- //
+
+// PortA returns the levels of the port A pins
+func (p *PIA) PortA() byte {
+	return p.s[0].pins()
+}
+
+// PortB returns the levels of the port B pins
+func (p *PIA) PortB() byte {
+	return p.s[1].pins()
+}
+
+// SetPortA sets the levels driven on the port A pins by the peripherals
+func (p *PIA) SetPortA(value byte) {
+	p.s[0].In = value
+}
+
+// SetPortB sets the levels driven on the port B pins by the peripherals
+func (p *PIA) SetPortB(value byte) {
+	p.s[1].In = value
+}
+
+// CA2 returns the level of CA2 (output modes)
+func (p *PIA) CA2() bool {
+	return p.s[0].C2Out
+}
+
+// CB2 returns the level of CB2 (output modes)
+func (p *PIA) CB2() bool {
+	return p.s[1].C2Out
+}
+
+// SetCA1 drives the CA1 input
+func (p *PIA) SetCA1(level bool) {
+	p.setC1(0, level)
+}
+
+// SetCA2 drives the CA2 input (input modes)
+func (p *PIA) SetCA2(level bool) {
+	p.setC2(0, level)
+}
+
+// SetCB1 drives the CB1 input
+func (p *PIA) SetCB1(level bool) {
+	p.setC1(1, level)
+}
+
+// SetCB2 drives the CB2 input (input modes)
+func (p *PIA) SetCB2(level bool) {
+	p.setC2(1, level)
+}
+
+// C1 input, the active edge sets the flag and ends the C2 handshake
+func (p *PIA) setC1(i int, level bool) {
+
+	s := &p.s[i]
+
+	if level == s.C1 {
+		return
+	}
+	s.C1 = level
+
+	if level != (s.CR&0x02 != 0) {
+		return
+	}
+
+	s.CR |= 0x80
+
+	if s.c2Mode() == 4 {
+		p.setC2Out(i, true)
+	}
+
+	p.updateIRQ()
+}
+
+func (p *PIA) setC2(i int, level bool) {
+
+	s := &p.s[i]
+
+	if level == s.C2 {
+		return
+	}
+	s.C2 = level
+
+	if s.CR&0x20 == 0 && level == (s.CR&0x10 != 0) {
+		s.CR |= 0x40
+		p.updateIRQ()
+	}
+}
+
+func (p *PIA) setC2Out(i int, level bool) {
+
+	if level == p.s[i].C2Out {
+		return
+	}
+	p.s[i].C2Out = level
+
+	if f := [2]func(bool){p.OnCA2, p.OnCB2}[i]; f != nil {
+		f(level)
+	}
+}
+
+// Handshake and pulse outputs of C2 (read of PRA, write of PRB)
+func (p *PIA) handshake(i int) {
+	switch p.s[i].c2Mode() {
+	case 4:
+		p.setC2Out(i, false)
+	case 5:
+		p.setC2Out(i, false)
+		p.s[i].Pulse = 2
+	}
+}
+
+func (p *PIA) portChanged(i int) {
+	if f := [2]func(byte){p.OnPortA, p.OnPortB}[i]; f != nil {
+		f(p.s[i].pins())
+	}
+}
+
+func (p *PIA) updateIRQ() {
+	if p.irq != nil {
+		p.irq.Set(p.s[0].irq() || p.s[1].irq())
+	}
+}
+
+// ------------------------------- Registers -------------------------------- //
+
+// Read a register (CPU data BUS)
+func (p *PIA) Read(offset uint16) byte {
+
+	i := int(offset>>1) & 1
+	s := &p.s[i]
+
+	if offset&1 != 0 {
+		return s.CR
+	}
+
+	if s.CR&0x04 == 0 {
+		return s.DDR
+	}
+
+	value := s.pins()
+
+	s.CR &^= 0xC0
+	if i == 0 {
+		p.handshake(0)
+	}
+	p.updateIRQ()
+
+	return value
+}
+
+// Write a register (CPU data BUS)
+func (p *PIA) Write(offset uint16, value byte) {
+
+	i := int(offset>>1) & 1
+	s := &p.s[i]
+
+	if offset&1 != 0 {
+		// The flags are read only
+		s.CR = s.CR&0xC0 | value&0x3F
+		switch s.c2Mode() {
+		case 4, 5:
+			p.setC2Out(i, true)
+		case 6, 7:
+			p.setC2Out(i, value&0x08 != 0)
+		}
+		p.updateIRQ()
+		return
+	}
+
+	if s.CR&0x04 == 0 {
+		s.DDR = value
+	} else {
+		s.OR = value
+		if i == 1 {
+			p.handshake(1)
+		}
+	}
+
+	p.portChanged(i)
+}
+
+// Clock runs one cycle, ending the C2 pulses (CPU_6502.Clocked)
+func (p *PIA) Clock() {
+	for i := range p.s {
+		if p.s[i].Pulse > 0 {
+			if p.s[i].Pulse--; p.s[i].Pulse == 0 {
+				p.setC2Out(i, true)
+			}
+		}
+	}
+}
+
+// ClockCycles runs several cycles (CPU_6502.MultiClocked)
+func (p *PIA) ClockCycles(cycles int) {
+	for ; cycles > 0; cycles-- {
+		p.Clock()
+	}
+}
+
+// ------------------------------- Snapshots -------------------------------- //
+
+func (p *PIA) save() []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, &p.s)
+	return buf.Bytes()
+}
+
+func (p *PIA) restore(data []byte) error {
+
+	var s [2]port
+
+	if len(data) != binary.Size(&s) {
+		return fmt.Errorf("invalid PIA state size %d", len(data))
+	}
+	_ = binary.Read(bytes.NewReader(data), binary.LittleEndian, &s)
+
+	p.s = s
+	p.updateIRQ()
+	p.portChanged(0)
+	p.portChanged(1)
+
+	return nil
+}
+
+// String shows the registers, for the monitors
+func (p *PIA) String() string {
+	return fmt.Sprintf("PIA  PA %02X DDRA %02X CRA %02X  PB %02X DDRB %02X CRB %02X",
+		p.PortA(), p.s[0].DDR, p.s[0].CR, p.PortB(), p.s[1].DDR, p.s[1].CR)
+}
@@ -0,0 +1,196 @@
+package pia
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Chip outside the bus with its IRQ outputs connected, released at the end of the test
+func testPIA(t *testing.T) *PIA {
+	p := New()
+	p.ConnectIRQ()
+	t.Cleanup(func() { p.irq.Set(false) })
+	return p
+}
+
+// The direction and peripheral registers share the address, selected by bit 2 of the control register
+func TestPorts(t *testing.T) {
+
+	p := testPIA(t)
+
+	var a []byte
+	p.OnPortA = func(value byte) { a = append(a, value) }
+
+	p.SetPortA(0xA5)
+	p.Write(PRA, 0x0F) // DDRA
+	p.Write(CRA, 0x04)
+	p.Write(PRA, 0x33)
+
+	if p.Read(PRA) != 0xA3 || p.PortA() != 0xA3 {
+		t.Errorf("port A $%02X, want $A3", p.PortA())
+	}
+	p.Write(CRA, 0x00)
+	if p.Read(PRA) != 0x0F {
+		t.Errorf("DDRA $%02X", p.Read(PRA))
+	}
+	if len(a) != 2 || a[0] != 0xA0 || a[1] != 0xA3 {
+		t.Errorf("port A changes %X", a)
+	}
+
+	// The peripherals drive the inputs, all pins are inputs after reset
+	p.SetPortB(0x3C)
+	p.Write(CRB, 0x04)
+	if p.Read(PRB) != 0x3C {
+		t.Errorf("port B $%02X", p.Read(PRB))
+	}
+	p.Reset()
+	if p.PortA() != 0xA5 || p.Read(CRA) != 0 || p.Read(CRB) != 0 {
+		t.Errorf("after reset %s", p)
+	}
+}
+
+// The C1 active edge and the C2 input edges set the flags, read only and cleared by the port read
+func TestInterrupts(t *testing.T) {
+
+	p := testPIA(t)
+
+	// CA1 negative edge without interrupt
+	p.Write(CRA, 0x04)
+	p.SetCA1(false)
+	if p.Read(CRA) != 0x84 || p.irq.Active() {
+		t.Errorf("CA1 edge: CRA $%02X, IRQ %v", p.Read(CRA), p.irq.Active())
+	}
+	p.Write(CRA, 0xC4)
+	if p.Read(CRA) != 0x84 {
+		t.Errorf("flags written, CRA $%02X", p.Read(CRA))
+	}
+
+	// The interrupt follows the enable bit of a set flag
+	p.Write(CRA, 0x05)
+	if !p.irq.Active() {
+		t.Errorf("no IRQ with CA1 enabled")
+	}
+	p.Read(PRA)
+	if p.irq.Active() || p.Read(CRA) != 0x05 {
+		t.Errorf("CRA $%02X, IRQ %v after the port read", p.Read(CRA), p.irq.Active())
+	}
+
+	// CB1 positive edge, the negative one is inactive
+	p.Write(CRB, 0x07)
+	p.SetCB1(false)
+	if p.irq.Active() {
+		t.Errorf("IRQ on the CB1 inactive edge")
+	}
+	p.SetCB1(true)
+	if !p.irq.Active() || p.Read(CRB) != 0x87 {
+		t.Errorf("CB1 edge: CRB $%02X, IRQ %v", p.Read(CRB), p.irq.Active())
+	}
+	p.Read(PRB)
+
+	// CB2 input on the positive edge with interrupt
+	p.Write(CRB, 0x1C)
+	p.SetCB2(false)
+	if p.Read(CRB)&0x40 != 0 {
+		t.Errorf("CB2 flag on the inactive edge")
+	}
+	p.SetCB2(true)
+	if !p.irq.Active() || p.Read(CRB) != 0x5C {
+		t.Errorf("CB2 edge: CRB $%02X, IRQ %v", p.Read(CRB), p.irq.Active())
+	}
+
+	// No C2 interrupt in the output modes
+	p.Write(CRB, 0x3C)
+	if p.irq.Active() {
+		t.Errorf("IRQ with CB2 as an output")
+	}
+}
+
+// C2 handshake, pulse and manual outputs
+func TestOutputs(t *testing.T) {
+
+	p := testPIA(t)
+
+	// CA2 low on the PRA read, high on the CA1 active edge
+	p.Write(CRA, 0x24)
+	p.Read(PRA)
+	if p.CA2() {
+		t.Errorf("CA2 high after the PRA read")
+	}
+	p.SetCA1(false)
+	if !p.CA2() {
+		t.Errorf("CA2 low after the CA1 edge")
+	}
+
+	// CB2 pulse for the cycle after the PRB write, a read doesn't pulse
+	var levels []bool
+	p.OnCB2 = func(level bool) { levels = append(levels, level) }
+	p.Write(CRB, 0x2C)
+	p.Read(PRB)
+	p.Write(PRB, 0x00)
+	p.Clock()
+	if p.CB2() {
+		t.Errorf("CB2 pulse too short")
+	}
+	p.Clock()
+	if len(levels) != 2 || levels[0] || !levels[1] {
+		t.Errorf("CB2 pulse %v", levels)
+	}
+
+	// Manual output of bit 3
+	p.Write(CRB, 0x30)
+	if p.CB2() {
+		t.Errorf("CB2 high with bit 3 clear")
+	}
+	p.Write(CRB, 0x38)
+	if !p.CB2() {
+		t.Errorf("CB2 low with bit 3 set")
+	}
+}
+
+// The chip decoded on the bus interrupts the CPU, its state goes in the snapshots
+func TestAttach(t *testing.T) {
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+
+	copy(CPU_6502.Memory[0x0200:], []byte{
+		0xA9, 0xFF, 0x8D, 0x02, 0x60, // LDA #$FF, STA DDRB
+		0xA9, 0x05, 0x8D, 0x13, 0x60, // LDA #$05, STA CRB (mirror)
+		0xA9, 0x42, 0x8D, 0x06, 0x60, // LDA #$42, STA PRB (mirror)
+	})
+	CPU_6502.PC = 0x0200
+
+	p, err := AttachDecoded(0xF000, 0x6000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Detach)
+	p.ConnectIRQ()
+
+	for i := 0; i < 6; i++ {
+		CPU_6502.Step()
+	}
+	if p.PortB() != 0x42 || p.Read(CRB) != 0x05 {
+		t.Errorf("after the program %s", p)
+	}
+
+	p.SetCB1(false)
+	if !CPU_6502.IRQ() {
+		t.Errorf("IRQ input inactive after the CB1 edge")
+	}
+
+	snap := CPU_6502.Snapshot()
+	p.Reset()
+
+	if err := CPU_6502.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if !CPU_6502.IRQ() || p.PortB() != 0x42 || p.Read(CRB) != 0x85 {
+		t.Errorf("restored %s", p)
+	}
+	if err := p.restore([]byte{1, 2}); err == nil || err.Error() != "invalid PIA state size 2" {
+		t.Errorf("size error %v", err)
+	}
+}
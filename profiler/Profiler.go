@@ -0,0 +1,406 @@
+// Package profiler measures where a 6502 program spends its cycles.
+//
+// It counts instructions and cycles per address, page-cross penalties and
+// branches taken, and attributes cycles to the subroutines by following
+// JSR / RTS and the interrupt entries (inclusive and exclusive cycles).
+// The results are written as a sorted text report or in the callgrind
+// format (KCachegrind, QCachegrind, gprof2dot).
+package profiler
+
+import (
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// Address holds the counters of the instruction at one address
+type Address struct {
+	Instructions  uint64 // Executions
+	Cycles        uint64 // Cycles, including the penalties
+	PageCrosses   uint64 // Executions with a page-cross penalty
+	Branches      uint64 // Executions of a branch instruction
+	BranchesTaken uint64
+}
+
+// Function holds the counters of a subroutine (or interrupt handler)
+type Function struct {
+	Entry     uint16
+	Calls     uint64
+	Inclusive uint64 // Cycles including the subroutines called (recursion counted once)
+	Exclusive uint64 // Cycles of the subroutine own instructions
+
+	InclusiveInstructions uint64
+	ExclusiveInstructions uint64
+
+	Interrupt bool // Entered by BRK / IRQ / NMI
+
+	active int              // Open frames (recursion)
+	calls  map[arcKey]*Call // Calls made by this function
+	costs  map[uint16]*cost // Exclusive cost per address
+}
+
+type cost struct {
+	cycles, instructions uint64
+}
+
+// Call is an arc of the call graph: calls from one site to a subroutine
+type Call struct {
+	Site         uint16 // Address of the JSR (interrupts: interrupted instruction)
+	Callee       uint16 // Entry of the subroutine
+	Count        uint64
+	Cycles       uint64 // Inclusive cycles of the callee
+	Instructions uint64
+}
+
+type arcKey struct {
+	site, callee uint16
+}
+
+// Subroutine or interrupt handler being executed
+type frame struct {
+	fn           *Function
+	site         uint16
+	sp           byte // Stack Pointer before the call
+	cycle        uint64
+	instructions uint64
+}
+
+// Profiler collects the counters through the CPU hooks
+type Profiler struct {
+	// Optional symbol lookup, used to name the subroutines in the reports
+	Symbols func(addr uint16) (string, bool)
+
+	addresses []Address
+	functions map[uint16]*Function
+	frames    []frame // frames[0] is the entry point of the program
+
+	cycles       uint64 // Totals of the instructions completed
+	instructions uint64
+
+	// Instruction in progress
+	running   bool
+	last_pc   uint16
+	last_opc  byte
+	last_cyc  uint64
+	last_func *Function
+
+	// JSR seen by the Instruction hook, confirmed on the next instruction
+	jsr_pending bool
+	jsr_pc      uint16
+	jsr_sp      byte
+
+	// Interrupt handler entered, pushed on its first instruction
+	int_pending bool
+	int_vector  uint16
+	int_site    uint16
+	int_sp      byte
+
+	table *[256]disassembler.Instruction
+	hooks *CPU_6502.Hooks
+}
+
+// New creates a profiler attached to the CPU
+func New() *Profiler {
+
+	p := &Profiler{
+		addresses: make([]Address, 0x10000),
+		functions: map[uint16]*Function{},
+		table:     disassembler.Table(disassembler.NMOS),
+	}
+
+	p.hooks = &CPU_6502.Hooks{
+		Instruction: p.onInstruction,
+		Interrupt:   p.onInterrupt,
+	}
+
+	CPU_6502.AttachHooks(p.hooks)
+
+	return p
+}
+
+// Close detaches the profiler from the CPU (the counters are kept)
+func (p *Profiler) Close() {
+	CPU_6502.DetachHooks(p.hooks)
+}
+
+// Reset clears all the counters
+func (p *Profiler) Reset() {
+
+	for i := range p.addresses {
+		p.addresses[i] = Address{}
+	}
+
+	p.functions = map[uint16]*Function{}
+	p.frames = p.frames[:0]
+	p.cycles, p.instructions = 0, 0
+	p.running = false
+	p.jsr_pending = false
+	p.int_pending = false
+}
+
+// Cycles returns the cycles of the instructions completed since the start
+func (p *Profiler) Cycles() uint64 {
+	return p.cycles
+}
+
+// Instructions returns the number of instructions completed since the start
+func (p *Profiler) Instructions() uint64 {
+	return p.instructions
+}
+
+// Address returns the counters of an address
+func (p *Profiler) Address(addr uint16) Address {
+	return p.addresses[addr]
+}
+
+// ---------------------------------- Hooks --------------------------------- //
+
+func (p *Profiler) onInstruction(pc uint16, opcode byte) bool {
+
+	cycle := CPU_6502.Cycle
+
+	if p.running {
+
+		// Same instruction seen again (the CPU was held by a debugger)
+		if cycle == p.last_cyc {
+			return false
+		}
+
+		// Time went backwards (snapshot restored or history step back)
+		if cycle < p.last_cyc {
+			for _, fn := range p.functions {
+				fn.active = 0
+			}
+			p.frames = p.frames[:0]
+			p.running = false
+			p.jsr_pending = false
+			p.int_pending = false
+		} else {
+			p.complete(pc, cycle)
+		}
+	}
+
+	p.update(pc, cycle)
+
+	if opcode == 0x20 { // JSR
+		p.jsr_pending = true
+		p.jsr_pc = pc
+		p.jsr_sp = CPU_6502.SP
+	}
+
+	p.running = true
+	p.last_pc = pc
+	p.last_opc = opcode
+	p.last_cyc = cycle
+	p.last_func = p.frames[len(p.frames)-1].fn
+
+	return false
+}
+
+// Account the previous instruction, finished when the next one starts at pc
+func (p *Profiler) complete(pc uint16, cycle uint64) {
+
+	cycles := cycle - p.last_cyc
+	ins := p.table[p.last_opc]
+	a := &p.addresses[p.last_pc]
+
+	a.Instructions++
+	a.Cycles += cycles
+
+	extra := uint64(0)
+	if cycles > uint64(ins.Cycles) {
+		extra = cycles - uint64(ins.Cycles)
+	}
+
+	if ins.Mode == disassembler.Relative {
+		a.Branches++
+		if pc != p.last_pc+2 {
+			a.BranchesTaken++
+			extra-- // The branch taken penalty
+		}
+	}
+
+	if extra > 0 {
+		a.PageCrosses++
+	}
+
+	fn := p.last_func
+	fn.Exclusive += cycles
+	fn.ExclusiveInstructions++
+
+	c, ok := fn.costs[p.last_pc]
+	if !ok {
+		c = &cost{}
+		fn.costs[p.last_pc] = c
+	}
+	c.cycles += cycles
+	c.instructions++
+
+	p.cycles += cycles
+	p.instructions++
+}
+
+// Push the frames entered by the previous instruction and remove the returned ones
+func (p *Profiler) update(pc uint16, cycle uint64) {
+
+	// First instruction: the entry point of the program
+	if len(p.frames) == 0 {
+		fn := p.function(pc)
+		fn.Calls++
+		fn.active++
+		p.frames = append(p.frames, frame{fn: fn, site: pc, cycle: cycle, instructions: p.instructions})
+		return
+	}
+
+	// The previous instruction was a JSR: a call if it pushed the return address
+	if p.jsr_pending {
+		p.jsr_pending = false
+		if CPU_6502.SP == p.jsr_sp-2 {
+			p.push(pc, p.jsr_pc, p.jsr_sp, cycle, false)
+		}
+	}
+
+	if p.int_pending {
+		p.int_pending = false
+		p.push(p.int_vector, p.int_site, p.int_sp, cycle, true)
+	}
+
+	// Returned (or the return address was dropped), the entry point is never removed
+	for len(p.frames) > 1 && CPU_6502.SP >= p.frames[len(p.frames)-1].sp {
+		p.pop(cycle)
+	}
+}
+
+func (p *Profiler) onInterrupt(source CPU_6502.Interrupt, vector uint16, return_addr uint16) {
+
+	if !p.running {
+		return
+	}
+
+	// Interrupt taken right after a JSR: the subroutine was entered
+	if p.jsr_pending {
+		p.jsr_pending = false
+		if CPU_6502.SP+3 == p.jsr_sp-2 {
+			p.push(return_addr, p.jsr_pc, p.jsr_sp, CPU_6502.Cycle, false)
+		}
+	}
+
+	// BRK is a 2 bytes instruction, IRQ and NMI return to the interrupted one
+	site := return_addr
+	if source == CPU_6502.INT_BRK {
+		site -= 2
+	}
+
+	// PC and P were already pushed
+	p.int_pending = true
+	p.int_vector = vector
+	p.int_site = site
+	p.int_sp = CPU_6502.SP + 3
+}
+
+func (p *Profiler) function(entry uint16) *Function {
+
+	fn, ok := p.functions[entry]
+	if !ok {
+		fn = &Function{Entry: entry, calls: map[arcKey]*Call{}, costs: map[uint16]*cost{}}
+		p.functions[entry] = fn
+	}
+
+	return fn
+}
+
+func (p *Profiler) push(entry, site uint16, sp byte, cycle uint64, interrupt bool) {
+
+	fn := p.function(entry)
+	fn.Calls++
+	fn.active++
+	fn.Interrupt = fn.Interrupt || interrupt
+
+	p.frames = append(p.frames, frame{fn: fn, site: site, sp: sp, cycle: cycle, instructions: p.instructions})
+}
+
+func (p *Profiler) pop(cycle uint64) {
+
+	f := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	p.account(f, p.frames[len(p.frames)-1].fn, cycle, p.instructions)
+	f.fn.active--
+}
+
+// Add the inclusive cost of a frame to its function and to the call arc of the caller
+func (p *Profiler) account(f frame, caller *Function, cycle, instructions uint64) {
+
+	cycles := cycle - f.cycle
+	count := instructions - f.instructions
+
+	if f.fn.active == 1 { // Outermost activation
+		f.fn.Inclusive += cycles
+		f.fn.InclusiveInstructions += count
+	}
+
+	if caller == nil {
+		return
+	}
+
+	key := arcKey{f.site, f.fn.Entry}
+	c, ok := caller.calls[key]
+	if !ok {
+		c = &Call{Site: f.site, Callee: f.fn.Entry}
+		caller.calls[key] = c
+	}
+
+	c.Count++
+	c.Cycles += cycles
+	c.Instructions += count
+}
+
+// ------------------------------- Results ---------------------------------- //
+
+// Functions returns the counters of the subroutines, sorted by inclusive cycles
+// The frames still open (e.g. the entry point) are accounted up to the last
+// completed instruction
+func (p *Profiler) Functions() []*Function {
+
+	result := p.results()
+
+	list := make([]*Function, 0, len(result))
+	for _, fn := range result {
+		list = append(list, fn)
+	}
+
+	sortFunctions(list)
+
+	return list
+}
+
+// Copy of the functions with the open frames closed at the current cycle
+func (p *Profiler) results() map[uint16]*Function {
+
+	result := make(map[uint16]*Function, len(p.functions))
+
+	for entry, fn := range p.functions {
+		c := *fn
+		c.calls = make(map[arcKey]*Call, len(fn.calls))
+		for k, call := range fn.calls {
+			copied := *call
+			c.calls[k] = &copied
+		}
+		result[entry] = &c
+	}
+
+	for i := len(p.frames) - 1; i >= 0; i-- {
+
+		f := p.frames[i]
+		f.fn = result[f.fn.Entry]
+
+		var caller *Function
+		if i > 0 {
+			caller = result[p.frames[i-1].fn.Entry]
+		}
+
+		p.account(f, caller, p.last_cyc, p.instructions)
+		f.fn.active--
+	}
+
+	return result
+}
@@ -0,0 +1,148 @@
+package profiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Clean CPU with the code blocks at their addresses, BRK handler at $0300
+func testCPU(t *testing.T, code map[uint16][]byte) *Profiler {
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+	for addr, c := range code {
+		copy(CPU_6502.Memory[addr:], c)
+	}
+	CPU_6502.Memory[0xFFFE], CPU_6502.Memory[0xFFFF] = 0x00, 0x03
+	CPU_6502.PC = 0x0200
+	CPU_6502.SP = 0xFF
+
+	p := New()
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+// Runs until PC reaches addr, then starts the instruction there so the previous one is accounted
+func testRunTo(t *testing.T, addr uint16) {
+	for i := 0; CPU_6502.PC != addr; i++ {
+		if i == 1000 {
+			t.Fatalf("PC $%04X never reached $%04X", CPU_6502.PC, addr)
+		}
+		CPU_6502.Step()
+	}
+	CPU_6502.Step()
+}
+
+// Main calls sub twice, sub calls leaf, then a BRK enters the handler
+func TestSubroutinesAndInterrupts(t *testing.T) {
+
+	p := testCPU(t, map[uint16][]byte{
+		0x0200: {
+			0x20, 0x10, 0x02, // JSR sub            6
+			0x20, 0x10, 0x02, // JSR sub            6
+			0x00, 0xEA, //       BRK                7
+			0x4C, 0x08, 0x02, // JMP $0208
+		},
+		0x0210: {0x20, 0x20, 0x02, 0x60}, // sub:  JSR leaf, RTS   6 + 6
+		0x0220: {0xA2, 0x00, 0x60},       // leaf: LDX #0, RTS     2 + 6
+		0x0300: {0x40},                   // RTI                   6
+	})
+
+	testRunTo(t, 0x0208)
+
+	if p.Cycles() != 65 || p.Instructions() != 12 {
+		t.Errorf("%d cycles and %d instructions, want 65 and 12", p.Cycles(), p.Instructions())
+	}
+
+	functions := map[uint16]*Function{}
+	for _, fn := range p.Functions() {
+		functions[fn.Entry] = fn
+	}
+
+	for _, want := range []Function{
+		{Entry: 0x0200, Calls: 1, Inclusive: 65, Exclusive: 19},
+		{Entry: 0x0210, Calls: 2, Inclusive: 40, Exclusive: 24},
+		{Entry: 0x0220, Calls: 2, Inclusive: 16, Exclusive: 16},
+		{Entry: 0x0300, Calls: 1, Inclusive: 6, Exclusive: 6, Interrupt: true},
+	} {
+		fn := functions[want.Entry]
+		if fn == nil {
+			t.Errorf("no function $%04X", want.Entry)
+			continue
+		}
+		if fn.Calls != want.Calls || fn.Inclusive != want.Inclusive || fn.Exclusive != want.Exclusive || fn.Interrupt != want.Interrupt {
+			t.Errorf("$%04X: %d calls, inclusive %d, exclusive %d, interrupt %v, want %d, %d, %d, %v",
+				fn.Entry, fn.Calls, fn.Inclusive, fn.Exclusive, fn.Interrupt, want.Calls, want.Inclusive, want.Exclusive, want.Interrupt)
+		}
+	}
+
+	// Call arcs from each site
+	var arcs []string
+	for _, entry := range []uint16{0x0200, 0x0210} {
+		for _, c := range functions[entry].Callees() {
+			arcs = append(arcs, p.Name(c.Site)+">"+p.Name(c.Callee)+":"+strings.Repeat("*", int(c.Count)))
+		}
+	}
+	if got := strings.Join(arcs, " "); got != "$0200>$0210:* $0203>$0210:* $0206>$0300:* $0210>$0220:**" {
+		t.Errorf("call arcs %s", got)
+	}
+
+	if a := p.Address(0x0210); a.Instructions != 2 || a.Cycles != 12 {
+		t.Errorf("JSR leaf: %+v", a)
+	}
+
+	// Reports
+	var report, callgrind bytes.Buffer
+	if err := p.Report(&report, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "$0300 (interrupt)") {
+		t.Errorf("report without the interrupt handler:\n%s", report.String())
+	}
+	if err := p.WriteCallgrind(&callgrind); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(callgrind.String(), "cfn=$0220\ncalls=2 0x0220\n0x0210 16 4\n") {
+		t.Errorf("callgrind without the call of leaf:\n%s", callgrind.String())
+	}
+}
+
+// Branches taken and page-cross penalties
+func TestBranches(t *testing.T) {
+
+	p := testCPU(t, map[uint16][]byte{
+		0x0200: {0x4C, 0xF0, 0x02}, // JMP $02F0
+		0x02F0: {
+			0xA2, 0x00, // LDX #0
+			0xF0, 0x10, // BEQ $0304  taken, next page
+		},
+		0x0304: {
+			0xD0, 0x10, //      BNE $0316  not taken
+			0xBD, 0xFF, 0x03, // LDA $03FF,X  no page cross
+			0xE8,             // INX
+			0xBD, 0xFF, 0x03, // LDA $03FF,X  page cross
+			0xEA, // NOP
+		},
+	})
+
+	testRunTo(t, 0x030D)
+
+	for _, test := range []struct {
+		addr uint16
+		want Address
+	}{
+		{0x02F2, Address{Instructions: 1, Cycles: 4, PageCrosses: 1, Branches: 1, BranchesTaken: 1}},
+		{0x0304, Address{Instructions: 1, Cycles: 2, Branches: 1}},
+		{0x0306, Address{Instructions: 1, Cycles: 4}},
+		{0x030A, Address{Instructions: 1, Cycles: 5, PageCrosses: 1}},
+	} {
+		if a := p.Address(test.addr); a != test.want {
+			t.Errorf("$%04X: %+v, want %+v", test.addr, a, test.want)
+		}
+	}
+}
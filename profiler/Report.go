@@ -0,0 +1,200 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+	"github.com/cassianoperin/6502_GO_Core/disassembler"
+)
+
+// -------------------------------- Helpers --------------------------------- //
+
+func sortFunctions(list []*Function) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Inclusive != list[j].Inclusive {
+			return list[i].Inclusive > list[j].Inclusive
+		}
+		return list[i].Entry < list[j].Entry
+	})
+}
+
+// Callees returns the calls made by the function, sorted by inclusive cycles
+func (fn *Function) Callees() []*Call {
+
+	list := make([]*Call, 0, len(fn.calls))
+	for _, c := range fn.calls {
+		list = append(list, c)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Cycles != list[j].Cycles {
+			return list[i].Cycles > list[j].Cycles
+		}
+		if list[i].Site != list[j].Site {
+			return list[i].Site < list[j].Site
+		}
+		return list[i].Callee < list[j].Callee
+	})
+
+	return list
+}
+
+// Name returns the symbol of an address or its hexadecimal value
+func (p *Profiler) Name(addr uint16) string {
+
+	if p.Symbols != nil {
+		if name, ok := p.Symbols(addr); ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("$%04X", addr)
+}
+
+func percent(value, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) * 100 / float64(total)
+}
+
+// ------------------------------ Text Report ------------------------------- //
+
+// Report writes the subroutines sorted by inclusive cycles and the addresses
+// sorted by cycles, max limits the lines of each table (0 = all)
+func (p *Profiler) Report(w io.Writer, max int) error {
+
+	out := bufio.NewWriter(w)
+	total := p.cycles
+
+	fmt.Fprintf(out, "Cycles: %d  Instructions: %d", total, p.instructions)
+	if p.instructions > 0 {
+		fmt.Fprintf(out, "  (%.2f cycles per instruction)", float64(total)/float64(p.instructions))
+	}
+	fmt.Fprintln(out)
+
+	// Subroutines
+	functions := p.Functions()
+	if max > 0 && len(functions) > max {
+		functions = functions[:max]
+	}
+
+	fmt.Fprintf(out, "\nSubroutines\n\n")
+	fmt.Fprintf(out, "%12s %7s %12s %7s %10s  %s\n", "Inclusive", "%", "Exclusive", "%", "Calls", "Subroutine")
+
+	for _, fn := range functions {
+		name := p.Name(fn.Entry)
+		if fn.Interrupt {
+			name += " (interrupt)"
+		}
+		fmt.Fprintf(out, "%12d %6.2f%% %12d %6.2f%% %10d  %s\n",
+			fn.Inclusive, percent(fn.Inclusive, total), fn.Exclusive, percent(fn.Exclusive, total), fn.Calls, name)
+	}
+
+	// Addresses
+	var addresses []uint16
+	for i := range p.addresses {
+		if p.addresses[i].Instructions > 0 {
+			addresses = append(addresses, uint16(i))
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := &p.addresses[addresses[i]], &p.addresses[addresses[j]]
+		if a.Cycles != b.Cycles {
+			return a.Cycles > b.Cycles
+		}
+		return addresses[i] < addresses[j]
+	})
+
+	if max > 0 && len(addresses) > max {
+		addresses = addresses[:max]
+	}
+
+	opts := disassembler.Options{Undocumented: true, Symbols: p.Symbols}
+
+	fmt.Fprintf(out, "\nAddresses\n\n")
+	fmt.Fprintf(out, "%12s %7s %12s %10s %15s  %-5s  %s\n", "Cycles", "%", "Executions", "PageCross", "Branches taken", "Addr", "Instruction")
+
+	for _, addr := range addresses {
+
+		a := &p.addresses[addr]
+
+		branches := ""
+		if a.Branches > 0 {
+			branches = fmt.Sprintf("%d/%d", a.BranchesTaken, a.Branches)
+		}
+
+		text := disassembler.Decode(CPU_6502.ReadMemory, addr, opts).Text
+
+		fmt.Fprintf(out, "%12d %6.2f%% %12d %10d %15s  $%04X  %s\n",
+			a.Cycles, percent(a.Cycles, total), a.Instructions, a.PageCrosses, branches, addr, text)
+	}
+
+	return out.Flush()
+}
+
+// ------------------------------- Callgrind -------------------------------- //
+
+// WriteCallgrind exports the profile in the callgrind format, with the
+// instruction addresses as positions and the Cycles and Instructions events
+func (p *Profiler) WriteCallgrind(w io.Writer) error {
+
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "# callgrind format")
+	fmt.Fprintln(out, "version: 1")
+	fmt.Fprintln(out, "creator: 6502_GO_Core profiler")
+	fmt.Fprintln(out, "positions: instr")
+	fmt.Fprintln(out, "events: Cycles Instructions")
+	fmt.Fprintf(out, "summary: %d %d\n", p.cycles, p.instructions)
+
+	functions := p.results()
+
+	entries := make([]uint16, 0, len(functions))
+	for entry := range functions {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+
+	for _, entry := range entries {
+
+		fn := functions[entry]
+
+		fmt.Fprintf(out, "\nfn=%s\n", p.callgrindName(entry))
+
+		addrs := make([]uint16, 0, len(fn.costs))
+		for addr := range fn.costs {
+			addrs = append(addrs, addr)
+		}
+		sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+		for _, addr := range addrs {
+			c := fn.costs[addr]
+			fmt.Fprintf(out, "0x%04x %d %d\n", addr, c.cycles, c.instructions)
+		}
+
+		for _, c := range fn.Callees() {
+			fmt.Fprintf(out, "cfn=%s\n", p.callgrindName(c.Callee))
+			fmt.Fprintf(out, "calls=%d 0x%04x\n", c.Count, c.Callee)
+			fmt.Fprintf(out, "0x%04x %d %d\n", c.Site, c.Cycles, c.Instructions)
+		}
+	}
+
+	return out.Flush()
+}
+
+// Function names must be unique, the address is added to the symbols
+func (p *Profiler) callgrindName(addr uint16) string {
+
+	if p.Symbols != nil {
+		if name, ok := p.Symbols(addr); ok {
+			return fmt.Sprintf("%s $%04X", name, addr)
+		}
+	}
+
+	return fmt.Sprintf("$%04X", addr)
+}
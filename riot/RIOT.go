@@ -0,0 +1,346 @@
+// Package riot emulates the MOS 6532 RAM-I/O-Timer.
+//
+// The chip has 128 bytes of RAM, two 8 bits ports with data direction
+// registers, an interval timer counting every 1, 8, 64 or 1024 cycles and
+// an edge detector on PA7. The RS input (A9 in the offsets) selects the RAM
+// or the registers:
+//
+//	A2=0         A1-A0: 0 port A, 1 DDRA, 2 port B, 3 DDRB
+//	A2=1 read    A0=0 timer (A3 enables its interrupt, clears its flag)
+//	             A0=1 interrupt flags (bit 7 timer, bit 6 PA7, clears PA7)
+//	A2=1 write   A4=1 start the timer, A1-A0 select the interval, A3 enables its interrupt
+//	             A4=0 PA7 edge control, A0 positive edge, A1 enables its interrupt
+//
+// When the timer passes zero its flag is set and it keeps counting down
+// every cycle from $FF, until the next start.
+package riot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Atari 2600 registers (I/O offsets)
+const (
+	SWCHA  = 0x280 // Port A (joysticks)
+	SWACNT = 0x281 // DDRA
+	SWCHB  = 0x282 // Port B (console switches)
+	SWBCNT = 0x283 // DDRB
+	INTIM  = 0x284 // Timer
+	INSTAT = 0x285 // Interrupt flags
+	TIM1T  = 0x294 // Start the timer, 1 cycle interval
+	TIM8T  = 0x295
+	TIM64T = 0x296
+	T1024T = 0x297
+)
+
+var intervals = [4]uint16{1, 8, 64, 1024}
+
+// Chip state, saved in the snapshots
+type state struct {
+	RAM                  [128]byte
+	ORA, DDRA, ORB, DDRB byte
+	InA, InB             byte // Levels driven on the pins by the peripherals
+
+	Timer       byte
+	Interval    uint16 // Cycles per count
+	Divider     uint16 // Cycles to the next count
+	Underflow   bool   // Passed zero, counting every cycle
+	TimerFlag   bool
+	TimerIRQ    bool
+	PA7Flag     bool
+	PA7IRQ      bool
+	PA7Positive bool // Edge detected on PA7
+}
+
+// RIOT is a 6532 chip
+type RIOT struct {
+	s   state
+	irq *CPU_6502.InterruptLine // nil when the IRQ output isn't connected
+
+	// Output changes, for the peripherals (any can be nil)
+	OnPortA func(value byte)
+	OnPortB func(value byte)
+}
+
+// New creates a chip in the reset state
+func New() *RIOT {
+
+	r := &RIOT{}
+	r.s.InA, r.s.InB = 0xFF, 0xFF
+	r.Reset()
+
+	return r
+}
+
+// Attach2600 creates a chip with the Atari 2600 decoding: selected by A12=0 and
+// A7=1, A9 selects the RAM ($80-$FF, also the stack at $180-$1FF) or the
+// registers ($280-$29F), mirrored in the whole address space. The 6507 has no
+// IRQ input, so the IRQ output isn't connected.
+func Attach2600() (*RIOT, error) {
+
+	r := New()
+
+	if err := CPU_6502.AttachDecodedDevice(r, 0x1080, 0x0080); err != nil {
+		return nil, err
+	}
+
+	CPU_6502.RegisterSnapshotChunk("RIOT", r.save, r.restore)
+
+	return r, nil
+}
+
+// Detach removes the chip from the bus
+func (r *RIOT) Detach() {
+	CPU_6502.DetachDevice(r)
+	CPU_6502.UnregisterSnapshotChunk("RIOT")
+	if r.irq != nil {
+		r.irq.Set(false)
+	}
+}
+
+// ConnectIRQ connects the IRQ output to the CPU, for the 6502 boards
+func (r *RIOT) ConnectIRQ() {
+	if r.irq == nil {
+		r.irq = CPU_6502.NewIRQLine()
+		r.updateIRQ()
+	}
+}
+
+// Reset clears the ports, their directions and the interrupt enables (the RAM and the timer keep their values)
+func (r *RIOT) Reset() {
+	r.s.ORA, r.s.DDRA, r.s.ORB, r.s.DDRB = 0, 0, 0, 0
+	r.s.TimerIRQ, r.s.PA7IRQ, r.s.PA7Flag, r.s.PA7Positive = false, false, false, false
+	if r.s.Interval == 0 {
+		r.s.Interval, r.s.Divider = 1024, 1024
+	}
+	r.updateIRQ()
+}
+
+// RAM returns the chip RAM
+func (r *RIOT) RAM() *[128]byte {
+	return &r.s.RAM
+}
+
+// ---------------------------------- Pins ---------------------------------- //
+
+// PortA returns the levels of the port A pins
+func (r *RIOT) PortA() byte {
+	return r.s.ORA&r.s.DDRA | r.s.InA&^r.s.DDRA
+}
+
+// PortB returns the levels of the port B pins
+func (r *RIOT) PortB() byte {
+	return r.s.ORB&r.s.DDRB | r.s.InB&^r.s.DDRB
+}
+
+// SetPortA sets the levels driven on the port A pins by the peripherals
+func (r *RIOT) SetPortA(value byte) {
+	pa7 := r.PortA() & 0x80
+	r.s.InA = value
+	r.edgePA7(pa7)
+}
+
+// SetPortB sets the levels driven on the port B pins by the peripherals
+func (r *RIOT) SetPortB(value byte) {
+	r.s.InB = value
+}
+
+// Edge detection on PA7, from its previous level
+func (r *RIOT) edgePA7(old byte) {
+
+	pa7 := r.PortA() & 0x80
+
+	if pa7 != old && (pa7 != 0) == r.s.PA7Positive {
+		r.s.PA7Flag = true
+		r.updateIRQ()
+	}
+}
+
+func (r *RIOT) portAChanged() {
+	if r.OnPortA != nil {
+		r.OnPortA(r.PortA())
+	}
+}
+
+func (r *RIOT) portBChanged() {
+	if r.OnPortB != nil {
+		r.OnPortB(r.PortB())
+	}
+}
+
+// ------------------------------- Registers -------------------------------- //
+
+// Read the RAM or a register (CPU data BUS)
+func (r *RIOT) Read(offset uint16) byte {
+
+	if offset&0x200 == 0 {
+		return r.s.RAM[offset&0x7F]
+	}
+
+	var value byte
+
+	switch {
+
+	case offset&0x04 == 0:
+		switch offset & 3 {
+		case 0:
+			value = r.PortA()
+		case 1:
+			value = r.s.DDRA
+		case 2:
+			value = r.PortB()
+		case 3:
+			value = r.s.DDRB
+		}
+
+	case offset&0x01 == 0:
+		value = r.s.Timer
+		r.s.TimerIRQ = offset&0x08 != 0
+		r.s.TimerFlag = false
+		r.updateIRQ()
+
+	default:
+		if r.s.TimerFlag {
+			value |= 0x80
+		}
+		if r.s.PA7Flag {
+			value |= 0x40
+		}
+		r.s.PA7Flag = false
+		r.updateIRQ()
+	}
+
+	return value
+}
+
+// Write the RAM or a register (CPU data BUS)
+func (r *RIOT) Write(offset uint16, value byte) {
+
+	if offset&0x200 == 0 {
+		r.s.RAM[offset&0x7F] = value
+		return
+	}
+
+	switch {
+
+	case offset&0x04 == 0:
+		pa7 := r.PortA() & 0x80
+		switch offset & 3 {
+		case 0:
+			r.s.ORA = value
+		case 1:
+			r.s.DDRA = value
+		case 2:
+			r.s.ORB = value
+		case 3:
+			r.s.DDRB = value
+		}
+		if offset&2 == 0 {
+			r.portAChanged()
+			r.edgePA7(pa7)
+		} else {
+			r.portBChanged()
+		}
+
+	case offset&0x10 != 0:
+		// Counts once on the next cycle, then every interval
+		r.s.Timer = value
+		r.s.Interval = intervals[offset&3]
+		r.s.Divider = 1
+		r.s.Underflow = false
+		r.s.TimerIRQ = offset&0x08 != 0
+		r.s.TimerFlag = false
+		r.updateIRQ()
+
+	default:
+		r.s.PA7Positive = offset&0x01 != 0
+		r.s.PA7IRQ = offset&0x02 != 0
+		r.updateIRQ()
+	}
+}
+
+func (r *RIOT) updateIRQ() {
+	if r.irq != nil {
+		r.irq.Set(r.s.TimerFlag && r.s.TimerIRQ || r.s.PA7Flag && r.s.PA7IRQ)
+	}
+}
+
+// --------------------------------- Timer ---------------------------------- //
+
+// Clock runs one cycle (CPU_6502.Clocked)
+func (r *RIOT) Clock() {
+
+	r.s.Divider--
+	if r.s.Divider != 0 {
+		return
+	}
+
+	r.s.Divider = r.s.Interval
+	if r.s.Underflow {
+		r.s.Divider = 1
+	}
+
+	r.s.Timer--
+
+	if r.s.Timer == 0xFF {
+		r.s.Underflow = true
+		r.s.Divider = 1
+		r.s.TimerFlag = true
+		r.updateIRQ()
+	}
+}
+
+// ClockCycles runs several cycles (CPU_6502.MultiClocked)
+func (r *RIOT) ClockCycles(cycles int) {
+	for ; cycles > 0; cycles-- {
+		r.Clock()
+	}
+}
+
+// ------------------------------- Snapshots -------------------------------- //
+
+func (r *RIOT) save() []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, &r.s)
+	return buf.Bytes()
+}
+
+func (r *RIOT) restore(data []byte) error {
+
+	var s state
+
+	if len(data) != binary.Size(&s) {
+		return fmt.Errorf("invalid RIOT state size %d", len(data))
+	}
+	_ = binary.Read(bytes.NewReader(data), binary.LittleEndian, &s)
+
+	if s.Interval == 0 || s.Divider == 0 {
+		return fmt.Errorf("invalid RIOT timer state")
+	}
+
+	r.s = s
+	r.updateIRQ()
+	r.portAChanged()
+	r.portBChanged()
+
+	return nil
+}
+
+// String shows the registers, for the monitors
+func (r *RIOT) String() string {
+
+	flags := "--"
+	if r.s.TimerFlag {
+		flags = "T" + flags[1:]
+	}
+	if r.s.PA7Flag {
+		flags = flags[:1] + "A"
+	}
+
+	return fmt.Sprintf("RIOT  PA %02X DDRA %02X  PB %02X DDRB %02X  TIMER %02X /%d  FLAGS %s",
+		r.PortA(), r.s.DDRA, r.PortB(), r.s.DDRB, r.s.Timer, r.s.Interval, flags)
+}
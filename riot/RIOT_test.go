@@ -0,0 +1,170 @@
+package riot
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Chip outside the bus with its IRQ output connected, released at the end of the test
+func testRIOT(t *testing.T) *RIOT {
+	r := New()
+	r.ConnectIRQ()
+	t.Cleanup(func() { r.irq.Set(false) })
+	return r
+}
+
+// The timer counts once on the next cycle, then every interval, and every cycle after zero
+func TestTimer(t *testing.T) {
+
+	r := testRIOT(t)
+
+	for _, test := range []struct {
+		cycles int
+		timer  byte
+	}{{0, 2}, {1, 1}, {8, 1}, {9, 0}, {16, 0}, {17, 0xFF}, {18, 0xFE}, {20, 0xFC}} {
+		r.Write(TIM8T, 2)
+		r.ClockCycles(test.cycles)
+		if flag := r.Read(INSTAT)&0x80 != 0; flag != (test.cycles >= 17) {
+			t.Errorf("timer flag %v after %d cycles", flag, test.cycles)
+		}
+		if timer := r.Read(INTIM); timer != test.timer {
+			t.Errorf("timer $%02X after %d cycles, want $%02X", timer, test.cycles, test.timer)
+		}
+	}
+
+	// The flag doesn't interrupt without A3, reading the timer clears it
+	if r.irq.Active() {
+		t.Errorf("IRQ without the timer interrupt enabled")
+	}
+	if r.Read(INSTAT) != 0 {
+		t.Errorf("flags after the timer read")
+	}
+
+	// T1024T is the power-on interval
+	if n := New(); n.s.Interval != 1024 {
+		t.Errorf("power-on interval %d", n.s.Interval)
+	}
+}
+
+// The timer and PA7 interrupts, enabled by A3 and A1
+func TestInterrupts(t *testing.T) {
+
+	r := testRIOT(t)
+
+	r.Write(TIM1T|0x08, 1)
+	r.ClockCycles(1)
+	if r.irq.Active() {
+		t.Errorf("IRQ before the timer passed zero")
+	}
+	r.Clock()
+	if !r.irq.Active() || r.Read(INSTAT) != 0x80 {
+		t.Errorf("no IRQ after the timer passed zero")
+	}
+
+	// Reading with A3 keeps the interrupt enabled
+	r.Read(INTIM | 0x08)
+	if r.irq.Active() || !r.s.TimerIRQ {
+		t.Errorf("timer read: IRQ %v, enabled %v", r.irq.Active(), r.s.TimerIRQ)
+	}
+	r.Read(INTIM)
+
+	// PA7 positive edge with interrupt
+	r.Write(0x287, 0)
+	r.SetPortA(0x7F)
+	if r.irq.Active() {
+		t.Errorf("IRQ on the negative edge")
+	}
+	r.SetPortA(0xFF)
+	if !r.irq.Active() {
+		t.Errorf("no IRQ on the positive edge")
+	}
+	if r.Read(INSTAT) != 0x40 || r.irq.Active() || r.Read(INSTAT) != 0 {
+		t.Errorf("PA7 flag not cleared by the flags read")
+	}
+
+	// Negative edge driven by the output register, without interrupt
+	r.Write(0x284, 0)
+	r.Write(SWACNT, 0x80)
+	r.Write(SWCHA, 0x00)
+	if r.irq.Active() || r.Read(INSTAT) != 0x40 {
+		t.Errorf("PA7 output edge: IRQ %v", r.irq.Active())
+	}
+}
+
+func TestPorts(t *testing.T) {
+
+	r := testRIOT(t)
+
+	var a, b []byte
+	r.OnPortA = func(value byte) { a = append(a, value) }
+	r.OnPortB = func(value byte) { b = append(b, value) }
+
+	r.SetPortA(0xF0)
+	r.Write(SWACNT, 0x0F)
+	r.Write(SWCHA, 0x55)
+	r.Write(SWBCNT, 0xFF)
+	r.Write(SWCHB, 0x0B)
+
+	if r.Read(SWCHA) != 0xF5 || r.Read(SWACNT) != 0x0F || r.Read(SWCHB) != 0x0B || r.Read(SWBCNT) != 0xFF {
+		t.Errorf("ports %s", r)
+	}
+	if len(a) != 2 || a[1] != 0xF5 || len(b) != 2 || b[1] != 0x0B {
+		t.Errorf("port changes A %X, B %X", a, b)
+	}
+
+	r.Reset()
+	if r.PortA() != 0xF0 || r.PortB() != 0xFF {
+		t.Errorf("ports after reset %s", r)
+	}
+}
+
+// Atari 2600 decoding: RAM at $80 mirrored in the stack, registers at $280
+func TestAttach2600(t *testing.T) {
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+
+	copy(CPU_6502.Memory[0xF000:], []byte{
+		0xA9, 0x42, //       LDA #$42
+		0x85, 0x80, //       STA $80
+		0xAE, 0x80, 0x01, // LDX $0180
+		0x8D, 0x96, 0x02, // STA TIM64T
+		0x4C, 0x0A, 0xF0, // JMP *
+	})
+	CPU_6502.PC = 0xF000
+
+	r, err := Attach2600()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Detach)
+
+	for i := 0; i < 4; i++ {
+		CPU_6502.Step()
+	}
+
+	// The timer counted once on the cycle after the write
+	if r.RAM()[0] != 0x42 || CPU_6502.X != 0x42 || r.s.Timer != 0x41 || r.s.Interval != 64 {
+		t.Errorf("RAM $%02X, X $%02X, %s", r.RAM()[0], CPU_6502.X, r)
+	}
+
+	snap := CPU_6502.Snapshot()
+	r.Write(T1024T, 0)
+	r.RAM()[0] = 0
+
+	if err := CPU_6502.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if r.RAM()[0] != 0x42 || r.s.Interval != 64 {
+		t.Errorf("restored %s", r)
+	}
+
+	bad := r.save()
+	bad[len(bad)-10] = 0 // Interval
+	bad[len(bad)-9] = 0
+	if err := r.restore(bad); err == nil || err.Error() != "invalid RIOT timer state" {
+		t.Errorf("error %v", err)
+	}
+}
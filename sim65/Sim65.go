@@ -0,0 +1,394 @@
+// Package sim65 runs the programs of the cc65 sim6502 target, like the sim65
+// simulator: C programs compiled with cc65 use the host file system, command
+// line arguments and exit code through the paravirtualization interface.
+//
+// The programs start with a 12 bytes header:
+//
+//	"sim65", version 2, CPU (0 6502, 1 65C02), zero page address of the C
+//	stack pointer, load address and start address (little endian)
+//
+// The interface routines are called with JSR at $FFF4 open, $FFF5 close,
+// $FFF6 read, $FFF7 write, $FFF8 args and $FFF9 exit, with the cc65 fastcall
+// convention: the last argument in A / X, the others on the C stack. The
+// results are returned in A / X, -1 on errors. The core only executes the
+// 6502 instruction set, so sim65c02 programs are rejected.
+package sim65
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Paravirtualization addresses
+const (
+	PV_OPEN  = 0xFFF4
+	PV_CLOSE = 0xFFF5
+	PV_READ  = 0xFFF6
+	PV_WRITE = 0xFFF7
+	PV_ARGS  = 0xFFF8
+	PV_EXIT  = 0xFFF9
+)
+
+// Exit codes of sim65 for its own errors
+const (
+	EXIT_ERROR   = 0x7F
+	EXIT_TIMEOUT = 0x7E
+)
+
+// cc65 open() flags
+const (
+	o_RDONLY = 0x01
+	o_WRONLY = 0x02
+	o_CREAT  = 0x10
+	o_TRUNC  = 0x20
+	o_APPEND = 0x40
+	o_EXCL   = 0x80
+)
+
+// Program is a sim6502 binary
+type Program struct {
+	CPU   byte   // 0 6502, 1 65C02
+	SP    byte   // Zero page address of the C stack pointer
+	Load  uint16 // Load address
+	Start uint16 // Start address
+	Code  []byte
+}
+
+// Config of a run
+type Config struct {
+	Args      []string  // argv, argv[0] is the program name
+	Stdin     io.Reader // File descriptors 0, 1 and 2 (default the host ones)
+	Stdout    io.Writer
+	Stderr    io.Writer
+	MaxCycles uint64 // 0 = no limit
+}
+
+// ErrTimeout is returned when the program runs for more than MaxCycles
+var ErrTimeout = fmt.Errorf("cycle limit reached")
+
+// LoadProgram reads a sim6502 binary file
+func LoadProgram(filename string) (*Program, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := ReadProgram(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return p, nil
+}
+
+// ReadProgram reads a sim6502 binary
+func ReadProgram(r io.Reader) (*Program, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[:5]) != "sim65" {
+		return nil, fmt.Errorf("not a sim65 program")
+	}
+	if data[5] != 2 {
+		return nil, fmt.Errorf("unsupported sim65 header version %d", data[5])
+	}
+
+	p := &Program{
+		CPU:   data[6],
+		SP:    data[7],
+		Load:  uint16(data[8]) | uint16(data[9])<<8,
+		Start: uint16(data[10]) | uint16(data[11])<<8,
+		Code:  data[12:],
+	}
+
+	switch {
+	case p.CPU == 1:
+		return nil, fmt.Errorf("sim65c02 program, the core doesn't execute the 65C02 instructions")
+	case p.CPU != 0:
+		return nil, fmt.Errorf("unknown CPU type %d", p.CPU)
+	case int(p.Load)+len(p.Code) > PV_OPEN:
+		return nil, fmt.Errorf("%d bytes at $%04X overlap the paravirtualization addresses", len(p.Code), p.Load)
+	}
+
+	return p, nil
+}
+
+// Runtime state of a run
+type sim struct {
+	p      *Program
+	args   []string
+	files  map[int]any // Open files (io.Reader, io.Writer, io.Closer)
+	exited bool
+	code   int
+}
+
+// Run executes a program until it calls exit (or returns from main), returning its exit code
+func Run(p *Program, cfg Config) (int, error) {
+
+	if cfg.Stdin == nil {
+		cfg.Stdin = os.Stdin
+	}
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = os.Stderr
+	}
+
+	s := &sim{
+		p:     p,
+		args:  cfg.Args,
+		files: map[int]any{0: cfg.Stdin, 1: cfg.Stdout, 2: cfg.Stderr},
+	}
+	defer s.closeAll()
+
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+	copy(CPU_6502.Memory[p.Load:], p.Code)
+	CPU_6502.Reset()
+	CPU_6502.PC = p.Start
+
+	handlers := map[uint16]func(r *CPU_6502.Registers){
+		PV_OPEN:  s.open,
+		PV_CLOSE: s.close,
+		PV_READ:  s.read,
+		PV_WRITE: s.write,
+		PV_ARGS:  s.argv,
+		PV_EXIT:  s.exit,
+	}
+	for addr, h := range handlers {
+		h := h
+		CPU_6502.SetTrap(addr, func(r *CPU_6502.Registers) CPU_6502.TrapAction {
+			h(r)
+			return CPU_6502.TRAP_RTS
+		})
+	}
+	defer CPU_6502.ClearTraps()
+
+	for !s.exited {
+		if cfg.MaxCycles != 0 && CPU_6502.Cycle >= cfg.MaxCycles {
+			return EXIT_TIMEOUT, ErrTimeout
+		}
+		if CPU_6502.Halted {
+			return EXIT_ERROR, fmt.Errorf("CPU halted on opcode $%02X at $%04X", CPU_6502.Memory[CPU_6502.PC], CPU_6502.PC)
+		}
+		CPU_6502.CPU_Interpreter()
+	}
+
+	return s.code, nil
+}
+
+func (s *sim) closeAll() {
+	for fd, f := range s.files {
+		if c, ok := f.(io.Closer); ok && fd > 2 {
+			c.Close()
+		}
+	}
+}
+
+// ----------------------------- Calling convention ------------------------- //
+
+// Pop a word parameter from the C stack, incr is the size of the parameter
+func (s *sim) pop(incr uint16) uint16 {
+	sp := s.word(uint16(s.p.SP))
+	value := s.word(sp)
+	s.setWord(uint16(s.p.SP), sp+incr)
+	return value
+}
+
+func (s *sim) word(addr uint16) uint16 {
+	return uint16(CPU_6502.ReadMemory(addr)) | uint16(CPU_6502.ReadMemory(addr+1))<<8
+}
+
+func (s *sim) setWord(addr, value uint16) {
+	CPU_6502.WriteMemory(addr, byte(value))
+	CPU_6502.WriteMemory(addr+1, byte(value>>8))
+}
+
+func ax(r *CPU_6502.Registers) uint16 {
+	return uint16(r.A) | uint16(r.X)<<8
+}
+
+func setAX(r *CPU_6502.Registers, value int) {
+	r.A, r.X = byte(value), byte(value>>8)
+}
+
+// ------------------------------- Functions -------------------------------- //
+
+// int open(const char* name, int flags, ...), Y has the size of the parameters
+func (s *sim) open(r *CPU_6502.Registers) {
+
+	mode := s.pop(uint16(r.Y) - 4)
+	flags := s.pop(2)
+	name := s.pop(2)
+
+	if r.Y < 6 { // No mode argument
+		mode = 0x03
+	}
+
+	var path []byte
+	for c := CPU_6502.ReadMemory(name); c != 0; c = CPU_6502.ReadMemory(name) {
+		path = append(path, c)
+		name++
+	}
+
+	oflag := 0
+	switch flags & (o_RDONLY | o_WRONLY) {
+	case o_WRONLY:
+		oflag = os.O_WRONLY
+	case o_RDONLY | o_WRONLY:
+		oflag = os.O_RDWR
+	}
+	if flags&o_CREAT != 0 {
+		oflag |= os.O_CREATE
+	}
+	if flags&o_TRUNC != 0 {
+		oflag |= os.O_TRUNC
+	}
+	if flags&o_APPEND != 0 {
+		oflag |= os.O_APPEND
+	}
+	if flags&o_EXCL != 0 {
+		oflag |= os.O_EXCL
+	}
+
+	// Mode 0x01 read, 0x02 write (for the owner, group and others like sim65)
+	var perm os.FileMode
+	if mode&0x01 != 0 {
+		perm |= 0444
+	}
+	if mode&0x02 != 0 {
+		perm |= 0222
+	}
+
+	f, err := os.OpenFile(string(path), oflag, perm)
+	if err != nil {
+		setAX(r, -1)
+		return
+	}
+
+	fd := 3
+	for s.files[fd] != nil {
+		fd++
+	}
+	s.files[fd] = f
+
+	setAX(r, fd)
+}
+
+// int close(int fd)
+func (s *sim) close(r *CPU_6502.Registers) {
+
+	fd := int(ax(r))
+
+	f, ok := s.files[fd]
+	if !ok {
+		setAX(r, -1)
+		return
+	}
+	delete(s.files, fd)
+
+	if c, ok := f.(io.Closer); ok && fd > 2 {
+		if c.Close() != nil {
+			setAX(r, -1)
+			return
+		}
+	}
+
+	setAX(r, 0)
+}
+
+// int read(int fd, void* buf, unsigned count)
+func (s *sim) read(r *CPU_6502.Registers) {
+
+	count := ax(r)
+	buf := s.pop(2)
+	fd := int(s.pop(2))
+
+	f, ok := s.files[fd].(io.Reader)
+	if !ok {
+		setAX(r, -1)
+		return
+	}
+
+	data := make([]byte, count)
+	n, err := f.Read(data)
+	if err != nil && err != io.EOF {
+		setAX(r, -1)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		CPU_6502.WriteMemory(buf+uint16(i), data[i])
+	}
+
+	setAX(r, n)
+}
+
+// int write(int fd, const void* buf, unsigned count)
+func (s *sim) write(r *CPU_6502.Registers) {
+
+	count := ax(r)
+	buf := s.pop(2)
+	fd := int(s.pop(2))
+
+	f, ok := s.files[fd].(io.Writer)
+	if !ok {
+		setAX(r, -1)
+		return
+	}
+
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = CPU_6502.ReadMemory(buf + uint16(i))
+	}
+
+	n, err := f.Write(data)
+	if err != nil {
+		setAX(r, -1)
+		return
+	}
+
+	setAX(r, n)
+}
+
+// Copy argv below the C stack and store its address at the pointer in A / X, returns argc
+func (s *sim) argv(r *CPU_6502.Registers) {
+
+	argv := ax(r)
+	sp := s.word(uint16(s.p.SP))
+	args := sp - uint16(len(s.args)+1)*2
+
+	s.setWord(argv, args)
+
+	sp = args
+	for _, arg := range s.args {
+		sp -= uint16(len(arg) + 1)
+		for i := 0; i < len(arg); i++ {
+			CPU_6502.WriteMemory(sp+uint16(i), arg[i])
+		}
+		CPU_6502.WriteMemory(sp+uint16(len(arg)), 0)
+
+		s.setWord(args, sp)
+		args += 2
+	}
+	s.setWord(args, 0)
+
+	s.setWord(uint16(s.p.SP), sp)
+
+	setAX(r, len(s.args))
+}
+
+// void exit(int code), the code is in A
+func (s *sim) exit(r *CPU_6502.Registers) {
+	s.exited = true
+	s.code = int(r.A)
+}
@@ -0,0 +1,189 @@
+package sim65
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Program at $0200 with the C stack pointer at $02
+func testProgram(code ...byte) []byte {
+	return append([]byte{'s', 'i', 'm', '6', '5', 2, 0, 0x02, 0x00, 0x02, 0x00, 0x02}, code...)
+}
+
+// Runtime state outside of Run, with the C stack at $0400
+func testSim(args ...string) *sim {
+
+	CPU_6502.Initialize()
+
+	return &sim{p: &Program{SP: 0x02}, args: args, files: map[int]any{}}
+}
+
+// Calls a function with A / X and Y, the parameters pushed on the C stack (the last one on top)
+func testCall(f func(r *CPU_6502.Registers), ax uint16, y byte, params ...uint16) uint16 {
+
+	sp := uint16(0x0400) - uint16(len(params))*2
+	for i, p := range params {
+		CPU_6502.Memory[sp+uint16(len(params)-1-i)*2] = byte(p)
+		CPU_6502.Memory[sp+uint16(len(params)-1-i)*2+1] = byte(p >> 8)
+	}
+	CPU_6502.Memory[0x02], CPU_6502.Memory[0x03] = byte(sp), byte(sp>>8)
+
+	r := CPU_6502.Registers{A: byte(ax), X: byte(ax >> 8), Y: y}
+	f(&r)
+
+	return uint16(r.A) | uint16(r.X)<<8
+}
+
+func TestRun(t *testing.T) {
+
+	CPU_6502.Debug = false
+
+	code := testProgram(
+		0xA9, 0x30, 0x85, 0x02, 0xA9, 0x02, 0x85, 0x03, // C stack at $0230
+		0xA9, 0x03, 0xA2, 0x00, 0x20, 0xF7, 0xFF, //       write(1, "hi\n", 3)
+		0xA9, 0x2A, 0x20, 0xF9, 0xFF, //                   exit(42)
+	)
+	code = append(code, make([]byte, 0x30-(len(code)-12))...)
+	code = append(code, 0x38, 0x02, 0x01, 0x00, 0, 0, 0, 0, 'h', 'i', '\n')
+
+	p, err := ReadProgram(bytes.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code, err := Run(p, Config{Stdout: &out}); err != nil || code != 42 {
+		t.Errorf("exit code %d, %v", code, err)
+	}
+	if out.String() != "hi\n" || CPU_6502.Memory[0x02] != 0x34 {
+		t.Errorf("output %q, C stack $%02X%02X", out.String(), CPU_6502.Memory[0x03], CPU_6502.Memory[0x02])
+	}
+
+	// Endless loop and halted CPU
+	p.Code = []byte{0x4C, 0x00, 0x02}
+	if code, err := Run(p, Config{MaxCycles: 100}); err != ErrTimeout || code != EXIT_TIMEOUT {
+		t.Errorf("loop: exit code %d, %v", code, err)
+	}
+	p.Code = []byte{0xEA, 0x02}
+	if code, err := Run(p, Config{}); err == nil || err.Error() != "CPU halted on opcode $02 at $0201" || code != EXIT_ERROR {
+		t.Errorf("halt: exit code %d, %v", code, err)
+	}
+}
+
+// Files created, written, closed and read back through the host file system
+func TestFiles(t *testing.T) {
+
+	s := testSim()
+	s.files[1] = io.Discard
+
+	name := filepath.Join(t.TempDir(), "out.txt")
+	copy(CPU_6502.Memory[0x0300:], name+"\x00")
+	copy(CPU_6502.Memory[0x0380:], "data")
+
+	if fd := testCall(s.open, 0, 6, 0x0300, o_WRONLY|o_CREAT|o_TRUNC, 0x03); fd != 3 {
+		t.Fatalf("open for writing returned %d", fd)
+	}
+	if n := testCall(s.write, 4, 0, 3, 0x0380); n != 4 {
+		t.Errorf("write returned %d", n)
+	}
+	if testCall(s.close, 3, 0) != 0 || testCall(s.close, 3, 0) != 0xFFFF {
+		t.Errorf("close of a closed file succeeded")
+	}
+
+	if data, err := os.ReadFile(name); err != nil || string(data) != "data" {
+		t.Errorf("file %q, %v", data, err)
+	}
+
+	// Without the mode argument, fd 3 is free again
+	if fd := testCall(s.open, 0, 4, 0x0300, o_RDONLY); fd != 3 {
+		t.Fatalf("open for reading returned %d", fd)
+	}
+	if n := testCall(s.read, 10, 0, 3, 0x0500); n != 4 || string(CPU_6502.Memory[0x0500:0x0504]) != "data" {
+		t.Errorf("read %d bytes: %q", n, CPU_6502.Memory[0x0500:0x0504])
+	}
+	if n := testCall(s.read, 10, 0, 3, 0x0500); n != 0 {
+		t.Errorf("read %d bytes at the end of the file", n)
+	}
+
+	// Errors return -1
+	for _, test := range []struct {
+		name string
+		f    func(r *CPU_6502.Registers)
+		ax   uint16
+		y    byte
+		args []uint16
+	}{
+		{"exclusive open", s.open, 0, 4, []uint16{0x0300, o_WRONLY | o_CREAT | o_EXCL}},
+		{"read of a write only fd", s.read, 1, 0, []uint16{1, 0x0500}},
+		{"write of a read only fd", s.write, 1, 0, []uint16{3, 0x0380}},
+		{"read of a closed fd", s.read, 1, 0, []uint16{7, 0x0500}},
+	} {
+		if r := testCall(test.f, test.ax, test.y, test.args...); r != 0xFFFF {
+			t.Errorf("%s returned %d", test.name, r)
+		}
+	}
+
+	s.closeAll()
+}
+
+// The arguments copied below the C stack
+func TestArgs(t *testing.T) {
+
+	s := testSim("prog", "-v")
+
+	if argc := testCall(s.argv, 0x0010, 0); argc != 2 {
+		t.Errorf("argc %d", argc)
+	}
+
+	argv := s.word(0x0010)
+	if argv != 0x0400-6 {
+		t.Fatalf("argv at $%04X", argv)
+	}
+
+	var args []string
+	for p := s.word(argv); p != 0; argv, p = argv+2, s.word(argv+2) {
+		end := bytes.IndexByte(CPU_6502.Memory[p:], 0)
+		args = append(args, string(CPU_6502.Memory[p:int(p)+end]))
+	}
+	if strings.Join(args, " ") != "prog -v" {
+		t.Errorf("args %q", args)
+	}
+	if sp := s.word(0x02); sp != 0x0400-6-5-3 {
+		t.Errorf("C stack $%04X below the strings", sp)
+	}
+}
+
+func TestReadProgram(t *testing.T) {
+
+	p, err := ReadProgram(bytes.NewReader(testProgram(0xEA)))
+	if err != nil || p.SP != 0x02 || p.Load != 0x0200 || p.Start != 0x0200 || len(p.Code) != 1 {
+		t.Errorf("program %+v, %v", p, err)
+	}
+
+	header := testProgram()
+	for _, test := range []struct {
+		change func(h []byte) []byte
+		err    string
+	}{
+		{func(h []byte) []byte { return h[:11] }, "not a sim65 program"},
+		{func(h []byte) []byte { h[5] = 1; return h }, "unsupported sim65 header version 1"},
+		{func(h []byte) []byte { h[6] = 1; return h }, "sim65c02 program, the core doesn't execute the 65C02 instructions"},
+		{func(h []byte) []byte { h[6] = 2; return h }, "unknown CPU type 2"},
+		{func(h []byte) []byte { h[9] = 0xFF; return append(h, make([]byte, 0xF5)...) }, "245 bytes at $FF00 overlap the paravirtualization addresses"},
+	} {
+		h := test.change(append([]byte(nil), header...))
+		if _, err := ReadProgram(bytes.NewReader(h)); err == nil || err.Error() != test.err {
+			t.Errorf("error %v, want %q", err, test.err)
+		}
+	}
+
+	if _, err := LoadProgram(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Errorf("missing file loaded")
+	}
+}
@@ -0,0 +1,271 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Format of a label file
+type Format byte
+
+const (
+	Auto        Format = iota // Detected from the first lines
+	VICE                      // al C:c0a2 .label
+	CA65                      // ca65 / ld65 debug information (.dbg)
+	Assignments               // label = $c0a2
+)
+
+func (f Format) String() string {
+	switch f {
+	case Auto:
+		return "auto"
+	case VICE:
+		return "VICE"
+	case CA65:
+		return "ca65 debug"
+	case Assignments:
+		return "assignments"
+	}
+	return "Unknown"
+}
+
+// Load reads a label file, detecting its format
+func Load(filename string) (*Table, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := Parse(f, Auto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return t, nil
+}
+
+// Parse reads the labels from r
+func Parse(r io.Reader, format Format) (*Table, error) {
+
+	t := New()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // ca65 debug lines can be long
+
+	for num := 1; scanner.Scan(); num++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if format == Auto {
+			format = detect(line)
+		}
+
+		var err error
+
+		switch format {
+		case VICE:
+			err = parseVICE(t, line)
+		case CA65:
+			err = parseCA65(t, line)
+		case Assignments:
+			err = parseAssignment(t, line)
+		default:
+			return nil, fmt.Errorf("unknown label format %d", format)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Format of a file from its first non empty line
+func detect(line string) Format {
+
+	fields := strings.Fields(line)
+
+	switch {
+	case len(fields) > 0 && fields[0] == "al":
+		return VICE
+	case len(fields) > 1 && fields[0] == "version" && strings.HasPrefix(fields[1], "major="):
+		return CA65
+	}
+
+	return Assignments
+}
+
+// ---------------------------------- VICE ---------------------------------- //
+
+// al C:c0a2 .init_screen (other monitor commands are ignored)
+func parseVICE(t *Table, line string) error {
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "al" {
+		return nil
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid label %q", line)
+	}
+
+	// Optional memory space (C:, 8:, ...)
+	text := fields[1]
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		text = text[i+1:]
+	}
+
+	addr, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid address %q", fields[1])
+	}
+
+	name := strings.TrimPrefix(fields[2], ".")
+	if name == "" {
+		return fmt.Errorf("empty label name")
+	}
+
+	t.Add(name, uint16(addr))
+
+	return nil
+}
+
+// ---------------------------------- ca65 ---------------------------------- //
+
+// sym id=0,name="init_screen",addrsize=absolute,...,val=0xC0A2,seg=0,type=lab
+// Only the labels are loaded: equates are often constants and not addresses
+func parseCA65(t *Table, line string) error {
+
+	kind, rest, _ := strings.Cut(line, "\t")
+	if strings.TrimSpace(kind) != "sym" {
+		kind, rest, _ = strings.Cut(line, " ")
+		if kind != "sym" {
+			return nil
+		}
+	}
+
+	attrs, err := ca65Attributes(strings.TrimSpace(rest))
+	if err != nil {
+		return err
+	}
+
+	if attrs["type"] != "lab" {
+		return nil
+	}
+
+	name := attrs["name"]
+	value, ok := attrs["val"]
+	if name == "" || !ok {
+		return nil // Imports have no value
+	}
+
+	addr, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid value %q of %q", value, name)
+	}
+
+	t.Add(name, uint16(addr))
+
+	return nil
+}
+
+// Split key=value pairs separated by commas, values may be quoted
+func ca65Attributes(text string) (map[string]string, error) {
+
+	attrs := map[string]string{}
+
+	for text != "" {
+
+		key, rest, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid attribute %q", text)
+		}
+
+		var value string
+
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", text)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+
+		attrs[key] = value
+		text = strings.TrimPrefix(rest, ",")
+	}
+
+	return attrs, nil
+}
+
+// ------------------------------- Assignments ------------------------------ //
+
+// label = $c0a2 ; comment
+// Values that are not numbers (strings, undefined "?") are ignored
+func parseAssignment(t *Table, line string) error {
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+		return nil
+	}
+
+	name, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return fmt.Errorf("expected \"name = value\": %q", line)
+	}
+
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(name), ":")) // ca65 ":=", 64tass ":="
+	name = strings.TrimPrefix(name, ".")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid label name in %q", line)
+	}
+
+	addr, ok := parseNumber(strings.TrimSpace(value))
+	if !ok {
+		return nil
+	}
+
+	t.Add(name, addr)
+
+	return nil
+}
+
+// $hex, 0xhex, %binary or decimal, up to 16 bits
+func parseNumber(text string) (uint16, bool) {
+
+	base := 10
+
+	switch {
+	case strings.HasPrefix(text, "$"):
+		text, base = text[1:], 16
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		text, base = text[2:], 16
+	case strings.HasPrefix(text, "%"):
+		text, base = text[1:], 2
+	}
+
+	v, err := strconv.ParseUint(text, base, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(v), true
+}
@@ -0,0 +1,181 @@
+// Package symbols loads label files produced by 6502 assemblers and maps
+// addresses to names for the tracer, the disassembler and the debugger.
+//
+// Supported formats (detected from the content):
+//
+//	VICE         al C:c0a2 .init_screen      (VICE, ld65 -Ln, ACME --vicelabels, 64tass --vice-labels)
+//	ca65 debug   sym id=0,name="init_screen",...,val=0xC0A2,...,type=lab   (ld65 --dbgfile)
+//	assignments  init_screen = $c0a2         (ACME -l, 64tass --labels, plain lists)
+//
+// A Table plugs into the other packages through its methods:
+//
+//	CPU_6502.Symbols = table.Lookup             // core debug messages
+//	disassembler.Options{Symbols: table.Lookup} // JSR init_screen
+//	dbg.Symbols = table.Resolve                 // [screen+1]==$20 in conditions
+package symbols
+
+import (
+	"sort"
+	"strings"
+)
+
+// Table maps names to addresses and back
+type Table struct {
+	addresses map[string]uint16
+	names     map[uint16]string // Preferred name of each address
+
+	by_address []string // Names sorted by address (built on demand)
+}
+
+// New creates an empty table
+func New() *Table {
+	return &Table{addresses: map[string]uint16{}, names: map[uint16]string{}}
+}
+
+// FromMap creates a table from a name / address map (e.g. assembler.Result.Symbols)
+func FromMap(symbols map[string]uint16) *Table {
+
+	t := New()
+
+	// Sorted, so the preferred name of an address doesn't depend on the map order
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Add(name, symbols[name])
+	}
+
+	return t
+}
+
+// Add defines a symbol, redefining a name moves it to the new address
+// When an address has several names the first global one is shown
+func (t *Table) Add(name string, addr uint16) {
+
+	if old, ok := t.addresses[name]; ok {
+		if old == addr {
+			return
+		}
+		t.remove(name, old)
+	}
+
+	t.addresses[name] = addr
+	t.by_address = nil
+
+	if current, ok := t.names[addr]; !ok || local(current) && !local(name) {
+		t.names[addr] = name
+	}
+}
+
+func (t *Table) remove(name string, addr uint16) {
+
+	delete(t.addresses, name)
+
+	if t.names[addr] != name {
+		return
+	}
+
+	// Next name of the address, if any
+	delete(t.names, addr)
+	for other, a := range t.addresses {
+		if a == addr {
+			if current, ok := t.names[addr]; !ok || local(current) && !local(other) || local(current) == local(other) && other < current {
+				t.names[addr] = other
+			}
+		}
+	}
+}
+
+// Cheap local labels (@loop, .loop) are only shown when there is no other name
+func local(name string) bool {
+	return strings.HasPrefix(name, "@") || strings.HasPrefix(name, ".")
+}
+
+// Merge adds the symbols of another table
+func (t *Table) Merge(other *Table) {
+	for _, name := range other.Names() {
+		t.Add(name, other.addresses[name])
+	}
+}
+
+// Len returns the number of symbols
+func (t *Table) Len() int {
+	return len(t.addresses)
+}
+
+// Lookup returns the name of the address (same signature as disassembler.Options.Symbols)
+func (t *Table) Lookup(addr uint16) (string, bool) {
+	name, ok := t.names[addr]
+	return name, ok
+}
+
+// Resolve returns the address of a name (same signature as debugger.Debugger.Symbols)
+func (t *Table) Resolve(name string) (uint16, bool) {
+	addr, ok := t.addresses[name]
+	return addr, ok
+}
+
+// Nearest returns the closest symbol at or before addr and the offset from it
+func (t *Table) Nearest(addr uint16) (string, uint16, bool) {
+
+	if name, ok := t.names[addr]; ok {
+		return name, 0, true
+	}
+
+	sorted := t.sorted()
+
+	i := sort.Search(len(sorted), func(i int) bool {
+		return t.addresses[sorted[i]] > addr
+	}) - 1
+
+	if i < 0 {
+		return "", 0, false
+	}
+
+	// Preferred name of the closest address
+	base := t.addresses[sorted[i]]
+	name := t.names[base]
+
+	return name, addr - base, true
+}
+
+// Names returns all the names sorted by address
+func (t *Table) Names() []string {
+	return append([]string(nil), t.sorted()...)
+}
+
+// Map returns a copy of the symbols (e.g. for assembler.Options.Symbols)
+func (t *Table) Map() map[string]uint16 {
+
+	m := make(map[string]uint16, len(t.addresses))
+	for name, addr := range t.addresses {
+		m[name] = addr
+	}
+
+	return m
+}
+
+func (t *Table) sorted() []string {
+
+	if t.by_address != nil {
+		return t.by_address
+	}
+
+	t.by_address = make([]string, 0, len(t.addresses))
+	for name := range t.addresses {
+		t.by_address = append(t.by_address, name)
+	}
+
+	sort.Slice(t.by_address, func(i, j int) bool {
+		a, b := t.addresses[t.by_address[i]], t.addresses[t.by_address[j]]
+		if a != b {
+			return a < b
+		}
+		return t.by_address[i] < t.by_address[j]
+	})
+
+	return t.by_address
+}
@@ -0,0 +1,122 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Each format detected from its first line
+func TestFormats(t *testing.T) {
+
+	for _, test := range []struct {
+		name string
+		text string
+		want map[string]uint16
+	}{
+		{"VICE", `
+al C:c0a2 .init_screen
+al 0400 .screen
+break c000
+`, map[string]uint16{"init_screen": 0xC0A2, "screen": 0x0400}},
+
+		{"ca65", `version	major=2,minor=0
+info	csym=0,file=1,lib=0,line=10,mod=1,scope=1,seg=2,span=5,sym=3,type=4
+sym	id=0,name="init_screen",addrsize=absolute,scope=0,def=1,ref=2,val=0xC0A2,seg=0,type=lab
+sym	id=1,name="WIDTH",addrsize=zeropage,scope=0,def=3,val=0x28,type=equ
+sym	id=2,name="chrout",addrsize=absolute,scope=0,ref=4,type=imp
+sym	id=3,name="a,b",addrsize=absolute,scope=0,def=5,val=0xC100,type=lab
+`, map[string]uint16{"init_screen": 0xC0A2, "a,b": 0xC100}},
+
+		{"assignments", `
+; ACME / 64tass labels
+init_screen = $c0a2 ; comment
+	.loop	= $C0A8
+screen := 1024
+mask = %1010
+vector = 0xFFFE
+name = "text"
+undefined = ?
+`, map[string]uint16{"init_screen": 0xC0A2, "loop": 0xC0A8, "screen": 0x0400, "mask": 0x0A, "vector": 0xFFFE}},
+	} {
+		table, err := Parse(strings.NewReader(test.text), Auto)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := table.Map(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	for _, test := range []struct {
+		text   string
+		format Format
+		err    string
+	}{
+		{"al C:c0a2", Auto, "line 1: invalid label"},
+		{"al C:zz .x", VICE, "invalid address"},
+		{"al C:c000 .", VICE, "empty label name"},
+		{"version major=2\nsym id=0,name=\"x", Auto, "line 2: unterminated string"},
+		{"version major=2\nsym id=0,name", Auto, "invalid attribute"},
+		{"version major=2\nsym name=\"x\",val=zz,type=lab", Auto, "invalid value"},
+		{"x = 1\njust text", Auto, "line 2: expected \"name = value\""},
+		{"two words = 1", Assignments, "invalid label name"},
+	} {
+		if _, err := Parse(strings.NewReader(test.text), test.format); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error %v, want %q", test.text, err, test.err)
+		}
+	}
+
+	// The file name is in the error
+	file := filepath.Join(t.TempDir(), "labels.txt")
+	if err := os.WriteFile(file, []byte("al C:c0a2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(file); err == nil || !strings.HasPrefix(err.Error(), file+": line 1") {
+		t.Errorf("Load error %v", err)
+	}
+}
+
+// Preferred names, redefinitions and the nearest symbol
+func TestTable(t *testing.T) {
+
+	table := FromMap(map[string]uint16{"@loop": 0xC010, "start": 0xC000, "main": 0xC000, "loop": 0xC010})
+
+	if name, _ := table.Lookup(0xC000); name != "main" {
+		t.Errorf("$C000 is %q, want the first name main", name)
+	}
+	if name, _ := table.Lookup(0xC010); name != "loop" {
+		t.Errorf("$C010 is %q, want the global name loop", name)
+	}
+
+	// Moving the preferred name to another address
+	table.Add("main", 0xC020)
+	if name, _ := table.Lookup(0xC000); name != "start" {
+		t.Errorf("$C000 is %q after moving main", name)
+	}
+	table.Add("loop", 0xC030)
+	if name, _ := table.Lookup(0xC010); name != "@loop" {
+		t.Errorf("$C010 is %q after moving loop", name)
+	}
+
+	if name, offset, ok := table.Nearest(0xC015); !ok || name != "@loop" || offset != 5 {
+		t.Errorf("nearest of $C015: %s+%d", name, offset)
+	}
+	if _, _, ok := table.Nearest(0xBFFF); ok {
+		t.Errorf("symbol before the first one")
+	}
+
+	other := New()
+	other.Add("end", 0xC040)
+	table.Merge(other)
+
+	if names := table.Names(); strings.Join(names, " ") != "start @loop main loop end" {
+		t.Errorf("names %v", names)
+	}
+}
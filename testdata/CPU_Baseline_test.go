@@ -0,0 +1,68 @@
+package CPU_6502
+
+// Benchmarks of the cycle interpreter of the baseline commit (a3169de), the
+// implementation the fast path is measured against. The go tool ignores the
+// testdata directory: copy this file and testdata/bench to a worktree of the
+// baseline commit to run it (see Benchmarks in the README).
+//
+// Only the API of the baseline is used, so the file also builds in the current
+// tree and gives the numbers of the current interpreter with the same harness.
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func BenchmarkBaselineArithmetic(b *testing.B) {
+	benchBaseline(b, "arithmetic")
+}
+
+func BenchmarkBaselineCopy(b *testing.B) {
+	benchBaseline(b, "copy")
+}
+
+func BenchmarkBaselineDecimal(b *testing.B) {
+	benchBaseline(b, "decimal")
+}
+
+func BenchmarkBaselineBranches(b *testing.B) {
+	benchBaseline(b, "branches")
+}
+
+// Runs b.N instructions of testdata/bench/<name>.bin, loaded at $0200
+func benchBaseline(b *testing.B, name string) {
+
+	program, err := os.ReadFile("testdata/bench/" + name + ".bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	Debug = false
+	CPU_MODE = 1
+	Initialize()
+	copy(Memory[0x0200:], program)
+	Memory[0xFFFC], Memory[0xFFFD] = 0x00, 0x02
+	Reset()
+
+	cycles, instructions := Cycle, IPS
+
+	var before, after runtime.MemStats
+	b.ReportAllocs()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+
+	start := time.Now()
+	for IPS-instructions < uint64(b.N) {
+		CPU_Interpreter()
+	}
+	elapsed := time.Since(start).Seconds()
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+
+	b.ReportMetric(float64(Cycle-cycles)/elapsed, "cycles/s")
+	b.ReportMetric(float64(IPS-instructions)/elapsed, "instructions/s")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(IPS-instructions), "allocs/instruction")
+}
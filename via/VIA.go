@@ -0,0 +1,669 @@
+// Package via emulates the MOS 6522 Versatile Interface Adapter.
+//
+// The chip has 16 registers mapped from its base address, two 8 bits ports
+// with data direction registers and the CA1 / CA2 / CB1 / CB2 handshake lines,
+// two 16 bits timers (T1 one-shot or free-running with the PB7 output, T2
+// one-shot or counting PB6 pulses), a shift register and the IFR / IER
+// interrupt logic driving the CPU IRQ input. It is clocked once per CPU cycle.
+//
+//	v, err := via.Attach(0x6000)
+//	v.OnPortB = func(value byte) { leds = value }
+//	v.SetPortA(keyboard)
+//
+// The T2 driven shift register modes use their own counter reloaded from the
+// T2 low latch (T2 keeps counting as a one-shot timer meanwhile).
+package via
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Registers
+const (
+	ORB    = 0x0 // Output / input register B
+	ORA    = 0x1 // Output / input register A (with handshake)
+	DDRB   = 0x2 // Data direction B (1 = output)
+	DDRA   = 0x3 // Data direction A
+	T1CL   = 0x4 // T1 counter low (write: latch low, read: clears the T1 flag)
+	T1CH   = 0x5 // T1 counter high (write: starts T1)
+	T1LL   = 0x6 // T1 latch low
+	T1LH   = 0x7 // T1 latch high
+	T2CL   = 0x8 // T2 counter low (write: latch low, read: clears the T2 flag)
+	T2CH   = 0x9 // T2 counter high (write: starts T2)
+	SR     = 0xA // Shift register
+	ACR    = 0xB // Auxiliary control
+	PCR    = 0xC // Peripheral control
+	IFR    = 0xD // Interrupt flags
+	IER    = 0xE // Interrupt enable
+	ORA_NH = 0xF // Register A without handshake
+)
+
+// Interrupt flags (IFR / IER bits)
+const (
+	INT_CA2 = 0x01
+	INT_CA1 = 0x02
+	INT_SR  = 0x04
+	INT_CB2 = 0x08
+	INT_CB1 = 0x10
+	INT_T2  = 0x20
+	INT_T1  = 0x40
+)
+
+// Chip state, saved in the snapshots
+type state struct {
+	ORA, ORB, DDRA, DDRB byte
+	InA, InB             byte // Levels driven on the pins by the peripherals
+	LatchA, LatchB       byte // Inputs latched on CA1 / CB1
+	ACR, PCR, IFR, IER   byte
+
+	T1, T1Latch        uint16
+	T1Armed, T1Reload  bool
+	PB7                bool
+	T2                 uint16
+	T2LatchL           byte
+	T2Armed            bool
+	SR, SRCount        byte // Bits shifted since the last SR access (8 = idle)
+	SRTimer            uint16
+	SRClock            bool
+	CA1, CA2, CB1, CB2 bool // Input levels
+	CA2Out, CB2Out     bool // Output levels
+	CA2Pulse, CB2Pulse byte // Cycles left of the pulse outputs
+}
+
+// VIA is a 6522 chip
+type VIA struct {
+	s    state
+	irq  *CPU_6502.InterruptLine
+	base uint16
+
+	// Output changes, for the peripherals (any can be nil)
+	OnPortA func(value byte) // Port A pins (outputs, inputs pulled by the peripherals)
+	OnPortB func(value byte) // Port B pins, including PB7 driven by T1
+	OnCA2   func(level bool)
+	OnCB2   func(level bool)
+	OnShift func(bit bool) // Bit shifted out on CB2
+}
+
+// New creates a chip in the reset state, with its own IRQ line
+func New() *VIA {
+
+	v := &VIA{irq: CPU_6502.NewIRQLine()}
+	v.s.InA, v.s.InB = 0xFF, 0xFF
+	v.s.CA1, v.s.CA2, v.s.CB1, v.s.CB2 = true, true, true, true
+	v.s.SRCount = 8
+	v.Reset()
+
+	return v
+}
+
+// Attach creates a chip mapped at base (16 registers) with its state in the snapshots
+func Attach(base uint16) (*VIA, error) {
+
+	v := New()
+
+	if err := CPU_6502.AttachDevice(v, base, 16); err != nil {
+		return nil, err
+	}
+
+	v.base = base
+	CPU_6502.RegisterSnapshotChunk(v.snapshotTag(), v.save, v.restore)
+
+	return v, nil
+}
+
+// Detach removes the chip from the bus and releases its IRQ line
+func (v *VIA) Detach() {
+	CPU_6502.DetachDevice(v)
+	CPU_6502.UnregisterSnapshotChunk(v.snapshotTag())
+	v.irq.Set(false)
+}
+
+// Snapshot chunk tag from the base address ("V600" for $6000)
+func (v *VIA) snapshotTag() string {
+	return fmt.Sprintf("V%03X", v.base>>4)
+}
+
+// Reset clears the registers, the timers, their latches and the shift register keep their values
+func (v *VIA) Reset() {
+	v.s.ORA, v.s.ORB, v.s.DDRA, v.s.DDRB = 0, 0, 0, 0
+	v.s.ACR, v.s.PCR, v.s.IFR, v.s.IER = 0, 0, 0, 0
+	v.s.T1Armed, v.s.T2Armed, v.s.T1Reload = false, false, false
+	v.s.CA2Out, v.s.CB2Out = true, true
+	v.s.CA2Pulse, v.s.CB2Pulse = 0, 0
+	v.updateIRQ()
+}
+
+// ---------------------------------- Pins ---------------------------------- //
+
+// PortA returns the levels of the port A pins
+func (v *VIA) PortA() byte {
+	return v.s.ORA&v.s.DDRA | v.s.InA&^v.s.DDRA
+}
+
+// PortB returns the levels of the port B pins
+func (v *VIA) PortB() byte {
+
+	value := v.s.ORB&v.s.DDRB | v.s.InB&^v.s.DDRB
+
+	if v.s.ACR&0x80 != 0 {
+		value &^= 0x80
+		if v.s.PB7 {
+			value |= 0x80
+		}
+	}
+
+	return value
+}
+
+// SetPortA sets the levels driven on the port A pins by the peripherals
+func (v *VIA) SetPortA(value byte) {
+	v.s.InA = value
+}
+
+// SetPortB sets the levels driven on the port B pins, a falling PB6 counts a T2 pulse
+func (v *VIA) SetPortB(value byte) {
+
+	falling := v.s.InB&0x40 != 0 && value&0x40 == 0
+	v.s.InB = value
+
+	if falling && v.s.ACR&0x20 != 0 {
+		v.s.T2--
+		if v.s.T2 == 0 && v.s.T2Armed {
+			v.s.IFR |= INT_T2
+			v.s.T2Armed = false
+			v.updateIRQ()
+		}
+	}
+}
+
+// CA2 returns the level of CA2 (output modes)
+func (v *VIA) CA2() bool {
+	return v.s.CA2Out
+}
+
+// CB2 returns the level of CB2 (output and shift out modes)
+func (v *VIA) CB2() bool {
+	return v.s.CB2Out
+}
+
+// SetCA1 drives the CA1 input, the active edge (PCR bit 0) sets the flag and latches port A
+func (v *VIA) SetCA1(level bool) {
+
+	if level == v.s.CA1 {
+		return
+	}
+	v.s.CA1 = level
+
+	if level != (v.s.PCR&0x01 != 0) {
+		return
+	}
+
+	v.s.IFR |= INT_CA1
+	v.s.LatchA = v.PortA()
+
+	if v.ca2Mode() == 4 { // Handshake: data taken
+		v.setCA2(true)
+	}
+
+	v.updateIRQ()
+}
+
+// SetCA2 drives the CA2 input (input modes)
+func (v *VIA) SetCA2(level bool) {
+
+	if level == v.s.CA2 {
+		return
+	}
+	v.s.CA2 = level
+
+	if mode := v.ca2Mode(); mode < 4 && level == (mode&2 != 0) {
+		v.s.IFR |= INT_CA2
+		v.updateIRQ()
+	}
+}
+
+// SetCB1 drives the CB1 input, the active edge (PCR bit 4) sets the flag and latches port B
+// A rising CB1 also clocks the shift register in the external clock modes
+func (v *VIA) SetCB1(level bool) {
+
+	if level == v.s.CB1 {
+		return
+	}
+	v.s.CB1 = level
+
+	if mode := v.srMode(); level && (mode == 3 || mode == 7) {
+		v.shift(mode)
+	}
+
+	if level != (v.s.PCR&0x10 != 0) {
+		return
+	}
+
+	v.s.IFR |= INT_CB1
+	v.s.LatchB = v.PortB()
+
+	if v.cb2Mode() == 4 {
+		v.setCB2(true)
+	}
+
+	v.updateIRQ()
+}
+
+// SetCB2 drives the CB2 input (input modes, and the data of the shift in modes)
+func (v *VIA) SetCB2(level bool) {
+
+	if level == v.s.CB2 {
+		return
+	}
+	v.s.CB2 = level
+
+	if mode := v.cb2Mode(); mode < 4 && level == (mode&2 != 0) {
+		v.s.IFR |= INT_CB2
+		v.updateIRQ()
+	}
+}
+
+func (v *VIA) ca2Mode() byte {
+	return (v.s.PCR >> 1) & 7
+}
+
+func (v *VIA) cb2Mode() byte {
+	return (v.s.PCR >> 5) & 7
+}
+
+func (v *VIA) srMode() byte {
+	return (v.s.ACR >> 2) & 7
+}
+
+func (v *VIA) setCA2(level bool) {
+	if level != v.s.CA2Out {
+		v.s.CA2Out = level
+		if v.OnCA2 != nil {
+			v.OnCA2(level)
+		}
+	}
+}
+
+func (v *VIA) setCB2(level bool) {
+	if level != v.s.CB2Out {
+		v.s.CB2Out = level
+		if v.OnCB2 != nil {
+			v.OnCB2(level)
+		}
+	}
+}
+
+func (v *VIA) portAChanged() {
+	if v.OnPortA != nil {
+		v.OnPortA(v.PortA())
+	}
+}
+
+func (v *VIA) portBChanged() {
+	if v.OnPortB != nil {
+		v.OnPortB(v.PortB())
+	}
+}
+
+// ------------------------------- Registers -------------------------------- //
+
+// Read a register (CPU data BUS)
+func (v *VIA) Read(offset uint16) byte {
+
+	var value byte
+
+	switch offset & 0xF {
+
+	case ORB:
+		value = v.PortB()
+		if v.s.ACR&0x02 != 0 { // Latched inputs
+			value = v.s.ORB&v.s.DDRB | v.s.LatchB&^v.s.DDRB
+		}
+		v.clearB()
+
+	case ORA, ORA_NH:
+		value = v.PortA()
+		if v.s.ACR&0x01 != 0 {
+			value = v.s.LatchA
+		}
+		if offset&0xF == ORA {
+			v.clearA()
+			v.handshakeA()
+		}
+
+	case DDRB:
+		value = v.s.DDRB
+	case DDRA:
+		value = v.s.DDRA
+
+	case T1CL:
+		value = byte(v.s.T1)
+		v.s.IFR &^= INT_T1
+	case T1CH:
+		value = byte(v.s.T1 >> 8)
+	case T1LL:
+		value = byte(v.s.T1Latch)
+	case T1LH:
+		value = byte(v.s.T1Latch >> 8)
+
+	case T2CL:
+		value = byte(v.s.T2)
+		v.s.IFR &^= INT_T2
+	case T2CH:
+		value = byte(v.s.T2 >> 8)
+
+	case SR:
+		value = v.s.SR
+		v.startShift()
+
+	case ACR:
+		value = v.s.ACR
+	case PCR:
+		value = v.s.PCR
+
+	case IFR:
+		value = v.s.IFR
+		if v.s.IFR&v.s.IER&0x7F != 0 {
+			value |= 0x80
+		}
+
+	case IER:
+		value = v.s.IER | 0x80
+	}
+
+	v.updateIRQ()
+
+	return value
+}
+
+// Write a register (CPU data BUS)
+func (v *VIA) Write(offset uint16, value byte) {
+
+	switch offset & 0xF {
+
+	case ORB:
+		v.s.ORB = value
+		v.clearB()
+		switch v.cb2Mode() {
+		case 4:
+			v.setCB2(false)
+		case 5:
+			v.setCB2(false)
+			v.s.CB2Pulse = 2
+		}
+		v.portBChanged()
+
+	case ORA, ORA_NH:
+		v.s.ORA = value
+		if offset&0xF == ORA {
+			v.clearA()
+			v.handshakeA()
+		}
+		v.portAChanged()
+
+	case DDRB:
+		v.s.DDRB = value
+		v.portBChanged()
+	case DDRA:
+		v.s.DDRA = value
+		v.portAChanged()
+
+	case T1CL, T1LL:
+		v.s.T1Latch = v.s.T1Latch&0xFF00 | uint16(value)
+
+	case T1CH:
+		v.s.T1Latch = v.s.T1Latch&0x00FF | uint16(value)<<8
+		v.s.T1 = v.s.T1Latch
+		v.s.T1Armed, v.s.T1Reload = true, false
+		v.s.IFR &^= INT_T1
+		if v.s.ACR&0x80 != 0 {
+			v.s.PB7 = false
+			v.portBChanged()
+		}
+
+	case T1LH:
+		v.s.T1Latch = v.s.T1Latch&0x00FF | uint16(value)<<8
+		v.s.IFR &^= INT_T1
+
+	case T2CL:
+		v.s.T2LatchL = value
+
+	case T2CH:
+		v.s.T2 = uint16(value)<<8 | uint16(v.s.T2LatchL)
+		v.s.T2Armed = true
+		v.s.IFR &^= INT_T2
+
+	case SR:
+		v.s.SR = value
+		v.startShift()
+
+	case ACR:
+		pb7 := v.s.ACR & 0x80
+		v.s.ACR = value
+		if value&0x80 != pb7 {
+			if value&0x80 != 0 {
+				v.s.PB7 = true // High until T1 is started
+			}
+			v.portBChanged()
+		}
+
+	case PCR:
+		v.s.PCR = value
+		switch v.ca2Mode() {
+		case 4, 5, 7:
+			v.setCA2(true)
+		case 6:
+			v.setCA2(false)
+		}
+		switch v.cb2Mode() {
+		case 4, 5, 7:
+			v.setCB2(true)
+		case 6:
+			v.setCB2(false)
+		}
+
+	case IFR:
+		v.s.IFR &^= value & 0x7F
+
+	case IER:
+		if value&0x80 != 0 {
+			v.s.IER |= value & 0x7F
+		} else {
+			v.s.IER &^= value & 0x7F
+		}
+	}
+
+	v.updateIRQ()
+}
+
+// Access to ORA clears CA1, and CA2 unless in an independent interrupt mode
+func (v *VIA) clearA() {
+	v.s.IFR &^= INT_CA1
+	if mode := v.ca2Mode(); mode != 1 && mode != 3 {
+		v.s.IFR &^= INT_CA2
+	}
+}
+
+func (v *VIA) clearB() {
+	v.s.IFR &^= INT_CB1
+	if mode := v.cb2Mode(); mode != 1 && mode != 3 {
+		v.s.IFR &^= INT_CB2
+	}
+}
+
+// CA2 handshake and pulse outputs on ORA accesses
+func (v *VIA) handshakeA() {
+	switch v.ca2Mode() {
+	case 4:
+		v.setCA2(false)
+	case 5:
+		v.setCA2(false)
+		v.s.CA2Pulse = 2
+	}
+}
+
+func (v *VIA) updateIRQ() {
+	v.irq.Set(v.s.IFR&v.s.IER&0x7F != 0)
+}
+
+// ------------------------------ Timers and SR ----------------------------- //
+
+// Clock runs one cycle (CPU_6502.Clocked)
+func (v *VIA) Clock() {
+
+	flags := v.s.IFR
+
+	// Timer 1
+	if v.s.T1Reload {
+		v.s.T1 = v.s.T1Latch
+		v.s.T1Reload = false
+	} else {
+		v.s.T1--
+		if v.s.T1 == 0xFFFF {
+			if v.s.ACR&0x40 != 0 { // Free-running: N+2 cycles period, PB7 square wave
+				v.s.IFR |= INT_T1
+				v.s.T1Reload = true
+				if v.s.ACR&0x80 != 0 {
+					v.s.PB7 = !v.s.PB7
+					v.portBChanged()
+				}
+			} else if v.s.T1Armed { // One-shot: a single interrupt, PB7 back high
+				v.s.IFR |= INT_T1
+				v.s.T1Armed = false
+				if v.s.ACR&0x80 != 0 {
+					v.s.PB7 = true
+					v.portBChanged()
+				}
+			}
+		}
+	}
+
+	// Timer 2 (interval mode, the pulse counting mode is clocked by PB6)
+	if v.s.ACR&0x20 == 0 {
+		v.s.T2--
+		if v.s.T2 == 0xFFFF && v.s.T2Armed {
+			v.s.IFR |= INT_T2
+			v.s.T2Armed = false
+		}
+	}
+
+	// Shift register
+	switch mode := v.srMode(); mode {
+	case 1, 4, 5: // T2 rate, N+2 cycles per half period
+		v.s.SRTimer--
+		if v.s.SRTimer == 0xFFFF {
+			v.s.SRTimer = uint16(v.s.T2LatchL) + 1
+			v.s.SRClock = !v.s.SRClock
+			if v.s.SRClock {
+				v.shift(mode)
+			}
+		}
+	case 2, 6: // Half the system clock
+		v.s.SRClock = !v.s.SRClock
+		if v.s.SRClock {
+			v.shift(mode)
+		}
+	}
+
+	// Pulse outputs
+	if v.s.CA2Pulse > 0 {
+		if v.s.CA2Pulse--; v.s.CA2Pulse == 0 {
+			v.setCA2(true)
+		}
+	}
+	if v.s.CB2Pulse > 0 {
+		if v.s.CB2Pulse--; v.s.CB2Pulse == 0 {
+			v.setCB2(true)
+		}
+	}
+
+	if v.s.IFR != flags {
+		v.updateIRQ()
+	}
+}
+
+// ClockCycles runs several cycles (CPU_6502.MultiClocked)
+func (v *VIA) ClockCycles(cycles int) {
+	for ; cycles > 0; cycles-- {
+		v.Clock()
+	}
+}
+
+// SR access: clears the flag and starts shifting 8 bits
+func (v *VIA) startShift() {
+	v.s.IFR &^= INT_SR
+	v.s.SRCount = 0
+	v.s.SRTimer = uint16(v.s.T2LatchL) + 1
+	v.s.SRClock = false
+}
+
+// Shift one bit, out modes rotate bit 7 to CB2 and bit 0, in modes take CB2
+func (v *VIA) shift(mode byte) {
+
+	if v.s.SRCount >= 8 && mode != 4 {
+		return
+	}
+
+	if mode >= 4 {
+		bit := v.s.SR&0x80 != 0
+		v.s.SR <<= 1
+		if bit {
+			v.s.SR |= 1
+		}
+		v.setCB2(bit)
+		if v.OnShift != nil {
+			v.OnShift(bit)
+		}
+	} else {
+		v.s.SR <<= 1
+		if v.s.CB2 {
+			v.s.SR |= 1
+		}
+	}
+
+	v.s.SRCount++
+
+	if v.s.SRCount == 8 {
+		if mode == 4 { // Free-running, no interrupt
+			v.s.SRCount = 0
+			return
+		}
+		v.s.IFR |= INT_SR
+		v.updateIRQ()
+	}
+}
+
+// ------------------------------- Snapshots -------------------------------- //
+
+func (v *VIA) save() []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, &v.s)
+	return buf.Bytes()
+}
+
+func (v *VIA) restore(data []byte) error {
+
+	var s state
+
+	if len(data) != binary.Size(&s) {
+		return fmt.Errorf("invalid VIA state size %d", len(data))
+	}
+	_ = binary.Read(bytes.NewReader(data), binary.LittleEndian, &s)
+
+	v.s = s
+	v.updateIRQ()
+	v.portAChanged()
+	v.portBChanged()
+
+	return nil
+}
+
+// String shows the registers, for the monitors
+func (v *VIA) String() string {
+	return fmt.Sprintf("VIA $%04X  PA %02X DDRA %02X  PB %02X DDRB %02X  T1 %04X/%04X  T2 %04X  SR %02X  ACR %02X PCR %02X IFR %02X IER %02X",
+		v.base, v.PortA(), v.s.DDRA, v.PortB(), v.s.DDRB, v.s.T1, v.s.T1Latch, v.s.T2, v.s.SR, v.s.ACR, v.s.PCR, v.s.IFR, v.s.IER)
+}
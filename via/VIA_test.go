@@ -0,0 +1,260 @@
+package via
+
+import (
+	"testing"
+
+	CPU_6502 "github.com/cassianoperin/6502_GO_Core"
+)
+
+// Chip outside the bus, its IRQ line released at the end of the test
+func testVIA(t *testing.T) *VIA {
+	v := New()
+	t.Cleanup(func() { v.irq.Set(false) })
+	return v
+}
+
+// Clocks until the flag is set, returns the number of cycles (0 if not set in limit cycles)
+func testCycles(v *VIA, flag byte, limit int) int {
+	for i := 1; i <= limit; i++ {
+		v.Clock()
+		if v.s.IFR&flag != 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// One-shot T1 interrupts once N+1 cycles after the start, the IRQ follows IER
+func TestTimer1OneShot(t *testing.T) {
+
+	v := testVIA(t)
+	v.Write(IER, 0x80|INT_T1)
+	v.Write(T1CL, 3)
+	v.Write(T1CH, 0)
+
+	if n := testCycles(v, INT_T1, 10); n != 4 {
+		t.Errorf("T1 flag after %d cycles, want 4", n)
+	}
+	if !v.irq.Active() || v.Read(IFR) != 0x80|INT_T1 {
+		t.Errorf("IRQ %v, IFR $%02X", v.irq.Active(), v.Read(IFR))
+	}
+
+	// Reading the low counter clears the flag, the counter keeps going without interrupts
+	v.Read(T1CL)
+	if v.irq.Active() || testCycles(v, INT_T1, 0x20000) != 0 {
+		t.Errorf("one-shot T1 interrupted again")
+	}
+
+	// Disabled interrupt: flag without IRQ
+	v.Write(IER, INT_T1)
+	v.Write(T1CH, 0)
+	testCycles(v, INT_T1, 10)
+	if v.irq.Active() || v.Read(IFR) != INT_T1 || v.Read(IER) != 0x80 {
+		t.Errorf("IRQ %v, IFR $%02X, IER $%02X with T1 disabled", v.irq.Active(), v.Read(IFR), v.Read(IER))
+	}
+	v.Write(IFR, INT_T1)
+	if v.Read(IFR) != 0 {
+		t.Errorf("flag not cleared by IFR write")
+	}
+}
+
+// Free-running T1 has a N+2 cycles period and toggles PB7
+func TestTimer1FreeRunning(t *testing.T) {
+
+	v := testVIA(t)
+
+	var pb7 []bool
+	v.OnPortB = func(value byte) { pb7 = append(pb7, value&0x80 != 0) }
+
+	v.Write(ACR, 0xC0)
+	v.Write(T1CL, 3)
+	v.Write(T1CH, 0)
+
+	for i, want := range []int{4, 5, 5} {
+		if n := testCycles(v, INT_T1, 10); n != want {
+			t.Errorf("period %d: %d cycles, want %d", i, n, want)
+		}
+		v.Write(IFR, INT_T1)
+	}
+
+	// High on ACR write, low on start, then toggled on each underflow
+	if len(pb7) != 5 || !pb7[0] || pb7[1] || !pb7[2] || pb7[3] || !pb7[4] {
+		t.Errorf("PB7 %v", pb7)
+	}
+	if v.PortB()&0x80 == 0 {
+		t.Errorf("port B $%02X without PB7", v.PortB())
+	}
+}
+
+// T2 counts cycles or PB6 pulses, interrupting once
+func TestTimer2(t *testing.T) {
+
+	v := testVIA(t)
+	v.Write(IER, 0x80|INT_T2)
+	v.Write(T2CL, 2)
+	v.Write(T2CH, 0)
+
+	if n := testCycles(v, INT_T2, 10); n != 3 || !v.irq.Active() {
+		t.Errorf("T2 flag after %d cycles, want 3", n)
+	}
+	v.Read(T2CL)
+	if v.irq.Active() || testCycles(v, INT_T2, 0x20000) != 0 {
+		t.Errorf("T2 interrupted again")
+	}
+
+	v.Write(ACR, 0x20)
+	v.Write(T2CL, 2)
+	v.Write(T2CH, 0)
+
+	for i := 0; i < 2; i++ {
+		if v.s.IFR&INT_T2 != 0 {
+			t.Errorf("T2 flag after %d pulses", i)
+		}
+		v.SetPortB(0xBF)
+		v.SetPortB(0xFF)
+		v.Clock()
+	}
+	if v.s.IFR&INT_T2 == 0 {
+		t.Errorf("no T2 flag after 2 pulses")
+	}
+}
+
+// CA1 active edges latch port A, CA2 handshake and pulse outputs
+func TestHandshake(t *testing.T) {
+
+	v := testVIA(t)
+	v.Write(IER, 0x80|INT_CA1|INT_CA2)
+	v.Write(ACR, 0x01) // Port A latching
+	v.Write(PCR, 0x01) // CA1 rising edge, CA2 input on falling edge
+
+	v.SetPortA(0x42)
+	v.SetCA1(false)
+	v.SetPortA(0x55)
+	if v.s.IFR != 0 {
+		t.Errorf("IFR $%02X on the inactive edge", v.s.IFR)
+	}
+
+	v.SetCA1(true)
+	v.SetPortA(0x66)
+	if !v.irq.Active() || v.Read(ORA_NH) != 0x55 || v.s.IFR&INT_CA1 == 0 {
+		t.Errorf("CA1 edge: IRQ %v, IFR $%02X, latch $%02X", v.irq.Active(), v.s.IFR, v.s.LatchA)
+	}
+
+	v.SetCA2(false)
+	v.Read(ORA)
+	if v.irq.Active() || v.s.IFR != 0 {
+		t.Errorf("IFR $%02X after the ORA read", v.s.IFR)
+	}
+
+	// Handshake: low on ORA read, high on the CA1 active edge
+	v.Write(PCR, 0x09)
+	v.Read(ORA)
+	if v.CA2() {
+		t.Errorf("CA2 high after the ORA read")
+	}
+	v.SetCA1(false)
+	v.SetCA1(true)
+	if !v.CA2() {
+		t.Errorf("CA2 low after the CA1 edge")
+	}
+
+	// Pulse: low for the cycle after the ORA write
+	var levels []bool
+	v.OnCA2 = func(level bool) { levels = append(levels, level) }
+	v.Write(PCR, 0x0A)
+	v.Write(ORA, 0x00)
+	v.Clock()
+	if v.CA2() {
+		t.Errorf("CA2 pulse too short")
+	}
+	v.Clock()
+	if len(levels) != 2 || levels[0] || !levels[1] {
+		t.Errorf("CA2 pulse %v", levels)
+	}
+}
+
+// Shift out at half the system clock, shift in under T2
+func TestShiftRegister(t *testing.T) {
+
+	v := testVIA(t)
+
+	var out []bool
+	v.OnShift = func(bit bool) { out = append(out, bit) }
+
+	v.Write(ACR, 0x18)
+	v.Write(SR, 0x81)
+
+	if n := testCycles(v, INT_SR, 100); n != 15 {
+		t.Errorf("SR flag after %d cycles, want 15", n)
+	}
+	if len(out) != 8 || !out[0] || out[1] || !out[7] || v.Read(SR) != 0x81 {
+		t.Errorf("shifted %v, SR $%02X", out, v.s.SR)
+	}
+	if testCycles(v, INT_SR, 100); len(out) != 16 {
+		t.Errorf("%d bits after the SR read, want 16", len(out))
+	}
+
+	// T2 rate: a bit every 2 * (latch + 2) cycles, the first one after half a period
+	v.Write(ACR, 0x04)
+	v.Write(T2CL, 1)
+	v.SetCB2(false)
+	v.Read(SR)
+
+	if n := testCycles(v, INT_SR, 100); n != 45 || v.s.SR != 0x00 {
+		t.Errorf("SR flag after %d cycles with SR $%02X, want 45 and $00", n, v.s.SR)
+	}
+}
+
+// The chip on the bus interrupts the CPU, its state goes in the snapshots
+func TestAttach(t *testing.T) {
+
+	CPU_6502.Debug = false
+	CPU_6502.CPU_MODE = 1
+	CPU_6502.Initialize()
+
+	copy(CPU_6502.Memory[0x0200:], []byte{
+		0xA9, 0xC0, 0x8D, 0x0E, 0x60, // LDA #$C0, STA IER
+		0xA9, 0x40, 0x8D, 0x04, 0x60, // LDA #$40, STA T1CL
+		0xA9, 0x00, 0x8D, 0x05, 0x60, // LDA #0, STA T1CH
+		0x58,             //             CLI
+		0x4C, 0x10, 0x02, //             JMP *
+	})
+	CPU_6502.Memory[0x0300] = 0xAD // LDA T1CL
+	CPU_6502.Memory[0x0301], CPU_6502.Memory[0x0302] = 0x04, 0x60
+	CPU_6502.Memory[0xFFFE], CPU_6502.Memory[0xFFFF] = 0x00, 0x03
+	CPU_6502.PC = 0x0200
+	CPU_6502.SP = 0xFF
+
+	v, err := Attach(0x6000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Detach)
+
+	for i := 0; CPU_6502.PC != 0x0300; i++ {
+		if i == 100 {
+			t.Fatalf("no interrupt, PC $%04X, %s", CPU_6502.PC, v)
+		}
+		CPU_6502.Step()
+	}
+	if !CPU_6502.IRQ() {
+		t.Errorf("IRQ input inactive in the handler")
+	}
+
+	snap := CPU_6502.Snapshot()
+
+	CPU_6502.Step()
+	if CPU_6502.IRQ() {
+		t.Errorf("IRQ input still active after the T1CL read")
+	}
+
+	if err := CPU_6502.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if !CPU_6502.IRQ() || v.Read(IFR)&INT_T1 == 0 || v.s.T1Latch != 0x0040 {
+		t.Errorf("restored %s", v)
+	}
+	if err := v.restore([]byte{1, 2}); err == nil || err.Error() != "invalid VIA state size 2" {
+		t.Errorf("size error %v", err)
+	}
+}